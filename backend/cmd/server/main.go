@@ -2,24 +2,31 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/davealexenglish/magnifimind-crm/internal/database"
 	"github.com/davealexenglish/magnifimind-crm/internal/handlers"
 	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
 	"github.com/davealexenglish/magnifimind-crm/internal/services"
 	"github.com/davealexenglish/magnifimind-crm/pkg/config"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Set Gin mode
 	gin.SetMode(cfg.Server.GinMode)
@@ -33,10 +40,45 @@ func main() {
 
 	log.Println("Database connection established")
 
+	// Apply schema additions on top of the original dump
+	if err := db.EnsureSchema(context.Background()); err != nil {
+		log.Fatalf("Failed to apply database schema: %v", err)
+	}
+
 	// Initialize repositories
 	userRepo := database.NewUserRepository(db)
+	userTokenRepo := database.NewUserTokenRepository(db)
 	personRepo := database.NewPersonRepository(db)
 	passwordRepo := database.NewPasswordRepository(db)
+	revocationRepo := database.NewRevocationRepository(db)
+	auditRepo := database.NewAuditRepository(db)
+	mfaRepo := database.NewMFARepository(db)
+	roleRepo := database.NewRoleRepository(db)
+	accessTokenRepo := database.NewAccessTokenRepository(db)
+	loginThrottleRepo := database.NewLoginThrottleRepository(db)
+	oidcRepo := database.NewOIDCRepository(db)
+	sessionRepo := database.NewSessionRepository(db)
+	orgRepo := database.NewOrgRepository(db)
+	shareRepo := database.NewShareRepository(db)
+	relationshipRepo := database.NewRelationshipRepository(db)
+	tagRepo := database.NewTagRepository(db)
+	customFieldRepo := database.NewCustomFieldRepository(db)
+	mergeRepo := database.NewMergeRepository(db)
+	contactRepo := database.NewContactRepository(db)
+
+	// Seed the privileges the API checks and the default admin/user/read-only roles
+	if err := roleRepo.SeedDefaults(context.Background()); err != nil {
+		log.Fatalf("Failed to seed default roles: %v", err)
+	}
+	if name := cfg.Auth.BootstrapAdminAccount; name != "" {
+		assigned, err := roleRepo.AssignRoleByName(context.Background(), name, models.RoleAdmin, "system")
+		if err != nil {
+			log.Fatalf("Failed to grant admin role to %s: %v", name, err)
+		}
+		if !assigned {
+			log.Printf("WARNING: BOOTSTRAP_ADMIN_ACCOUNT %s does not exist", name)
+		}
+	}
 
 	// Initialize email service
 	devMode := !cfg.HasAWSCredentials()
@@ -54,22 +96,109 @@ func main() {
 		log.Println("Email service initialized (production mode - using AWS SES)")
 	}
 
+	// Initialize access-token revocation list (cached in memory, backed by Postgres)
+	revocationService := services.NewTokenRevocationService(revocationRepo)
+	if err := revocationService.Load(context.Background()); err != nil {
+		log.Fatalf("Failed to load token revocation list: %v", err)
+	}
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	revocationService.StartSync(syncCtx, 30*time.Second)
+
+	// Effective privileges per account, cached briefly so role changes apply quickly
+	privilegeService := services.NewPrivilegeService(roleRepo, time.Duration(cfg.Auth.PrivilegeCacheSeconds)*time.Second)
+
+	// Personal access tokens are accepted by AuthMiddleware alongside JWTs
+	accessTokenService := services.NewAccessTokenService(accessTokenRepo)
+
+	// Failed login backoff (per account and client IP) and account lockout
+	loginThrottle := services.NewLoginThrottleService(loginThrottleRepo, services.LoginThrottlePolicy{
+		Window:           time.Duration(cfg.Auth.LoginFailureWindowMinutes) * time.Minute,
+		FreeAttempts:     cfg.Auth.LoginFreeAttempts,
+		IPFreeAttempts:   cfg.Auth.LoginIPFreeAttempts,
+		BackoffBase:      time.Duration(cfg.Auth.LoginBackoffBaseSeconds) * time.Second,
+		BackoffMax:       time.Duration(cfg.Auth.LoginBackoffMaxSeconds) * time.Second,
+		LockoutThreshold: cfg.Auth.LoginLockoutThreshold,
+		LockoutDuration:  time.Duration(cfg.Auth.LoginLockoutMinutes) * time.Minute,
+	})
+
+	// OpenID Connect single sign-on (the provider is contacted on first use)
+	oidcService := services.NewOIDCService(services.OIDCClientConfig{
+		IssuerURL:    cfg.OIDC.IssuerURL,
+		ClientID:     cfg.OIDC.ClientID,
+		ClientSecret: cfg.OIDC.ClientSecret,
+		RedirectURL:  cfg.OIDC.RedirectURL,
+		Scopes:       strings.Fields(cfg.OIDC.Scopes),
+	}, nil)
+	if cfg.OIDC.Enabled {
+		log.Printf("Single sign-on enabled with issuer %s", cfg.OIDC.IssuerURL)
+	}
+
+	// Access token signing keys
+	jwtKeys, err := loadJWTKeySet(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	tokenIssuer := services.NewTokenIssuer(jwtKeys, time.Duration(cfg.JWT.AccessTokenMinutes)*time.Minute)
+
+	// Scores likely duplicate people for merging
+	duplicateService := services.NewDuplicateService(mergeRepo)
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(userRepo, emailService, cfg)
-	userHandler := handlers.NewUserHandler(userRepo)
-	personHandler := handlers.NewPersonHandler(personRepo)
+	authHandler := handlers.NewAuthHandler(userRepo, userTokenRepo, auditRepo, mfaRepo, sessionRepo, emailService, revocationService, loginThrottle, tokenIssuer, cfg)
+	oidcHandler := handlers.NewOIDCHandler(authHandler, oidcService, oidcRepo, userRepo, auditRepo, cfg)
+	impersonationHandler := handlers.NewImpersonationHandler(userRepo, auditRepo, privilegeService, tokenIssuer, cfg)
+	sessionHandler := handlers.NewSessionHandler(sessionRepo, userRepo, auditRepo, revocationService, cfg)
+	mfaHandler := handlers.NewMFAHandler(userRepo, mfaRepo, auditRepo, cfg)
+	roleHandler := handlers.NewRoleHandler(roleRepo, userRepo, auditRepo, privilegeService)
+	accessTokenHandler := handlers.NewAccessTokenHandler(accessTokenRepo, auditRepo)
+	orgHandler := handlers.NewOrgHandler(orgRepo, userRepo, auditRepo)
+	shareHandler := handlers.NewShareHandler(shareRepo, userRepo, auditRepo)
+	relationshipHandler := handlers.NewRelationshipHandler(relationshipRepo, personRepo)
+	tagHandler := handlers.NewTagHandler(tagRepo)
+	customFieldHandler := handlers.NewCustomFieldHandler(customFieldRepo)
+	mergeHandler := handlers.NewMergeHandler(mergeRepo, customFieldRepo, duplicateService)
+	vcardHandler := handlers.NewVCardHandler(db, personRepo, contactRepo, customFieldRepo)
+	userHandler := handlers.NewUserHandler(userRepo, roleRepo, auditRepo, privilegeService, loginThrottle)
+	personHandler := handlers.NewPersonHandler(personRepo, customFieldRepo)
 	passwordHandler := handlers.NewPasswordHandler(passwordRepo)
 	tableHandler := handlers.NewTableHandler(db)
 	adminHandler := handlers.NewAdminHandler(cfg)
 
 	// Initialize router
 	router := gin.Default()
+	authMiddleware := middleware.AuthMiddleware(tokenIssuer, revocationService, privilegeService, accessTokenService, auditRepo)
+
+	// Privilege checks (must follow authMiddleware)
+	contactsRead := middleware.RequirePrivilege(models.PrivContactsRead)
+	contactsWrite := middleware.RequirePrivilege(models.PrivContactsWrite)
+	vaultAccess := middleware.RequirePrivilege(models.PrivVaultAccess)
+	usersManage := middleware.RequirePrivilege(models.PrivUsersManage)
+	rolesManage := middleware.RequirePrivilege(models.PrivRolesManage)
+	impersonate := middleware.RequirePrivilege(models.PrivImpersonate)
+
+	// Scope checks for personal access tokens (no effect on login sessions)
+	peopleScope := middleware.RequireScope(models.ScopePeople)
+	vaultScope := middleware.RequireScope(models.ScopeVault)
+	usersScope := middleware.RequireScope(models.ScopeUsers)
+	rolesScope := middleware.RequireScope(models.ScopeRoles)
+	adminScope := middleware.RequireScope(models.ScopeAdmin)
+	sessionOnly := middleware.RequireSession()
+
+	// Endpoints an admin may not use while impersonating someone (must follow authMiddleware)
+	notImpersonating := middleware.DenyImpersonation()
+
+	// Active organization for contact routes, from the X-Organization-ID header (must follow authMiddleware)
+	orgScope := middleware.OrgContext(orgRepo)
+	// Runs the rest of a pdat_* route in a transaction under the database's row-level security
+	// policies for the current user (must follow orgScope, which may begin its own transaction)
+	rowSecurity := middleware.RowSecurity(db)
 
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Organization-ID")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
@@ -88,6 +217,9 @@ func main() {
 		})
 	})
 
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
@@ -103,42 +235,85 @@ func main() {
 		{
 			authRoutes.POST("/register", authHandler.Register)
 			authRoutes.POST("/login", authHandler.Login)
+			authRoutes.POST("/login/mfa", authHandler.LoginMFA)
+			authRoutes.POST("/login/mfa/enroll", authHandler.LoginMFAEnroll)
 			authRoutes.POST("/refresh", authHandler.Refresh)
-			authRoutes.POST("/logout", authHandler.Logout)
+			authRoutes.GET("/oidc", oidcHandler.Status)
+			authRoutes.GET("/oidc/login", oidcHandler.Login)
+			authRoutes.GET("/oidc/callback", oidcHandler.Callback)
+			authRoutes.POST("/logout", authMiddleware, sessionOnly, authHandler.Logout)
+			authRoutes.POST("/logout-all", authMiddleware, sessionOnly, notImpersonating, authHandler.LogoutAll)
+			authRoutes.GET("/verify", authHandler.VerifyEmail)
+			authRoutes.POST("/verify", authHandler.VerifyEmail)
+			authRoutes.POST("/verify/resend", authHandler.ResendVerification)
+			authRoutes.POST("/password/forgot", authHandler.ForgotPassword)
+			authRoutes.POST("/password/reset", authHandler.ResetPassword)
+			authRoutes.GET("/email/confirm", authHandler.ConfirmEmailChange)
+			authRoutes.POST("/email/confirm", authHandler.ConfirmEmailChange)
 		}
 
-		// User routes
+		// Current user's own credentials (not available to personal access tokens)
+		meRoutes := v1.Group("/me")
+		meRoutes.Use(authMiddleware, sessionOnly, notImpersonating)
+		{
+			meRoutes.PUT("/password", authHandler.ChangePassword)
+			meRoutes.PUT("/email", authHandler.ChangeEmail)
+			meRoutes.GET("/mfa", mfaHandler.GetStatus)
+			meRoutes.POST("/mfa/totp/enroll", mfaHandler.EnrollTOTP)
+			meRoutes.POST("/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+			meRoutes.DELETE("/mfa/totp", mfaHandler.DisableTOTP)
+			meRoutes.POST("/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+			meRoutes.GET("/privileges", roleHandler.MyPrivileges)
+			meRoutes.GET("/tokens", accessTokenHandler.ListTokens)
+			meRoutes.POST("/tokens", accessTokenHandler.CreateToken)
+			meRoutes.DELETE("/tokens/:id", accessTokenHandler.RevokeToken)
+			meRoutes.GET("/sessions", sessionHandler.ListMySessions)
+			meRoutes.DELETE("/sessions/:id", sessionHandler.RevokeMySession)
+		}
+
+		// User routes. Users can get and update themselves; everything else needs users.manage.
 		userRoutes := v1.Group("/users")
+		userRoutes.Use(authMiddleware, usersScope)
 		{
-			// Public routes
-			userRoutes.GET("", userHandler.ListUsers)
+			userRoutes.GET("", usersManage, userHandler.ListUsers)
+			userRoutes.GET("/search", usersManage, userHandler.SearchUsers)
 			userRoutes.GET("/:id", userHandler.GetUser)
-			userRoutes.GET("/search", userHandler.SearchUsers)
-
-			// Protected routes
-			protected := userRoutes.Group("")
-			protected.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
-			{
-				protected.PUT("/:id", userHandler.UpdateUser)
-				protected.DELETE("/:id", userHandler.DeleteUser)
-			}
+			userRoutes.PUT("/:id", userHandler.UpdateUser)
+			userRoutes.DELETE("/:id", usersManage, userHandler.DeleteUser)
+			userRoutes.GET("/:id/sessions", usersManage, sessionHandler.ListUserSessions)
+			userRoutes.DELETE("/:id/sessions/:sessionId", usersManage, sessionHandler.RevokeUserSession)
 		}
 
 		// Person routes
 		personRoutes := v1.Group("/persons")
-		personRoutes.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
+		personRoutes.Use(authMiddleware, peopleScope, orgScope, rowSecurity)
 		{
-			personRoutes.GET("", personHandler.ListPersons)
-			personRoutes.GET("/:id", personHandler.GetPerson)
-			personRoutes.POST("", personHandler.CreatePerson)
-			personRoutes.PUT("/:id", personHandler.UpdatePerson)
-			personRoutes.DELETE("/:id", personHandler.DeletePerson)
-			personRoutes.GET("/search", personHandler.SearchPersons)
+			personRoutes.GET("", contactsRead, personHandler.ListPersons)
+			personRoutes.GET("/:id", contactsRead, personHandler.GetPerson)
+			personRoutes.POST("", contactsWrite, personHandler.CreatePerson)
+			personRoutes.PUT("/:id", contactsWrite, personHandler.UpdatePerson)
+			personRoutes.DELETE("/:id", contactsWrite, personHandler.DeletePerson)
+			personRoutes.GET("/search", contactsRead, personHandler.SearchPersons)
+		}
+
+		// Organizations and their members
+		orgRoutes := v1.Group("/orgs")
+		orgRoutes.Use(authMiddleware, peopleScope)
+		{
+			orgRoutes.GET("", contactsRead, orgHandler.ListOrgs)
+			orgRoutes.POST("", contactsWrite, orgHandler.CreateOrg)
+			orgRoutes.GET("/:id", contactsRead, orgHandler.GetOrg)
+			orgRoutes.PUT("/:id", contactsWrite, orgHandler.UpdateOrg)
+			orgRoutes.DELETE("/:id", contactsWrite, orgHandler.DeleteOrg)
+			orgRoutes.GET("/:id/members", contactsRead, orgHandler.ListMembers)
+			orgRoutes.POST("/:id/members", contactsWrite, orgHandler.AddMember)
+			orgRoutes.PUT("/:id/members/:userId", contactsWrite, orgHandler.UpdateMember)
+			orgRoutes.DELETE("/:id/members/:userId", contactsWrite, orgHandler.RemoveMember)
 		}
 
 		// Password Vault routes (client-side encryption only!)
 		passwordRoutes := v1.Group("/passwords")
-		passwordRoutes.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
+		passwordRoutes.Use(authMiddleware, notImpersonating, vaultAccess, vaultScope, rowSecurity)
 		{
 			passwordRoutes.GET("", passwordHandler.ListPasswords)
 			passwordRoutes.GET("/:id", passwordHandler.GetPassword)
@@ -150,77 +325,161 @@ func main() {
 
 		// Generic table routes (protected)
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
+		protected.Use(authMiddleware)
 		{
 			// People (using generic handler)
-			protected.GET("/people", tableHandler.ListRecords("people"))
-			protected.GET("/people/:id", tableHandler.GetRecord("people"))
-			protected.GET("/people/:id/full", tableHandler.GetPersonFull)
-			protected.DELETE("/people/:id", tableHandler.DeleteRecord("people"))
+			protected.GET("/people", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("people"))
+			protected.GET("/people/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("people"))
+			protected.GET("/people/:id/full", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetPersonFull)
+			protected.DELETE("/people/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("people"))
+			protected.PUT("/people/:id/privacy", peopleScope, contactsWrite, orgScope, rowSecurity, personHandler.SetPersonPrivacy)
+			// Sharing individual people with other users
+			protected.GET("/people/:id/shares", peopleScope, contactsRead, orgScope, rowSecurity, shareHandler.ListShares)
+			protected.POST("/people/:id/shares", peopleScope, contactsWrite, orgScope, rowSecurity, shareHandler.SharePerson)
+			protected.DELETE("/people/:id/shares/:userId", peopleScope, contactsWrite, orgScope, rowSecurity, shareHandler.RevokeShare)
+			protected.GET("/shared-with-me", peopleScope, contactsRead, orgScope, rowSecurity, shareHandler.ListSharedWithMe)
 			// Hard delete routes for people (permanent deletion with CASCADE)
-			protected.DELETE("/people/:id/hard", personHandler.HardDeletePerson)
-			protected.POST("/people/hard-delete-bulk", personHandler.HardDeletePersonsBulk)
+			protected.DELETE("/people/:id/hard", peopleScope, contactsWrite, orgScope, rowSecurity, personHandler.HardDeletePerson)
+			protected.POST("/people/hard-delete-bulk", peopleScope, contactsWrite, orgScope, rowSecurity, personHandler.HardDeletePersonsBulk)
+			// CSV export of the people list, with tags; takes the list's filters
+			protected.GET("/people/export.csv", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ExportPeopleCSV)
 
 			// Addresses
-			protected.GET("/addresses", tableHandler.ListRecords("addresses"))
-			protected.GET("/addresses/:id", tableHandler.GetRecord("addresses"))
-			protected.POST("/addresses", tableHandler.CreateRecord("addresses"))
-			protected.PUT("/addresses/:id", tableHandler.UpdateRecord("addresses"))
-			protected.DELETE("/addresses/:id", tableHandler.DeleteRecord("addresses"))
+			protected.GET("/addresses", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("addresses"))
+			protected.GET("/addresses/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("addresses"))
+			protected.POST("/addresses", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.CreateRecord("addresses"))
+			protected.PUT("/addresses/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.UpdateRecord("addresses"))
+			protected.DELETE("/addresses/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("addresses"))
 
 			// Emails
-			protected.GET("/emails", tableHandler.ListRecords("emails"))
-			protected.GET("/emails/:id", tableHandler.GetRecord("emails"))
-			protected.POST("/emails", tableHandler.CreateRecord("emails"))
-			protected.PUT("/emails/:id", tableHandler.UpdateRecord("emails"))
-			protected.DELETE("/emails/:id", tableHandler.DeleteRecord("emails"))
+			protected.GET("/emails", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("emails"))
+			protected.GET("/emails/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("emails"))
+			protected.POST("/emails", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.CreateRecord("emails"))
+			protected.PUT("/emails/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.UpdateRecord("emails"))
+			protected.DELETE("/emails/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("emails"))
 
 			// Phones
-			protected.GET("/phones", tableHandler.ListRecords("phones"))
-			protected.GET("/phones/:id", tableHandler.GetRecord("phones"))
-			protected.POST("/phones", tableHandler.CreateRecord("phones"))
-			protected.PUT("/phones/:id", tableHandler.UpdateRecord("phones"))
-			protected.DELETE("/phones/:id", tableHandler.DeleteRecord("phones"))
+			protected.GET("/phones", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("phones"))
+			protected.GET("/phones/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("phones"))
+			protected.POST("/phones", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.CreateRecord("phones"))
+			protected.PUT("/phones/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.UpdateRecord("phones"))
+			protected.DELETE("/phones/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("phones"))
 
 			// Notes
-			protected.GET("/notes", tableHandler.ListRecords("notes"))
-			protected.GET("/notes/:id", tableHandler.GetRecord("notes"))
-			protected.POST("/notes", tableHandler.CreateRecord("notes"))
-			protected.PUT("/notes/:id", tableHandler.UpdateRecord("notes"))
-			protected.DELETE("/notes/:id", tableHandler.DeleteRecord("notes"))
+			protected.GET("/notes", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("notes"))
+			protected.GET("/notes/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("notes"))
+			protected.POST("/notes", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.CreateRecord("notes"))
+			protected.PUT("/notes/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.UpdateRecord("notes"))
+			protected.DELETE("/notes/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("notes"))
 
 			// Links
-			protected.GET("/links", tableHandler.ListRecords("links"))
-			protected.GET("/links/:id", tableHandler.GetRecord("links"))
-			protected.POST("/links", tableHandler.CreateRecord("links"))
-			protected.PUT("/links/:id", tableHandler.UpdateRecord("links"))
-			protected.DELETE("/links/:id", tableHandler.DeleteRecord("links"))
+			protected.GET("/links", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("links"))
+			protected.GET("/links/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("links"))
+			protected.POST("/links", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.CreateRecord("links"))
+			protected.PUT("/links/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.UpdateRecord("links"))
+			protected.DELETE("/links/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("links"))
+
+			// Companies people work for, with their addresses, phones and employees
+			protected.GET("/companies", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("companies"))
+			protected.GET("/companies/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("companies"))
+			protected.GET("/companies/:id/full", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetCompanyFull)
+			protected.POST("/companies", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.CreateRecord("companies"))
+			protected.PUT("/companies/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.UpdateRecord("companies"))
+			protected.DELETE("/companies/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("companies"))
+
+			protected.GET("/company-addresses", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("company-addresses"))
+			protected.GET("/company-addresses/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("company-addresses"))
+			protected.POST("/company-addresses", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.CreateRecord("company-addresses"))
+			protected.PUT("/company-addresses/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.UpdateRecord("company-addresses"))
+			protected.DELETE("/company-addresses/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("company-addresses"))
+
+			protected.GET("/company-phones", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("company-phones"))
+			protected.GET("/company-phones/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("company-phones"))
+			protected.POST("/company-phones", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.CreateRecord("company-phones"))
+			protected.PUT("/company-phones/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.UpdateRecord("company-phones"))
+			protected.DELETE("/company-phones/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("company-phones"))
+
+			// Employment links people to companies with a title, department and dates
+			protected.GET("/employments", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.ListRecords("employments"))
+			protected.GET("/employments/:id", peopleScope, contactsRead, orgScope, rowSecurity, tableHandler.GetRecord("employments"))
+			protected.POST("/employments", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.CreateRecord("employments"))
+			protected.PUT("/employments/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.UpdateRecord("employments"))
+			protected.DELETE("/employments/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tableHandler.DeleteRecord("employments"))
+
+			// Relationships between people in the same organization
+			protected.GET("/relationships/:id", peopleScope, contactsRead, orgScope, rowSecurity, relationshipHandler.GetRelationship)
+			protected.POST("/relationships", peopleScope, contactsWrite, orgScope, rowSecurity, relationshipHandler.CreateRelationship)
+			protected.PUT("/relationships/:id", peopleScope, contactsWrite, orgScope, rowSecurity, relationshipHandler.UpdateRelationship)
+			protected.DELETE("/relationships/:id", peopleScope, contactsWrite, orgScope, rowSecurity, relationshipHandler.DeleteRelationship)
+			protected.GET("/people/:id/network", peopleScope, contactsRead, orgScope, rowSecurity, relationshipHandler.GetNetwork)
+
+			// Tags in the active organization and bulk tagging of people
+			protected.GET("/tags", peopleScope, contactsRead, orgScope, rowSecurity, tagHandler.ListTags)
+			protected.GET("/tags/:id", peopleScope, contactsRead, orgScope, rowSecurity, tagHandler.GetTag)
+			protected.POST("/tags", peopleScope, contactsWrite, orgScope, rowSecurity, tagHandler.CreateTag)
+			protected.PUT("/tags/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tagHandler.UpdateTag)
+			protected.DELETE("/tags/:id", peopleScope, contactsWrite, orgScope, rowSecurity, tagHandler.DeleteTag)
+			protected.POST("/people/tags/assign", peopleScope, contactsWrite, orgScope, rowSecurity, tagHandler.AssignTags)
+			protected.POST("/people/tags/unassign", peopleScope, contactsWrite, orgScope, rowSecurity, tagHandler.UnassignTags)
+
+			// Custom fields the active organization defines for its people
+			protected.GET("/custom-fields", peopleScope, contactsRead, orgScope, rowSecurity, customFieldHandler.ListCustomFields)
+			protected.GET("/custom-fields/:id", peopleScope, contactsRead, orgScope, rowSecurity, customFieldHandler.GetCustomField)
+			protected.POST("/custom-fields", peopleScope, contactsWrite, orgScope, rowSecurity, customFieldHandler.CreateCustomField)
+			protected.PUT("/custom-fields/:id", peopleScope, contactsWrite, orgScope, rowSecurity, customFieldHandler.UpdateCustomField)
+			protected.DELETE("/custom-fields/:id", peopleScope, contactsWrite, orgScope, rowSecurity, customFieldHandler.DeleteCustomField)
+
+			// Finding likely duplicate people, merging them and undoing merges
+			protected.GET("/people/duplicates", peopleScope, contactsRead, orgScope, rowSecurity, mergeHandler.FindDuplicates)
+			protected.POST("/people/merge", peopleScope, contactsWrite, orgScope, rowSecurity, mergeHandler.MergePeople)
+			protected.POST("/people/merges/:id/undo", peopleScope, contactsWrite, orgScope, rowSecurity, mergeHandler.UndoMerge)
+			protected.GET("/people/:id/merges", peopleScope, contactsRead, orgScope, rowSecurity, mergeHandler.ListPersonMerges)
+
+			// vCard 3.0/4.0 export and import of people with their contact details
+			protected.GET("/people/:id/vcard", peopleScope, contactsRead, orgScope, rowSecurity, vcardHandler.ExportPersonVCard)
+			protected.GET("/people/export.vcf", peopleScope, contactsRead, orgScope, rowSecurity, vcardHandler.ExportPeopleVCF)
+			protected.POST("/people/import/vcard", peopleScope, contactsWrite, orgScope, rowSecurity, vcardHandler.ImportVCards)
 
 			// Accounts
-			protected.GET("/accounts", tableHandler.ListRecords("accounts"))
-			protected.GET("/accounts/:id", tableHandler.GetRecord("accounts"))
-			protected.DELETE("/accounts/:id", tableHandler.DeleteRecord("accounts"))
+			protected.GET("/accounts", usersScope, usersManage, tableHandler.ListRecords("accounts"))
+			protected.GET("/accounts/:id", usersScope, usersManage, tableHandler.GetRecord("accounts"))
+			protected.DELETE("/accounts/:id", usersScope, usersManage, tableHandler.DeleteRecord("accounts"))
+			protected.GET("/accounts/:id/lockout", usersScope, usersManage, userHandler.GetAccountLockout)
+			protected.POST("/accounts/:id/unlock", usersScope, usersManage, userHandler.UnlockAccount)
+			protected.POST("/accounts/:id/impersonate", sessionOnly, notImpersonating, impersonate, impersonationHandler.Impersonate)
+			protected.GET("/accounts/:id/roles", rolesScope, rolesManage, roleHandler.ListAccountRoles)
+			protected.PUT("/accounts/:id/roles/:roleId", rolesScope, rolesManage, roleHandler.AssignRole)
+			protected.DELETE("/accounts/:id/roles/:roleId", rolesScope, rolesManage, roleHandler.RemoveRole)
 
 			// Users (table view)
-			protected.GET("/users-table", tableHandler.ListRecords("users"))
-			protected.GET("/users-table/:id", tableHandler.GetRecord("users"))
-			protected.DELETE("/users-table/:id", tableHandler.DeleteRecord("users"))
-
-			// Roles
-			protected.GET("/roles", tableHandler.ListRecords("roles"))
-			protected.GET("/roles/:id", tableHandler.GetRecord("roles"))
-			protected.DELETE("/roles/:id", tableHandler.DeleteRecord("roles"))
+			protected.GET("/users-table", usersScope, usersManage, tableHandler.ListRecords("users"))
+			protected.GET("/users-table/:id", usersScope, usersManage, tableHandler.GetRecord("users"))
+			protected.DELETE("/users-table/:id", usersScope, usersManage, userHandler.DeleteUser)
+
+			// Roles and privileges
+			protected.GET("/roles", rolesScope, rolesManage, tableHandler.ListRecords("roles"))
+			protected.GET("/roles/:id", rolesScope, rolesManage, tableHandler.GetRecord("roles"))
+			protected.POST("/roles", rolesScope, rolesManage, roleHandler.CreateRole)
+			protected.PUT("/roles/:id", rolesScope, rolesManage, roleHandler.UpdateRole)
+			protected.DELETE("/roles/:id", rolesScope, rolesManage, roleHandler.DeleteRole)
+			protected.PUT("/roles/:id/mfa", rolesScope, rolesManage, mfaHandler.SetRoleRequirement)
+			protected.GET("/roles/:id/privileges", rolesScope, rolesManage, roleHandler.ListRolePrivileges)
+			protected.PUT("/roles/:id/privileges/:privilegeId", rolesScope, rolesManage, roleHandler.GrantPrivilege)
+			protected.DELETE("/roles/:id/privileges/:privilegeId", rolesScope, rolesManage, roleHandler.RevokePrivilege)
+			protected.GET("/privileges", rolesScope, rolesManage, roleHandler.ListPrivileges)
+			protected.POST("/privileges", rolesScope, rolesManage, roleHandler.CreatePrivilege)
+			protected.DELETE("/privileges/:id", rolesScope, rolesManage, roleHandler.DeletePrivilege)
 
 			// Lookup tables for dropdowns
-			protected.GET("/email-types", tableHandler.ListRecords("email-types"))
-			protected.GET("/email-types/:id", tableHandler.GetRecord("email-types"))
+			protected.GET("/email-types", peopleScope, contactsRead, rowSecurity, tableHandler.ListRecords("email-types"))
+			protected.GET("/email-types/:id", peopleScope, contactsRead, rowSecurity, tableHandler.GetRecord("email-types"))
 
-			protected.GET("/phone-types", tableHandler.ListRecords("phone-types"))
-			protected.GET("/phone-types/:id", tableHandler.GetRecord("phone-types"))
+			protected.GET("/phone-types", peopleScope, contactsRead, rowSecurity, tableHandler.ListRecords("phone-types"))
+			protected.GET("/phone-types/:id", peopleScope, contactsRead, rowSecurity, tableHandler.GetRecord("phone-types"))
 
 			// Admin routes for backup and restore
-			protected.GET("/admin/backup", adminHandler.Backup)
-			protected.POST("/admin/restore", adminHandler.Restore)
+			protected.GET("/admin/backup", adminScope, middleware.RequirePrivilege(models.PrivAdminBackup), adminHandler.Backup)
+			protected.POST("/admin/restore", adminScope, middleware.RequirePrivilege(models.PrivAdminRestore), adminHandler.Restore)
 		}
 	}
 
@@ -262,3 +521,38 @@ func main() {
 
 	log.Println("Server exited gracefully")
 }
+
+// loadJWTKeySet builds the access token key set from the JWT configuration. Without a
+// signing key file an RS256/EdDSA key is generated at startup (tokens then do not
+// survive a restart); Validate rejects that in release mode.
+func loadJWTKeySet(cfg *config.Config) (*utils.JWTKeySet, error) {
+	var active *utils.SigningKey
+	var err error
+	switch {
+	case cfg.JWT.Algorithm == utils.AlgHS256:
+		active = utils.NewHMACKey(cfg.JWT.Secret)
+	case cfg.JWT.SigningKeyFile != "":
+		active, err = utils.LoadSigningKey(cfg.JWT.SigningKeyFile)
+	default:
+		log.Printf("WARNING: JWT_SIGNING_KEY_FILE is not set, generating a temporary %s key", cfg.JWT.Algorithm)
+		active, err = utils.GenerateSigningKey(cfg.JWT.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if active.Method.Alg() != cfg.JWT.Algorithm {
+		return nil, fmt.Errorf("signing key is %s but JWT_ALGORITHM is %s", active.Method.Alg(), cfg.JWT.Algorithm)
+	}
+
+	retired := make([]*utils.SigningKey, 0, len(cfg.JWT.VerificationKeyFiles))
+	for _, path := range cfg.JWT.VerificationKeyFiles {
+		key, err := utils.LoadSigningKey(path)
+		if err != nil {
+			return nil, err
+		}
+		retired = append(retired, key)
+	}
+
+	log.Printf("Signing access tokens with %s key %s", active.Method.Alg(), active.ID)
+	return utils.NewJWTKeySet(cfg.JWT.Issuer, active, retired...)
+}
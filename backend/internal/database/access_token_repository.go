@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// AccessTokenRepository handles personal access tokens
+type AccessTokenRepository struct {
+	db *DB
+}
+
+// NewAccessTokenRepository creates a new AccessTokenRepository
+func NewAccessTokenRepository(db *DB) *AccessTokenRepository {
+	return &AccessTokenRepository{db: db}
+}
+
+// Create stores a new personal access token (TokenHash must already be hashed)
+func (r *AccessTokenRepository) Create(ctx context.Context, t *models.AccessToken) error {
+	query := `INSERT INTO sec_access_tokens (sec_accounts_id, name, token_prefix, token_hash, scopes, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING sec_access_tokens_id, create_date`
+
+	return r.db.QueryRowContext(ctx, query,
+		t.AccountID,
+		t.Name,
+		t.Prefix,
+		t.TokenHash,
+		pq.Array(t.Scopes),
+		t.ExpiresAt,
+	).Scan(&t.ID, &t.CreateDate)
+}
+
+// ListByAccount returns an account's personal access tokens that have not been revoked, newest first
+func (r *AccessTokenRepository) ListByAccount(ctx context.Context, accountID int) ([]models.AccessToken, error) {
+	query := `SELECT t.sec_access_tokens_id, t.sec_accounts_id, a.sec_users_id, t.name, t.token_prefix,
+	                 t.scopes, t.expires_at, t.last_used_at, t.create_date
+	          FROM sec_access_tokens t
+	          JOIN sec_accounts a ON a.sec_accounts_id = t.sec_accounts_id
+	          WHERE t.sec_accounts_id = $1 AND t.revoked_at IS NULL
+	          ORDER BY t.create_date DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.AccessToken{}
+	for rows.Next() {
+		var t models.AccessToken
+		if err := rows.Scan(
+			&t.ID,
+			&t.AccountID,
+			&t.UserID,
+			&t.Name,
+			&t.Prefix,
+			pq.Array(&t.Scopes),
+			&t.ExpiresAt,
+			&t.LastUsedAt,
+			&t.CreateDate,
+		); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// FindActiveByHash returns the unrevoked, unexpired token with the given hash along with
+// its account's user and name, or nil if there is none
+func (r *AccessTokenRepository) FindActiveByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	query := `SELECT t.sec_access_tokens_id, t.sec_accounts_id, a.sec_users_id, a.name, t.name, t.token_prefix,
+	                 t.token_hash, t.scopes, t.expires_at, t.last_used_at, t.create_date
+	          FROM sec_access_tokens t
+	          JOIN sec_accounts a ON a.sec_accounts_id = t.sec_accounts_id
+	          WHERE t.token_hash = $1 AND t.revoked_at IS NULL
+	            AND (t.expires_at IS NULL OR t.expires_at > NOW())`
+
+	t := &models.AccessToken{}
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&t.ID,
+		&t.AccountID,
+		&t.UserID,
+		&t.AccountName,
+		&t.Name,
+		&t.Prefix,
+		&t.TokenHash,
+		pq.Array(&t.Scopes),
+		&t.ExpiresAt,
+		&t.LastUsedAt,
+		&t.CreateDate,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// TouchLastUsed records that a token was used. The timestamp is only written once a minute
+// so a busy script does not turn every request into a write.
+func (r *AccessTokenRepository) TouchLastUsed(ctx context.Context, id int) error {
+	query := `UPDATE sec_access_tokens SET last_used_at = NOW()
+	          WHERE sec_access_tokens_id = $1
+	            AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Revoke revokes one of an account's tokens. Returns false if the account has no such active token.
+func (r *AccessTokenRepository) Revoke(ctx context.Context, accountID, id int) (bool, error) {
+	query := `UPDATE sec_access_tokens SET revoked_at = NOW()
+	          WHERE sec_access_tokens_id = $1 AND sec_accounts_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, accountID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
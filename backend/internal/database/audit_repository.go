@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// AuditRepository handles the security audit log
+type AuditRepository struct {
+	db *DB
+}
+
+// NewAuditRepository creates a new AuditRepository
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Record inserts an audit event
+func (r *AuditRepository) Record(ctx context.Context, e *models.AuditEvent) error {
+	query := `INSERT INTO sec_audit_log (sec_users_id, sec_accounts_id, event, detail, ip_address, user_agent)
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING sec_audit_log_id, create_date`
+
+	return r.db.QueryRowContext(ctx, query,
+		e.UserID,
+		e.AccountID,
+		e.Event,
+		e.Detail,
+		e.IPAddress,
+		e.UserAgent,
+	).Scan(&e.ID, &e.CreateDate)
+}
@@ -0,0 +1,20 @@
+package database
+
+import "fmt"
+
+// CompanyRepository handles companies, which belong to an organization
+type CompanyRepository struct {
+	db *DB
+}
+
+// NewCompanyRepository creates a new CompanyRepository
+func NewCompanyRepository(db *DB) *CompanyRepository {
+	return &CompanyRepository{db: db}
+}
+
+// CompanyCondition limits pdat_company rows, or rows of tables and views carrying a
+// pdat_company_id, to companies in a tenant scope's organization. prefix qualifies the
+// column (e.g. "c."); orgArg is the placeholder number of the scope's OrgID.
+func CompanyCondition(prefix string, orgArg int) string {
+	return fmt.Sprintf("%spdat_company_id IN (SELECT pdat_company_id FROM pdat_company WHERE sec_orgs_id = $%d)", prefix, orgArg)
+}
@@ -0,0 +1,282 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// ContactRepository reads and writes people together with their emails, phones,
+// addresses, notes and links, as exchanged in vCards
+type ContactRepository struct {
+	db *DB
+}
+
+// NewContactRepository creates a new ContactRepository
+func NewContactRepository(db *DB) *ContactRepository {
+	return &ContactRepository{db: db}
+}
+
+// eachRow runs a query and calls scan for every row
+func (r *ContactRepository) eachRow(ctx context.Context, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Load returns the contacts of people by ID, in the order given, with their active
+// details and the tags they carry in an organization. Callers check that the people are
+// visible; IDs of people that do not exist are skipped.
+func (r *ContactRepository) Load(ctx context.Context, orgID int, ids []int) ([]*models.Contact, error) {
+	byID := make(map[int]*models.Contact, len(ids))
+	args := []interface{}{pq.Array(ids)}
+
+	err := r.eachRow(ctx, `SELECT pdat_person_id, fname, lname, birthday, business_flag, modify_date
+	                       FROM pdat_person WHERE pdat_person_id = ANY($1)`, args, func(rows *sql.Rows) error {
+		c := &models.Contact{}
+		if err := rows.Scan(&c.PersonID, &c.FirstName, &c.LastName, &c.Birthday, &c.BusinessFlag, &c.ModifyDate); err != nil {
+			return err
+		}
+		byID[c.PersonID] = c
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load people: %w", err)
+	}
+
+	var id int
+	err = r.eachRow(ctx, `SELECT e.pdat_person_id, e.email_addr, COALESCE(t.name, '')
+	                      FROM pdat_pers_emails e
+	                      LEFT JOIN pdat_email_types t ON t.pdat_email_types_id = e.pdat_email_types_id
+	                      WHERE e.pdat_person_id = ANY($1) AND e.active_flag = 'Y' AND e.email_addr IS NOT NULL
+	                      ORDER BY e.pdat_pers_emails_id`, args, func(rows *sql.Rows) error {
+		var email models.ContactEmail
+		if err := rows.Scan(&id, &email.Address, &email.Type); err != nil {
+			return err
+		}
+		byID[id].Emails = append(byID[id].Emails, email)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load emails: %w", err)
+	}
+
+	err = r.eachRow(ctx, `SELECT ph.pdat_person_id, ph.phone_num, COALESCE(ph.phone_ext, ''), COALESCE(ph.country_code, ''), COALESCE(t.name, '')
+	                      FROM pdat_pers_phone ph
+	                      LEFT JOIN pdat_phone_type t ON t.pdat_phone_type_id = ph.pdat_phone_type_id
+	                      WHERE ph.pdat_person_id = ANY($1) AND ph.active_flag = 'Y' AND ph.phone_num IS NOT NULL
+	                      ORDER BY ph.pdat_pers_phone_id`, args, func(rows *sql.Rows) error {
+		var phone models.ContactPhone
+		if err := rows.Scan(&id, &phone.Number, &phone.Ext, &phone.CountryCode, &phone.Type); err != nil {
+			return err
+		}
+		byID[id].Phones = append(byID[id].Phones, phone)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load phones: %w", err)
+	}
+
+	err = r.eachRow(ctx, `SELECT a.pdat_person_id, COALESCE(a.addr1, ''), COALESCE(a.addr2, ''), COALESCE(a.city, ''),
+	                             COALESCE(s.abbrev, ''), COALESCE(a.zip, ''), COALESCE(a.zip_plus_4, ''), COALESCE(a.country, '')
+	                      FROM pdat_address a
+	                      LEFT JOIN cmn_states s ON s.cmn_states_id = a.cmn_states_id
+	                      WHERE a.pdat_person_id = ANY($1) AND a.active_flag = 'Y'
+	                      ORDER BY a.pdat_address_id`, args, func(rows *sql.Rows) error {
+		var addr models.ContactAddress
+		if err := rows.Scan(&id, &addr.Addr1, &addr.Addr2, &addr.City, &addr.State, &addr.Zip, &addr.ZipPlus4, &addr.Country); err != nil {
+			return err
+		}
+		addr.State = strings.TrimSpace(addr.State)
+		byID[id].Addresses = append(byID[id].Addresses, addr)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load addresses: %w", err)
+	}
+
+	err = r.eachRow(ctx, `SELECT pdat_person_id, note_text FROM pdat_pers_notes
+	                      WHERE pdat_person_id = ANY($1) AND active_flag = 'Y'
+	                      ORDER BY pdat_pers_notes_id`, args, func(rows *sql.Rows) error {
+		var note string
+		if err := rows.Scan(&id, &note); err != nil {
+			return err
+		}
+		byID[id].Notes = append(byID[id].Notes, note)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notes: %w", err)
+	}
+
+	err = r.eachRow(ctx, `SELECT pdat_person_id, link_url, COALESCE(link_text, '') FROM pdat_links
+	                      WHERE pdat_person_id = ANY($1) AND active_flag = 'Y'
+	                      ORDER BY pdat_links_id`, args, func(rows *sql.Rows) error {
+		var link models.ContactLink
+		if err := rows.Scan(&id, &link.URL, &link.Text); err != nil {
+			return err
+		}
+		byID[id].Links = append(byID[id].Links, link)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load links: %w", err)
+	}
+
+	err = r.eachRow(ctx, `SELECT pt.pdat_person_id, t.name FROM pdat_person_tag pt
+	                      JOIN pdat_tag t ON t.pdat_tag_id = pt.pdat_tag_id
+	                      WHERE pt.pdat_person_id = ANY($1) AND t.sec_orgs_id = $2
+	                      ORDER BY LOWER(t.name)`, append(args, orgID), func(rows *sql.Rows) error {
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, tag)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+
+	contacts := make([]*models.Contact, 0, len(ids))
+	for _, id := range ids {
+		if c := byID[id]; c != nil {
+			contacts = append(contacts, c)
+		}
+	}
+	return contacts, nil
+}
+
+// StateIDs returns the cmn_states IDs keyed by lowercase abbreviation and by lowercase name
+func (r *ContactRepository) StateIDs(ctx context.Context) (map[string]int, error) {
+	states := map[string]int{}
+	err := r.eachRow(ctx, `SELECT cmn_states_id, name, abbrev FROM cmn_states`, nil, func(rows *sql.Rows) error {
+		var id int
+		var name, abbrev string
+		if err := rows.Scan(&id, &name, &abbrev); err != nil {
+			return err
+		}
+		states[strings.ToLower(strings.TrimSpace(abbrev))] = id
+		states[strings.ToLower(strings.TrimSpace(name))] = id
+		return nil
+	})
+	return states, err
+}
+
+// Create creates an active person in the tenant scope's organization, owned by its user,
+// with the contact's details, in a single transaction. Email and phone types are matched
+// by name ignoring case, preferring the user's own, and created for the user when no
+// type has the name. Returns the new person's ID.
+func (r *ContactRepository) Create(ctx context.Context, scope models.TenantScope, contact *models.Contact, modifyUser string) (int, error) {
+	var personID int
+	err := r.db.InTx(ctx, func(ctx context.Context) error {
+		now := time.Now()
+		err := r.db.QueryRowContext(ctx, `INSERT INTO pdat_person (fname, lname, birthday, business_flag, sec_users_id, sec_orgs_id, private_flag,
+		                                      create_date, create_user, modify_date, modify_user, active_flag)
+		                                  VALUES ($1, $2, $3, $4, $5, $6, 'N', $7, $8, $7, $8, 'Y') RETURNING pdat_person_id`,
+			contact.FirstName, contact.LastName, contact.Birthday, contact.BusinessFlag, scope.UserID, scope.OrgID, now, modifyUser,
+		).Scan(&personID)
+		if err != nil {
+			return fmt.Errorf("failed to create person: %w", err)
+		}
+
+		for _, email := range contact.Emails {
+			typeID, err := r.typeID(ctx, "pdat_email_types", "pdat_email_types_id", email.Type, scope.UserID, modifyUser)
+			if err != nil {
+				return err
+			}
+			_, err = r.db.ExecContext(ctx, `INSERT INTO pdat_pers_emails (email_addr, pdat_person_id, pdat_email_types_id,
+			                                    create_date, create_user, modify_date, modify_user, active_flag)
+			                                VALUES ($1, $2, $3, $4, $5, $4, $5, 'Y')`,
+				email.Address, personID, typeID, now, modifyUser)
+			if err != nil {
+				return fmt.Errorf("failed to add email %q: %w", email.Address, err)
+			}
+		}
+
+		for _, phone := range contact.Phones {
+			typeID, err := r.typeID(ctx, "pdat_phone_type", "pdat_phone_type_id", phone.Type, scope.UserID, modifyUser)
+			if err != nil {
+				return err
+			}
+			_, err = r.db.ExecContext(ctx, `INSERT INTO pdat_pers_phone (phone_num, phone_ext, country_code, pdat_phone_type_id, pdat_person_id,
+			                                    create_date, create_user, modify_date, modify_user, active_flag)
+			                                VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7, $6, $7, 'Y')`,
+				phone.Number, phone.Ext, phone.CountryCode, typeID, personID, now, modifyUser)
+			if err != nil {
+				return fmt.Errorf("failed to add phone %q: %w", phone.Number, err)
+			}
+		}
+
+		for _, addr := range contact.Addresses {
+			_, err = r.db.ExecContext(ctx, `INSERT INTO pdat_address (addr1, addr2, city, zip, zip_plus_4, cmn_states_id, country, pdat_person_id,
+			                                    create_date, create_user, modify_date, modify_user, active_flag)
+			                                VALUES (NULLIF($1, ''), NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''), $8,
+			                                        $9, $10, $9, $10, 'Y')`,
+				addr.Addr1, addr.Addr2, addr.City, addr.Zip, addr.ZipPlus4, addr.StateID, addr.Country, personID, now, modifyUser)
+			if err != nil {
+				return fmt.Errorf("failed to add address: %w", err)
+			}
+		}
+
+		for _, note := range contact.Notes {
+			_, err = r.db.ExecContext(ctx, `INSERT INTO pdat_pers_notes (note_text, pdat_person_id, create_date, create_user, modify_date, modify_user, active_flag)
+			                                VALUES ($1, $2, $3, $4, $3, $4, 'Y')`, note, personID, now, modifyUser)
+			if err != nil {
+				return fmt.Errorf("failed to add note: %w", err)
+			}
+		}
+
+		for _, link := range contact.Links {
+			_, err = r.db.ExecContext(ctx, `INSERT INTO pdat_links (link_text, link_url, pdat_person_id, sec_users_id,
+			                                    create_date, create_user, modify_date, modify_user, active_flag)
+			                                VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $5, $6, 'Y')`,
+				link.Text, link.URL, personID, scope.UserID, now, modifyUser)
+			if err != nil {
+				return fmt.Errorf("failed to add link %q: %w", link.URL, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return personID, nil
+}
+
+// typeID returns the ID of the active email or phone type with a name, creating one for
+// the user if there is none. table and idColumn name pdat_email_types or pdat_phone_type.
+func (r *ContactRepository) typeID(ctx context.Context, table, idColumn, name string, userID int, modifyUser string) (int, error) {
+	var id int
+	query := fmt.Sprintf(`SELECT %[2]s FROM %[1]s WHERE LOWER(name) = LOWER($1) AND active_flag = 'Y'
+	                      ORDER BY (sec_users_id = $2) DESC, %[2]s LIMIT 1`, table, idColumn)
+	err := r.db.QueryRowContext(ctx, query, name, userID).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to look up %s %q: %w", table, name, err)
+	}
+
+	query = fmt.Sprintf(`INSERT INTO %s (name, sec_users_id, create_date, create_user, modify_date, modify_user, active_flag)
+	                     VALUES ($1, $2, $3, $4, $3, $4, 'Y') RETURNING %s`, table, idColumn)
+	if err := r.db.QueryRowContext(ctx, query, name, userID, time.Now(), modifyUser).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to create %s %q: %w", table, name, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// ErrDuplicateCustomField is returned when an organization already has a custom field with the same key
+var ErrDuplicateCustomField = errors.New("custom field already exists")
+
+// CustomFieldRepository handles an organization's custom field definitions and the
+// values people have for them
+type CustomFieldRepository struct {
+	db *DB
+}
+
+// NewCustomFieldRepository creates a new CustomFieldRepository
+func NewCustomFieldRepository(db *DB) *CustomFieldRepository {
+	return &CustomFieldRepository{db: db}
+}
+
+// CustomValueColumn returns the pdat_person_custom_value column holding values of a field type
+func CustomValueColumn(fieldType string) string {
+	switch fieldType {
+	case models.CustomFieldNumber:
+		return "value_number"
+	case models.CustomFieldDate:
+		return "value_date"
+	case models.CustomFieldBoolean:
+		return "value_bool"
+	default:
+		return "value_text"
+	}
+}
+
+// CustomValuesJSON selects a person's custom field values as a JSON object keyed by field
+// key. prefix must name the outer table or view carrying the person's pdat_person_id and
+// sec_orgs_id (e.g. "p.").
+func CustomValuesJSON(prefix string) string {
+	return fmt.Sprintf(`(SELECT COALESCE(jsonb_object_agg(f.field_key, COALESCE(
+			to_jsonb(v.value_text), to_jsonb(v.value_number), to_jsonb(v.value_date), to_jsonb(v.value_bool))), '{}'::jsonb)
+		FROM pdat_person_custom_value v JOIN pdat_custom_field f ON f.pdat_custom_field_id = v.pdat_custom_field_id
+		WHERE v.pdat_person_id = %[1]spdat_person_id AND f.sec_orgs_id = %[1]ssec_orgs_id)`, prefix)
+}
+
+// CustomValueExpr selects the value a person has for a custom field, e.g. to sort by.
+// prefix must name the outer table or view (e.g. "p.").
+func CustomValueExpr(prefix string, field *models.CustomField) string {
+	return fmt.Sprintf("(SELECT v.%s FROM pdat_person_custom_value v WHERE v.pdat_person_id = %spdat_person_id AND v.pdat_custom_field_id = %d)",
+		CustomValueColumn(field.Type), prefix, field.ID)
+}
+
+// CustomValueCondition matches people whose value for a custom field compares with op
+// (e.g. "=", ">=", "ILIKE") to the value at valueArg. prefix must name the outer table or
+// view (e.g. "p.").
+func CustomValueCondition(prefix string, field *models.CustomField, op string, valueArg int) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM pdat_person_custom_value v WHERE v.pdat_person_id = %spdat_person_id AND v.pdat_custom_field_id = %d AND v.%s %s $%d)",
+		prefix, field.ID, CustomValueColumn(field.Type), op, valueArg)
+}
+
+// customFieldColumns are the pdat_custom_field columns scanCustomField reads
+const customFieldColumns = `pdat_custom_field_id, sec_orgs_id, field_key, label, field_type, options, required, sort_order,
+	create_date, create_user, modify_date, modify_user`
+
+// scanCustomField scans a row selected with customFieldColumns
+func scanCustomField(row interface{ Scan(...interface{}) error }) (*models.CustomField, error) {
+	field := &models.CustomField{}
+	err := row.Scan(
+		&field.ID,
+		&field.OrgID,
+		&field.Key,
+		&field.Label,
+		&field.Type,
+		pq.Array(&field.Options),
+		&field.Required,
+		&field.SortOrder,
+		&field.CreateDate,
+		&field.CreateUser,
+		&field.ModifyDate,
+		&field.ModifyUser,
+	)
+	return field, err
+}
+
+// List returns an organization's custom fields in display order
+func (r *CustomFieldRepository) List(ctx context.Context, orgID int) ([]*models.CustomField, error) {
+	query := `SELECT ` + customFieldColumns + ` FROM pdat_custom_field
+	          WHERE sec_orgs_id = $1 ORDER BY sort_order, label`
+
+	rows, err := r.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := []*models.CustomField{}
+	for rows.Next() {
+		field, err := scanCustomField(rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, rows.Err()
+}
+
+// FindByID finds a custom field in the tenant scope's organization
+func (r *CustomFieldRepository) FindByID(ctx context.Context, scope models.TenantScope, id int) (*models.CustomField, error) {
+	query := `SELECT ` + customFieldColumns + ` FROM pdat_custom_field
+	          WHERE pdat_custom_field_id = $1 AND sec_orgs_id = $2`
+
+	field, err := scanCustomField(r.db.QueryRowContext(ctx, query, id, scope.OrgID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
+
+// Create defines a custom field in the tenant scope's organization
+func (r *CustomFieldRepository) Create(ctx context.Context, scope models.TenantScope, field *models.CustomField) error {
+	query := `INSERT INTO pdat_custom_field (sec_orgs_id, field_key, label, field_type, options, required, sort_order,
+	                                       create_date, create_user, modify_date, modify_user)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $8, $9)
+	          RETURNING pdat_custom_field_id, create_date, modify_date`
+
+	field.OrgID = scope.OrgID
+	err := r.db.QueryRowContext(ctx, query,
+		scope.OrgID,
+		field.Key,
+		field.Label,
+		field.Type,
+		pq.Array(field.Options),
+		field.Required,
+		field.SortOrder,
+		time.Now(),
+		field.CreateUser,
+	).Scan(&field.ID, &field.CreateDate, &field.ModifyDate)
+	if isUniqueViolation(err, "uk_pdat_custom_field_key") {
+		return ErrDuplicateCustomField
+	}
+	return err
+}
+
+// Update changes a custom field's label, options, required flag and position. Returns
+// false if it is not in the tenant scope's organization.
+func (r *CustomFieldRepository) Update(ctx context.Context, scope models.TenantScope, field *models.CustomField) (bool, error) {
+	query := `UPDATE pdat_custom_field
+	          SET label = $1, options = $2, required = $3, sort_order = $4, modify_date = $5, modify_user = $6
+	          WHERE pdat_custom_field_id = $7 AND sec_orgs_id = $8`
+
+	result, err := r.db.ExecContext(ctx, query,
+		field.Label,
+		pq.Array(field.Options),
+		field.Required,
+		field.SortOrder,
+		time.Now(),
+		field.ModifyUser,
+		field.ID,
+		scope.OrgID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Delete deletes a custom field and every value people have for it. Returns false if it
+// is not in the tenant scope's organization.
+func (r *CustomFieldRepository) Delete(ctx context.Context, scope models.TenantScope, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM pdat_custom_field WHERE pdat_custom_field_id = $1 AND sec_orgs_id = $2`, id, scope.OrgID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Values returns a person's custom field values keyed by field key. Dates are formatted
+// as YYYY-MM-DD.
+func (r *CustomFieldRepository) Values(ctx context.Context, personID int) (map[string]interface{}, error) {
+	var raw []byte
+	query := `SELECT ` + CustomValuesJSON("p.") + ` FROM pdat_person p WHERE p.pdat_person_id = $1`
+	err := r.db.QueryRowContext(ctx, query, personID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return map[string]interface{}{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// SaveValues stores a person's custom field values, clearing those with no value set.
+// Fields not in values are left alone.
+func (r *CustomFieldRepository) SaveValues(ctx context.Context, personID int, values []models.CustomFieldValue, modifyUser string) error {
+	upsert := `INSERT INTO pdat_person_custom_value (pdat_person_id, pdat_custom_field_id,
+	                                               value_text, value_number, value_date, value_bool, modify_date, modify_user)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	           ON CONFLICT (pdat_person_id, pdat_custom_field_id) DO UPDATE
+	           SET value_text = EXCLUDED.value_text, value_number = EXCLUDED.value_number,
+	               value_date = EXCLUDED.value_date, value_bool = EXCLUDED.value_bool,
+	               modify_date = EXCLUDED.modify_date, modify_user = EXCLUDED.modify_user`
+	clear := `DELETE FROM pdat_person_custom_value WHERE pdat_person_id = $1 AND pdat_custom_field_id = $2`
+
+	now := time.Now()
+	for _, v := range values {
+		var err error
+		if v.Text == nil && v.Number == nil && v.Date == nil && v.Bool == nil {
+			_, err = r.db.ExecContext(ctx, clear, personID, v.FieldID)
+		} else {
+			_, err = r.db.ExecContext(ctx, upsert, personID, v.FieldID, v.Text, v.Number, v.Date, v.Bool, now, modifyUser)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save custom field %d: %w", v.FieldID, err)
+		}
+	}
+	return nil
+}
@@ -2,10 +2,11 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // DB wraps a SQL database connection
@@ -41,3 +42,23 @@ func (db *DB) Close() error {
 	}
 	return nil
 }
+
+// isUniqueViolation reports whether err is a unique constraint violation on the named constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// secUserColumnLen is the width of create_user/modify_user on the sec_* tables
+const secUserColumnLen = 20
+
+// secModifyUser trims an actor name to fit the create_user/modify_user columns on the sec_* tables
+func secModifyUser(name string) string {
+	if len(name) > secUserColumnLen {
+		return name[:secUserColumnLen]
+	}
+	return name
+}
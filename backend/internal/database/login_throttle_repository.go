@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// LoginThrottleRepository handles failed login counters, backoff and account lockouts
+type LoginThrottleRepository struct {
+	db *DB
+}
+
+// NewLoginThrottleRepository creates a new LoginThrottleRepository
+func NewLoginThrottleRepository(db *DB) *LoginThrottleRepository {
+	return &LoginThrottleRepository{db: db}
+}
+
+// Find returns the failure record for an account or IP, or nil if it has none
+func (r *LoginThrottleRepository) Find(ctx context.Context, scope, key string) (*models.LoginThrottle, error) {
+	query := `SELECT scope, throttle_key, failures, last_failure_at, blocked_until, locked
+	          FROM sec_login_throttle WHERE scope = $1 AND throttle_key = $2`
+
+	t := &models.LoginThrottle{}
+	err := r.db.QueryRowContext(ctx, query, scope, key).Scan(
+		&t.Scope,
+		&t.Key,
+		&t.Failures,
+		&t.LastFailureAt,
+		&t.BlockedUntil,
+		&t.Locked,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// RecordFailure counts a failed login and returns the new failure count.
+// The count starts over if the previous failure is older than window.
+func (r *LoginThrottleRepository) RecordFailure(ctx context.Context, scope, key string, window time.Duration) (int, error) {
+	query := `INSERT INTO sec_login_throttle (scope, throttle_key, failures, last_failure_at)
+	          VALUES ($1, $2, 1, NOW())
+	          ON CONFLICT (scope, throttle_key) DO UPDATE
+	          SET failures = CASE
+	                  WHEN sec_login_throttle.last_failure_at < NOW() - make_interval(secs => $3) THEN 1
+	                  ELSE sec_login_throttle.failures + 1
+	              END,
+	              last_failure_at = NOW(),
+	              locked = sec_login_throttle.locked AND sec_login_throttle.blocked_until > NOW()
+	          RETURNING failures`
+
+	var failures int
+	err := r.db.QueryRowContext(ctx, query, scope, key, window.Seconds()).Scan(&failures)
+	return failures, err
+}
+
+// Block refuses logins for an account or IP until the given time.
+// locked marks an account lockout rather than a backoff delay.
+func (r *LoginThrottleRepository) Block(ctx context.Context, scope, key string, until time.Time, locked bool) error {
+	query := `UPDATE sec_login_throttle SET blocked_until = $3, locked = $4
+	          WHERE scope = $1 AND throttle_key = $2`
+	_, err := r.db.ExecContext(ctx, query, scope, key, until, locked)
+	return err
+}
+
+// Reset clears the failure record for an account or IP.
+// Returns false if there was nothing to clear.
+func (r *LoginThrottleRepository) Reset(ctx context.Context, scope, key string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM sec_login_throttle WHERE scope = $1 AND throttle_key = $2`, scope, key)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// Prune deletes records whose last failure is older than window and which no longer block anything
+func (r *LoginThrottleRepository) Prune(ctx context.Context, window time.Duration) error {
+	query := `DELETE FROM sec_login_throttle
+	          WHERE last_failure_at < NOW() - make_interval(secs => $1)
+	            AND (blocked_until IS NULL OR blocked_until <= NOW())`
+	_, err := r.db.ExecContext(ctx, query, window.Seconds())
+	return err
+}
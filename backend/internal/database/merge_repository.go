@@ -0,0 +1,505 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// ErrMergeUndone is returned when undoing a merge that was already undone
+var ErrMergeUndone = errors.New("merge already undone")
+
+// ErrMergeSuperseded is returned when undoing a merge that a later merge of either person builds on
+var ErrMergeSuperseded = errors.New("a later merge involves these people")
+
+// MergeRepository finds likely duplicate people and merges them. Both people in a merge
+// must be visible in the tenant scope, which also keeps them in the same organization.
+type MergeRepository struct {
+	db *DB
+}
+
+// NewMergeRepository creates a new MergeRepository
+func NewMergeRepository(db *DB) *MergeRepository {
+	return &MergeRepository{db: db}
+}
+
+// mergeChildTable is a table whose rows a merge moves from the merged person to the
+// survivor. sameAs matches a row c of the merged person to a row s the survivor already
+// has with the same value; those rows are left behind.
+type mergeChildTable struct {
+	table    string
+	idColumn string
+	sameAs   string
+}
+
+// mergeChildTables are the tables a merge moves rows of, in the order it moves them
+var mergeChildTables = []mergeChildTable{
+	{"pdat_pers_emails", "pdat_pers_emails_id", "LOWER(TRIM(s.email_addr)) = LOWER(TRIM(c.email_addr))"},
+	{"pdat_pers_phone", "pdat_pers_phone_id",
+		`regexp_replace(s.phone_num, '\D', '', 'g') = regexp_replace(c.phone_num, '\D', '', 'g') AND COALESCE(s.phone_ext, '') = COALESCE(c.phone_ext, '')`},
+	{"pdat_address", "pdat_address_id", "LOWER(TRIM(s.addr1)) = LOWER(TRIM(c.addr1)) AND COALESCE(s.zip, '') = COALESCE(c.zip, '')"},
+	{"pdat_pers_notes", "pdat_pers_notes_id", "s.note_text = c.note_text"},
+	{"pdat_links", "pdat_links_id", "s.link_url = c.link_url"},
+}
+
+// mergePerson holds the pdat_person columns a merge chooses values for
+type mergePerson struct {
+	FirstName    *string
+	LastName     *string
+	Birthday     *time.Time
+	BusinessFlag string
+}
+
+// DuplicateCandidates returns the active people visible in the tenant scope with the
+// emails, phones and addresses duplicate detection compares
+func (r *MergeRepository) DuplicateCandidates(ctx context.Context, scope models.TenantScope) ([]*models.DuplicatePerson, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT p.pdat_person_id, p.fname, p.lname FROM pdat_person p
+	          WHERE p.active_flag = 'Y' AND `+VisibleCondition("p.", 1, 2)+`
+	          ORDER BY p.pdat_person_id`, scope.OrgID, scope.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	people := []*models.DuplicatePerson{}
+	byID := map[int]*models.DuplicatePerson{}
+	for rows.Next() {
+		p := &models.DuplicatePerson{Emails: []string{}, Phones: []string{}, Addresses: []models.DuplicateAddress{}}
+		if err := rows.Scan(&p.PersonID, &p.FirstName, &p.LastName); err != nil {
+			return nil, err
+		}
+		people = append(people, p)
+		byID[p.PersonID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// details loads one kind of contact detail; scan reads a row of the person ID and
+	// columns and returns the person it belongs to
+	details := func(columns, table, where string, scan func(rows *sql.Rows) (int, error), add func(p *models.DuplicatePerson)) error {
+		query := `SELECT x.pdat_person_id, ` + columns + ` FROM ` + table + ` x
+		          JOIN pdat_person p ON p.pdat_person_id = x.pdat_person_id
+		          WHERE x.active_flag = 'Y' AND ` + where + ` AND p.active_flag = 'Y' AND ` + VisibleCondition("p.", 1, 2)
+		rows, err := r.db.QueryContext(ctx, query, scope.OrgID, scope.UserID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			id, err := scan(rows)
+			if err != nil {
+				return err
+			}
+			if p := byID[id]; p != nil {
+				add(p)
+			}
+		}
+		return rows.Err()
+	}
+
+	var id int
+	var value string
+	err = details("x.email_addr", "pdat_pers_emails", "x.email_addr IS NOT NULL",
+		func(rows *sql.Rows) (int, error) { return id, rows.Scan(&id, &value) },
+		func(p *models.DuplicatePerson) { p.Emails = append(p.Emails, value) })
+	if err != nil {
+		return nil, fmt.Errorf("failed to load emails: %w", err)
+	}
+
+	err = details("x.phone_num", "pdat_pers_phone", "x.phone_num IS NOT NULL",
+		func(rows *sql.Rows) (int, error) { return id, rows.Scan(&id, &value) },
+		func(p *models.DuplicatePerson) { p.Phones = append(p.Phones, value) })
+	if err != nil {
+		return nil, fmt.Errorf("failed to load phones: %w", err)
+	}
+
+	var addr models.DuplicateAddress
+	err = details("x.addr1, COALESCE(x.city, ''), COALESCE(x.zip, '')", "pdat_address", "x.addr1 IS NOT NULL",
+		func(rows *sql.Rows) (int, error) { return id, rows.Scan(&id, &addr.Addr1, &addr.City, &addr.Zip) },
+		func(p *models.DuplicatePerson) { p.Addresses = append(p.Addresses, addr) })
+	if err != nil {
+		return nil, fmt.Errorf("failed to load addresses: %w", err)
+	}
+
+	return people, nil
+}
+
+// Merge merges one active person into another, both visible in the tenant scope, in a
+// single transaction. personChoices maps fields in models.MergeFields, and customChoices
+// custom field IDs, to the person whose value the survivor keeps; fields without a
+// choice keep the survivor's value unless it is empty. The merged person's emails,
+// phones, addresses, notes and links move to the survivor, except ones the survivor
+// already has, and the merged person is deactivated. Returns nil if either person is
+// not active and visible.
+func (r *MergeRepository) Merge(ctx context.Context, scope models.TenantScope, survivorID, mergedID int, personChoices map[string]string, customChoices map[int]string, modifyUser string) (*models.PersonMerge, error) {
+	var merge *models.PersonMerge
+	err := r.db.InTx(ctx, func(ctx context.Context) error {
+		query := `SELECT pdat_person_id, fname, lname, birthday, business_flag FROM pdat_person
+		          WHERE pdat_person_id IN ($1, $2) AND active_flag = 'Y' AND ` + VisibleCondition("", 3, 4) + `
+		          FOR UPDATE`
+		rows, err := r.db.QueryContext(ctx, query, survivorID, mergedID, scope.OrgID, scope.UserID)
+		if err != nil {
+			return err
+		}
+		people := map[int]*mergePerson{}
+		for rows.Next() {
+			var id int
+			p := &mergePerson{}
+			if err := rows.Scan(&id, &p.FirstName, &p.LastName, &p.Birthday, &p.BusinessFlag); err != nil {
+				rows.Close()
+				return err
+			}
+			people[id] = p
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		survivor, merged := people[survivorID], people[mergedID]
+		if survivor == nil || merged == nil {
+			return nil
+		}
+
+		now := time.Now()
+		after := *survivor
+		before := map[string]interface{}{}
+		if takeMerged(personChoices["fname"], blank(survivor.FirstName)) {
+			after.FirstName = merged.FirstName
+			before["fname"] = survivor.FirstName
+		}
+		if takeMerged(personChoices["lname"], blank(survivor.LastName)) {
+			after.LastName = merged.LastName
+			before["lname"] = survivor.LastName
+		}
+		if takeMerged(personChoices["birthday"], survivor.Birthday == nil) {
+			after.Birthday = merged.Birthday
+			before["birthday"] = survivor.Birthday
+		}
+		if takeMerged(personChoices["business_flag"], false) {
+			after.BusinessFlag = merged.BusinessFlag
+			before["business_flag"] = survivor.BusinessFlag
+		}
+
+		_, err = r.db.ExecContext(ctx, `UPDATE pdat_person SET fname = $1, lname = $2, birthday = $3, business_flag = $4,
+		                                 modify_date = $5, modify_user = $6 WHERE pdat_person_id = $7`,
+			after.FirstName, after.LastName, after.Birthday, after.BusinessFlag, now, modifyUser, survivorID)
+		if err != nil {
+			return fmt.Errorf("failed to update survivor: %w", err)
+		}
+
+		fieldIDs, err := r.mergedCustomFields(ctx, survivorID, mergedID, customChoices)
+		if err != nil {
+			return err
+		}
+		customBefore := []byte("[]")
+		if len(fieldIDs) > 0 {
+			err = r.db.QueryRowContext(ctx, `SELECT COALESCE(jsonb_agg(to_jsonb(v)), '[]'::jsonb) FROM pdat_person_custom_value v
+			                                 WHERE v.pdat_person_id = $1 AND v.pdat_custom_field_id = ANY($2)`,
+				survivorID, pq.Array(fieldIDs)).Scan(&customBefore)
+			if err != nil {
+				return fmt.Errorf("failed to record custom field values: %w", err)
+			}
+			if _, err := r.db.ExecContext(ctx, `DELETE FROM pdat_person_custom_value WHERE pdat_person_id = $1 AND pdat_custom_field_id = ANY($2)`,
+				survivorID, pq.Array(fieldIDs)); err != nil {
+				return fmt.Errorf("failed to clear custom field values: %w", err)
+			}
+			_, err = r.db.ExecContext(ctx, `INSERT INTO pdat_person_custom_value (pdat_person_id, pdat_custom_field_id,
+			                                    value_text, value_number, value_date, value_bool, modify_date, modify_user)
+			                                SELECT $1::integer, pdat_custom_field_id, value_text, value_number, value_date, value_bool, $3::timestamptz, $4::varchar
+			                                FROM pdat_person_custom_value
+			                                WHERE pdat_person_id = $5 AND pdat_custom_field_id = ANY($2)`,
+				survivorID, pq.Array(fieldIDs), now, modifyUser, mergedID)
+			if err != nil {
+				return fmt.Errorf("failed to copy custom field values: %w", err)
+			}
+		}
+
+		moved := map[string][]int{}
+		for _, t := range mergeChildTables {
+			query := fmt.Sprintf(`UPDATE %[1]s c SET pdat_person_id = $1, modify_date = $3, modify_user = $4
+			                      WHERE c.pdat_person_id = $2
+			                        AND NOT EXISTS (SELECT 1 FROM %[1]s s WHERE s.pdat_person_id = $1 AND %[3]s)
+			                      RETURNING c.%[2]s`, t.table, t.idColumn, t.sameAs)
+			ids, err := r.queryIDs(ctx, query, survivorID, mergedID, now, modifyUser)
+			if err != nil {
+				return fmt.Errorf("failed to move %s: %w", t.table, err)
+			}
+			if len(ids) > 0 {
+				moved[t.table] = ids
+			}
+		}
+
+		if _, err := r.db.ExecContext(ctx, `UPDATE pdat_person SET active_flag = 'N', modify_date = $1, modify_user = $2 WHERE pdat_person_id = $3`,
+			now, modifyUser, mergedID); err != nil {
+			return fmt.Errorf("failed to deactivate merged person: %w", err)
+		}
+
+		movedJSON, err := json.Marshal(moved)
+		if err != nil {
+			return err
+		}
+		beforeJSON, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		merge = &models.PersonMerge{SurvivorID: survivorID, MergedID: mergedID, Moved: moved, CreateUser: &modifyUser}
+		return r.db.QueryRowContext(ctx, `INSERT INTO pdat_person_merge (survivor_id, merged_id, moved, person_before,
+		                                      custom_fields_before, custom_field_ids, create_date, create_user)
+		                                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		                                  RETURNING pdat_person_merge_id, create_date`,
+			survivorID, mergedID, movedJSON, beforeJSON, customBefore, pq.Array(fieldIDs), now, modifyUser,
+		).Scan(&merge.ID, &merge.CreateDate)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
+
+// mergedCustomFields returns the IDs of the custom fields whose values a merge copies
+// from the merged person to the survivor
+func (r *MergeRepository) mergedCustomFields(ctx context.Context, survivorID, mergedID int, choices map[int]string) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT pdat_person_id, pdat_custom_field_id FROM pdat_person_custom_value
+	                                     WHERE pdat_person_id IN ($1, $2)`, survivorID, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load custom field values: %w", err)
+	}
+	defer rows.Close()
+
+	has := map[int]map[int]bool{survivorID: {}, mergedID: {}}
+	for rows.Next() {
+		var personID, fieldID int
+		if err := rows.Scan(&personID, &fieldID); err != nil {
+			return nil, err
+		}
+		has[personID][fieldID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	candidates := map[int]bool{}
+	for fieldID := range has[mergedID] {
+		candidates[fieldID] = true
+	}
+	for fieldID := range choices {
+		candidates[fieldID] = true
+	}
+
+	fieldIDs := []int{}
+	for fieldID := range candidates {
+		if takeMerged(choices[fieldID], !has[survivorID][fieldID]) {
+			fieldIDs = append(fieldIDs, fieldID)
+		}
+	}
+	return fieldIDs, nil
+}
+
+// queryIDs runs a query returning a single integer column
+func (r *MergeRepository) queryIDs(ctx context.Context, query string, args ...interface{}) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Undo reverses a merge whose people are both visible in the tenant scope, in a single
+// transaction: moved rows go back to the merged person, which is reactivated, and the
+// survivor gets back the values the merge replaced. Rows deleted since the merge stay
+// deleted. Returns false if the merge does not exist or either person is not visible.
+func (r *MergeRepository) Undo(ctx context.Context, scope models.TenantScope, id int, modifyUser string) (bool, error) {
+	found := false
+	err := r.db.InTx(ctx, func(ctx context.Context) error {
+		var survivorID, mergedID int
+		var movedJSON []byte
+		var undoneDate *time.Time
+		err := r.db.QueryRowContext(ctx, `SELECT m.survivor_id, m.merged_id, m.moved, m.undone_date
+		          FROM pdat_person_merge m
+		          JOIN pdat_person s ON s.pdat_person_id = m.survivor_id
+		          JOIN pdat_person d ON d.pdat_person_id = m.merged_id
+		          WHERE m.pdat_person_merge_id = $1 AND `+VisibleCondition("s.", 2, 3)+` AND `+VisibleCondition("d.", 2, 3)+`
+		          FOR UPDATE OF m`, id, scope.OrgID, scope.UserID).Scan(&survivorID, &mergedID, &movedJSON, &undoneDate)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		if undoneDate != nil {
+			return ErrMergeUndone
+		}
+
+		var superseded bool
+		err = r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pdat_person_merge
+		          WHERE pdat_person_merge_id > $1 AND undone_date IS NULL
+		            AND (survivor_id IN ($2, $3) OR merged_id IN ($2, $3)))`, id, survivorID, mergedID).Scan(&superseded)
+		if err != nil {
+			return err
+		}
+		if superseded {
+			return ErrMergeSuperseded
+		}
+
+		moved := map[string][]int{}
+		if err := json.Unmarshal(movedJSON, &moved); err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, t := range mergeChildTables {
+			if len(moved[t.table]) == 0 {
+				continue
+			}
+			query := fmt.Sprintf(`UPDATE %s SET pdat_person_id = $1, modify_date = $2, modify_user = $3
+			                      WHERE %s = ANY($4) AND pdat_person_id = $5`, t.table, t.idColumn)
+			if _, err := r.db.ExecContext(ctx, query, mergedID, now, modifyUser, pq.Array(moved[t.table]), survivorID); err != nil {
+				return fmt.Errorf("failed to move back %s: %w", t.table, err)
+			}
+		}
+
+		_, err = r.db.ExecContext(ctx, `UPDATE pdat_person p SET
+		              fname = CASE WHEN m.person_before ? 'fname' THEN m.person_before->>'fname' ELSE p.fname END,
+		              lname = CASE WHEN m.person_before ? 'lname' THEN m.person_before->>'lname' ELSE p.lname END,
+		              birthday = CASE WHEN m.person_before ? 'birthday' THEN (m.person_before->>'birthday')::timestamptz ELSE p.birthday END,
+		              business_flag = COALESCE(m.person_before->>'business_flag', p.business_flag),
+		              modify_date = $2, modify_user = $3
+		          FROM pdat_person_merge m
+		          WHERE m.pdat_person_merge_id = $1 AND p.pdat_person_id = m.survivor_id`, id, now, modifyUser)
+		if err != nil {
+			return fmt.Errorf("failed to restore survivor: %w", err)
+		}
+
+		_, err = r.db.ExecContext(ctx, `DELETE FROM pdat_person_custom_value v USING pdat_person_merge m
+		          WHERE m.pdat_person_merge_id = $1 AND v.pdat_person_id = m.survivor_id AND v.pdat_custom_field_id = ANY(m.custom_field_ids)`, id)
+		if err != nil {
+			return fmt.Errorf("failed to clear custom field values: %w", err)
+		}
+		_, err = r.db.ExecContext(ctx, `INSERT INTO pdat_person_custom_value (pdat_person_id, pdat_custom_field_id,
+		              value_text, value_number, value_date, value_bool, modify_date, modify_user)
+		          SELECT m.survivor_id, x.pdat_custom_field_id, x.value_text, x.value_number, x.value_date, x.value_bool, $2::timestamptz, $3::varchar
+		          FROM pdat_person_merge m
+		          CROSS JOIN jsonb_to_recordset(m.custom_fields_before)
+		              AS x(pdat_custom_field_id integer, value_text text, value_number numeric, value_date date, value_bool boolean)
+		          JOIN pdat_custom_field f ON f.pdat_custom_field_id = x.pdat_custom_field_id
+		          WHERE m.pdat_person_merge_id = $1`, id, now, modifyUser)
+		if err != nil {
+			return fmt.Errorf("failed to restore custom field values: %w", err)
+		}
+
+		if _, err := r.db.ExecContext(ctx, `UPDATE pdat_person SET active_flag = 'Y', modify_date = $1, modify_user = $2 WHERE pdat_person_id = $3`,
+			now, modifyUser, mergedID); err != nil {
+			return fmt.Errorf("failed to reactivate merged person: %w", err)
+		}
+
+		_, err = r.db.ExecContext(ctx, `UPDATE pdat_person_merge SET undone_date = $1, undone_user = $2 WHERE pdat_person_merge_id = $3`,
+			now, modifyUser, id)
+		return err
+	})
+	return found, err
+}
+
+// mergeQuery selects merges whose people are both visible in the tenant scope, with the
+// scope's OrgID and UserID as $1 and $2
+func mergeQuery(where string) string {
+	return `SELECT m.pdat_person_merge_id, m.survivor_id, m.merged_id, m.moved, m.create_date, m.create_user, m.undone_date, m.undone_user
+	        FROM pdat_person_merge m
+	        JOIN pdat_person s ON s.pdat_person_id = m.survivor_id
+	        JOIN pdat_person d ON d.pdat_person_id = m.merged_id
+	        WHERE ` + VisibleCondition("s.", 1, 2) + ` AND ` + VisibleCondition("d.", 1, 2) + ` AND ` + where
+}
+
+// scanMerge scans a row selected with mergeQuery
+func scanMerge(row interface{ Scan(...interface{}) error }) (*models.PersonMerge, error) {
+	merge := &models.PersonMerge{}
+	var moved []byte
+	err := row.Scan(
+		&merge.ID,
+		&merge.SurvivorID,
+		&merge.MergedID,
+		&moved,
+		&merge.CreateDate,
+		&merge.CreateUser,
+		&merge.UndoneDate,
+		&merge.UndoneUser,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(moved, &merge.Moved); err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
+
+// FindByID finds a merge whose people are both visible in the tenant scope
+func (r *MergeRepository) FindByID(ctx context.Context, scope models.TenantScope, id int) (*models.PersonMerge, error) {
+	merge, err := scanMerge(r.db.QueryRowContext(ctx, mergeQuery("m.pdat_person_merge_id = $3"), scope.OrgID, scope.UserID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+// ListForPerson returns the merges a person took part in, as survivor or as merged
+// person, newest first
+func (r *MergeRepository) ListForPerson(ctx context.Context, scope models.TenantScope, personID int) ([]*models.PersonMerge, error) {
+	query := mergeQuery("(m.survivor_id = $3 OR m.merged_id = $3)") + ` ORDER BY m.pdat_person_merge_id DESC`
+	rows, err := r.db.QueryContext(ctx, query, scope.OrgID, scope.UserID, personID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	merges := []*models.PersonMerge{}
+	for rows.Next() {
+		merge, err := scanMerge(rows)
+		if err != nil {
+			return nil, err
+		}
+		merges = append(merges, merge)
+	}
+
+	return merges, rows.Err()
+}
+
+// takeMerged reports whether a merge gives a field the merged person's value, from the
+// choice made for it and whether the survivor's value is empty
+func takeMerged(choice string, survivorEmpty bool) bool {
+	switch choice {
+	case models.MergeKeepMerged:
+		return true
+	case models.MergeKeepSurvivor:
+		return false
+	}
+	return survivorEmpty
+}
+
+// blank reports whether an optional text value is missing or only whitespace
+func blank(s *string) bool {
+	return s == nil || strings.TrimSpace(*s) == ""
+}
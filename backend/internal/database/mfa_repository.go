@@ -0,0 +1,242 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// MFARepository handles TOTP enrollment, recovery codes and login challenges
+type MFARepository struct {
+	db *DB
+}
+
+// NewMFARepository creates a new MFARepository
+func NewMFARepository(db *DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// FindByAccountID returns an account's TOTP enrollment, or nil if it has never enrolled
+func (r *MFARepository) FindByAccountID(ctx context.Context, accountID int) (*models.AccountMFA, error) {
+	query := `SELECT sec_accounts_id, totp_secret, enabled, confirmed_at, last_used_step, create_date
+	          FROM sec_account_mfa WHERE sec_accounts_id = $1`
+
+	m := &models.AccountMFA{}
+	err := r.db.QueryRowContext(ctx, query, accountID).Scan(
+		&m.AccountID,
+		&m.Secret,
+		&m.Enabled,
+		&m.ConfirmedAt,
+		&m.LastUsedStep,
+		&m.CreateDate,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// SavePendingSecret stores a new, unconfirmed TOTP secret for an account.
+// An existing enabled enrollment is left untouched.
+func (r *MFARepository) SavePendingSecret(ctx context.Context, accountID int, secret string) error {
+	query := `INSERT INTO sec_account_mfa (sec_accounts_id, totp_secret, enabled)
+	          VALUES ($1, $2, FALSE)
+	          ON CONFLICT (sec_accounts_id) DO UPDATE
+	          SET totp_secret = EXCLUDED.totp_secret, last_used_step = NULL, create_date = NOW()
+	          WHERE sec_account_mfa.enabled = FALSE`
+	_, err := r.db.ExecContext(ctx, query, accountID, secret)
+	return err
+}
+
+// Enable marks a pending TOTP secret as confirmed
+func (r *MFARepository) Enable(ctx context.Context, accountID int) error {
+	query := `UPDATE sec_account_mfa SET enabled = TRUE, confirmed_at = NOW() WHERE sec_accounts_id = $1`
+	_, err := r.db.ExecContext(ctx, query, accountID)
+	return err
+}
+
+// Disable removes an account's TOTP enrollment and recovery codes
+func (r *MFARepository) Disable(ctx context.Context, accountID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sec_mfa_recovery_codes WHERE sec_accounts_id = $1`, accountID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sec_account_mfa WHERE sec_accounts_id = $1`, accountID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records that the code for a time step has been used.
+// Returns false if that step (or a later one) was already used, so a code cannot be replayed.
+func (r *MFARepository) UseStep(ctx context.Context, accountID int, step int64) (bool, error) {
+	query := `UPDATE sec_account_mfa SET last_used_step = $2
+	          WHERE sec_accounts_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)`
+
+	result, err := r.db.ExecContext(ctx, query, accountID, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// ReplaceRecoveryCodes discards an account's recovery codes and stores new ones (already hashed)
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, accountID int, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sec_mfa_recovery_codes WHERE sec_accounts_id = $1`, accountID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		query := `INSERT INTO sec_mfa_recovery_codes (sec_accounts_id, code_hash) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, accountID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used.
+// Returns false if the code does not exist or was already used.
+func (r *MFARepository) ConsumeRecoveryCode(ctx context.Context, accountID int, codeHash string) (bool, error) {
+	query := `UPDATE sec_mfa_recovery_codes SET used_at = NOW()
+	          WHERE sec_accounts_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, accountID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes an account has left
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, accountID int) (int, error) {
+	query := `SELECT COUNT(*) FROM sec_mfa_recovery_codes WHERE sec_accounts_id = $1 AND used_at IS NULL`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, accountID).Scan(&count)
+	return count, err
+}
+
+// CreateChallenge stores a new login challenge (TokenHash must already be hashed)
+func (r *MFARepository) CreateChallenge(ctx context.Context, ch *models.MFAChallenge) error {
+	query := `INSERT INTO sec_mfa_challenges (token_hash, sec_accounts_id, expires_at)
+	          VALUES ($1, $2, $3) RETURNING sec_mfa_challenges_id, create_date`
+
+	return r.db.QueryRowContext(ctx, query, ch.TokenHash, ch.AccountID, ch.ExpiresAt).Scan(&ch.ID, &ch.CreateDate)
+}
+
+// FindChallenge returns an unused, unexpired login challenge, or nil if there is none
+func (r *MFARepository) FindChallenge(ctx context.Context, tokenHash string) (*models.MFAChallenge, error) {
+	query := `SELECT sec_mfa_challenges_id, token_hash, sec_accounts_id, expires_at, attempts, used_at, create_date
+	          FROM sec_mfa_challenges
+	          WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()`
+
+	ch := &models.MFAChallenge{}
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&ch.ID,
+		&ch.TokenHash,
+		&ch.AccountID,
+		&ch.ExpiresAt,
+		&ch.Attempts,
+		&ch.UsedAt,
+		&ch.CreateDate,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+// RecordChallengeFailure counts a failed code against a challenge and returns the new attempt count
+func (r *MFARepository) RecordChallengeFailure(ctx context.Context, id int) (int, error) {
+	query := `UPDATE sec_mfa_challenges SET attempts = attempts + 1
+	          WHERE sec_mfa_challenges_id = $1 RETURNING attempts`
+
+	var attempts int
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&attempts)
+	return attempts, err
+}
+
+// ConsumeChallenge marks a challenge as used.
+// Returns false if it was already used, so each challenge completes at most one login.
+func (r *MFARepository) ConsumeChallenge(ctx context.Context, id int) (bool, error) {
+	query := `UPDATE sec_mfa_challenges SET used_at = NOW()
+	          WHERE sec_mfa_challenges_id = $1 AND used_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// PruneChallenges deletes challenges that have expired
+func (r *MFARepository) PruneChallenges(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sec_mfa_challenges WHERE expires_at <= NOW()`)
+	return err
+}
+
+// AccountRequiresMFA reports whether any of an account's roles requires two-factor authentication
+func (r *MFARepository) AccountRequiresMFA(ctx context.Context, accountID int) (bool, error) {
+	query := `SELECT EXISTS (
+	              SELECT 1 FROM sec_acct_roles ar
+	              JOIN sec_roles ro ON ro.sec_roles_id = ar.sec_roles_id
+	              WHERE ar.sec_accounts_id = $1 AND ro.require_mfa
+	          )`
+
+	var required bool
+	err := r.db.QueryRowContext(ctx, query, accountID).Scan(&required)
+	return required, err
+}
+
+// SetRoleRequireMFA sets whether members of a role must use two-factor authentication.
+// Returns false if the role does not exist.
+func (r *MFARepository) SetRoleRequireMFA(ctx context.Context, roleID int, required bool, modifyUser string) (bool, error) {
+	query := `UPDATE sec_roles SET require_mfa = $2, modify_date = NOW(), modify_user = $3
+	          WHERE sec_roles_id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, roleID, required, secModifyUser(modifyUser))
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
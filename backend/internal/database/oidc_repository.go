@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// OIDCRepository handles pending single sign-on logins and linked identity provider subjects
+type OIDCRepository struct {
+	db *DB
+}
+
+// NewOIDCRepository creates a new OIDCRepository
+func NewOIDCRepository(db *DB) *OIDCRepository {
+	return &OIDCRepository{db: db}
+}
+
+// CreateState stores a pending login (StateHash must already be hashed)
+func (r *OIDCRepository) CreateState(ctx context.Context, st *models.OIDCLoginState) error {
+	query := `INSERT INTO sec_oidc_states (state_hash, code_verifier, nonce, expires_at)
+	          VALUES ($1, $2, $3, $4) RETURNING sec_oidc_states_id, create_date`
+
+	return r.db.QueryRowContext(ctx, query, st.StateHash, st.CodeVerifier, st.Nonce, st.ExpiresAt).Scan(&st.ID, &st.CreateDate)
+}
+
+// ConsumeState deletes and returns an unexpired pending login, or nil if there is none.
+// Each state completes at most one login.
+func (r *OIDCRepository) ConsumeState(ctx context.Context, stateHash string) (*models.OIDCLoginState, error) {
+	query := `DELETE FROM sec_oidc_states
+	          WHERE state_hash = $1 AND expires_at > NOW()
+	          RETURNING sec_oidc_states_id, state_hash, code_verifier, nonce, expires_at, create_date`
+
+	st := &models.OIDCLoginState{}
+	err := r.db.QueryRowContext(ctx, query, stateHash).Scan(
+		&st.ID,
+		&st.StateHash,
+		&st.CodeVerifier,
+		&st.Nonce,
+		&st.ExpiresAt,
+		&st.CreateDate,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// PruneStates deletes pending logins that have expired
+func (r *OIDCRepository) PruneStates(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sec_oidc_states WHERE expires_at <= NOW()`)
+	return err
+}
+
+// FindIdentity returns the identity linked to an issuer and subject, or nil if there is none
+func (r *OIDCRepository) FindIdentity(ctx context.Context, issuer, subject string) (*models.AccountIdentity, error) {
+	query := `SELECT sec_account_identities_id, sec_accounts_id, issuer, subject, email, last_login_at, create_date
+	          FROM sec_account_identities WHERE issuer = $1 AND subject = $2`
+
+	id := &models.AccountIdentity{}
+	err := r.db.QueryRowContext(ctx, query, issuer, subject).Scan(
+		&id.ID,
+		&id.AccountID,
+		&id.Issuer,
+		&id.Subject,
+		&id.Email,
+		&id.LastLoginAt,
+		&id.CreateDate,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return id, nil
+}
+
+// LinkIdentity links an issuer and subject to an account
+func (r *OIDCRepository) LinkIdentity(ctx context.Context, id *models.AccountIdentity) error {
+	query := `INSERT INTO sec_account_identities (sec_accounts_id, issuer, subject, email)
+	          VALUES ($1, $2, $3, $4) RETURNING sec_account_identities_id, create_date`
+
+	return r.db.QueryRowContext(ctx, query, id.AccountID, id.Issuer, id.Subject, id.Email).Scan(&id.ID, &id.CreateDate)
+}
+
+// TouchIdentity records a login through an identity and the email address the IdP reported
+func (r *OIDCRepository) TouchIdentity(ctx context.Context, id int, email *string) error {
+	query := `UPDATE sec_account_identities SET last_login_at = NOW(), email = $2
+	          WHERE sec_account_identities_id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, email)
+	return err
+}
@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// ErrAlreadyMember is returned when adding a user to an organization they already belong to
+var ErrAlreadyMember = errors.New("user is already a member of the organization")
+
+// personalOrgName is the name given to each user's personal organization
+const personalOrgName = "Personal"
+
+// OrgRepository handles organizations and their members
+type OrgRepository struct {
+	db *DB
+}
+
+// NewOrgRepository creates a new OrgRepository
+func NewOrgRepository(db *DB) *OrgRepository {
+	return &OrgRepository{db: db}
+}
+
+// EnsurePersonalOrg returns the ID of a user's personal organization, creating it
+// (with the user as owner) if the user has none yet
+func (r *OrgRepository) EnsurePersonalOrg(ctx context.Context, userID int) (int, error) {
+	var orgID int
+	err := r.db.QueryRowContext(ctx, `SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $1`, userID).Scan(&orgID)
+	if err == nil {
+		return orgID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// A concurrent request may have created it first
+	insert := `INSERT INTO sec_orgs (name, personal_user_id, create_user, modify_user)
+	           VALUES ($1, $2, 'system', 'system')
+	           ON CONFLICT (personal_user_id) DO UPDATE SET personal_user_id = EXCLUDED.personal_user_id
+	           RETURNING sec_orgs_id`
+	if err := tx.QueryRowContext(ctx, insert, personalOrgName, userID).Scan(&orgID); err != nil {
+		return 0, err
+	}
+
+	member := `INSERT INTO sec_org_members (sec_orgs_id, sec_users_id, role) VALUES ($1, $2, $3)
+	           ON CONFLICT (sec_orgs_id, sec_users_id) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, member, orgID, userID, models.OrgRoleOwner); err != nil {
+		return 0, err
+	}
+
+	return orgID, tx.Commit()
+}
+
+// MemberRole returns a user's role in an organization, or "" if they are not a member
+func (r *OrgRepository) MemberRole(ctx context.Context, orgID, userID int) (string, error) {
+	var role string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT role FROM sec_org_members WHERE sec_orgs_id = $1 AND sec_users_id = $2`, orgID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// FindByID finds an organization by ID
+func (r *OrgRepository) FindByID(ctx context.Context, id int) (*models.Org, error) {
+	query := `SELECT sec_orgs_id, name, personal_user_id IS NOT NULL, create_date, create_user, modify_date, modify_user
+	          FROM sec_orgs WHERE sec_orgs_id = $1`
+
+	org := &models.Org{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&org.ID,
+		&org.Name,
+		&org.Personal,
+		&org.CreateDate,
+		&org.CreateUser,
+		&org.ModifyDate,
+		&org.ModifyUser,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// ListForUser returns the organizations a user belongs to, with their role in each
+func (r *OrgRepository) ListForUser(ctx context.Context, userID int) ([]*models.Org, error) {
+	query := `SELECT o.sec_orgs_id, o.name, o.personal_user_id IS NOT NULL, m.role,
+	                 o.create_date, o.create_user, o.modify_date, o.modify_user
+	          FROM sec_orgs o
+	          JOIN sec_org_members m ON m.sec_orgs_id = o.sec_orgs_id
+	          WHERE m.sec_users_id = $1
+	          ORDER BY o.personal_user_id IS NULL, o.name`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []*models.Org{}
+	for rows.Next() {
+		org := &models.Org{}
+		if err := rows.Scan(
+			&org.ID,
+			&org.Name,
+			&org.Personal,
+			&org.Role,
+			&org.CreateDate,
+			&org.CreateUser,
+			&org.ModifyDate,
+			&org.ModifyUser,
+		); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
+
+// Create creates a shared organization with the given user as its owner
+func (r *OrgRepository) Create(ctx context.Context, org *models.Org, ownerID int, actor string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO sec_orgs (name, create_user, modify_user) VALUES ($1, $2, $2)
+	          RETURNING sec_orgs_id, create_date, create_user, modify_date, modify_user`
+	if err := tx.QueryRowContext(ctx, query, org.Name, actor).Scan(
+		&org.ID,
+		&org.CreateDate,
+		&org.CreateUser,
+		&org.ModifyDate,
+		&org.ModifyUser,
+	); err != nil {
+		return err
+	}
+
+	member := `INSERT INTO sec_org_members (sec_orgs_id, sec_users_id, role) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, member, org.ID, ownerID, models.OrgRoleOwner); err != nil {
+		return err
+	}
+
+	org.Role = models.OrgRoleOwner
+	return tx.Commit()
+}
+
+// Rename changes an organization's name. Returns false if it does not exist.
+func (r *OrgRepository) Rename(ctx context.Context, id int, name, actor string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE sec_orgs SET name = $2, modify_date = NOW(), modify_user = $3 WHERE sec_orgs_id = $1`, id, name, actor)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CountPeople returns how many people (active or not) belong to an organization
+func (r *OrgRepository) CountPeople(ctx context.Context, id int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pdat_person WHERE sec_orgs_id = $1`, id).Scan(&count)
+	return count, err
+}
+
+// Delete deletes a shared organization and its memberships. Personal organizations are
+// never deleted. Returns false if there was no such shared organization.
+func (r *OrgRepository) Delete(ctx context.Context, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM sec_orgs WHERE sec_orgs_id = $1 AND personal_user_id IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ListMembers returns an organization's members
+func (r *OrgRepository) ListMembers(ctx context.Context, orgID int) ([]*models.OrgMember, error) {
+	query := `SELECT m.sec_orgs_id, m.sec_users_id, u.fname, u.lname, u.email, m.role, m.create_date
+	          FROM sec_org_members m
+	          JOIN sec_users u ON u.sec_users_id = m.sec_users_id
+	          WHERE m.sec_orgs_id = $1
+	          ORDER BY u.lname, u.fname`
+
+	rows, err := r.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*models.OrgMember{}
+	for rows.Next() {
+		m := &models.OrgMember{}
+		if err := rows.Scan(
+			&m.OrgID,
+			&m.UserID,
+			&m.FirstName,
+			&m.LastName,
+			&m.Email,
+			&m.Role,
+			&m.CreateDate,
+		); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMember adds a user to an organization with the given role
+func (r *OrgRepository) AddMember(ctx context.Context, orgID, userID int, role string) error {
+	query := `INSERT INTO sec_org_members (sec_orgs_id, sec_users_id, role) VALUES ($1, $2, $3)
+	          ON CONFLICT (sec_orgs_id, sec_users_id) DO NOTHING`
+	result, err := r.db.ExecContext(ctx, query, orgID, userID, role)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAlreadyMember
+	}
+	return nil
+}
+
+// SetMemberRole changes a member's role. Returns false if the user is not a member.
+func (r *OrgRepository) SetMemberRole(ctx context.Context, orgID, userID int, role string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE sec_org_members SET role = $3 WHERE sec_orgs_id = $1 AND sec_users_id = $2`, orgID, userID, role)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// RemoveMember removes a user from an organization. People they own there stay in the
+// organization; private ones stay hidden unless they rejoin. Returns false if the user was not a member.
+func (r *OrgRepository) RemoveMember(ctx context.Context, orgID, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM sec_org_members WHERE sec_orgs_id = $1 AND sec_users_id = $2`, orgID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CountOwners returns how many owners an organization has
+func (r *OrgRepository) CountOwners(ctx context.Context, orgID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sec_org_members WHERE sec_orgs_id = $1 AND role = $2`, orgID, models.OrgRoleOwner).Scan(&count)
+	return count, err
+}
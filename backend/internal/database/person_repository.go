@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
+
 	"github.com/davealexenglish/magnifimind-crm/internal/models"
 )
 
@@ -19,20 +21,44 @@ func NewPersonRepository(db *DB) *PersonRepository {
 	return &PersonRepository{db: db}
 }
 
-// FindByID finds a person by ID
-func (r *PersonRepository) FindByID(ctx context.Context, id int) (*models.PdatPerson, error) {
-	query := `SELECT pdat_person_id, fname, lname, birthday, business_flag, sec_users_id,
+// VisibleCondition limits pdat_person rows, or v_* view rows carrying the person's columns,
+// to a tenant scope's organization, hiding people other members marked private. prefix
+// qualifies the columns (e.g. "p."); orgArg and userArg are the placeholder numbers of
+// the scope's OrgID and UserID.
+func VisibleCondition(prefix string, orgArg, userArg int) string {
+	return fmt.Sprintf("%[1]ssec_orgs_id = $%[2]d AND (%[1]sprivate_flag = 'N' OR %[1]ssec_users_id = $%[3]d)", prefix, orgArg, userArg)
+}
+
+// AccessibleCondition widens VisibleCondition to people shared with the scope's user
+// through pdat_person_shares; with edit set only shares granting edit count. prefix
+// must name the outer table or alias (e.g. "p.") since the share subquery has its own
+// pdat_person_id.
+func AccessibleCondition(prefix string, orgArg, userArg int, edit bool) string {
+	permission := ""
+	if edit {
+		permission = " AND s.permission = '" + models.SharePermissionEdit + "'"
+	}
+	return fmt.Sprintf("((%s) OR EXISTS (SELECT 1 FROM pdat_person_shares s WHERE s.pdat_person_id = %spdat_person_id AND s.sec_users_id = $%d%s))",
+		VisibleCondition(prefix, orgArg, userArg), prefix, userArg, permission)
+}
+
+// FindByID finds a person visible in the tenant scope or shared with its user.
+// Returns nil for people the scope cannot see.
+func (r *PersonRepository) FindByID(ctx context.Context, scope models.TenantScope, id int) (*models.PdatPerson, error) {
+	query := `SELECT pdat_person_id, fname, lname, birthday, business_flag, sec_users_id, sec_orgs_id, private_flag,
 	                 create_date, create_user, modify_date, modify_user, active_flag
-	          FROM pdat_person WHERE pdat_person_id = $1`
+	          FROM pdat_person WHERE pdat_person_id = $1 AND ` + AccessibleCondition("pdat_person.", 2, 3, false)
 
 	person := &models.PdatPerson{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, id, scope.OrgID, scope.UserID).Scan(
 		&person.ID,
 		&person.FirstName,
 		&person.LastName,
 		&person.Birthday,
 		&person.BusinessFlag,
 		&person.UserID,
+		&person.OrgID,
+		&person.PrivateFlag,
 		&person.CreateDate,
 		&person.CreateUser,
 		&person.ModifyDate,
@@ -50,23 +76,23 @@ func (r *PersonRepository) FindByID(ctx context.Context, id int) (*models.PdatPe
 	return person, nil
 }
 
-// List returns a list of persons
-func (r *PersonRepository) List(ctx context.Context, limit, offset int) ([]*models.PdatPerson, int, error) {
+// List returns a list of persons visible in the tenant scope
+func (r *PersonRepository) List(ctx context.Context, scope models.TenantScope, limit, offset int) ([]*models.PdatPerson, int, error) {
 	// Get total count
 	var total int
-	countQuery := `SELECT COUNT(*) FROM pdat_person WHERE active_flag = 'Y'`
-	err := r.db.QueryRowContext(ctx, countQuery).Scan(&total)
+	countQuery := `SELECT COUNT(*) FROM pdat_person WHERE active_flag = 'Y' AND ` + VisibleCondition("", 1, 2)
+	err := r.db.QueryRowContext(ctx, countQuery, scope.OrgID, scope.UserID).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get persons
-	query := `SELECT pdat_person_id, fname, lname, birthday, business_flag, sec_users_id,
+	query := `SELECT pdat_person_id, fname, lname, birthday, business_flag, sec_users_id, sec_orgs_id, private_flag,
 	                 create_date, create_user, modify_date, modify_user, active_flag
-	          FROM pdat_person WHERE active_flag = 'Y'
-	          ORDER BY pdat_person_id LIMIT $1 OFFSET $2`
+	          FROM pdat_person WHERE active_flag = 'Y' AND ` + VisibleCondition("", 1, 2) + `
+	          ORDER BY pdat_person_id LIMIT $3 OFFSET $4`
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, scope.OrgID, scope.UserID, limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -82,6 +108,8 @@ func (r *PersonRepository) List(ctx context.Context, limit, offset int) ([]*mode
 			&person.Birthday,
 			&person.BusinessFlag,
 			&person.UserID,
+			&person.OrgID,
+			&person.PrivateFlag,
 			&person.CreateDate,
 			&person.CreateUser,
 			&person.ModifyDate,
@@ -97,12 +125,12 @@ func (r *PersonRepository) List(ctx context.Context, limit, offset int) ([]*mode
 	return persons, total, nil
 }
 
-// ListWithFilters returns a filtered list of persons
-func (r *PersonRepository) ListWithFilters(ctx context.Context, fname, lname string, businessFlag bool, limit, offset int) ([]*models.PdatPerson, int, error) {
+// ListWithFilters returns a filtered list of persons visible in the tenant scope
+func (r *PersonRepository) ListWithFilters(ctx context.Context, scope models.TenantScope, fname, lname string, businessFlag bool, limit, offset int) ([]*models.PdatPerson, int, error) {
 	// Build WHERE conditions
-	conditions := []string{"active_flag = 'Y'"}
-	args := []interface{}{}
-	argNum := 1
+	conditions := []string{"active_flag = 'Y'", VisibleCondition("", 1, 2)}
+	args := []interface{}{scope.OrgID, scope.UserID}
+	argNum := 3
 
 	if fname != "" {
 		conditions = append(conditions, fmt.Sprintf("fname ILIKE $%d", argNum))
@@ -144,7 +172,7 @@ func (r *PersonRepository) ListWithFilters(ctx context.Context, fname, lname str
 	args = append(args, limit, offset)
 
 	// Get persons
-	query := fmt.Sprintf(`SELECT pdat_person_id, fname, lname, birthday, business_flag, sec_users_id,
+	query := fmt.Sprintf(`SELECT pdat_person_id, fname, lname, birthday, business_flag, sec_users_id, sec_orgs_id, private_flag,
 	                 create_date, create_user, modify_date, modify_user, active_flag
 	          FROM pdat_person %s
 	          ORDER BY pdat_person_id LIMIT %s OFFSET %s`, whereClause, limitArg, offsetArg)
@@ -168,6 +196,8 @@ func (r *PersonRepository) ListWithFilters(ctx context.Context, fname, lname str
 			&person.Birthday,
 			&person.BusinessFlag,
 			&person.UserID,
+			&person.OrgID,
+			&person.PrivateFlag,
 			&person.CreateDate,
 			&person.CreateUser,
 			&person.ModifyDate,
@@ -183,11 +213,17 @@ func (r *PersonRepository) ListWithFilters(ctx context.Context, fname, lname str
 	return persons, total, nil
 }
 
-// Create creates a new person
-func (r *PersonRepository) Create(ctx context.Context, person *models.PdatPerson) error {
-	query := `INSERT INTO pdat_person (fname, lname, birthday, business_flag, sec_users_id,
+// Create creates a new person in the tenant scope's organization, owned by its user
+func (r *PersonRepository) Create(ctx context.Context, scope models.TenantScope, person *models.PdatPerson) error {
+	query := `INSERT INTO pdat_person (fname, lname, birthday, business_flag, sec_users_id, sec_orgs_id, private_flag,
 	                                    create_date, create_user, modify_date, modify_user, active_flag)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING pdat_person_id`
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING pdat_person_id`
+
+	person.UserID = scope.UserID
+	person.OrgID = &scope.OrgID
+	if person.PrivateFlag != "Y" {
+		person.PrivateFlag = "N"
+	}
 
 	now := time.Now()
 	return r.db.QueryRowContext(ctx, query,
@@ -196,6 +232,8 @@ func (r *PersonRepository) Create(ctx context.Context, person *models.PdatPerson
 		person.Birthday,
 		person.BusinessFlag,
 		person.UserID,
+		person.OrgID,
+		person.PrivateFlag,
 		now,
 		person.CreateUser,
 		now,
@@ -204,13 +242,14 @@ func (r *PersonRepository) Create(ctx context.Context, person *models.PdatPerson
 	).Scan(&person.ID)
 }
 
-// Update updates a person
-func (r *PersonRepository) Update(ctx context.Context, person *models.PdatPerson) error {
+// Update updates a person visible in the tenant scope or shared with its user for editing.
+// Returns false if the scope cannot edit the person.
+func (r *PersonRepository) Update(ctx context.Context, scope models.TenantScope, person *models.PdatPerson) (bool, error) {
 	query := `UPDATE pdat_person SET fname = $1, lname = $2, birthday = $3, business_flag = $4,
 	                                  modify_date = $5, modify_user = $6, active_flag = $7
-	          WHERE pdat_person_id = $8`
+	          WHERE pdat_person_id = $8 AND ` + AccessibleCondition("pdat_person.", 9, 10, true)
 
-	_, err := r.db.ExecContext(ctx, query,
+	result, err := r.db.ExecContext(ctx, query,
 		person.FirstName,
 		person.LastName,
 		person.Birthday,
@@ -219,66 +258,106 @@ func (r *PersonRepository) Update(ctx context.Context, person *models.PdatPerson
 		person.ModifyUser,
 		person.ActiveFlag,
 		person.ID,
+		scope.OrgID,
+		scope.UserID,
 	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// SetPrivate marks a person private to its owner or shares it with the organization.
+// Only the owner can do this. Returns false if the person is not the scope user's own
+// person in the scope's organization.
+func (r *PersonRepository) SetPrivate(ctx context.Context, scope models.TenantScope, id int, private bool, modifyUser string) (bool, error) {
+	flag := "N"
+	if private {
+		flag = "Y"
+	}
 
-	return err
+	query := `UPDATE pdat_person SET private_flag = $1, modify_date = $2, modify_user = $3
+	          WHERE pdat_person_id = $4 AND sec_orgs_id = $5 AND sec_users_id = $6`
+	result, err := r.db.ExecContext(ctx, query, flag, time.Now(), modifyUser, id, scope.OrgID, scope.UserID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
 }
 
-// Delete soft-deletes a person by setting active_flag to 'N'
-func (r *PersonRepository) Delete(ctx context.Context, id int) error {
-	query := `UPDATE pdat_person SET active_flag = 'N', modify_date = $1 WHERE pdat_person_id = $2`
-	_, err := r.db.ExecContext(ctx, query, time.Now(), id)
-	return err
+// Delete soft-deletes a person visible in the tenant scope by setting active_flag to 'N'.
+// Shares never allow deleting. Returns false if the scope cannot see the person.
+func (r *PersonRepository) Delete(ctx context.Context, scope models.TenantScope, id int, modifyUser string) (bool, error) {
+	query := `UPDATE pdat_person SET active_flag = 'N', modify_date = $1, modify_user = $2
+	          WHERE pdat_person_id = $3 AND ` + VisibleCondition("", 4, 5)
+	result, err := r.db.ExecContext(ctx, query, time.Now(), modifyUser, id, scope.OrgID, scope.UserID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
 }
 
-// HardDelete permanently deletes a person and all related records (via CASCADE)
-func (r *PersonRepository) HardDelete(ctx context.Context, id int) error {
-	query := `DELETE FROM pdat_person WHERE pdat_person_id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+// HardDelete permanently deletes a person visible in the tenant scope and all related
+// records (via CASCADE). Returns false if the scope cannot see the person.
+func (r *PersonRepository) HardDelete(ctx context.Context, scope models.TenantScope, id int) (bool, error) {
+	query := `DELETE FROM pdat_person WHERE pdat_person_id = $1 AND ` + VisibleCondition("", 2, 3)
+	result, err := r.db.ExecContext(ctx, query, id, scope.OrgID, scope.UserID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
 }
 
-// HardDeleteBulk permanently deletes multiple persons and all related records (via CASCADE)
-func (r *PersonRepository) HardDeleteBulk(ctx context.Context, ids []int) error {
+// HardDeleteBulk permanently deletes the persons among ids that are visible in the tenant
+// scope, with all related records (via CASCADE). Returns the IDs actually deleted.
+func (r *PersonRepository) HardDeleteBulk(ctx context.Context, scope models.TenantScope, ids []int) ([]int, error) {
+	deleted := []int{}
 	if len(ids) == 0 {
-		return nil
+		return deleted, nil
 	}
 
-	// Build placeholders for IN clause
-	placeholders := ""
-	args := make([]interface{}, len(ids))
-	for i, id := range ids {
-		if i > 0 {
-			placeholders += ", "
+	query := `DELETE FROM pdat_person WHERE pdat_person_id = ANY($1) AND ` + VisibleCondition("", 2, 3) + `
+	          RETURNING pdat_person_id`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids), scope.OrgID, scope.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
 		}
-		placeholders += fmt.Sprintf("$%d", i+1)
-		args[i] = id
+		deleted = append(deleted, id)
 	}
 
-	query := fmt.Sprintf(`DELETE FROM pdat_person WHERE pdat_person_id IN (%s)`, placeholders)
-	_, err := r.db.ExecContext(ctx, query, args...)
-	return err
+	return deleted, rows.Err()
 }
 
-// Search searches for persons by name
-func (r *PersonRepository) Search(ctx context.Context, searchTerm string, limit, offset int) ([]*models.PdatPerson, int, error) {
+// Search searches for persons visible in the tenant scope by name
+func (r *PersonRepository) Search(ctx context.Context, scope models.TenantScope, searchTerm string, limit, offset int) ([]*models.PdatPerson, int, error) {
 	// Get total count
 	var total int
 	countQuery := `SELECT COUNT(*) FROM pdat_person
-	               WHERE active_flag = 'Y' AND (fname ILIKE $1 OR lname ILIKE $1)`
-	err := r.db.QueryRowContext(ctx, countQuery, "%"+searchTerm+"%").Scan(&total)
+	               WHERE active_flag = 'Y' AND (fname ILIKE $1 OR lname ILIKE $1) AND ` + VisibleCondition("", 2, 3)
+	err := r.db.QueryRowContext(ctx, countQuery, "%"+searchTerm+"%", scope.OrgID, scope.UserID).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get persons
-	query := `SELECT pdat_person_id, fname, lname, birthday, business_flag, sec_users_id,
+	query := `SELECT pdat_person_id, fname, lname, birthday, business_flag, sec_users_id, sec_orgs_id, private_flag,
 	                 create_date, create_user, modify_date, modify_user, active_flag
 	          FROM pdat_person
-	          WHERE active_flag = 'Y' AND (fname ILIKE $1 OR lname ILIKE $1)
-	          ORDER BY pdat_person_id LIMIT $2 OFFSET $3`
+	          WHERE active_flag = 'Y' AND (fname ILIKE $1 OR lname ILIKE $1) AND ` + VisibleCondition("", 2, 3) + `
+	          ORDER BY pdat_person_id LIMIT $4 OFFSET $5`
 
-	rows, err := r.db.QueryContext(ctx, query, "%"+searchTerm+"%", limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, "%"+searchTerm+"%", scope.OrgID, scope.UserID, limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -294,6 +373,8 @@ func (r *PersonRepository) Search(ctx context.Context, searchTerm string, limit,
 			&person.Birthday,
 			&person.BusinessFlag,
 			&person.UserID,
+			&person.OrgID,
+			&person.PrivateFlag,
 			&person.CreateDate,
 			&person.CreateUser,
 			&person.ModifyDate,
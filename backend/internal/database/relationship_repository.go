@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// ErrDuplicateRelationship is returned when two people already have a relationship of the same type
+var ErrDuplicateRelationship = errors.New("relationship already exists")
+
+// RelationshipRepository handles relationships between people. Both people must be
+// visible in the tenant scope, which also keeps them in the same organization.
+type RelationshipRepository struct {
+	db *DB
+}
+
+// NewRelationshipRepository creates a new RelationshipRepository
+func NewRelationshipRepository(db *DB) *RelationshipRepository {
+	return &RelationshipRepository{db: db}
+}
+
+// FindByID finds a relationship whose people are both visible in the tenant scope
+func (r *RelationshipRepository) FindByID(ctx context.Context, scope models.TenantScope, id int) (*models.PersonRelationship, error) {
+	query := `SELECT r.pdat_relationship_id, r.pdat_person_id, r.related_person_id, r.relationship_type, r.bidirectional,
+	                 r.create_date, r.create_user, r.modify_date, r.modify_user
+	          FROM pdat_person_relationship r
+	          JOIN pdat_person a ON a.pdat_person_id = r.pdat_person_id
+	          JOIN pdat_person b ON b.pdat_person_id = r.related_person_id
+	          WHERE r.pdat_relationship_id = $1 AND ` + VisibleCondition("a.", 2, 3) + ` AND ` + VisibleCondition("b.", 2, 3)
+
+	rel := &models.PersonRelationship{}
+	err := r.db.QueryRowContext(ctx, query, id, scope.OrgID, scope.UserID).Scan(
+		&rel.ID,
+		&rel.PersonID,
+		&rel.RelatedPersonID,
+		&rel.Type,
+		&rel.Bidirectional,
+		&rel.CreateDate,
+		&rel.CreateUser,
+		&rel.ModifyDate,
+		&rel.ModifyUser,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rel, nil
+}
+
+// Create relates two people visible in the tenant scope. Returns false if either is not.
+func (r *RelationshipRepository) Create(ctx context.Context, scope models.TenantScope, rel *models.PersonRelationship) (bool, error) {
+	query := `INSERT INTO pdat_person_relationship (pdat_person_id, related_person_id, relationship_type, bidirectional,
+	                                              create_date, create_user, modify_date, modify_user)
+	          SELECT a.pdat_person_id, b.pdat_person_id, $3::varchar, $4::boolean, $5::timestamptz, $6::varchar, $5, $6
+	          FROM pdat_person a, pdat_person b
+	          WHERE a.pdat_person_id = $1 AND b.pdat_person_id = $2
+	            AND ` + VisibleCondition("a.", 7, 8) + ` AND ` + VisibleCondition("b.", 7, 8) + `
+	          RETURNING pdat_relationship_id, create_date, modify_date`
+
+	err := r.db.QueryRowContext(ctx, query,
+		rel.PersonID,
+		rel.RelatedPersonID,
+		rel.Type,
+		rel.Bidirectional,
+		time.Now(),
+		rel.CreateUser,
+		scope.OrgID,
+		scope.UserID,
+	).Scan(&rel.ID, &rel.CreateDate, &rel.ModifyDate)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if isUniqueViolation(err, "uk_pdat_person_relationship") {
+		return false, ErrDuplicateRelationship
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Update changes a relationship's type and direction. Returns false if the relationship
+// does not exist or either person is not visible in the tenant scope.
+func (r *RelationshipRepository) Update(ctx context.Context, scope models.TenantScope, id int, relType string, bidirectional bool, modifyUser string) (bool, error) {
+	query := `UPDATE pdat_person_relationship r SET relationship_type = $1, bidirectional = $2, modify_date = $3, modify_user = $4
+	          FROM pdat_person a, pdat_person b
+	          WHERE r.pdat_relationship_id = $5
+	            AND a.pdat_person_id = r.pdat_person_id AND b.pdat_person_id = r.related_person_id
+	            AND ` + VisibleCondition("a.", 6, 7) + ` AND ` + VisibleCondition("b.", 6, 7)
+
+	result, err := r.db.ExecContext(ctx, query, relType, bidirectional, time.Now(), modifyUser, id, scope.OrgID, scope.UserID)
+	if isUniqueViolation(err, "uk_pdat_person_relationship") {
+		return false, ErrDuplicateRelationship
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Delete deletes a relationship. Returns false if the relationship does not exist or
+// either person is not visible in the tenant scope.
+func (r *RelationshipRepository) Delete(ctx context.Context, scope models.TenantScope, id int) (bool, error) {
+	query := `DELETE FROM pdat_person_relationship r
+	          USING pdat_person a, pdat_person b
+	          WHERE r.pdat_relationship_id = $1
+	            AND a.pdat_person_id = r.pdat_person_id AND b.pdat_person_id = r.related_person_id
+	            AND ` + VisibleCondition("a.", 2, 3) + ` AND ` + VisibleCondition("b.", 2, 3)
+
+	result, err := r.db.ExecContext(ctx, query, id, scope.OrgID, scope.UserID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Network returns the active people within hops relationships of a person, each with the
+// fewest hops it takes to reach them. Relationships are followed in both directions and
+// only through people visible in the tenant scope. The walk keeps one row per hop, with
+// the people first reached at that hop and everyone reached so far, so nobody is visited
+// twice and its cost grows with the number of relationships rather than of paths.
+func (r *RelationshipRepository) Network(ctx context.Context, scope models.TenantScope, personID, hops int) ([]*models.NetworkPerson, error) {
+	query := `WITH RECURSIVE edges AS (
+	              SELECT r.pdat_person_id AS from_id, r.related_person_id AS to_id
+	              FROM pdat_person_relationship r
+	              JOIN pdat_person a ON a.pdat_person_id = r.pdat_person_id
+	              JOIN pdat_person b ON b.pdat_person_id = r.related_person_id
+	              WHERE a.active_flag = 'Y' AND b.active_flag = 'Y'
+	                AND ` + VisibleCondition("a.", 3, 4) + ` AND ` + VisibleCondition("b.", 3, 4) + `
+	          ), links AS (
+	              SELECT from_id, to_id FROM edges
+	              UNION
+	              SELECT to_id, from_id FROM edges
+	          ), walk (hops, frontier, visited) AS (
+	              SELECT 0, ARRAY[$1::integer], ARRAY[$1::integer]
+	              UNION ALL
+	              SELECT w.hops + 1, n.next, w.visited || n.next
+	              FROM walk w
+	              CROSS JOIN LATERAL (
+	                  SELECT array_agg(DISTINCT l.to_id) AS next
+	                  FROM links l
+	                  WHERE l.from_id = ANY(w.frontier) AND NOT l.to_id = ANY(w.visited)
+	              ) n
+	              WHERE w.hops < $2 AND n.next IS NOT NULL
+	          )
+	          SELECT p.pdat_person_id, p.fname, p.lname, w.hops
+	          FROM walk w
+	          CROSS JOIN LATERAL unnest(w.frontier) AS f(person_id)
+	          JOIN pdat_person p ON p.pdat_person_id = f.person_id
+	          WHERE w.hops > 0
+	          ORDER BY w.hops, p.lname, p.fname`
+
+	rows, err := r.db.QueryContext(ctx, query, personID, hops, scope.OrgID, scope.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	people := []*models.NetworkPerson{}
+	for rows.Next() {
+		np := &models.NetworkPerson{}
+		if err := rows.Scan(&np.PersonID, &np.FirstName, &np.LastName, &np.Hops); err != nil {
+			return nil, err
+		}
+		people = append(people, np)
+	}
+
+	return people, rows.Err()
+}
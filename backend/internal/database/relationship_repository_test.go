@@ -0,0 +1,99 @@
+package database_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/testutil"
+)
+
+func TestRelationshipNetworkReportsFewestHops(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+	account := testutil.CreateUser(t, db, "network")
+	scope := models.TenantScope{UserID: account.UserID, OrgID: testutil.PersonalOrg(t, db, account.UserID)}
+	people := database.NewPersonRepository(db)
+	relationships := database.NewRelationshipRepository(db)
+
+	person := func(name string) int {
+		lastName := testutil.Unique("Network")
+		p := &models.PdatPerson{FirstName: &name, LastName: &lastName, BusinessFlag: "N", PrivateFlag: "N",
+			ActiveFlag: "Y", CreateUser: account.Name, ModifyUser: account.Name}
+		if err := people.Create(ctx, scope, p); err != nil {
+			t.Fatal(err)
+		}
+		return p.ID
+	}
+	relate := func(a, b int) {
+		if ok, err := relationships.Create(ctx, scope, &models.PersonRelationship{
+			PersonID: a, RelatedPersonID: b, Type: models.RelationshipColleague, Bidirectional: true,
+		}); err != nil || !ok {
+			t.Fatalf("Failed to relate %d and %d: %v", a, b, err)
+		}
+	}
+
+	// Two fully connected layers of colleagues give many paths to everyone; each must still
+	// be listed once, at the hop it is first reached
+	root := person("root")
+	want := map[int]int{}
+	var first, second []int
+	for i := 0; i < 5; i++ {
+		first = append(first, person(fmt.Sprintf("first-%d", i)))
+		second = append(second, person(fmt.Sprintf("second-%d", i)))
+	}
+	for i, a := range first {
+		relate(root, a)
+		want[a] = 1
+		for _, b := range first[i+1:] {
+			relate(a, b)
+		}
+		for _, b := range second {
+			relate(b, a)
+			want[b] = 2
+		}
+	}
+	for i, a := range second {
+		for _, b := range second[i+1:] {
+			relate(a, b)
+		}
+	}
+	last := person("last")
+	relate(second[0], last)
+	want[last] = 3
+
+	tests := []struct {
+		hops int
+		want int // number of people
+	}{
+		{hops: 1, want: 5},
+		{hops: 2, want: 10},
+		{hops: models.MaxRelationshipHops, want: 11},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d hops", tt.hops), func(t *testing.T) {
+			network, err := relationships.Network(ctx, scope, root, tt.hops)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(network) != tt.want {
+				t.Errorf("got %d people, want %d", len(network), tt.want)
+			}
+			seen := map[int]bool{}
+			for _, np := range network {
+				if seen[np.PersonID] {
+					t.Errorf("person %d listed twice", np.PersonID)
+				}
+				seen[np.PersonID] = true
+				if np.PersonID == root {
+					t.Error("the person themself is listed")
+				}
+				if np.Hops != want[np.PersonID] || np.Hops > tt.hops {
+					t.Errorf("person %d at %d hops, want %d", np.PersonID, np.Hops, want[np.PersonID])
+				}
+			}
+		})
+	}
+}
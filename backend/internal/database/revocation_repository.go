@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// RevocationRepository handles the access-token revocation list
+type RevocationRepository struct {
+	db *DB
+}
+
+// NewRevocationRepository creates a new RevocationRepository
+func NewRevocationRepository(db *DB) *RevocationRepository {
+	return &RevocationRepository{db: db}
+}
+
+// RevokeAccessToken adds an access token's jti to the revocation list until the token expires
+func (r *RevocationRepository) RevokeAccessToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_access_tokens (jti, sec_users_id, expires_at)
+	          VALUES ($1, $2, $3) ON CONFLICT (jti) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, jti, userID, expiresAt)
+	return err
+}
+
+// ListRevokedAccessTokens returns the jti and expiry of every revoked token that has not yet expired
+func (r *RevocationRepository) ListRevokedAccessTokens(ctx context.Context) (map[string]time.Time, error) {
+	query := `SELECT jti, expires_at FROM revoked_access_tokens WHERE expires_at > NOW()`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revoked := make(map[string]time.Time)
+	for rows.Next() {
+		var jti string
+		var expiresAt time.Time
+		if err := rows.Scan(&jti, &expiresAt); err != nil {
+			return nil, err
+		}
+		revoked[jti] = expiresAt
+	}
+
+	return revoked, rows.Err()
+}
+
+// PruneRevokedAccessTokens deletes revocation entries for tokens that have expired anyway
+func (r *RevocationRepository) PruneRevokedAccessTokens(ctx context.Context) error {
+	query := `DELETE FROM revoked_access_tokens WHERE expires_at <= NOW()`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+// RevokeSession adds a session to the revocation list until its last access token expires
+func (r *RevocationRepository) RevokeSession(ctx context.Context, familyID string, userID int, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_sessions (family_id, sec_users_id, expires_at)
+	          VALUES ($1, $2, $3) ON CONFLICT (family_id) DO UPDATE SET expires_at = EXCLUDED.expires_at`
+	_, err := r.db.ExecContext(ctx, query, familyID, userID, expiresAt)
+	return err
+}
+
+// ListRevokedSessions returns the ID and expiry of every revoked session whose access tokens may still be live
+func (r *RevocationRepository) ListRevokedSessions(ctx context.Context) (map[string]time.Time, error) {
+	query := `SELECT family_id, expires_at FROM revoked_sessions WHERE expires_at > NOW()`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revoked := make(map[string]time.Time)
+	for rows.Next() {
+		var familyID string
+		var expiresAt time.Time
+		if err := rows.Scan(&familyID, &expiresAt); err != nil {
+			return nil, err
+		}
+		revoked[familyID] = expiresAt
+	}
+
+	return revoked, rows.Err()
+}
+
+// PruneRevokedSessions deletes revocation entries for sessions whose access tokens have expired
+func (r *RevocationRepository) PruneRevokedSessions(ctx context.Context) error {
+	query := `DELETE FROM revoked_sessions WHERE expires_at <= NOW()`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+// SetTokensValidAfter invalidates every access token issued to a user at or before the given time
+func (r *RevocationRepository) SetTokensValidAfter(ctx context.Context, userID int, cutoff time.Time) error {
+	query := `UPDATE sec_users SET tokens_valid_after = $1 WHERE sec_users_id = $2`
+	_, err := r.db.ExecContext(ctx, query, cutoff, userID)
+	return err
+}
+
+// ListTokenCutoffs returns the tokens_valid_after cutoff for every user that has one
+func (r *RevocationRepository) ListTokenCutoffs(ctx context.Context) (map[int]time.Time, error) {
+	query := `SELECT sec_users_id, tokens_valid_after FROM sec_users WHERE tokens_valid_after IS NOT NULL`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cutoffs := make(map[int]time.Time)
+	for rows.Next() {
+		var userID int
+		var cutoff time.Time
+		if err := rows.Scan(&userID, &cutoff); err != nil {
+			return nil, err
+		}
+		cutoffs[userID] = cutoff
+	}
+
+	return cutoffs, rows.Err()
+}
@@ -0,0 +1,493 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// Role repository errors
+var (
+	ErrDuplicateRoleName      = errors.New("role name already exists")
+	ErrDuplicatePrivilegeName = errors.New("privilege name already exists")
+)
+
+// RoleRepository handles roles, privileges and their assignments
+type RoleRepository struct {
+	db *DB
+}
+
+// NewRoleRepository creates a new RoleRepository
+func NewRoleRepository(db *DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// SeedDefaults creates the privileges checked by the API and the default roles.
+// A default role gets its privileges only when it is first created, so later edits
+// stick, except admin which always holds every privilege. When the user role is
+// first created every existing account is given it, so upgrading does not lock anyone out.
+func (r *RoleRepository) SeedDefaults(ctx context.Context) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const seedUser = "system"
+
+	for _, name := range models.AllPrivileges {
+		query := `INSERT INTO sec_privileges (name, create_date, create_user, modify_date, modify_user)
+		          VALUES ($1, NOW(), $2, NOW(), $2) ON CONFLICT (name) DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, name, seedUser); err != nil {
+			return err
+		}
+	}
+
+	grantQuery := `INSERT INTO sec_role_privs (sec_privileges_id, sec_roles_id, create_date, create_user, modify_date, modify_user)
+	               SELECT sec_privileges_id, $1, NOW(), $2, NOW(), $2 FROM sec_privileges WHERE name = $3
+	               ON CONFLICT (sec_roles_id, sec_privileges_id) DO NOTHING`
+
+	for _, role := range []string{models.RoleAdmin, models.RoleUser, models.RoleReadOnly} {
+		query := `INSERT INTO sec_roles (name, create_date, create_user, modify_date, modify_user)
+		          VALUES ($1, NOW(), $2, NOW(), $2) ON CONFLICT (name) DO NOTHING RETURNING sec_roles_id`
+
+		var roleID int
+		created := true
+		err := tx.QueryRowContext(ctx, query, role, seedUser).Scan(&roleID)
+		if err == sql.ErrNoRows {
+			created = false
+			err = tx.QueryRowContext(ctx, `SELECT sec_roles_id FROM sec_roles WHERE name = $1`, role).Scan(&roleID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if created || role == models.RoleAdmin {
+			for _, priv := range models.DefaultRolePrivileges[role] {
+				if _, err := tx.ExecContext(ctx, grantQuery, roleID, seedUser, priv); err != nil {
+					return err
+				}
+			}
+		}
+
+		if created && role == models.RoleUser {
+			backfill := `INSERT INTO sec_acct_roles (sec_roles_id, sec_accounts_id, create_date, create_user, modify_date, modify_user)
+			             SELECT $1, sec_accounts_id, NOW(), $2, NOW(), $2 FROM sec_accounts
+			             ON CONFLICT (sec_accounts_id, sec_roles_id) DO NOTHING`
+			if _, err := tx.ExecContext(ctx, backfill, roleID, seedUser); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListRoles returns all roles
+func (r *RoleRepository) ListRoles(ctx context.Context) ([]models.SecRole, error) {
+	query := `SELECT sec_roles_id, name, descr, require_mfa, create_date, create_user, modify_date, modify_user
+	          FROM sec_roles ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRoles(rows)
+}
+
+// FindRoleByID finds a role by ID
+func (r *RoleRepository) FindRoleByID(ctx context.Context, id int) (*models.SecRole, error) {
+	query := `SELECT sec_roles_id, name, descr, require_mfa, create_date, create_user, modify_date, modify_user
+	          FROM sec_roles WHERE sec_roles_id = $1`
+
+	role := &models.SecRole{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&role.ID,
+		&role.Name,
+		&role.Description,
+		&role.RequireMFA,
+		&role.CreateDate,
+		&role.CreateUser,
+		&role.ModifyDate,
+		&role.ModifyUser,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// CreateRole creates a new role
+func (r *RoleRepository) CreateRole(ctx context.Context, role *models.SecRole, createUser string) error {
+	query := `INSERT INTO sec_roles (name, descr, create_date, create_user, modify_date, modify_user)
+	          VALUES ($1, $2, $3, $4, $3, $4) RETURNING sec_roles_id`
+
+	now := time.Now()
+	role.CreateDate, role.ModifyDate = now, now
+	role.CreateUser = secModifyUser(createUser)
+	role.ModifyUser = role.CreateUser
+
+	err := r.db.QueryRowContext(ctx, query, role.Name, role.Description, now, role.CreateUser).Scan(&role.ID)
+	if isUniqueViolation(err, "uk_sec_roles_name") {
+		return ErrDuplicateRoleName
+	}
+	return err
+}
+
+// UpdateRole updates a role's name and description. Returns false if the role does not exist.
+func (r *RoleRepository) UpdateRole(ctx context.Context, role *models.SecRole, modifyUser string) (bool, error) {
+	query := `UPDATE sec_roles SET name = $2, descr = $3, modify_date = NOW(), modify_user = $4 WHERE sec_roles_id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, role.ID, role.Name, role.Description, secModifyUser(modifyUser))
+	if isUniqueViolation(err, "uk_sec_roles_name") {
+		return false, ErrDuplicateRoleName
+	}
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// DeleteRole deletes a role along with its account and privilege assignments.
+// Returns false if the role does not exist.
+func (r *RoleRepository) DeleteRole(ctx context.Context, id int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sec_acct_roles WHERE sec_roles_id = $1`, id); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sec_role_privs WHERE sec_roles_id = $1`, id); err != nil {
+		return false, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM sec_roles WHERE sec_roles_id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	return true, tx.Commit()
+}
+
+// ListPrivileges returns all privileges
+func (r *RoleRepository) ListPrivileges(ctx context.Context) ([]models.SecPrivilege, error) {
+	query := `SELECT sec_privileges_id, name, create_date, create_user, modify_date, modify_user
+	          FROM sec_privileges ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPrivileges(rows)
+}
+
+// FindPrivilegeByID finds a privilege by ID
+func (r *RoleRepository) FindPrivilegeByID(ctx context.Context, id int) (*models.SecPrivilege, error) {
+	query := `SELECT sec_privileges_id, name, create_date, create_user, modify_date, modify_user
+	          FROM sec_privileges WHERE sec_privileges_id = $1`
+
+	priv := &models.SecPrivilege{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&priv.ID,
+		&priv.Name,
+		&priv.CreateDate,
+		&priv.CreateUser,
+		&priv.ModifyDate,
+		&priv.ModifyUser,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return priv, nil
+}
+
+// CreatePrivilege creates a new privilege
+func (r *RoleRepository) CreatePrivilege(ctx context.Context, name, createUser string) (*models.SecPrivilege, error) {
+	query := `INSERT INTO sec_privileges (name, create_date, create_user, modify_date, modify_user)
+	          VALUES ($1, $2, $3, $2, $3) RETURNING sec_privileges_id`
+
+	now := time.Now()
+	priv := &models.SecPrivilege{
+		Name:       name,
+		CreateDate: now,
+		CreateUser: secModifyUser(createUser),
+		ModifyDate: now,
+		ModifyUser: secModifyUser(createUser),
+	}
+
+	err := r.db.QueryRowContext(ctx, query, priv.Name, now, priv.CreateUser).Scan(&priv.ID)
+	if isUniqueViolation(err, "uk_sec_privileges_name") {
+		return nil, ErrDuplicatePrivilegeName
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return priv, nil
+}
+
+// DeletePrivilege deletes a privilege and removes it from every role.
+// Returns false if the privilege does not exist.
+func (r *RoleRepository) DeletePrivilege(ctx context.Context, id int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sec_role_privs WHERE sec_privileges_id = $1`, id); err != nil {
+		return false, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM sec_privileges WHERE sec_privileges_id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	return true, tx.Commit()
+}
+
+// ListRolePrivileges returns the privileges granted to a role
+func (r *RoleRepository) ListRolePrivileges(ctx context.Context, roleID int) ([]models.SecPrivilege, error) {
+	query := `SELECT p.sec_privileges_id, p.name, p.create_date, p.create_user, p.modify_date, p.modify_user
+	          FROM sec_privileges p
+	          JOIN sec_role_privs rp ON rp.sec_privileges_id = p.sec_privileges_id
+	          WHERE rp.sec_roles_id = $1
+	          ORDER BY p.name`
+
+	rows, err := r.db.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPrivileges(rows)
+}
+
+// GrantPrivilege grants a privilege to a role. Granting one the role already has is a no-op.
+func (r *RoleRepository) GrantPrivilege(ctx context.Context, roleID, privilegeID int, createUser string) error {
+	query := `INSERT INTO sec_role_privs (sec_privileges_id, sec_roles_id, create_date, create_user, modify_date, modify_user)
+	          VALUES ($1, $2, NOW(), $3, NOW(), $3)
+	          ON CONFLICT (sec_roles_id, sec_privileges_id) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, privilegeID, roleID, secModifyUser(createUser))
+	return err
+}
+
+// RevokePrivilege removes a privilege from a role. Returns false if the role did not have it.
+func (r *RoleRepository) RevokePrivilege(ctx context.Context, roleID, privilegeID int) (bool, error) {
+	query := `DELETE FROM sec_role_privs WHERE sec_roles_id = $1 AND sec_privileges_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, roleID, privilegeID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// ListAccountRoles returns the roles assigned to an account
+func (r *RoleRepository) ListAccountRoles(ctx context.Context, accountID int) ([]models.SecRole, error) {
+	query := `SELECT ro.sec_roles_id, ro.name, ro.descr, ro.require_mfa, ro.create_date, ro.create_user, ro.modify_date, ro.modify_user
+	          FROM sec_roles ro
+	          JOIN sec_acct_roles ar ON ar.sec_roles_id = ro.sec_roles_id
+	          WHERE ar.sec_accounts_id = $1
+	          ORDER BY ro.name`
+
+	rows, err := r.db.QueryContext(ctx, query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRoles(rows)
+}
+
+// AssignRole gives an account a role. Assigning one the account already has is a no-op.
+func (r *RoleRepository) AssignRole(ctx context.Context, accountID, roleID int, createUser string) error {
+	query := `INSERT INTO sec_acct_roles (sec_roles_id, sec_accounts_id, create_date, create_user, modify_date, modify_user)
+	          VALUES ($1, $2, NOW(), $3, NOW(), $3)
+	          ON CONFLICT (sec_accounts_id, sec_roles_id) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, roleID, accountID, secModifyUser(createUser))
+	return err
+}
+
+// AssignRoleByName gives the named account the named role.
+// Returns false if either does not exist.
+func (r *RoleRepository) AssignRoleByName(ctx context.Context, accountName, roleName, createUser string) (bool, error) {
+	query := `INSERT INTO sec_acct_roles (sec_roles_id, sec_accounts_id, create_date, create_user, modify_date, modify_user)
+	          SELECT ro.sec_roles_id, a.sec_accounts_id, NOW(), $3, NOW(), $3
+	          FROM sec_roles ro, sec_accounts a
+	          WHERE ro.name = $2 AND a.name = $1
+	          ON CONFLICT (sec_accounts_id, sec_roles_id) DO NOTHING`
+	if _, err := r.db.ExecContext(ctx, query, accountName, roleName, secModifyUser(createUser)); err != nil {
+		return false, err
+	}
+
+	var assigned bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (
+	              SELECT 1 FROM sec_acct_roles ar
+	              JOIN sec_roles ro ON ro.sec_roles_id = ar.sec_roles_id
+	              JOIN sec_accounts a ON a.sec_accounts_id = ar.sec_accounts_id
+	              WHERE a.name = $1 AND ro.name = $2)`, accountName, roleName).Scan(&assigned)
+	return assigned, err
+}
+
+// RemoveRole takes a role away from an account. Returns false if the account did not have it.
+func (r *RoleRepository) RemoveRole(ctx context.Context, accountID, roleID int) (bool, error) {
+	query := `DELETE FROM sec_acct_roles WHERE sec_accounts_id = $1 AND sec_roles_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, accountID, roleID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// CountRoleMembers returns how many accounts hold a role
+func (r *RoleRepository) CountRoleMembers(ctx context.Context, roleID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sec_acct_roles WHERE sec_roles_id = $1`, roleID).Scan(&count)
+	return count, err
+}
+
+// CountRoleMembersExcludingUser returns how many accounts hold the named role, ignoring a user's own accounts
+func (r *RoleRepository) CountRoleMembersExcludingUser(ctx context.Context, roleName string, userID int) (int, error) {
+	query := `SELECT COUNT(*) FROM sec_acct_roles ar
+	          JOIN sec_roles ro ON ro.sec_roles_id = ar.sec_roles_id
+	          JOIN sec_accounts a ON a.sec_accounts_id = ar.sec_accounts_id
+	          WHERE ro.name = $1 AND a.sec_users_id <> $2`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, roleName, userID).Scan(&count)
+	return count, err
+}
+
+// UserHasRole reports whether any of a user's accounts holds the named role
+func (r *RoleRepository) UserHasRole(ctx context.Context, userID int, roleName string) (bool, error) {
+	query := `SELECT EXISTS (
+	              SELECT 1 FROM sec_acct_roles ar
+	              JOIN sec_roles ro ON ro.sec_roles_id = ar.sec_roles_id
+	              JOIN sec_accounts a ON a.sec_accounts_id = ar.sec_accounts_id
+	              WHERE ro.name = $1 AND a.sec_users_id = $2
+	          )`
+
+	var has bool
+	err := r.db.QueryRowContext(ctx, query, roleName, userID).Scan(&has)
+	return has, err
+}
+
+// ListAccountPrivileges returns the names of every privilege an account holds through its roles
+func (r *RoleRepository) ListAccountPrivileges(ctx context.Context, accountID int) ([]string, error) {
+	query := `SELECT DISTINCT p.name
+	          FROM sec_acct_roles ar
+	          JOIN sec_role_privs rp ON rp.sec_roles_id = ar.sec_roles_id
+	          JOIN sec_privileges p ON p.sec_privileges_id = rp.sec_privileges_id
+	          WHERE ar.sec_accounts_id = $1`
+
+	rows, err := r.db.QueryContext(ctx, query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var privileges []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		privileges = append(privileges, name)
+	}
+
+	return privileges, rows.Err()
+}
+
+// scanRoles reads role rows
+func scanRoles(rows *sql.Rows) ([]models.SecRole, error) {
+	var roles []models.SecRole
+	for rows.Next() {
+		var role models.SecRole
+		if err := rows.Scan(
+			&role.ID,
+			&role.Name,
+			&role.Description,
+			&role.RequireMFA,
+			&role.CreateDate,
+			&role.CreateUser,
+			&role.ModifyDate,
+			&role.ModifyUser,
+		); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// scanPrivileges reads privilege rows
+func scanPrivileges(rows *sql.Rows) ([]models.SecPrivilege, error) {
+	var privileges []models.SecPrivilege
+	for rows.Next() {
+		var priv models.SecPrivilege
+		if err := rows.Scan(
+			&priv.ID,
+			&priv.Name,
+			&priv.CreateDate,
+			&priv.CreateUser,
+			&priv.ModifyDate,
+			&priv.ModifyUser,
+		); err != nil {
+			return nil, err
+		}
+		privileges = append(privileges, priv)
+	}
+
+	return privileges, rows.Err()
+}
@@ -0,0 +1,407 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// rowSecurityRole is the role request transactions switch to. It does not own the
+// tables, so PostgreSQL enforces their row-level security policies for it even when
+// the server connects as the table owner or a superuser.
+const rowSecurityRole = "crm_app"
+
+// errNestedRequestTx is returned by BeginTx inside a request transaction. A second
+// transaction would run on another connection without row security.
+var errNestedRequestTx = errors.New("cannot begin a transaction inside a row security request transaction")
+
+// requestTxKey is the context key for the request transaction
+type requestTxKey struct{}
+
+// requestTx returns the request transaction carried by ctx, if any
+func requestTx(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(requestTxKey{}).(*sql.Tx)
+	return tx
+}
+
+// BeginRequest starts a transaction with row security applied for a user. Queries made
+// through the DB with the returned context run in it; the caller commits or rolls back.
+func (db *DB) BeginRequest(ctx context.Context, userID int) (context.Context, *sql.Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ctx, nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "SET LOCAL ROLE "+rowSecurityRole); err != nil {
+		_ = tx.Rollback()
+		return ctx, nil, fmt.Errorf("failed to switch to role %s: %w", rowSecurityRole, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL app.user_id = '%d'", userID)); err != nil {
+		_ = tx.Rollback()
+		return ctx, nil, fmt.Errorf("failed to set app.user_id: %w", err)
+	}
+	return context.WithValue(ctx, requestTxKey{}, tx), tx, nil
+}
+
+// QueryContext runs a query in the request transaction carried by ctx, if any
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if tx := requestTx(ctx); tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+	return db.DB.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a single-row query in the request transaction carried by ctx, if any
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if tx := requestTx(ctx); tx != nil {
+		return tx.QueryRowContext(ctx, query, args...)
+	}
+	return db.DB.QueryRowContext(ctx, query, args...)
+}
+
+// ExecContext runs a statement in the request transaction carried by ctx, if any
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if tx := requestTx(ctx); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+// BeginTx starts a transaction. It refuses inside a request transaction.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if requestTx(ctx) != nil {
+		return nil, errNestedRequestTx
+	}
+	return db.DB.BeginTx(ctx, opts)
+}
+
+// InTx runs fn so that its statements take effect together or not at all. Inside a
+// request transaction fn runs under a savepoint; otherwise it gets a transaction of its
+// own, carried by the context passed to fn.
+func (db *DB) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx := requestTx(ctx); tx != nil {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT in_tx"); err != nil {
+			return err
+		}
+		if err := fn(ctx); err != nil {
+			if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT in_tx"); rbErr != nil {
+				return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+			}
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT in_tx")
+		return err
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, requestTxKey{}, tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// personChildTables are the pdat_* tables whose rows belong to a person and inherit
+// its visibility
+var personChildTables = []string{
+	"pdat_address",
+	"pdat_pers_emails",
+	"pdat_pers_phone",
+	"pdat_pers_notes",
+	"pdat_cal_pers",
+	"pdat_person_custom_value",
+}
+
+// ownedTables are the pdat_* tables whose rows belong only to the user in sec_users_id
+var ownedTables = []string{
+	"pdat_calendar",
+	"pdat_passwd",
+}
+
+// lookupTables are the pdat_* tables every user can read but only the creating user can change
+var lookupTables = []string{
+	"pdat_email_types",
+	"pdat_phone_type",
+}
+
+// personViews read pdat_* tables and must check row security as the querying role
+var personViews = []string{
+	"v_active_people",
+	"v_person_emails",
+	"v_person_phones",
+	"v_person_addresses",
+	"v_person_notes",
+	"v_person_links",
+	"v_active_companies",
+	"v_company_addresses",
+	"v_company_phones",
+	"v_person_employment",
+}
+
+// companyChildTables are the pdat_* tables whose rows belong to a company and inherit
+// its visibility
+var companyChildTables = []string{
+	"pdat_company_address",
+	"pdat_company_phone",
+}
+
+// rowSecurityStatements returns the idempotent DDL for the row security role, its
+// helper functions and the policies on every pdat_* table. The policies mirror the
+// application's rules: a person is visible to its owner, to members of its organization
+// unless it is private, and to users it is shared with; viewers and read-only shares
+// cannot change it. A company is visible to members of its organization and to anyone
+// who can see one of its employees. They apply only while app.user_id is set, i.e.
+// inside BeginRequest.
+func rowSecurityStatements() []string {
+	stmts := []string{
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = '` + rowSecurityRole + `') THEN
+				CREATE ROLE ` + rowSecurityRole + ` NOLOGIN;
+			END IF;
+			IF NOT pg_has_role(current_user, '` + rowSecurityRole + `', 'MEMBER') THEN
+				EXECUTE 'GRANT ` + rowSecurityRole + ` TO ' || quote_ident(current_user);
+			END IF;
+		END
+		$$`,
+		`GRANT USAGE ON SCHEMA public TO ` + rowSecurityRole,
+		`GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO ` + rowSecurityRole,
+		`GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO ` + rowSecurityRole,
+
+		// The user a request runs as, or NULL outside a request transaction
+		`CREATE OR REPLACE FUNCTION app_user_id() RETURNS integer
+		 LANGUAGE sql STABLE
+		 AS $$ SELECT NULLIF(current_setting('app.user_id', true), '')::integer $$`,
+
+		// The helpers below are SECURITY DEFINER so their lookups are not themselves
+		// filtered, which would make the person and share policies recurse.
+		`CREATE OR REPLACE FUNCTION app_person_access(p_owner integer, p_org integer, p_private character, p_person integer, p_edit boolean)
+		 RETURNS boolean
+		 LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public
+		 AS $$
+			SELECT p_owner = app_user_id()
+			    OR (p_private = 'N' AND EXISTS (
+			        SELECT 1 FROM sec_org_members m
+			        WHERE m.sec_orgs_id = p_org AND m.sec_users_id = app_user_id()
+			          AND (NOT p_edit OR m.role <> 'viewer')))
+			    OR EXISTS (
+			        SELECT 1 FROM pdat_person_shares s
+			        WHERE s.pdat_person_id = p_person AND s.sec_users_id = app_user_id()
+			          AND (NOT p_edit OR s.permission = 'edit'))
+		 $$`,
+		`CREATE OR REPLACE FUNCTION app_can_access_person(p_person integer, p_edit boolean)
+		 RETURNS boolean
+		 LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public
+		 AS $$
+			SELECT COALESCE((
+			    SELECT app_person_access(p.sec_users_id, p.sec_orgs_id, p.private_flag, p.pdat_person_id, p_edit)
+			    FROM pdat_person p WHERE p.pdat_person_id = p_person), FALSE)
+		 $$`,
+		`CREATE OR REPLACE FUNCTION app_owns_person(p_person integer)
+		 RETURNS boolean
+		 LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public
+		 AS $$
+			SELECT EXISTS (
+			    SELECT 1 FROM pdat_person p
+			    JOIN sec_org_members m ON m.sec_orgs_id = p.sec_orgs_id AND m.sec_users_id = p.sec_users_id
+			    WHERE p.pdat_person_id = p_person AND p.sec_users_id = app_user_id() AND m.role <> 'viewer')
+		 $$`,
+		`CREATE OR REPLACE FUNCTION app_org_writer(p_org integer)
+		 RETURNS boolean
+		 LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public
+		 AS $$
+			SELECT EXISTS (
+			    SELECT 1 FROM sec_org_members
+			    WHERE sec_orgs_id = p_org AND sec_users_id = app_user_id() AND role <> 'viewer')
+		 $$`,
+
+		`CREATE OR REPLACE FUNCTION app_org_member(p_org integer)
+		 RETURNS boolean
+		 LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public
+		 AS $$
+			SELECT EXISTS (
+			    SELECT 1 FROM sec_org_members
+			    WHERE sec_orgs_id = p_org AND sec_users_id = app_user_id())
+		 $$`,
+		`CREATE OR REPLACE FUNCTION app_company_has_visible_person(p_company integer)
+		 RETURNS boolean
+		 LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public
+		 AS $$
+			SELECT EXISTS (
+			    SELECT 1 FROM pdat_employment e
+			    WHERE e.pdat_company_id = p_company AND app_can_access_person(e.pdat_person_id, false))
+		 $$`,
+		`CREATE OR REPLACE FUNCTION app_can_access_company(p_company integer, p_edit boolean)
+		 RETURNS boolean
+		 LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public
+		 AS $$
+			SELECT COALESCE((
+			    SELECT CASE WHEN p_edit THEN app_org_writer(c.sec_orgs_id)
+			                ELSE app_org_member(c.sec_orgs_id) OR app_company_has_visible_person(c.pdat_company_id) END
+			    FROM pdat_company c WHERE c.pdat_company_id = p_company), FALSE)
+		 $$`,
+
+		// People: owners, organization members and share recipients. Shares never allow deleting.
+		`ALTER TABLE pdat_person ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS person_select ON pdat_person`,
+		`CREATE POLICY person_select ON pdat_person FOR SELECT
+		 USING (app_person_access(sec_users_id, sec_orgs_id, private_flag, pdat_person_id, false))`,
+		`DROP POLICY IF EXISTS person_insert ON pdat_person`,
+		`CREATE POLICY person_insert ON pdat_person FOR INSERT
+		 WITH CHECK (sec_users_id = app_user_id() AND (sec_orgs_id IS NULL OR app_org_writer(sec_orgs_id)))`,
+		`DROP POLICY IF EXISTS person_update ON pdat_person`,
+		`CREATE POLICY person_update ON pdat_person FOR UPDATE
+		 USING (app_person_access(sec_users_id, sec_orgs_id, private_flag, pdat_person_id, true))
+		 WITH CHECK (app_person_access(sec_users_id, sec_orgs_id, private_flag, pdat_person_id, true))`,
+		`DROP POLICY IF EXISTS person_delete ON pdat_person`,
+		`CREATE POLICY person_delete ON pdat_person FOR DELETE
+		 USING (app_person_access(sec_users_id, sec_orgs_id, private_flag, NULL, true))`,
+
+		// Links follow their person; links without one belong to their creator
+		`ALTER TABLE pdat_links ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS links_select ON pdat_links`,
+		`CREATE POLICY links_select ON pdat_links FOR SELECT
+		 USING (app_can_access_person(pdat_person_id, false) OR (pdat_person_id IS NULL AND sec_users_id = app_user_id()))`,
+		`DROP POLICY IF EXISTS links_write ON pdat_links`,
+		`CREATE POLICY links_write ON pdat_links FOR ALL
+		 USING (app_can_access_person(pdat_person_id, true) OR (pdat_person_id IS NULL AND sec_users_id = app_user_id()))
+		 WITH CHECK (app_can_access_person(pdat_person_id, true) OR (pdat_person_id IS NULL AND sec_users_id = app_user_id()))`,
+
+		// Companies: organization members, plus read access for anyone who can see an employee
+		`ALTER TABLE pdat_company ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS company_select ON pdat_company`,
+		`CREATE POLICY company_select ON pdat_company FOR SELECT
+		 USING (app_org_member(sec_orgs_id) OR app_company_has_visible_person(pdat_company_id))`,
+		`DROP POLICY IF EXISTS company_write ON pdat_company`,
+		`CREATE POLICY company_write ON pdat_company FOR ALL
+		 USING (app_org_writer(sec_orgs_id))
+		 WITH CHECK (app_org_writer(sec_orgs_id))`,
+
+		// Employment follows the person; recording one also needs sight of the company
+		`ALTER TABLE pdat_employment ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS employment_select ON pdat_employment`,
+		`CREATE POLICY employment_select ON pdat_employment FOR SELECT
+		 USING (app_can_access_person(pdat_person_id, false))`,
+		`DROP POLICY IF EXISTS employment_write ON pdat_employment`,
+		`CREATE POLICY employment_write ON pdat_employment FOR ALL
+		 USING (app_can_access_person(pdat_person_id, true))
+		 WITH CHECK (app_can_access_person(pdat_person_id, true) AND app_can_access_company(pdat_company_id, false))`,
+
+		// Relationships need sight of both people; changing one needs edit access to the first
+		`ALTER TABLE pdat_person_relationship ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS relationship_select ON pdat_person_relationship`,
+		`CREATE POLICY relationship_select ON pdat_person_relationship FOR SELECT
+		 USING (app_can_access_person(pdat_person_id, false) AND app_can_access_person(related_person_id, false))`,
+		`DROP POLICY IF EXISTS relationship_write ON pdat_person_relationship`,
+		`CREATE POLICY relationship_write ON pdat_person_relationship FOR ALL
+		 USING (app_can_access_person(pdat_person_id, true) AND app_can_access_person(related_person_id, false))
+		 WITH CHECK (app_can_access_person(pdat_person_id, true) AND app_can_access_person(related_person_id, false))`,
+
+		// Tags belong to an organization; tagging a person also needs edit access to them
+		`ALTER TABLE pdat_tag ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS tag_select ON pdat_tag`,
+		`CREATE POLICY tag_select ON pdat_tag FOR SELECT
+		 USING (app_org_member(sec_orgs_id))`,
+		`DROP POLICY IF EXISTS tag_write ON pdat_tag`,
+		`CREATE POLICY tag_write ON pdat_tag FOR ALL
+		 USING (app_org_writer(sec_orgs_id))
+		 WITH CHECK (app_org_writer(sec_orgs_id))`,
+		`ALTER TABLE pdat_person_tag ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS person_tag_select ON pdat_person_tag`,
+		`CREATE POLICY person_tag_select ON pdat_person_tag FOR SELECT
+		 USING (app_can_access_person(pdat_person_id, false))`,
+		`DROP POLICY IF EXISTS person_tag_write ON pdat_person_tag`,
+		`CREATE POLICY person_tag_write ON pdat_person_tag FOR ALL
+		 USING (app_can_access_person(pdat_person_id, true))
+		 WITH CHECK (app_can_access_person(pdat_person_id, true))`,
+
+		// Custom field definitions belong to an organization like tags
+		`ALTER TABLE pdat_custom_field ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS custom_field_select ON pdat_custom_field`,
+		`CREATE POLICY custom_field_select ON pdat_custom_field FOR SELECT
+		 USING (app_org_member(sec_orgs_id))`,
+		`DROP POLICY IF EXISTS custom_field_write ON pdat_custom_field`,
+		`CREATE POLICY custom_field_write ON pdat_custom_field FOR ALL
+		 USING (app_org_writer(sec_orgs_id))
+		 WITH CHECK (app_org_writer(sec_orgs_id))`,
+
+		// Merge records need sight of both people; recording or undoing one needs edit access
+		`ALTER TABLE pdat_person_merge ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS person_merge_select ON pdat_person_merge`,
+		`CREATE POLICY person_merge_select ON pdat_person_merge FOR SELECT
+		 USING (app_can_access_person(survivor_id, false) AND app_can_access_person(merged_id, false))`,
+		`DROP POLICY IF EXISTS person_merge_write ON pdat_person_merge`,
+		`CREATE POLICY person_merge_write ON pdat_person_merge FOR ALL
+		 USING (app_can_access_person(survivor_id, true) AND app_can_access_person(merged_id, true))
+		 WITH CHECK (app_can_access_person(survivor_id, true) AND app_can_access_person(merged_id, true))`,
+
+		// Shares are visible to the owner and the recipient; only the owner changes them,
+		// while they can still edit the person's organization
+		`ALTER TABLE pdat_person_shares ENABLE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS shares_select ON pdat_person_shares`,
+		`CREATE POLICY shares_select ON pdat_person_shares FOR SELECT
+		 USING (sec_users_id = app_user_id() OR app_owns_person(pdat_person_id))`,
+		`DROP POLICY IF EXISTS shares_write ON pdat_person_shares`,
+		`CREATE POLICY shares_write ON pdat_person_shares FOR ALL
+		 USING (app_owns_person(pdat_person_id))
+		 WITH CHECK (app_owns_person(pdat_person_id))`,
+	}
+
+	for _, table := range personChildTables {
+		stmts = append(stmts,
+			fmt.Sprintf(`ALTER TABLE %s ENABLE ROW LEVEL SECURITY`, table),
+			fmt.Sprintf(`DROP POLICY IF EXISTS person_child_select ON %s`, table),
+			fmt.Sprintf(`CREATE POLICY person_child_select ON %s FOR SELECT
+			 USING (app_can_access_person(pdat_person_id, false))`, table),
+			fmt.Sprintf(`DROP POLICY IF EXISTS person_child_write ON %s`, table),
+			fmt.Sprintf(`CREATE POLICY person_child_write ON %s FOR ALL
+			 USING (app_can_access_person(pdat_person_id, true))
+			 WITH CHECK (app_can_access_person(pdat_person_id, true))`, table),
+		)
+	}
+
+	for _, table := range companyChildTables {
+		stmts = append(stmts,
+			fmt.Sprintf(`ALTER TABLE %s ENABLE ROW LEVEL SECURITY`, table),
+			fmt.Sprintf(`DROP POLICY IF EXISTS company_child_select ON %s`, table),
+			fmt.Sprintf(`CREATE POLICY company_child_select ON %s FOR SELECT
+			 USING (app_can_access_company(pdat_company_id, false))`, table),
+			fmt.Sprintf(`DROP POLICY IF EXISTS company_child_write ON %s`, table),
+			fmt.Sprintf(`CREATE POLICY company_child_write ON %s FOR ALL
+			 USING (app_can_access_company(pdat_company_id, true))
+			 WITH CHECK (app_can_access_company(pdat_company_id, true))`, table),
+		)
+	}
+
+	for _, table := range ownedTables {
+		stmts = append(stmts,
+			fmt.Sprintf(`ALTER TABLE %s ENABLE ROW LEVEL SECURITY`, table),
+			fmt.Sprintf(`DROP POLICY IF EXISTS owner_all ON %s`, table),
+			fmt.Sprintf(`CREATE POLICY owner_all ON %s FOR ALL
+			 USING (sec_users_id = app_user_id())
+			 WITH CHECK (sec_users_id = app_user_id())`, table),
+		)
+	}
+
+	for _, table := range lookupTables {
+		stmts = append(stmts,
+			fmt.Sprintf(`ALTER TABLE %s ENABLE ROW LEVEL SECURITY`, table),
+			fmt.Sprintf(`DROP POLICY IF EXISTS lookup_select ON %s`, table),
+			fmt.Sprintf(`CREATE POLICY lookup_select ON %s FOR SELECT USING (true)`, table),
+			fmt.Sprintf(`DROP POLICY IF EXISTS owner_all ON %s`, table),
+			fmt.Sprintf(`CREATE POLICY owner_all ON %s FOR ALL
+			 USING (sec_users_id = app_user_id())
+			 WITH CHECK (sec_users_id = app_user_id())`, table),
+		)
+	}
+
+	// CREATE OR REPLACE VIEW in schemaStatements resets view options, so this runs after it
+	for _, view := range personViews {
+		stmts = append(stmts, fmt.Sprintf(`ALTER VIEW %s SET (security_invoker = true)`, view))
+	}
+
+	return stmts
+}
@@ -0,0 +1,493 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// schemaStatements holds idempotent DDL for schema added after the original
+// database dump (db/init_magnifimind_crm.sql). They run in order at startup
+// so existing databases pick up new tables and columns without a manual step.
+var schemaStatements = []string{
+	// Email address for self-service registration
+	`ALTER TABLE sec_users ADD COLUMN IF NOT EXISTS email character varying(254)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uk_sec_users_email ON sec_users (LOWER(email)) WHERE email IS NOT NULL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uk_sec_accounts_name ON sec_accounts (name)`,
+
+	// Email verification: accounts that predate verification are treated as verified,
+	// new accounts start unverified
+	`ALTER TABLE sec_accounts ADD COLUMN IF NOT EXISTS email_verified boolean NOT NULL DEFAULT TRUE`,
+	`ALTER TABLE sec_accounts ALTER COLUMN email_verified SET DEFAULT FALSE`,
+
+	// Single-use, expiring tokens emailed to users (verification, password reset, ...)
+	`CREATE TABLE IF NOT EXISTS sec_user_tokens (
+		sec_user_tokens_id SERIAL PRIMARY KEY,
+		sec_users_id integer NOT NULL REFERENCES sec_users(sec_users_id) ON DELETE CASCADE,
+		purpose character varying(30) NOT NULL,
+		token_hash character(64) NOT NULL UNIQUE,
+		email character varying(254),
+		expires_at timestamp with time zone NOT NULL,
+		used_at timestamp with time zone,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_sec_user_tokens_user_purpose ON sec_user_tokens (sec_users_id, purpose)`,
+
+	// Rotating refresh tokens, stored hashed. family_id groups every token descended from one login.
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id integer NOT NULL REFERENCES sec_users(sec_users_id) ON DELETE CASCADE,
+		sec_accounts_id integer NOT NULL REFERENCES sec_accounts(sec_accounts_id) ON DELETE CASCADE,
+		token_hash character(64) NOT NULL UNIQUE,
+		family_id uuid NOT NULL,
+		expires_at timestamp with time zone NOT NULL,
+		revoked boolean NOT NULL DEFAULT FALSE,
+		rotated_at timestamp with time zone,
+		created_at timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_refresh_tokens_family ON refresh_tokens (family_id)`,
+	`CREATE INDEX IF NOT EXISTS ix_refresh_tokens_user ON refresh_tokens (user_id)`,
+
+	// Access-token revocation list (by jti) and per-user "log out everywhere" cutoff
+	`CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+		jti character varying(64) PRIMARY KEY,
+		sec_users_id integer NOT NULL,
+		expires_at timestamp with time zone NOT NULL,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_revoked_access_tokens_expires ON revoked_access_tokens (expires_at)`,
+	`ALTER TABLE sec_users ADD COLUMN IF NOT EXISTS tokens_valid_after timestamp with time zone`,
+
+	// Security audit log
+	`CREATE TABLE IF NOT EXISTS sec_audit_log (
+		sec_audit_log_id SERIAL PRIMARY KEY,
+		sec_users_id integer,
+		sec_accounts_id integer,
+		event character varying(50) NOT NULL,
+		detail text,
+		ip_address character varying(64),
+		user_agent text,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_sec_audit_log_user ON sec_audit_log (sec_users_id, create_date)`,
+
+	// TOTP two-factor authentication
+	`CREATE TABLE IF NOT EXISTS sec_account_mfa (
+		sec_accounts_id integer PRIMARY KEY REFERENCES sec_accounts(sec_accounts_id) ON DELETE CASCADE,
+		totp_secret character varying(64) NOT NULL,
+		enabled boolean NOT NULL DEFAULT FALSE,
+		confirmed_at timestamp with time zone,
+		last_used_step bigint,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS sec_mfa_recovery_codes (
+		sec_mfa_recovery_codes_id SERIAL PRIMARY KEY,
+		sec_accounts_id integer NOT NULL REFERENCES sec_accounts(sec_accounts_id) ON DELETE CASCADE,
+		code_hash character(64) NOT NULL,
+		used_at timestamp with time zone,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_sec_mfa_recovery_codes_account ON sec_mfa_recovery_codes (sec_accounts_id)`,
+	`CREATE TABLE IF NOT EXISTS sec_mfa_challenges (
+		sec_mfa_challenges_id SERIAL PRIMARY KEY,
+		token_hash character(64) NOT NULL UNIQUE,
+		sec_accounts_id integer NOT NULL REFERENCES sec_accounts(sec_accounts_id) ON DELETE CASCADE,
+		expires_at timestamp with time zone NOT NULL,
+		attempts integer NOT NULL DEFAULT 0,
+		used_at timestamp with time zone,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`ALTER TABLE sec_roles ADD COLUMN IF NOT EXISTS require_mfa boolean NOT NULL DEFAULT FALSE`,
+
+	// Role-based access control. The original dump has no keys on sec_roles or
+	// the assignment tables, and its sequences were never advanced past the loaded rows.
+	`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'pk_sec_roles') THEN
+			ALTER TABLE sec_roles ADD CONSTRAINT pk_sec_roles PRIMARY KEY (sec_roles_id);
+		END IF;
+	END $$`,
+	`ALTER TABLE sec_roles ADD COLUMN IF NOT EXISTS descr character varying(255)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uk_sec_roles_name ON sec_roles (name)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uk_sec_privileges_name ON sec_privileges (name)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uk_sec_acct_roles ON sec_acct_roles (sec_accounts_id, sec_roles_id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uk_sec_role_privs ON sec_role_privs (sec_roles_id, sec_privileges_id)`,
+	`SELECT setval('sec_roles_sec_roles_id_seq', GREATEST(
+		(SELECT COALESCE(MAX(sec_roles_id), 0) FROM sec_roles),
+		(SELECT last_value FROM sec_roles_sec_roles_id_seq)))`,
+	`SELECT setval('sec_privilege_sec_privilege_seq', GREATEST(
+		(SELECT COALESCE(MAX(sec_privileges_id), 0) FROM sec_privileges),
+		(SELECT last_value FROM sec_privilege_sec_privilege_seq)))`,
+
+	// Personal access tokens for scripting against the API
+	`CREATE TABLE IF NOT EXISTS sec_access_tokens (
+		sec_access_tokens_id SERIAL PRIMARY KEY,
+		sec_accounts_id integer NOT NULL REFERENCES sec_accounts(sec_accounts_id) ON DELETE CASCADE,
+		name character varying(100) NOT NULL,
+		token_prefix character varying(16) NOT NULL,
+		token_hash character(64) NOT NULL UNIQUE,
+		scopes text[] NOT NULL,
+		expires_at timestamp with time zone,
+		last_used_at timestamp with time zone,
+		revoked_at timestamp with time zone,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_sec_access_tokens_account ON sec_access_tokens (sec_accounts_id)`,
+
+	// Failed login tracking for backoff and account lockout. throttle_key is the
+	// sec_accounts_id for scope 'account' and the client IP for scope 'ip'.
+	`CREATE TABLE IF NOT EXISTS sec_login_throttle (
+		scope character varying(10) NOT NULL,
+		throttle_key character varying(64) NOT NULL,
+		failures integer NOT NULL DEFAULT 0,
+		last_failure_at timestamp with time zone NOT NULL DEFAULT NOW(),
+		blocked_until timestamp with time zone,
+		locked boolean NOT NULL DEFAULT FALSE,
+		PRIMARY KEY (scope, throttle_key)
+	)`,
+
+	// OpenID Connect single sign-on: pending logins and the IdP subjects linked to accounts
+	`CREATE TABLE IF NOT EXISTS sec_oidc_states (
+		sec_oidc_states_id SERIAL PRIMARY KEY,
+		state_hash character(64) NOT NULL UNIQUE,
+		code_verifier character varying(128) NOT NULL,
+		nonce character varying(128) NOT NULL,
+		expires_at timestamp with time zone NOT NULL,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS sec_account_identities (
+		sec_account_identities_id SERIAL PRIMARY KEY,
+		sec_accounts_id integer NOT NULL REFERENCES sec_accounts(sec_accounts_id) ON DELETE CASCADE,
+		issuer character varying(255) NOT NULL,
+		subject character varying(255) NOT NULL,
+		email character varying(254),
+		last_login_at timestamp with time zone,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW(),
+		UNIQUE (issuer, subject)
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_sec_account_identities_account ON sec_account_identities (sec_accounts_id)`,
+
+	// Where each login session (refresh token family) was started and last used, and
+	// sessions ended from the session list whose access tokens have not yet expired
+	`CREATE TABLE IF NOT EXISTS sec_sessions (
+		family_id uuid PRIMARY KEY,
+		sec_users_id integer NOT NULL REFERENCES sec_users(sec_users_id) ON DELETE CASCADE,
+		sec_accounts_id integer NOT NULL REFERENCES sec_accounts(sec_accounts_id) ON DELETE CASCADE,
+		user_agent text,
+		ip_address character varying(64),
+		created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+		last_seen_at timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_sec_sessions_user ON sec_sessions (sec_users_id)`,
+	`CREATE TABLE IF NOT EXISTS revoked_sessions (
+		family_id uuid PRIMARY KEY,
+		sec_users_id integer NOT NULL,
+		expires_at timestamp with time zone NOT NULL,
+		create_date timestamp with time zone NOT NULL DEFAULT NOW()
+	)`,
+
+	// Organizations own contacts and share them between their members. Every user has a
+	// personal organization (personal_user_id) that nobody else can join.
+	`CREATE TABLE IF NOT EXISTS sec_orgs (
+		sec_orgs_id SERIAL PRIMARY KEY,
+		name character varying(100) NOT NULL,
+		personal_user_id integer UNIQUE REFERENCES sec_users(sec_users_id),
+		create_date timestamp with time zone NOT NULL DEFAULT NOW(),
+		create_user character varying(30),
+		modify_date timestamp with time zone NOT NULL DEFAULT NOW(),
+		modify_user character varying(30)
+	)`,
+	`CREATE TABLE IF NOT EXISTS sec_org_members (
+		sec_orgs_id integer NOT NULL REFERENCES sec_orgs(sec_orgs_id) ON DELETE CASCADE,
+		sec_users_id integer NOT NULL REFERENCES sec_users(sec_users_id) ON DELETE CASCADE,
+		role character varying(10) NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+		create_date timestamp with time zone NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (sec_orgs_id, sec_users_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_sec_org_members_user ON sec_org_members (sec_users_id)`,
+
+	// People belong to an organization; private people are only visible to the member who owns them
+	`ALTER TABLE pdat_person ADD COLUMN IF NOT EXISTS sec_orgs_id integer REFERENCES sec_orgs(sec_orgs_id)`,
+	`ALTER TABLE pdat_person ADD COLUMN IF NOT EXISTS private_flag character(1) NOT NULL DEFAULT 'N'`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_person_org ON pdat_person (sec_orgs_id)`,
+
+	// Give every user a personal organization and move people that predate organizations into it
+	`INSERT INTO sec_orgs (name, personal_user_id, create_user, modify_user)
+	 SELECT 'Personal', sec_users_id, 'system', 'system' FROM sec_users
+	 ON CONFLICT (personal_user_id) DO NOTHING`,
+	`INSERT INTO sec_org_members (sec_orgs_id, sec_users_id, role)
+	 SELECT sec_orgs_id, personal_user_id, 'owner' FROM sec_orgs WHERE personal_user_id IS NOT NULL
+	 ON CONFLICT (sec_orgs_id, sec_users_id) DO NOTHING`,
+	`UPDATE pdat_person p SET sec_orgs_id = o.sec_orgs_id
+	 FROM sec_orgs o WHERE p.sec_orgs_id IS NULL AND o.personal_user_id = p.sec_users_id`,
+
+	// The v_* views carry the person's organization and privacy flag for tenant filtering.
+	// New columns go last: CREATE OR REPLACE VIEW can only append columns.
+	`CREATE OR REPLACE VIEW v_person_emails AS
+	 SELECT pe.pdat_pers_emails_id, pe.email_addr, pe.pdat_person_id, pe.pdat_email_types_id,
+	        et.name AS email_type_name, p.fname AS person_fname, p.lname AS person_lname,
+	        p.fname || ' ' || p.lname AS person_full_name, p.sec_users_id,
+	        pe.create_date, pe.create_user, pe.modify_date, pe.modify_user, pe.active_flag,
+	        p.sec_orgs_id, p.private_flag
+	 FROM pdat_pers_emails pe
+	 JOIN pdat_person p ON pe.pdat_person_id = p.pdat_person_id
+	 JOIN pdat_email_types et ON pe.pdat_email_types_id = et.pdat_email_types_id
+	 WHERE pe.active_flag = 'Y' AND p.active_flag = 'Y' AND et.active_flag = 'Y'`,
+	`CREATE OR REPLACE VIEW v_person_addresses AS
+	 SELECT pa.pdat_address_id, pa.addr1, pa.addr2, pa.city, pa.cmn_states_id,
+	        s.abbrev AS state, s.name AS state_name, pa.zip, pa.zip_plus_4, pa.country, pa.pdat_person_id,
+	        p.fname AS person_fname, p.lname AS person_lname,
+	        p.fname || ' ' || p.lname AS person_full_name, p.sec_users_id,
+	        pa.create_date, pa.create_user, pa.modify_date, pa.modify_user, pa.active_flag,
+	        p.sec_orgs_id, p.private_flag
+	 FROM pdat_address pa
+	 JOIN pdat_person p ON pa.pdat_person_id = p.pdat_person_id
+	 LEFT JOIN cmn_states s ON pa.cmn_states_id = s.cmn_states_id
+	 WHERE pa.active_flag = 'Y' AND p.active_flag = 'Y'`,
+	`CREATE OR REPLACE VIEW v_person_phones AS
+	 SELECT pp.pdat_pers_phone_id, pp.phone_num, pp.phone_ext, pp.country_code,
+	        pp.pdat_phone_type_id, pt.name AS phone_type_name, pp.pdat_person_id,
+	        p.fname AS person_fname, p.lname AS person_lname,
+	        p.fname || ' ' || p.lname AS person_full_name, p.sec_users_id,
+	        pp.create_date, pp.create_user, pp.modify_date, pp.modify_user, pp.active_flag,
+	        p.sec_orgs_id, p.private_flag
+	 FROM pdat_pers_phone pp
+	 JOIN pdat_person p ON pp.pdat_person_id = p.pdat_person_id
+	 JOIN pdat_phone_type pt ON pp.pdat_phone_type_id = pt.pdat_phone_type_id
+	 WHERE pp.active_flag = 'Y' AND p.active_flag = 'Y' AND pt.active_flag = 'Y'`,
+	`CREATE OR REPLACE VIEW v_person_notes AS
+	 SELECT pn.pdat_pers_notes_id, pn.note_text, pn.pdat_person_id,
+	        p.fname AS person_fname, p.lname AS person_lname,
+	        p.fname || ' ' || p.lname AS person_full_name, p.sec_users_id,
+	        pn.create_date, pn.create_user, pn.modify_date, pn.modify_user, pn.active_flag,
+	        p.sec_orgs_id, p.private_flag
+	 FROM pdat_pers_notes pn
+	 JOIN pdat_person p ON pn.pdat_person_id = p.pdat_person_id
+	 WHERE pn.active_flag = 'Y' AND p.active_flag = 'Y'`,
+	`CREATE OR REPLACE VIEW v_person_links AS
+	 SELECT pl.pdat_links_id, pl.link_text, pl.link_url, pl.note, pl.pdat_person_id,
+	        p.fname AS person_fname, p.lname AS person_lname,
+	        p.fname || ' ' || p.lname AS person_full_name, p.sec_users_id,
+	        pl.create_date, pl.create_user, pl.modify_date, pl.modify_user, pl.active_flag,
+	        p.sec_orgs_id, p.private_flag
+	 FROM pdat_links pl
+	 JOIN pdat_person p ON pl.pdat_person_id = p.pdat_person_id
+	 WHERE pl.active_flag = 'Y' AND p.active_flag = 'Y'`,
+	`CREATE OR REPLACE VIEW v_active_people AS
+	 SELECT pdat_person_id, fname, lname, fname || ' ' || lname AS full_name,
+	        birthday, business_flag, sec_users_id,
+	        create_date, create_user, modify_date, modify_user, active_flag,
+	        sec_orgs_id, private_flag
+	 FROM pdat_person
+	 WHERE active_flag = 'Y'`,
+
+	// Individual people shared by their owner with other users, read-only or editable
+	`CREATE TABLE IF NOT EXISTS pdat_person_shares (
+		pdat_person_id integer NOT NULL REFERENCES pdat_person(pdat_person_id) ON DELETE CASCADE,
+		sec_users_id integer NOT NULL REFERENCES sec_users(sec_users_id) ON DELETE CASCADE,
+		permission character varying(10) NOT NULL CHECK (permission IN ('read', 'edit')),
+		create_date timestamp with time zone NOT NULL DEFAULT NOW(),
+		create_user character varying(30),
+		PRIMARY KEY (pdat_person_id, sec_users_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_person_shares_user ON pdat_person_shares (sec_users_id)`,
+
+	// Companies people work for, kept per organization with their own addresses and phones
+	`CREATE TABLE IF NOT EXISTS pdat_company (
+		pdat_company_id SERIAL PRIMARY KEY,
+		name character varying(120) NOT NULL,
+		domain character varying(255),
+		industry character varying(100),
+		sec_orgs_id integer NOT NULL REFERENCES sec_orgs(sec_orgs_id) ON DELETE CASCADE,
+		create_date timestamp with time zone NOT NULL,
+		create_user character varying(30) NOT NULL,
+		modify_date timestamp with time zone NOT NULL,
+		modify_user character varying(30) NOT NULL,
+		active_flag character(1) NOT NULL DEFAULT 'Y'
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_company_org ON pdat_company (sec_orgs_id)`,
+	`CREATE TABLE IF NOT EXISTS pdat_company_address (
+		pdat_company_address_id SERIAL PRIMARY KEY,
+		addr1 character varying(80),
+		addr2 character varying(80),
+		city character varying(50),
+		zip character varying(5),
+		zip_plus_4 character varying(4),
+		cmn_states_id integer REFERENCES cmn_states(cmn_states_id),
+		country character varying(50),
+		pdat_company_id integer NOT NULL REFERENCES pdat_company(pdat_company_id) ON DELETE CASCADE,
+		create_date timestamp with time zone NOT NULL,
+		create_user character varying(30) NOT NULL,
+		modify_date timestamp with time zone NOT NULL,
+		modify_user character varying(30) NOT NULL,
+		active_flag character(1) NOT NULL DEFAULT 'Y'
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_company_address_company ON pdat_company_address (pdat_company_id)`,
+	`CREATE TABLE IF NOT EXISTS pdat_company_phone (
+		pdat_company_phone_id SERIAL PRIMARY KEY,
+		phone_num character varying(10),
+		phone_ext character varying(5),
+		country_code character varying(5),
+		pdat_phone_type_id integer NOT NULL REFERENCES pdat_phone_type(pdat_phone_type_id),
+		pdat_company_id integer NOT NULL REFERENCES pdat_company(pdat_company_id) ON DELETE CASCADE,
+		create_date timestamp with time zone NOT NULL,
+		create_user character varying(30) NOT NULL,
+		modify_date timestamp with time zone NOT NULL,
+		modify_user character varying(30) NOT NULL,
+		active_flag character(1) NOT NULL DEFAULT 'Y'
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_company_phone_company ON pdat_company_phone (pdat_company_id)`,
+	// A person's job at a company; end_date is empty while they still work there
+	`CREATE TABLE IF NOT EXISTS pdat_employment (
+		pdat_employment_id SERIAL PRIMARY KEY,
+		pdat_person_id integer NOT NULL REFERENCES pdat_person(pdat_person_id) ON DELETE CASCADE,
+		pdat_company_id integer NOT NULL REFERENCES pdat_company(pdat_company_id) ON DELETE CASCADE,
+		title character varying(120),
+		department character varying(120),
+		start_date date,
+		end_date date,
+		create_date timestamp with time zone NOT NULL,
+		create_user character varying(30) NOT NULL,
+		modify_date timestamp with time zone NOT NULL,
+		modify_user character varying(30) NOT NULL,
+		active_flag character(1) NOT NULL DEFAULT 'Y',
+		CONSTRAINT chk_pdat_employment_dates CHECK (end_date IS NULL OR start_date IS NULL OR end_date >= start_date)
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_employment_person ON pdat_employment (pdat_person_id)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_employment_company ON pdat_employment (pdat_company_id)`,
+	`CREATE OR REPLACE VIEW v_active_companies AS
+	 SELECT pdat_company_id, name, domain, industry, sec_orgs_id,
+	        create_date, create_user, modify_date, modify_user, active_flag
+	 FROM pdat_company
+	 WHERE active_flag = 'Y'`,
+	`CREATE OR REPLACE VIEW v_company_addresses AS
+	 SELECT ca.pdat_company_address_id, ca.addr1, ca.addr2, ca.city, ca.cmn_states_id,
+	        s.abbrev AS state, s.name AS state_name, ca.zip, ca.zip_plus_4, ca.country,
+	        ca.pdat_company_id, c.name AS company_name, c.sec_orgs_id,
+	        ca.create_date, ca.create_user, ca.modify_date, ca.modify_user, ca.active_flag
+	 FROM pdat_company_address ca
+	 JOIN pdat_company c ON ca.pdat_company_id = c.pdat_company_id
+	 LEFT JOIN cmn_states s ON ca.cmn_states_id = s.cmn_states_id
+	 WHERE ca.active_flag = 'Y' AND c.active_flag = 'Y'`,
+	`CREATE OR REPLACE VIEW v_company_phones AS
+	 SELECT cp.pdat_company_phone_id, cp.phone_num, cp.phone_ext, cp.country_code,
+	        cp.pdat_phone_type_id, pt.name AS phone_type_name,
+	        cp.pdat_company_id, c.name AS company_name, c.sec_orgs_id,
+	        cp.create_date, cp.create_user, cp.modify_date, cp.modify_user, cp.active_flag
+	 FROM pdat_company_phone cp
+	 JOIN pdat_company c ON cp.pdat_company_id = c.pdat_company_id
+	 JOIN pdat_phone_type pt ON cp.pdat_phone_type_id = pt.pdat_phone_type_id
+	 WHERE cp.active_flag = 'Y' AND c.active_flag = 'Y' AND pt.active_flag = 'Y'`,
+	// sec_orgs_id, sec_users_id and private_flag are the person's, so person visibility rules apply
+	`CREATE OR REPLACE VIEW v_person_employment AS
+	 SELECT e.pdat_employment_id, e.pdat_person_id, e.pdat_company_id, c.name AS company_name,
+	        e.title, e.department, e.start_date, e.end_date,
+	        p.fname AS person_fname, p.lname AS person_lname,
+	        p.fname || ' ' || p.lname AS person_full_name, p.sec_users_id,
+	        e.create_date, e.create_user, e.modify_date, e.modify_user, e.active_flag,
+	        p.sec_orgs_id, p.private_flag
+	 FROM pdat_employment e
+	 JOIN pdat_person p ON e.pdat_person_id = p.pdat_person_id
+	 JOIN pdat_company c ON e.pdat_company_id = c.pdat_company_id
+	 WHERE e.active_flag = 'Y' AND p.active_flag = 'Y' AND c.active_flag = 'Y'`,
+
+	// Typed relationships between two people in the same organization, read from
+	// pdat_person_id to related_person_id unless bidirectional
+	`CREATE TABLE IF NOT EXISTS pdat_person_relationship (
+		pdat_relationship_id SERIAL PRIMARY KEY,
+		pdat_person_id integer NOT NULL REFERENCES pdat_person(pdat_person_id) ON DELETE CASCADE,
+		related_person_id integer NOT NULL REFERENCES pdat_person(pdat_person_id) ON DELETE CASCADE,
+		relationship_type character varying(30) NOT NULL,
+		bidirectional boolean NOT NULL DEFAULT FALSE,
+		create_date timestamp with time zone NOT NULL,
+		create_user character varying(30) NOT NULL,
+		modify_date timestamp with time zone NOT NULL,
+		modify_user character varying(30) NOT NULL,
+		CONSTRAINT chk_pdat_person_relationship_self CHECK (pdat_person_id <> related_person_id),
+		CONSTRAINT uk_pdat_person_relationship UNIQUE (pdat_person_id, related_person_id, relationship_type)
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_person_relationship_related ON pdat_person_relationship (related_person_id)`,
+
+	// Tags are per organization with case-insensitive unique names; colours are #rrggbb
+	`CREATE TABLE IF NOT EXISTS pdat_tag (
+		pdat_tag_id SERIAL PRIMARY KEY,
+		name character varying(50) NOT NULL,
+		color character varying(7) NOT NULL DEFAULT '#808080',
+		sec_orgs_id integer NOT NULL REFERENCES sec_orgs(sec_orgs_id) ON DELETE CASCADE,
+		create_date timestamp with time zone NOT NULL,
+		create_user character varying(30) NOT NULL,
+		modify_date timestamp with time zone NOT NULL,
+		modify_user character varying(30) NOT NULL,
+		CONSTRAINT chk_pdat_tag_color CHECK (color ~ '^#[0-9a-fA-F]{6}$')
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uk_pdat_tag_name ON pdat_tag (sec_orgs_id, LOWER(name))`,
+	`CREATE TABLE IF NOT EXISTS pdat_person_tag (
+		pdat_person_id integer NOT NULL REFERENCES pdat_person(pdat_person_id) ON DELETE CASCADE,
+		pdat_tag_id integer NOT NULL REFERENCES pdat_tag(pdat_tag_id) ON DELETE CASCADE,
+		create_date timestamp with time zone NOT NULL,
+		create_user character varying(30) NOT NULL,
+		PRIMARY KEY (pdat_person_id, pdat_tag_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_person_tag_tag ON pdat_person_tag (pdat_tag_id)`,
+
+	// Custom fields an organization defines for its people. field_key names the field in
+	// the API; select fields take one of options.
+	`CREATE TABLE IF NOT EXISTS pdat_custom_field (
+		pdat_custom_field_id SERIAL PRIMARY KEY,
+		sec_orgs_id integer NOT NULL REFERENCES sec_orgs(sec_orgs_id) ON DELETE CASCADE,
+		field_key character varying(50) NOT NULL,
+		label character varying(100) NOT NULL,
+		field_type character varying(10) NOT NULL,
+		options text[] NOT NULL DEFAULT '{}',
+		required boolean NOT NULL DEFAULT FALSE,
+		sort_order integer NOT NULL DEFAULT 0,
+		create_date timestamp with time zone NOT NULL,
+		create_user character varying(30) NOT NULL,
+		modify_date timestamp with time zone NOT NULL,
+		modify_user character varying(30) NOT NULL,
+		CONSTRAINT uk_pdat_custom_field_key UNIQUE (sec_orgs_id, field_key),
+		CONSTRAINT chk_pdat_custom_field_key CHECK (field_key ~ '^[a-z][a-z0-9_]*$'),
+		CONSTRAINT chk_pdat_custom_field_type CHECK (field_type IN ('text', 'number', 'date', 'select', 'boolean'))
+	)`,
+	// A person's value for a custom field, in the column matching the field's type
+	`CREATE TABLE IF NOT EXISTS pdat_person_custom_value (
+		pdat_person_id integer NOT NULL REFERENCES pdat_person(pdat_person_id) ON DELETE CASCADE,
+		pdat_custom_field_id integer NOT NULL REFERENCES pdat_custom_field(pdat_custom_field_id) ON DELETE CASCADE,
+		value_text text,
+		value_number numeric,
+		value_date date,
+		value_bool boolean,
+		modify_date timestamp with time zone NOT NULL,
+		modify_user character varying(30) NOT NULL,
+		PRIMARY KEY (pdat_person_id, pdat_custom_field_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_person_custom_value_field ON pdat_person_custom_value (pdat_custom_field_id)`,
+
+	// Merges of one person into another. The merged person is deactivated rather than
+	// deleted; moved lists the child rows moved to the survivor by table, and the
+	// *_before columns hold the survivor's values the merge replaced, so it can be undone.
+	`CREATE TABLE IF NOT EXISTS pdat_person_merge (
+		pdat_person_merge_id SERIAL PRIMARY KEY,
+		survivor_id integer NOT NULL REFERENCES pdat_person(pdat_person_id) ON DELETE CASCADE,
+		merged_id integer NOT NULL REFERENCES pdat_person(pdat_person_id) ON DELETE CASCADE,
+		moved jsonb NOT NULL DEFAULT '{}',
+		person_before jsonb NOT NULL,
+		custom_fields_before jsonb NOT NULL DEFAULT '[]',
+		custom_field_ids integer[] NOT NULL DEFAULT '{}',
+		create_date timestamp with time zone NOT NULL,
+		create_user character varying(30) NOT NULL,
+		undone_date timestamp with time zone,
+		undone_user character varying(30),
+		CONSTRAINT chk_pdat_person_merge_self CHECK (survivor_id <> merged_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_person_merge_survivor ON pdat_person_merge (survivor_id)`,
+	`CREATE INDEX IF NOT EXISTS ix_pdat_person_merge_merged ON pdat_person_merge (merged_id)`,
+}
+
+// EnsureSchema applies schemaStatements and then the row security policies to the database
+func (db *DB) EnsureSchema(ctx context.Context) error {
+	for _, stmt := range append(schemaStatements, rowSecurityStatements()...) {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to apply schema statement %q: %w", stmt, err)
+		}
+	}
+	return nil
+}
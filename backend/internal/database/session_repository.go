@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// SessionRepository handles login session metadata. A session is active while its
+// refresh token family has a live token; ending one revokes the family.
+type SessionRepository struct {
+	db *DB
+}
+
+// NewSessionRepository creates a new SessionRepository
+func NewSessionRepository(db *DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Record stores a session when it starts, or updates the device and last use when it is refreshed
+func (r *SessionRepository) Record(ctx context.Context, s *models.Session) error {
+	query := `INSERT INTO sec_sessions (family_id, sec_users_id, sec_accounts_id, user_agent, ip_address)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (family_id) DO UPDATE
+	          SET user_agent = EXCLUDED.user_agent, ip_address = EXCLUDED.ip_address, last_seen_at = NOW()`
+
+	_, err := r.db.ExecContext(ctx, query, s.ID, s.UserID, s.AccountID, s.UserAgent, s.IPAddress)
+	return err
+}
+
+// ListActive returns a user's sessions that can still be refreshed, most recently used first.
+// Sessions started before they were recorded are included with what refresh_tokens knows.
+func (r *SessionRepository) ListActive(ctx context.Context, userID int) ([]models.Session, error) {
+	query := `SELECT rt.family_id, rt.user_id, rt.sec_accounts_id, a.name, s.user_agent, s.ip_address,
+	                 COALESCE(s.created_at, rt.created_at), COALESCE(s.last_seen_at, rt.created_at), rt.expires_at
+	          FROM refresh_tokens rt
+	          JOIN sec_accounts a ON a.sec_accounts_id = rt.sec_accounts_id
+	          LEFT JOIN sec_sessions s ON s.family_id = rt.family_id
+	          WHERE rt.user_id = $1 AND rt.revoked = FALSE AND rt.rotated_at IS NULL AND rt.expires_at > NOW()
+	          ORDER BY 8 DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.AccountID,
+			&s.AccountName,
+			&s.UserAgent,
+			&s.IPAddress,
+			&s.CreatedAt,
+			&s.LastSeenAt,
+			&s.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Revoke ends one of a user's sessions by revoking its refresh tokens.
+// Returns false if the user has no such active session.
+func (r *SessionRepository) Revoke(ctx context.Context, userID int, familyID string) (bool, error) {
+	query := `WITH revoked AS (
+	              UPDATE refresh_tokens SET revoked = TRUE
+	              WHERE user_id = $1 AND family_id = $2 AND revoked = FALSE
+	              RETURNING rotated_at, expires_at
+	          )
+	          SELECT COUNT(*) FROM revoked WHERE rotated_at IS NULL AND expires_at > NOW()`
+
+	var active int
+	if err := r.db.QueryRowContext(ctx, query, userID, familyID).Scan(&active); err != nil {
+		return false, err
+	}
+
+	return active > 0, nil
+}
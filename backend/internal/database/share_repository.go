@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// ShareRepository handles people shared with individual users
+type ShareRepository struct {
+	db *DB
+}
+
+// NewShareRepository creates a new ShareRepository
+func NewShareRepository(db *DB) *ShareRepository {
+	return &ShareRepository{db: db}
+}
+
+// OwnsPerson reports whether a user owns a person and can still edit its organization's
+// people. Only such owners can share a person; one who has left the organization or been
+// made a viewer cannot.
+func (r *ShareRepository) OwnsPerson(ctx context.Context, personID, userID int) (bool, error) {
+	query := `SELECT EXISTS (
+	              SELECT 1 FROM pdat_person p
+	              JOIN sec_org_members m ON m.sec_orgs_id = p.sec_orgs_id AND m.sec_users_id = p.sec_users_id
+	              WHERE p.pdat_person_id = $1 AND p.sec_users_id = $2 AND m.role <> 'viewer')`
+
+	var owns bool
+	err := r.db.QueryRowContext(ctx, query, personID, userID).Scan(&owns)
+	return owns, err
+}
+
+// ListForPerson returns the users a person is shared with
+func (r *ShareRepository) ListForPerson(ctx context.Context, personID int) ([]*models.PersonShare, error) {
+	query := `SELECT s.pdat_person_id, s.sec_users_id, u.fname, u.lname, s.permission, s.create_date, s.create_user
+	          FROM pdat_person_shares s
+	          JOIN sec_users u ON u.sec_users_id = s.sec_users_id
+	          WHERE s.pdat_person_id = $1
+	          ORDER BY u.lname, u.fname`
+
+	rows, err := r.db.QueryContext(ctx, query, personID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*models.PersonShare{}
+	for rows.Next() {
+		share := &models.PersonShare{}
+		if err := rows.Scan(
+			&share.PersonID,
+			&share.UserID,
+			&share.FirstName,
+			&share.LastName,
+			&share.Permission,
+			&share.CreateDate,
+			&share.CreateUser,
+		); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
+
+// Grant shares a person with a user, or changes the permission of an existing share
+func (r *ShareRepository) Grant(ctx context.Context, personID, userID int, permission, actor string) error {
+	query := `INSERT INTO pdat_person_shares (pdat_person_id, sec_users_id, permission, create_user)
+	          VALUES ($1, $2, $3, $4)
+	          ON CONFLICT (pdat_person_id, sec_users_id) DO UPDATE SET permission = EXCLUDED.permission`
+	_, err := r.db.ExecContext(ctx, query, personID, userID, permission, actor)
+	return err
+}
+
+// Revoke stops sharing a person with a user. Returns false if it was not shared with them.
+func (r *ShareRepository) Revoke(ctx context.Context, personID, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM pdat_person_shares WHERE pdat_person_id = $1 AND sec_users_id = $2`, personID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ListSharedWith returns the active people other users have shared with a user
+func (r *ShareRepository) ListSharedWith(ctx context.Context, userID int) ([]*models.SharedPerson, error) {
+	query := `SELECT p.pdat_person_id, p.fname, p.lname, p.birthday, p.business_flag, p.sec_users_id,
+	                 o.fname, o.lname, s.permission, s.create_date
+	          FROM pdat_person_shares s
+	          JOIN pdat_person p ON p.pdat_person_id = s.pdat_person_id
+	          LEFT JOIN sec_users o ON o.sec_users_id = p.sec_users_id
+	          WHERE s.sec_users_id = $1 AND p.active_flag = 'Y'
+	          ORDER BY p.lname, p.fname`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	people := []*models.SharedPerson{}
+	for rows.Next() {
+		sp := &models.SharedPerson{}
+		if err := rows.Scan(
+			&sp.PersonID,
+			&sp.FirstName,
+			&sp.LastName,
+			&sp.Birthday,
+			&sp.BusinessFlag,
+			&sp.OwnerID,
+			&sp.OwnerFirstName,
+			&sp.OwnerLastName,
+			&sp.Permission,
+			&sp.SharedDate,
+		); err != nil {
+			return nil, err
+		}
+		people = append(people, sp)
+	}
+
+	return people, rows.Err()
+}
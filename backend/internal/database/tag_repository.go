@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// ErrDuplicateTag is returned when an organization already has a tag with the same name
+var ErrDuplicateTag = errors.New("tag already exists")
+
+// TagRepository handles tags and their assignment to people. Tags belong to the tenant
+// scope's organization and can only be given to people visible in it.
+type TagRepository struct {
+	db *DB
+}
+
+// NewTagRepository creates a new TagRepository
+func NewTagRepository(db *DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// TagCondition matches people carrying the tag named by nameArg (case-insensitively) in
+// the organization at orgArg. prefix must name the outer table or alias (e.g. "p.").
+func TagCondition(prefix string, orgArg, nameArg int) string {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM pdat_person_tag pt JOIN pdat_tag t ON t.pdat_tag_id = pt.pdat_tag_id
+		WHERE pt.pdat_person_id = %spdat_person_id AND t.sec_orgs_id = $%d AND LOWER(t.name) = LOWER($%d))`, prefix, orgArg, nameArg)
+}
+
+// tagQuery selects the tags of the scope's organization matching where, each with the
+// number of people visible in the scope carrying it. $1 and $2 are the scope's OrgID and UserID.
+func tagQuery(where string) string {
+	return `SELECT t.pdat_tag_id, t.name, t.color, t.sec_orgs_id,
+	               (SELECT COUNT(*) FROM pdat_person_tag pt JOIN pdat_person p ON p.pdat_person_id = pt.pdat_person_id
+	                WHERE pt.pdat_tag_id = t.pdat_tag_id AND ` + VisibleCondition("p.", 1, 2) + `) AS person_count,
+	               t.create_date, t.create_user, t.modify_date, t.modify_user
+	        FROM pdat_tag t
+	        WHERE t.sec_orgs_id = $1` + where
+}
+
+// List returns the tags of the tenant scope's organization by name
+func (r *TagRepository) List(ctx context.Context, scope models.TenantScope) ([]*models.Tag, error) {
+	rows, err := r.db.QueryContext(ctx, tagQuery(" ORDER BY LOWER(t.name)"), scope.OrgID, scope.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// FindByID finds a tag in the tenant scope's organization
+func (r *TagRepository) FindByID(ctx context.Context, scope models.TenantScope, id int) (*models.Tag, error) {
+	tag, err := scanTag(r.db.QueryRowContext(ctx, tagQuery(" AND t.pdat_tag_id = $3"), scope.OrgID, scope.UserID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// scanTag scans a row selected by tagQuery
+func scanTag(row interface{ Scan(...interface{}) error }) (*models.Tag, error) {
+	tag := &models.Tag{}
+	err := row.Scan(
+		&tag.ID,
+		&tag.Name,
+		&tag.Color,
+		&tag.OrgID,
+		&tag.PersonCount,
+		&tag.CreateDate,
+		&tag.CreateUser,
+		&tag.ModifyDate,
+		&tag.ModifyUser,
+	)
+	return tag, err
+}
+
+// Create adds a tag to the tenant scope's organization
+func (r *TagRepository) Create(ctx context.Context, scope models.TenantScope, tag *models.Tag) error {
+	query := `INSERT INTO pdat_tag (name, color, sec_orgs_id, create_date, create_user, modify_date, modify_user)
+	          VALUES ($1, $2, $3, $4, $5, $4, $5)
+	          RETURNING pdat_tag_id, create_date, modify_date`
+
+	tag.OrgID = scope.OrgID
+	err := r.db.QueryRowContext(ctx, query, tag.Name, tag.Color, scope.OrgID, time.Now(), tag.CreateUser).
+		Scan(&tag.ID, &tag.CreateDate, &tag.ModifyDate)
+	if isUniqueViolation(err, "uk_pdat_tag_name") {
+		return ErrDuplicateTag
+	}
+	return err
+}
+
+// Update renames and recolours a tag. Returns false if it is not in the tenant scope's organization.
+func (r *TagRepository) Update(ctx context.Context, scope models.TenantScope, id int, name, color, modifyUser string) (bool, error) {
+	query := `UPDATE pdat_tag SET name = $1, color = $2, modify_date = $3, modify_user = $4
+	          WHERE pdat_tag_id = $5 AND sec_orgs_id = $6`
+
+	result, err := r.db.ExecContext(ctx, query, name, color, time.Now(), modifyUser, id, scope.OrgID)
+	if isUniqueViolation(err, "uk_pdat_tag_name") {
+		return false, ErrDuplicateTag
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Delete deletes a tag, removing it from everyone carrying it. Returns false if it is not
+// in the tenant scope's organization.
+func (r *TagRepository) Delete(ctx context.Context, scope models.TenantScope, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM pdat_tag WHERE pdat_tag_id = $1 AND sec_orgs_id = $2`, id, scope.OrgID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Assign gives every tag in tagIDs to every person in personIDs. People not visible in
+// the tenant scope and tags outside its organization are skipped; tags a person already
+// carries are left alone.
+func (r *TagRepository) Assign(ctx context.Context, scope models.TenantScope, personIDs, tagIDs []int, actor string) (*models.TagAssignmentResult, error) {
+	change := `INSERT INTO pdat_person_tag (pdat_person_id, pdat_tag_id, create_date, create_user)
+	           SELECT p.pdat_person_id, t.pdat_tag_id, $5::timestamptz, $6::varchar FROM people p CROSS JOIN tags t
+	           ON CONFLICT DO NOTHING
+	           RETURNING 1`
+	return r.changeAssignments(ctx, scope, personIDs, tagIDs, change, time.Now(), actor)
+}
+
+// Unassign removes every tag in tagIDs from every person in personIDs, skipping people
+// and tags as Assign does
+func (r *TagRepository) Unassign(ctx context.Context, scope models.TenantScope, personIDs, tagIDs []int) (*models.TagAssignmentResult, error) {
+	change := `DELETE FROM pdat_person_tag pt USING people p, tags t
+	           WHERE pt.pdat_person_id = p.pdat_person_id AND pt.pdat_tag_id = t.pdat_tag_id
+	           RETURNING 1`
+	return r.changeAssignments(ctx, scope, personIDs, tagIDs, change)
+}
+
+// changeAssignments runs change against the people and tags among personIDs and tagIDs
+// the scope can see. change reads them from the people and tags CTEs and returns a row per
+// assignment it changes; any extra args follow the four the CTEs use.
+func (r *TagRepository) changeAssignments(ctx context.Context, scope models.TenantScope, personIDs, tagIDs []int, change string, extra ...interface{}) (*models.TagAssignmentResult, error) {
+	query := `WITH people AS (
+	              SELECT pdat_person_id FROM pdat_person
+	              WHERE pdat_person_id = ANY($1) AND ` + VisibleCondition("", 3, 4) + `
+	          ), tags AS (
+	              SELECT pdat_tag_id FROM pdat_tag WHERE pdat_tag_id = ANY($2) AND sec_orgs_id = $3
+	          ), changed AS (` + change + `)
+	          SELECT ARRAY(SELECT pdat_person_id FROM people ORDER BY 1),
+	                 ARRAY(SELECT pdat_tag_id FROM tags ORDER BY 1),
+	                 (SELECT COUNT(*) FROM changed)`
+
+	args := append([]interface{}{pq.Array(personIDs), pq.Array(tagIDs), scope.OrgID, scope.UserID}, extra...)
+	var people, tags pq.Int64Array
+	result := &models.TagAssignmentResult{}
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&people, &tags, &result.Changed); err != nil {
+		return nil, err
+	}
+
+	result.PersonIDs = make([]int, len(people))
+	for i, id := range people {
+		result.PersonIDs[i] = int(id)
+	}
+	result.TagIDs = make([]int, len(tags))
+	for i, id := range tags {
+		result.TagIDs[i] = int(id)
+	}
+	return result, nil
+}
@@ -9,6 +9,13 @@ import (
 	"github.com/davealexenglish/magnifimind-crm/internal/models"
 )
 
+var (
+	// ErrDuplicateAccountName is returned when an account name is already taken
+	ErrDuplicateAccountName = errors.New("account name already exists")
+	// ErrDuplicateEmail is returned when an email address is already registered
+	ErrDuplicateEmail = errors.New("email already registered")
+)
+
 // UserRepository handles user-related database operations
 type UserRepository struct {
 	db *DB
@@ -21,7 +28,7 @@ func NewUserRepository(db *DB) *UserRepository {
 
 // FindByID finds a user by ID
 func (r *UserRepository) FindByID(ctx context.Context, id int) (*models.SecUser, error) {
-	query := `SELECT sec_users_id, fname, lname, create_date, create_user, modify_date, modify_user
+	query := `SELECT sec_users_id, fname, lname, email, create_date, create_user, modify_date, modify_user
 	          FROM sec_users WHERE sec_users_id = $1`
 
 	user := &models.SecUser{}
@@ -29,6 +36,7 @@ func (r *UserRepository) FindByID(ctx context.Context, id int) (*models.SecUser,
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
+		&user.Email,
 		&user.CreateDate,
 		&user.CreateUser,
 		&user.ModifyDate,
@@ -47,7 +55,7 @@ func (r *UserRepository) FindByID(ctx context.Context, id int) (*models.SecUser,
 
 // FindByUsername finds a user account by username (account name)
 func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*models.SecAccount, error) {
-	query := `SELECT sec_accounts_id, name, password, sec_users_id, create_date, create_user, modify_date, modify_user
+	query := `SELECT sec_accounts_id, name, password, sec_users_id, email_verified, create_date, create_user, modify_date, modify_user
 	          FROM sec_accounts WHERE name = $1`
 
 	account := &models.SecAccount{}
@@ -56,6 +64,7 @@ func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*
 		&account.Name,
 		&account.Password,
 		&account.UserID,
+		&account.EmailVerified,
 		&account.CreateDate,
 		&account.CreateUser,
 		&account.ModifyDate,
@@ -72,6 +81,33 @@ func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*
 	return account, nil
 }
 
+// FindByEmail finds a user by email address (case-insensitive)
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.SecUser, error) {
+	query := `SELECT sec_users_id, fname, lname, email, create_date, create_user, modify_date, modify_user
+	          FROM sec_users WHERE LOWER(email) = LOWER($1)`
+
+	user := &models.SecUser{}
+	err := r.db.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.CreateDate,
+		&user.CreateUser,
+		&user.ModifyDate,
+		&user.ModifyUser,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 // Create creates a new user and account
 func (r *UserRepository) Create(ctx context.Context, user *models.SecUser, account *models.SecAccount) error {
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -81,8 +117,8 @@ func (r *UserRepository) Create(ctx context.Context, user *models.SecUser, accou
 	defer tx.Rollback()
 
 	// Insert user
-	userQuery := `INSERT INTO sec_users (fname, lname, create_date, create_user, modify_date, modify_user)
-	              VALUES ($1, $2, $3, $4, $5, $6) RETURNING sec_users_id`
+	userQuery := `INSERT INTO sec_users (fname, lname, email, create_date, create_user, modify_date, modify_user)
+	              VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING sec_users_id`
 
 	now := time.Now()
 	createUser := "system"
@@ -90,12 +126,16 @@ func (r *UserRepository) Create(ctx context.Context, user *models.SecUser, accou
 	err = tx.QueryRowContext(ctx, userQuery,
 		user.FirstName,
 		user.LastName,
+		user.Email,
 		now,
 		createUser,
 		now,
 		createUser,
 	).Scan(&user.ID)
 
+	if isUniqueViolation(err, "uk_sec_users_email") {
+		return ErrDuplicateEmail
+	}
 	if err != nil {
 		return err
 	}
@@ -114,51 +154,153 @@ func (r *UserRepository) Create(ctx context.Context, user *models.SecUser, accou
 		createUser,
 	).Scan(&account.ID)
 
+	if isUniqueViolation(err, "uk_sec_accounts_name") {
+		return ErrDuplicateAccountName
+	}
 	if err != nil {
 		return err
 	}
 
+	// New accounts start with the default role
+	roleQuery := `INSERT INTO sec_acct_roles (sec_roles_id, sec_accounts_id, create_date, create_user, modify_date, modify_user)
+	              SELECT sec_roles_id, $1, $2, $3, $2, $3 FROM sec_roles WHERE name = $4`
+	if _, err := tx.ExecContext(ctx, roleQuery, account.ID, now, createUser, models.RoleUser); err != nil {
+		return err
+	}
+
 	account.UserID = user.ID
+	user.CreateDate, user.ModifyDate = now, now
+	user.CreateUser, user.ModifyUser = createUser, createUser
+	account.CreateDate, account.ModifyDate = now, now
+	account.CreateUser, account.ModifyUser = createUser, createUser
 
 	return tx.Commit()
 }
 
-// SaveRefreshToken saves a refresh token
-func (r *UserRepository) SaveRefreshToken(ctx context.Context, rt *models.RefreshToken) error {
-	// Create refresh_tokens table if it doesn't exist
-	createTableQuery := `
-		CREATE TABLE IF NOT EXISTS refresh_tokens (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER NOT NULL,
-			token VARCHAR(255) NOT NULL UNIQUE,
-			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			revoked BOOLEAN NOT NULL DEFAULT FALSE,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
-		)
-	`
-	_, err := r.db.ExecContext(ctx, createTableQuery)
+// SetEmailVerified marks all of a user's accounts as having a verified email address
+func (r *UserRepository) SetEmailVerified(ctx context.Context, userID int) error {
+	query := `UPDATE sec_accounts SET email_verified = TRUE WHERE sec_users_id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// IsEmailVerified reports whether any of a user's accounts has a verified email address
+func (r *UserRepository) IsEmailVerified(ctx context.Context, userID int) (bool, error) {
+	query := `SELECT COALESCE(BOOL_OR(email_verified), FALSE) FROM sec_accounts WHERE sec_users_id = $1`
+	var verified bool
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&verified)
+	return verified, err
+}
+
+// FindAccountByID finds an account by ID
+func (r *UserRepository) FindAccountByID(ctx context.Context, id int) (*models.SecAccount, error) {
+	query := `SELECT sec_accounts_id, name, password, sec_users_id, email_verified, create_date, create_user, modify_date, modify_user
+	          FROM sec_accounts WHERE sec_accounts_id = $1`
+
+	account := &models.SecAccount{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&account.ID,
+		&account.Name,
+		&account.Password,
+		&account.UserID,
+		&account.EmailVerified,
+		&account.CreateDate,
+		&account.CreateUser,
+		&account.ModifyDate,
+		&account.ModifyUser,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	query := `INSERT INTO refresh_tokens (user_id, token, expires_at)
-	          VALUES ($1, $2, $3) RETURNING id, created_at`
+	return account, nil
+}
+
+// FindFirstAccountForUser returns a user's oldest account, or nil if they have none
+func (r *UserRepository) FindFirstAccountForUser(ctx context.Context, userID int) (*models.SecAccount, error) {
+	query := `SELECT sec_accounts_id, name, password, sec_users_id, email_verified, create_date, create_user, modify_date, modify_user
+	          FROM sec_accounts WHERE sec_users_id = $1 ORDER BY sec_accounts_id LIMIT 1`
+
+	account := &models.SecAccount{}
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&account.ID,
+		&account.Name,
+		&account.Password,
+		&account.UserID,
+		&account.EmailVerified,
+		&account.CreateDate,
+		&account.CreateUser,
+		&account.ModifyDate,
+		&account.ModifyUser,
+	)
 
-	return r.db.QueryRowContext(ctx, query, rt.UserID, rt.Token, rt.ExpiresAt).Scan(&rt.ID, &rt.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// UpdatePasswordForUser sets the password hash on all of a user's accounts
+func (r *UserRepository) UpdatePasswordForUser(ctx context.Context, userID int, passwordHash, modifyUser string) error {
+	query := `UPDATE sec_accounts SET password = $1, modify_date = $2, modify_user = $3 WHERE sec_users_id = $4`
+	_, err := r.db.ExecContext(ctx, query, passwordHash, time.Now(), secModifyUser(modifyUser), userID)
+	return err
+}
+
+// UpdateAccountPassword sets the password hash on a single account
+func (r *UserRepository) UpdateAccountPassword(ctx context.Context, accountID int, passwordHash, modifyUser string) error {
+	query := `UPDATE sec_accounts SET password = $1, modify_date = $2, modify_user = $3 WHERE sec_accounts_id = $4`
+	_, err := r.db.ExecContext(ctx, query, passwordHash, time.Now(), secModifyUser(modifyUser), accountID)
+	return err
+}
+
+// UpdateEmail changes a user's email address
+func (r *UserRepository) UpdateEmail(ctx context.Context, userID int, email, modifyUser string) error {
+	query := `UPDATE sec_users SET email = $1, modify_date = $2, modify_user = $3 WHERE sec_users_id = $4`
+	_, err := r.db.ExecContext(ctx, query, email, time.Now(), secModifyUser(modifyUser), userID)
+	if isUniqueViolation(err, "uk_sec_users_email") {
+		return ErrDuplicateEmail
+	}
+	return err
 }
 
-// FindRefreshToken finds a refresh token
-func (r *UserRepository) FindRefreshToken(ctx context.Context, token string) (*models.RefreshToken, error) {
-	query := `SELECT id, user_id, token, expires_at, revoked, created_at
-	          FROM refresh_tokens WHERE token = $1`
+// SaveRefreshToken saves a refresh token (TokenHash must already be hashed)
+func (r *UserRepository) SaveRefreshToken(ctx context.Context, rt *models.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (user_id, sec_accounts_id, token_hash, family_id, expires_at)
+	          VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	return r.db.QueryRowContext(ctx, query,
+		rt.UserID,
+		rt.AccountID,
+		rt.TokenHash,
+		rt.FamilyID,
+		rt.ExpiresAt,
+	).Scan(&rt.ID, &rt.CreatedAt)
+}
+
+// FindRefreshToken finds a refresh token by its hash
+func (r *UserRepository) FindRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `SELECT id, user_id, sec_accounts_id, token_hash, family_id, expires_at, revoked, rotated_at, created_at
+	          FROM refresh_tokens WHERE token_hash = $1`
 
 	rt := &models.RefreshToken{}
-	err := r.db.QueryRowContext(ctx, query, token).Scan(
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
 		&rt.ID,
 		&rt.UserID,
-		&rt.Token,
+		&rt.AccountID,
+		&rt.TokenHash,
+		&rt.FamilyID,
 		&rt.ExpiresAt,
 		&rt.Revoked,
+		&rt.RotatedAt,
 		&rt.CreatedAt,
 	)
 
@@ -172,10 +314,28 @@ func (r *UserRepository) FindRefreshToken(ctx context.Context, token string) (*m
 	return rt, nil
 }
 
-// RevokeRefreshToken revokes a refresh token
-func (r *UserRepository) RevokeRefreshToken(ctx context.Context, token string) error {
-	query := `UPDATE refresh_tokens SET revoked = TRUE WHERE token = $1`
-	result, err := r.db.ExecContext(ctx, query, token)
+// MarkRefreshTokenRotated records that a refresh token has been exchanged for its successor.
+// Returns false if the token was already rotated or revoked, which signals reuse.
+func (r *UserRepository) MarkRefreshTokenRotated(ctx context.Context, id int) (bool, error) {
+	query := `UPDATE refresh_tokens SET rotated_at = NOW()
+	          WHERE id = $1 AND rotated_at IS NULL AND revoked = FALSE`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// RevokeRefreshToken revokes a refresh token by its hash
+func (r *UserRepository) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	query := `UPDATE refresh_tokens SET revoked = TRUE WHERE token_hash = $1`
+	result, err := r.db.ExecContext(ctx, query, tokenHash)
 	if err != nil {
 		return err
 	}
@@ -192,6 +352,46 @@ func (r *UserRepository) RevokeRefreshToken(ctx context.Context, token string) e
 	return nil
 }
 
+// RevokeRefreshTokenFamily revokes every token descended from the same login
+func (r *UserRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked = TRUE WHERE family_id = $1 AND revoked = FALSE`
+	_, err := r.db.ExecContext(ctx, query, familyID)
+	return err
+}
+
+// RevokeAllRefreshTokens revokes every refresh token belonging to a user
+func (r *UserRepository) RevokeAllRefreshTokens(ctx context.Context, userID int) error {
+	query := `UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = $1 AND revoked = FALSE`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// RevokeRefreshTokensExcept revokes every refresh token of a user outside the given family
+// and returns the families (sessions) it revoked
+func (r *UserRepository) RevokeRefreshTokensExcept(ctx context.Context, userID int, familyID string) ([]string, error) {
+	query := `WITH revoked AS (
+	              UPDATE refresh_tokens SET revoked = TRUE
+	              WHERE user_id = $1 AND family_id <> $2 AND revoked = FALSE
+	              RETURNING family_id)
+	          SELECT DISTINCT family_id FROM revoked`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	families := []string{}
+	for rows.Next() {
+		var family string
+		if err := rows.Scan(&family); err != nil {
+			return nil, err
+		}
+		families = append(families, family)
+	}
+	return families, rows.Err()
+}
+
 // List returns a list of all users
 func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*models.SecUser, int, error) {
 	// Get total count
@@ -203,7 +403,7 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*models
 	}
 
 	// Get users
-	query := `SELECT sec_users_id, fname, lname, create_date, create_user, modify_date, modify_user
+	query := `SELECT sec_users_id, fname, lname, email, create_date, create_user, modify_date, modify_user
 	          FROM sec_users ORDER BY sec_users_id LIMIT $1 OFFSET $2`
 
 	rows, err := r.db.QueryContext(ctx, query, limit, offset)
@@ -219,6 +419,7 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*models
 			&user.ID,
 			&user.FirstName,
 			&user.LastName,
+			&user.Email,
 			&user.CreateDate,
 			&user.CreateUser,
 			&user.ModifyDate,
@@ -233,27 +434,170 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*models
 	return users, total, nil
 }
 
-// Update updates a user
-func (r *UserRepository) Update(ctx context.Context, user *models.SecUser) error {
+// Update updates a user's name, recording who made the change
+func (r *UserRepository) Update(ctx context.Context, user *models.SecUser, modifyUser string) error {
 	query := `UPDATE sec_users SET fname = $1, lname = $2, modify_date = $3, modify_user = $4
 	          WHERE sec_users_id = $5`
 
+	now := time.Now()
+	user.ModifyDate = now
+	user.ModifyUser = secModifyUser(modifyUser)
+
 	_, err := r.db.ExecContext(ctx, query,
 		user.FirstName,
 		user.LastName,
-		time.Now(),
-		"system",
+		now,
+		user.ModifyUser,
 		user.ID,
 	)
 
 	return err
 }
 
-// Delete deletes a user
-func (r *UserRepository) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM sec_users WHERE sec_users_id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+// Delete deletes a user, their accounts and everything they own, in one transaction.
+// People the user created in shared organizations stay there: they are given to the
+// member who takes over (reassignTo if they belong to the organization, otherwise an
+// owner, then an admin, then a member) and deleted only if no such member is left. The
+// user's personal organization is deleted with its contents unless reassignTo is set, in
+// which case its people, companies, tags and custom fields move to reassignTo's personal
+// organization and the user's links and calendar entries go to reassignTo. Vault entries
+// are always deleted: they are encrypted with the owner's master password and are useless
+// to anyone else. Email and phone types still used by other users' contacts are given to
+// the user named by typesTo.
+func (r *UserRepository) Delete(ctx context.Context, id int, reassignTo *int, typesTo int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var statements []string
+	var args [][]interface{}
+	add := func(query string, a ...interface{}) {
+		statements = append(statements, query)
+		args = append(args, a)
+	}
+
+	// People in shared organizations go to a remaining member who can edit them; viewers
+	// would gain edit rights as owners, so they are passed over
+	add(`UPDATE pdat_person p SET sec_users_id = heir.sec_users_id
+	     FROM (SELECT DISTINCT ON (m.sec_orgs_id) m.sec_orgs_id, m.sec_users_id
+	           FROM sec_org_members m
+	           JOIN sec_orgs o ON o.sec_orgs_id = m.sec_orgs_id
+	           WHERE o.personal_user_id IS NULL AND m.sec_users_id <> $1 AND m.role <> 'viewer'
+	           ORDER BY m.sec_orgs_id, COALESCE(m.sec_users_id = $2, FALSE) DESC,
+	                    CASE m.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, m.sec_users_id) heir
+	     WHERE p.sec_orgs_id = heir.sec_orgs_id AND p.sec_users_id = $1`, id, reassignTo)
+	// Child rows of pdat_person (addresses, emails, phones, notes, links, calendar links) cascade
+	add(`DELETE FROM pdat_person p USING sec_orgs o
+	     WHERE o.sec_orgs_id = p.sec_orgs_id AND o.personal_user_id IS NULL AND p.sec_users_id = $1`, id)
+
+	if reassignTo != nil {
+		// People and companies in the user's personal organization move to the new owner's personal organization
+		add(`INSERT INTO sec_orgs (name, personal_user_id, create_user, modify_user) VALUES ($1, $2, 'system', 'system')
+		         ON CONFLICT (personal_user_id) DO NOTHING`, personalOrgName, *reassignTo)
+		add(`INSERT INTO sec_org_members (sec_orgs_id, sec_users_id, role)
+		         SELECT sec_orgs_id, personal_user_id, 'owner' FROM sec_orgs WHERE personal_user_id = $1
+		         ON CONFLICT (sec_orgs_id, sec_users_id) DO NOTHING`, *reassignTo)
+		add(`UPDATE pdat_person SET sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $2),
+		                            sec_users_id = $2
+		         WHERE sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $1)`, id, *reassignTo)
+		add(`UPDATE pdat_company SET sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $2)
+		         WHERE sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $1)`, id, *reassignTo)
+		// Tags move too; where the new owner has a tag of the same name, people are given that one
+		add(`INSERT INTO pdat_person_tag (pdat_person_id, pdat_tag_id, create_date, create_user)
+		         SELECT pt.pdat_person_id, nt.pdat_tag_id, pt.create_date, pt.create_user
+		         FROM pdat_person_tag pt
+		         JOIN pdat_tag ot ON ot.pdat_tag_id = pt.pdat_tag_id
+		         JOIN pdat_tag nt ON LOWER(nt.name) = LOWER(ot.name)
+		         WHERE ot.sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $1)
+		           AND nt.sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $2)
+		         ON CONFLICT DO NOTHING`, id, *reassignTo)
+		add(`UPDATE pdat_tag ot SET sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $2)
+		         WHERE ot.sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $1)
+		           AND NOT EXISTS (SELECT 1 FROM pdat_tag nt
+		                           WHERE nt.sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $2)
+		                             AND LOWER(nt.name) = LOWER(ot.name))`, id, *reassignTo)
+		// Custom fields move the same way, keeping values where the new owner has a field with the same key and type
+		add(`INSERT INTO pdat_person_custom_value (pdat_person_id, pdat_custom_field_id,
+		                                           value_text, value_number, value_date, value_bool, modify_date, modify_user)
+		         SELECT v.pdat_person_id, nf.pdat_custom_field_id,
+		                v.value_text, v.value_number, v.value_date, v.value_bool, v.modify_date, v.modify_user
+		         FROM pdat_person_custom_value v
+		         JOIN pdat_custom_field f ON f.pdat_custom_field_id = v.pdat_custom_field_id
+		         JOIN pdat_custom_field nf ON nf.field_key = f.field_key AND nf.field_type = f.field_type
+		         WHERE f.sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $1)
+		           AND nf.sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $2)
+		         ON CONFLICT DO NOTHING`, id, *reassignTo)
+		add(`UPDATE pdat_custom_field f SET sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $2)
+		         WHERE f.sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $1)
+		           AND NOT EXISTS (SELECT 1 FROM pdat_custom_field nf
+		                           WHERE nf.sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $2)
+		                             AND nf.field_key = f.field_key)`, id, *reassignTo)
+		add(`UPDATE pdat_calendar SET sec_users_id = $2 WHERE sec_users_id = $1`, id, *reassignTo)
+		add(`UPDATE pdat_email_types SET sec_users_id = $2 WHERE sec_users_id = $1`, id, *reassignTo)
+		add(`UPDATE pdat_phone_type SET sec_users_id = $2 WHERE sec_users_id = $1`, id, *reassignTo)
+	} else {
+		add(`DELETE FROM pdat_person WHERE sec_orgs_id = (SELECT sec_orgs_id FROM sec_orgs WHERE personal_user_id = $1)`, id)
+		add(`DELETE FROM pdat_cal_pers WHERE pdat_calendar_id IN
+		         (SELECT pdat_calendar_id FROM pdat_calendar WHERE sec_users_id = $1)`, id)
+		add(`DELETE FROM pdat_calendar WHERE sec_users_id = $1`, id)
+		add(`DELETE FROM pdat_email_types t WHERE sec_users_id = $1
+		         AND NOT EXISTS (SELECT 1 FROM pdat_pers_emails e WHERE e.pdat_email_types_id = t.pdat_email_types_id)`, id)
+		add(`DELETE FROM pdat_phone_type t WHERE sec_users_id = $1
+		         AND NOT EXISTS (SELECT 1 FROM pdat_pers_phone p WHERE p.pdat_phone_type_id = t.pdat_phone_type_id)
+		         AND NOT EXISTS (SELECT 1 FROM pdat_company_phone p WHERE p.pdat_phone_type_id = t.pdat_phone_type_id)`, id)
+		add(`UPDATE pdat_email_types SET sec_users_id = $2 WHERE sec_users_id = $1`, id, typesTo)
+		add(`UPDATE pdat_phone_type SET sec_users_id = $2 WHERE sec_users_id = $1`, id, typesTo)
+	}
+
+	// Links the user added to people who remain go to those people's owners; links on no
+	// person follow the calendar
+	add(`UPDATE pdat_links l SET sec_users_id = p.sec_users_id FROM pdat_person p
+	     WHERE p.pdat_person_id = l.pdat_person_id AND l.sec_users_id = $1`, id)
+	if reassignTo != nil {
+		add(`UPDATE pdat_links SET sec_users_id = $2 WHERE sec_users_id = $1`, id, *reassignTo)
+	} else {
+		add(`DELETE FROM pdat_links WHERE sec_users_id = $1`, id)
+	}
+
+	// Memberships of shared organizations cascade from sec_users; companies, tags and
+	// custom fields in the personal organization cascade from it
+	add(`DELETE FROM sec_orgs WHERE personal_user_id = $1`, id)
+	add(`DELETE FROM pdat_passwd WHERE sec_users_id = $1`, id)
+	add(`DELETE FROM sec_acct_roles WHERE sec_accounts_id IN
+	         (SELECT sec_accounts_id FROM sec_accounts WHERE sec_users_id = $1)`, id)
+	// Refresh tokens, MFA enrollment and emailed tokens cascade from these
+	add(`DELETE FROM sec_accounts WHERE sec_users_id = $1`, id)
+	add(`DELETE FROM sec_users WHERE sec_users_id = $1`, id)
+
+	for i, query := range statements {
+		if _, err := tx.ExecContext(ctx, query, args[i]...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListAccountIDs returns the IDs of a user's accounts
+func (r *UserRepository) ListAccountIDs(ctx context.Context, userID int) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT sec_accounts_id FROM sec_accounts WHERE sec_users_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
 }
 
 // Search searches for users by name
@@ -267,7 +611,7 @@ func (r *UserRepository) Search(ctx context.Context, searchTerm string, limit, o
 	}
 
 	// Get users
-	query := `SELECT sec_users_id, fname, lname, create_date, create_user, modify_date, modify_user
+	query := `SELECT sec_users_id, fname, lname, email, create_date, create_user, modify_date, modify_user
 	          FROM sec_users WHERE fname ILIKE $1 OR lname ILIKE $1
 	          ORDER BY sec_users_id LIMIT $2 OFFSET $3`
 
@@ -284,6 +628,7 @@ func (r *UserRepository) Search(ctx context.Context, searchTerm string, limit, o
 			&user.ID,
 			&user.FirstName,
 			&user.LastName,
+			&user.Email,
 			&user.CreateDate,
 			&user.CreateUser,
 			&user.ModifyDate,
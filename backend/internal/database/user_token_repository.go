@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// UserTokenRepository handles single-use tokens emailed to users
+type UserTokenRepository struct {
+	db *DB
+}
+
+// NewUserTokenRepository creates a new UserTokenRepository
+func NewUserTokenRepository(db *DB) *UserTokenRepository {
+	return &UserTokenRepository{db: db}
+}
+
+// Create stores a new token (TokenHash must already be hashed)
+func (r *UserTokenRepository) Create(ctx context.Context, t *models.UserToken) error {
+	query := `INSERT INTO sec_user_tokens (sec_users_id, purpose, token_hash, email, expires_at)
+	          VALUES ($1, $2, $3, $4, $5) RETURNING sec_user_tokens_id, create_date`
+
+	return r.db.QueryRowContext(ctx, query,
+		t.UserID,
+		t.Purpose,
+		t.TokenHash,
+		t.Email,
+		t.ExpiresAt,
+	).Scan(&t.ID, &t.CreateDate)
+}
+
+// Consume marks an unused, unexpired token as used and returns it.
+// Returns nil if no such token exists, so each token can only be redeemed once.
+func (r *UserTokenRepository) Consume(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	query := `UPDATE sec_user_tokens SET used_at = NOW()
+	          WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+	          RETURNING sec_user_tokens_id, sec_users_id, purpose, token_hash, email, expires_at, used_at, create_date`
+
+	t := &models.UserToken{}
+	err := r.db.QueryRowContext(ctx, query, purpose, tokenHash).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.Purpose,
+		&t.TokenHash,
+		&t.Email,
+		&t.ExpiresAt,
+		&t.UsedAt,
+		&t.CreateDate,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// InvalidateForUser marks all outstanding tokens of a purpose for a user as used
+func (r *UserTokenRepository) InvalidateForUser(ctx context.Context, userID int, purpose string) error {
+	query := `UPDATE sec_user_tokens SET used_at = NOW()
+	          WHERE sec_users_id = $1 AND purpose = $2 AND used_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, userID, purpose)
+	return err
+}
+
+// LastIssuedAt returns when the most recent token of a purpose was issued to a user, or nil if never
+func (r *UserTokenRepository) LastIssuedAt(ctx context.Context, userID int, purpose string) (*time.Time, error) {
+	query := `SELECT MAX(create_date) FROM sec_user_tokens WHERE sec_users_id = $1 AND purpose = $2`
+
+	var last sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query, userID, purpose).Scan(&last); err != nil {
+		return nil, err
+	}
+	if !last.Valid {
+		return nil, nil
+	}
+	return &last.Time, nil
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// accessTokenPrefixLen is how much of a token is kept in clear so users can tell their tokens apart
+const accessTokenPrefixLen = len(models.AccessTokenPrefix) + 4
+
+// AccessTokenHandler handles the current account's personal access tokens
+type AccessTokenHandler struct {
+	tokenRepo *database.AccessTokenRepository
+	auditRepo *database.AuditRepository
+}
+
+// NewAccessTokenHandler creates a new access token handler
+func NewAccessTokenHandler(tokenRepo *database.AccessTokenRepository, auditRepo *database.AuditRepository) *AccessTokenHandler {
+	return &AccessTokenHandler{
+		tokenRepo: tokenRepo,
+		auditRepo: auditRepo,
+	}
+}
+
+// ListTokens returns the current account's active personal access tokens (never the tokens themselves)
+func (h *AccessTokenHandler) ListTokens(c *gin.Context) {
+	accountID, ok := middleware.GetAccountID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	tokens, err := h.tokenRepo.ListByAccount(c.Request.Context(), accountID)
+	if err != nil {
+		log.Printf("Failed to list access tokens for account %d: %v", accountID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateToken creates a personal access token for the current account.
+// The token is returned only in this response; just its hash is stored.
+func (h *AccessTokenHandler) CreateToken(c *gin.Context) {
+	var req models.CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	known := make(map[string]bool, len(models.AllScopes))
+	for _, scope := range models.AllScopes {
+		known[scope] = true
+	}
+	granted := make(map[string]bool, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !known[scope] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":       "Unknown scope: " + scope,
+				"validScopes": models.AllScopes,
+			})
+			return
+		}
+		granted[scope] = true
+	}
+	scopes := make([]string, 0, len(granted))
+	for scope := range granted {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	accountID, ok := middleware.GetAccountID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	userID, _ := middleware.GetUserID(c)
+
+	secret, err := utils.GenerateSecureToken()
+	if err != nil {
+		log.Printf("Failed to generate access token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create access token"})
+		return
+	}
+	raw := models.AccessTokenPrefix + secret
+
+	token := &models.AccessToken{
+		AccountID: accountID,
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    raw[:accessTokenPrefixLen],
+		TokenHash: utils.HashToken(raw),
+		Scopes:    scopes,
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := h.tokenRepo.Create(c.Request.Context(), token); err != nil {
+		log.Printf("Failed to create access token for account %d: %v", accountID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create access token"})
+		return
+	}
+
+	recordAudit(c, h.auditRepo, userID, models.AuditAccessTokenCreated, token.Name+" ("+strings.Join(scopes, " ")+")")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"accessToken": token,
+		"token":       raw,
+	})
+}
+
+// RevokeToken revokes one of the current account's personal access tokens
+func (h *AccessTokenHandler) RevokeToken(c *gin.Context) {
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	accountID, ok := middleware.GetAccountID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	revoked, err := h.tokenRepo.Revoke(c.Request.Context(), accountID, id)
+	if err != nil {
+		log.Printf("Failed to revoke access token %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access token"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Access token not found"})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	recordAudit(c, h.auditRepo, userID, models.AuditAccessTokenRevoked, "token "+c.Param("id"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access token revoked"})
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit writes an audit event for the given user, taking the account,
+// client IP and user agent from the request. Failures are logged, not returned,
+// so auditing never blocks the action being audited.
+func recordAudit(c *gin.Context, auditRepo *database.AuditRepository, userID int, event, detail string) {
+	ip := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+	e := &models.AuditEvent{
+		UserID:    &userID,
+		Event:     event,
+		IPAddress: &ip,
+		UserAgent: &userAgent,
+	}
+	if accountID, ok := middleware.GetAccountID(c); ok {
+		e.AccountID = &accountID
+	}
+	if detail != "" {
+		e.Detail = &detail
+	}
+
+	if err := auditRepo.Record(c.Request.Context(), e); err != nil {
+		log.Printf("Failed to record audit event %s for user %d: %v", event, userID, err)
+	}
+}
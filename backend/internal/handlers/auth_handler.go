@@ -1,35 +1,94 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
 	"github.com/davealexenglish/magnifimind-crm/internal/models"
 	"github.com/davealexenglish/magnifimind-crm/internal/services"
 	"github.com/davealexenglish/magnifimind-crm/pkg/config"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
 )
 
 // AuthHandler handles authentication endpoints
 type AuthHandler struct {
 	userRepo     *database.UserRepository
+	tokenRepo    *database.UserTokenRepository
+	auditRepo    *database.AuditRepository
+	mfaRepo      *database.MFARepository
+	sessionRepo  *database.SessionRepository
 	emailService *services.EmailService
+	revocations  *services.TokenRevocationService
+	throttle     *services.LoginThrottleService
+	tokens       *services.TokenIssuer
 	config       *config.Config
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(userRepo *database.UserRepository, emailService *services.EmailService, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(userRepo *database.UserRepository, tokenRepo *database.UserTokenRepository, auditRepo *database.AuditRepository, mfaRepo *database.MFARepository, sessionRepo *database.SessionRepository, emailService *services.EmailService, revocations *services.TokenRevocationService, throttle *services.LoginThrottleService, tokens *services.TokenIssuer, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
 		userRepo:     userRepo,
+		tokenRepo:    tokenRepo,
+		auditRepo:    auditRepo,
+		mfaRepo:      mfaRepo,
+		sessionRepo:  sessionRepo,
 		emailService: emailService,
+		revocations:  revocations,
+		throttle:     throttle,
+		tokens:       tokens,
 		config:       cfg,
 	}
 }
 
+// ForgotPasswordRequest represents a request to email a password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=6"`
+}
+
+// ChangePasswordRequest represents an authenticated password change
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=6"`
+}
+
+// ChangeEmailRequest represents an authenticated email change
+type ChangeEmailRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewEmail        string `json:"newEmail" binding:"required,email,max=254"`
+}
+
+// LogoutRequest represents a logout request
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// VerifyEmailRequest represents an email verification request
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest represents a request to resend the verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
 // Register handles user registration
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
@@ -38,6 +97,31 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+
+	// Reject duplicates up front so the client gets a clear 409
+	existingAccount, err := h.userRepo.FindByUsername(ctx, req.Username)
+	if err != nil {
+		log.Printf("Failed to look up account %s: %v", req.Username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+		return
+	}
+	if existingAccount != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		return
+	}
+
+	existingUser, err := h.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		log.Printf("Failed to look up email %s: %v", req.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+		return
+	}
+	if existingUser != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -45,15 +129,292 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// TODO: Create user in database
-	// For now, return success
+	email := strings.TrimSpace(req.Email)
+	user := &models.SecUser{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     &email,
+	}
+	account := &models.SecAccount{
+		Name:     req.Username,
+		Password: string(hashedPassword),
+	}
+
+	// Creates sec_users and sec_accounts rows in one transaction
+	if err := h.userRepo.Create(ctx, user, account); err != nil {
+		switch {
+		case errors.Is(err, database.ErrDuplicateAccountName):
+			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		case errors.Is(err, database.ErrDuplicateEmail):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		default:
+			log.Printf("Failed to create user %s: %v", req.Username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+		}
+		return
+	}
+
+	h.sendVerification(ctx, user)
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message":         "User registered successfully",
-		"username":        req.Username,
-		"hashed_password": string(hashedPassword),
+		"message": "User registered successfully. Please check your email to verify your account.",
+		"user":    user,
+		"account": gin.H{
+			"id":           account.ID,
+			"account_name": account.Name,
+		},
 	})
 }
 
+// issueUserToken invalidates outstanding tokens of the same purpose and stores a new one.
+// Returns the plaintext token, which is only ever sent to the user.
+func (h *AuthHandler) issueUserToken(ctx context.Context, userID int, purpose string, email *string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.tokenRepo.InvalidateForUser(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	userToken := &models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := h.tokenRepo.Create(ctx, userToken); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// tokenRecentlyIssued reports whether a token of this purpose was sent to the user within the window
+func (h *AuthHandler) tokenRecentlyIssued(ctx context.Context, userID int, purpose string, window time.Duration) (bool, error) {
+	lastIssued, err := h.tokenRepo.LastIssuedAt(ctx, userID, purpose)
+	if err != nil {
+		return false, err
+	}
+	return lastIssued != nil && time.Since(*lastIssued) < window, nil
+}
+
+// sendVerification issues a new verification token and emails it to the user.
+// Failures are logged rather than returned so a mail outage does not fail registration.
+func (h *AuthHandler) sendVerification(ctx context.Context, user *models.SecUser) {
+	if user.Email == nil || *user.Email == "" {
+		return
+	}
+
+	ttl := time.Duration(h.config.Auth.VerificationTokenTTLHours) * time.Hour
+	token, err := h.issueUserToken(ctx, user.ID, models.TokenPurposeVerifyEmail, user.Email, ttl)
+	if err != nil {
+		log.Printf("Failed to issue verification token for user %d: %v", user.ID, err)
+		return
+	}
+
+	if err := h.emailService.SendVerificationEmail(*user.Email, token); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", *user.Email, err)
+	}
+}
+
+// VerifyEmail redeems an email verification token.
+// Accepts the token as a ?token= query parameter (GET, from the emailed link) or a JSON body (POST).
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if c.Request.Method == http.MethodPost {
+		var req VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		token = req.Token
+	}
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification token required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	userToken, err := h.tokenRepo.Consume(ctx, models.TokenPurposeVerifyEmail, utils.HashToken(token))
+	if err != nil {
+		log.Printf("Failed to redeem verification token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+	if userToken == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	if err := h.userRepo.SetEmailVerified(ctx, userToken.UserID); err != nil {
+		log.Printf("Failed to mark user %d as verified: %v", userToken.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification emails a fresh verification token.
+// Always responds with the same message so it cannot be used to discover registered addresses.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "If that address belongs to an unverified account, a verification email has been sent"}
+	ctx := c.Request.Context()
+
+	user, err := h.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		log.Printf("Failed to look up email %s: %v", req.Email, err)
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	// Throttle: at most one verification email per user per resend window
+	window := time.Duration(h.config.Auth.VerificationResendSeconds) * time.Second
+	throttled, err := h.tokenRecentlyIssued(ctx, user.ID, models.TokenPurposeVerifyEmail, window)
+	if err != nil {
+		log.Printf("Failed to check verification throttle for user %d: %v", user.ID, err)
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+	if throttled {
+		log.Printf("Verification resend for user %d throttled", user.ID)
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	verified, err := h.userRepo.IsEmailVerified(ctx, user.ID)
+	if err != nil {
+		log.Printf("Failed to check verification status for user %d: %v", user.ID, err)
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+	if !verified {
+		h.sendVerification(ctx, user)
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// ForgotPassword emails a password reset link.
+// Always responds with the same message so it cannot be used to discover whether an account exists.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "If an account exists for that address, a password reset email has been sent"}
+	ctx := c.Request.Context()
+
+	user, err := h.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		log.Printf("Failed to look up email %s: %v", req.Email, err)
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+	if user == nil || user.Email == nil {
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	window := time.Duration(h.config.Auth.PasswordResetResendSeconds) * time.Second
+	throttled, err := h.tokenRecentlyIssued(ctx, user.ID, models.TokenPurposeResetPassword, window)
+	if err != nil {
+		log.Printf("Failed to check password reset throttle for user %d: %v", user.ID, err)
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+	if throttled {
+		log.Printf("Password reset for user %d throttled", user.ID)
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	ttl := time.Duration(h.config.Auth.PasswordResetTokenTTLMinutes) * time.Minute
+	token, err := h.issueUserToken(ctx, user.ID, models.TokenPurposeResetPassword, user.Email, ttl)
+	if err != nil {
+		log.Printf("Failed to issue password reset token for user %d: %v", user.ID, err)
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	if err := h.emailService.SendPasswordResetEmail(*user.Email, token); err != nil {
+		log.Printf("Failed to send password reset email to %s: %v", *user.Email, err)
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// ResetPassword redeems a password reset token, sets the new password and
+// revokes every existing session of the user
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	userToken, err := h.tokenRepo.Consume(ctx, models.TokenPurposeResetPassword, utils.HashToken(req.Token))
+	if err != nil {
+		log.Printf("Failed to redeem password reset token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	if userToken == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := h.userRepo.UpdatePasswordForUser(ctx, userToken.UserID, string(hashedPassword), "system"); err != nil {
+		log.Printf("Failed to update password for user %d: %v", userToken.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := h.revokeAllSessions(ctx, userToken.UserID); err != nil {
+		log.Printf("Failed to revoke sessions for user %d after password reset: %v", userToken.UserID, err)
+	}
+
+	// Receiving the reset email proves ownership of the address
+	if err := h.userRepo.SetEmailVerified(ctx, userToken.UserID); err != nil {
+		log.Printf("Failed to mark user %d as verified: %v", userToken.UserID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// emailVerificationBlocks reports whether an unverified account must be refused login
+func (h *AuthHandler) emailVerificationBlocks(account *models.SecAccount) bool {
+	if account.EmailVerified || !h.config.Auth.RequireEmailVerification {
+		return false
+	}
+	grace := time.Duration(h.config.Auth.VerificationGraceHours) * time.Hour
+	return time.Since(account.CreateDate) >= grace
+}
+
 // Login handles user login
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
@@ -62,49 +423,734 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+
 	// Get user by username (account name)
-	user, err := h.userRepo.FindByUsername(c.Request.Context(), req.Username)
-	if err != nil || user == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	user, err := h.userRepo.FindByUsername(ctx, req.Username)
+	if err != nil {
+		log.Printf("Failed to look up account %s: %v", req.Username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+
+	// Refuse while the client IP or account is backing off or locked, before checking the password
+	if !h.checkLoginAllowed(c, user) {
 		return
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		if locked := h.recordLoginFailure(c, user); locked {
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":      user.ID,
-		"account_name": user.Name,
-		"exp":          time.Now().Add(24 * time.Hour).Unix(),
+	if h.emailVerificationBlocks(user) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Email address not verified",
+			"code":  "email_not_verified",
+		})
+		return
+	}
+
+	// Accounts with TOTP enabled, or whose role requires it, get a challenge
+	// token instead of a session and finish the login at /auth/login/mfa
+	challenge, err := h.mfaGate(ctx, user)
+	if err != nil {
+		log.Printf("Failed to check MFA for account %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	h.completeLogin(c, user, nil)
+}
+
+// mfaGate returns the MFA challenge response for an account that has TOTP enabled or
+// whose role requires it, or nil if the account may be given a session straight away
+func (h *AuthHandler) mfaGate(ctx context.Context, account *models.SecAccount) (gin.H, error) {
+	mfa, err := h.mfaRepo.FindByAccountID(ctx, account.ID)
+	if err != nil {
+		return nil, err
+	}
+	mfaEnabled := mfa != nil && mfa.Enabled
+	mfaRequired := mfaEnabled
+	if !mfaRequired {
+		mfaRequired, err = h.mfaRepo.AccountRequiresMFA(ctx, account.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !mfaRequired {
+		return nil, nil
+	}
+
+	mfaToken, err := h.createMFAChallenge(ctx, account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"mfaRequired":           true,
+		"mfaEnrollmentRequired": !mfaEnabled,
+		"mfaToken":              mfaToken,
+		"expiresIn":             h.config.Auth.MFAChallengeTTLMinutes * 60,
+	}, nil
+}
+
+// checkLoginAllowed reports whether a login may be attempted from this client for this
+// account (nil if the name is unknown), writing a 429 response if it may not
+func (h *AuthHandler) checkLoginAllowed(c *gin.Context, account *models.SecAccount) bool {
+	accountID := 0
+	if account != nil {
+		accountID = account.ID
+	}
+
+	block, err := h.throttle.Blocked(c.Request.Context(), accountID, c.ClientIP())
+	if err != nil {
+		log.Printf("Failed to check login throttle for account %d: %v", accountID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return false
+	}
+	if block != nil {
+		respondLoginBlocked(c, block)
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed password or second factor. If the failure locks
+// the account, the owner is notified and a 429 response is written; returns true in that case.
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, account *models.SecAccount) bool {
+	ctx := c.Request.Context()
+	accountID := 0
+	if account != nil {
+		accountID = account.ID
+	}
+
+	lock, err := h.throttle.RecordFailure(ctx, accountID, c.ClientIP())
+	if err != nil {
+		log.Printf("Failed to record failed login for account %d: %v", accountID, err)
+		return false
+	}
+	if lock == nil {
+		return false
+	}
+
+	log.Printf("Account %d locked after repeated failed logins", accountID)
+	recordAudit(c, h.auditRepo, account.UserID, models.AuditAccountLocked, "until "+lock.Until.Format(time.RFC3339))
+
+	user, err := h.userRepo.FindByID(ctx, account.UserID)
+	if err != nil {
+		log.Printf("Failed to load user %d for lockout notice: %v", account.UserID, err)
+	} else if user != nil && user.Email != nil {
+		if err := h.emailService.SendAccountLockedEmail(*user.Email, lock.Until); err != nil {
+			log.Printf("Failed to send account locked email to %s: %v", *user.Email, err)
+		}
+	}
+
+	respondLoginBlocked(c, lock)
+	return true
+}
+
+// respondLoginBlocked writes a 429 response with a Retry-After header
+func respondLoginBlocked(c *gin.Context, block *services.LoginBlock) {
+	retryAfter := int(block.RetryAfter().Seconds()) + 1
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+
+	if block.Locked {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":      "Account temporarily locked after too many failed login attempts",
+			"code":       "account_locked",
+			"retryAfter": retryAfter,
+		})
+		return
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":      "Too many failed login attempts, try again later",
+		"code":       "login_throttled",
+		"retryAfter": retryAfter,
 	})
+}
 
-	tokenString, err := token.SignedString([]byte(h.config.JWT.Secret))
+// completeLogin starts a new session for an authenticated account and writes the login response
+func (h *AuthHandler) completeLogin(c *gin.Context, account *models.SecAccount, recoveryCodes []string) {
+	if err := h.throttle.RecordSuccess(c.Request.Context(), account.ID); err != nil {
+		log.Printf("Failed to clear failed logins for account %d: %v", account.ID, err)
+	}
+
+	response, err := h.startSession(c, account)
 	if err != nil {
+		log.Printf("Failed to issue tokens for account %d: %v", account.ID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	if recoveryCodes != nil {
+		response["recoveryCodes"] = recoveryCodes
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
+	c.JSON(http.StatusOK, response)
+}
+
+// startSession issues the token pair for a new session and returns the login response body
+func (h *AuthHandler) startSession(c *gin.Context, account *models.SecAccount) (gin.H, error) {
+	familyID := uuid.New().String()
+	accessToken, refreshToken, err := h.issueTokens(c, account, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    h.config.JWT.AccessTokenMinutes * 60,
 		"user": gin.H{
-			"id":           user.ID,
-			"account_name": user.Name,
+			"id":           account.ID,
+			"account_name": account.Name,
 		},
-	})
+	}, nil
 }
 
-// Refresh handles token refresh
+// createMFAChallenge stores a short-lived challenge for a password or single sign-on login and returns its plaintext token
+func (h *AuthHandler) createMFAChallenge(ctx context.Context, accountID int) (string, error) {
+	if err := h.mfaRepo.PruneChallenges(ctx); err != nil {
+		log.Printf("Failed to prune expired MFA challenges: %v", err)
+	}
+
+	token, err := utils.GenerateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	challenge := &models.MFAChallenge{
+		TokenHash: utils.HashToken(token),
+		AccountID: accountID,
+		ExpiresAt: time.Now().Add(time.Duration(h.config.Auth.MFAChallengeTTLMinutes) * time.Minute),
+	}
+	if err := h.mfaRepo.CreateChallenge(ctx, challenge); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// loadMFAChallenge resolves an MFA token to its challenge and account, writing an error response if it cannot
+func (h *AuthHandler) loadMFAChallenge(c *gin.Context, mfaToken string) (*models.MFAChallenge, *models.SecAccount, bool) {
+	ctx := c.Request.Context()
+
+	challenge, err := h.mfaRepo.FindChallenge(ctx, utils.HashToken(mfaToken))
+	if err != nil {
+		log.Printf("Failed to look up MFA challenge: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return nil, nil, false
+	}
+	if challenge == nil || challenge.Attempts >= h.config.Auth.MFAChallengeMaxAttempts {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return nil, nil, false
+	}
+
+	account, err := h.userRepo.FindAccountByID(ctx, challenge.AccountID)
+	if err != nil || account == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return nil, nil, false
+	}
+
+	return challenge, account, true
+}
+
+// LoginMFAEnroll starts TOTP enrollment during login for an account whose role requires MFA
+// but which has not enrolled yet. The login is finished by sending a code to /auth/login/mfa.
+func (h *AuthHandler) LoginMFAEnroll(c *gin.Context) {
+	var req models.MFAEnrollChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, account, ok := h.loadMFAChallenge(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	mfa, err := h.mfaRepo.FindByAccountID(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to load MFA for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+	if mfa != nil && mfa.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	enrollment, err := startEnrollment(ctx, h.mfaRepo, h.config, account)
+	if err != nil {
+		log.Printf("Failed to start MFA enrollment for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// LoginMFA completes a login with a TOTP code or a recovery code.
+// If the account was enrolling during login, a valid code also enables TOTP and
+// the response includes the new recovery codes.
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req models.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.Code == "") == (req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either code or recoveryCode"})
+		return
+	}
+
+	challenge, account, ok := h.loadMFAChallenge(c, req.MFAToken)
+	if !ok {
+		return
+	}
+	if !h.checkLoginAllowed(c, account) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	mfa, err := h.mfaRepo.FindByAccountID(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to load MFA for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+	if mfa == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Two-factor enrollment required",
+			"code":  "mfa_enrollment_required",
+		})
+		return
+	}
+
+	var valid bool
+	if req.Code != "" {
+		valid, err = verifyTOTP(ctx, h.mfaRepo, mfa, req.Code)
+	} else if mfa.Enabled {
+		valid, err = h.mfaRepo.ConsumeRecoveryCode(ctx, account.ID, utils.HashToken(utils.NormalizeRecoveryCode(req.RecoveryCode)))
+	}
+	if err != nil {
+		log.Printf("Failed to verify second factor for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+	if !valid {
+		if _, err := h.mfaRepo.RecordChallengeFailure(ctx, challenge.ID); err != nil {
+			log.Printf("Failed to record MFA failure for challenge %d: %v", challenge.ID, err)
+		}
+		if locked := h.recordLoginFailure(c, account); locked {
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	// The same challenge must not complete two logins
+	consumed, err := h.mfaRepo.ConsumeChallenge(ctx, challenge.ID)
+	if err != nil {
+		log.Printf("Failed to consume MFA challenge %d: %v", challenge.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+	if !consumed {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	var recoveryCodes []string
+	if !mfa.Enabled {
+		if err := h.mfaRepo.Enable(ctx, account.ID); err != nil {
+			log.Printf("Failed to enable MFA for account %d: %v", account.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+			return
+		}
+		recoveryCodes, err = issueRecoveryCodes(ctx, h.mfaRepo, account.ID)
+		if err != nil {
+			log.Printf("Failed to issue recovery codes for account %d: %v", account.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+			return
+		}
+		recordAudit(c, h.auditRepo, account.UserID, models.AuditMFAEnabled, account.Name)
+	}
+	if req.RecoveryCode != "" {
+		recordAudit(c, h.auditRepo, account.UserID, models.AuditMFARecoveryCodeUsed, account.Name)
+	}
+
+	h.completeLogin(c, account, recoveryCodes)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh token.
+// Each refresh token can be used once; presenting one that was already rotated
+// is treated as theft and revokes every token in its family.
 func (h *AuthHandler) Refresh(c *gin.Context) {
-	// TODO: Implement refresh token logic
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	rt, err := h.userRepo.FindRefreshToken(ctx, utils.HashToken(req.RefreshToken))
+	if err != nil {
+		log.Printf("Failed to look up refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+	if rt == nil || rt.Revoked || time.Now().After(rt.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	rotated := false
+	if rt.RotatedAt == nil {
+		rotated, err = h.userRepo.MarkRefreshTokenRotated(ctx, rt.ID)
+		if err != nil {
+			log.Printf("Failed to rotate refresh token %d: %v", rt.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+			return
+		}
+	}
+	if !rotated {
+		log.Printf("Refresh token reuse detected for account %d, revoking family %s", rt.AccountID, rt.FamilyID)
+		if err := h.userRepo.RevokeRefreshTokenFamily(ctx, rt.FamilyID); err != nil {
+			log.Printf("Failed to revoke refresh token family %s: %v", rt.FamilyID, err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	account, err := h.userRepo.FindAccountByID(ctx, rt.AccountID)
+	if err != nil || account == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	accessToken, refreshToken, err := h.issueTokens(c, account, rt.FamilyID)
+	if err != nil {
+		log.Printf("Failed to issue tokens for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    h.config.JWT.AccessTokenMinutes * 60,
+	})
+}
+
+// issueTokens signs a short-lived access token and stores a new refresh token in the given family,
+// recording the client it was issued to in the session list
+func (h *AuthHandler) issueTokens(c *gin.Context, account *models.SecAccount, familyID string) (string, string, error) {
+	ctx := c.Request.Context()
+	accessToken, err := h.tokens.IssueAccessToken(account, familyID)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	rt := &models.RefreshToken{
+		UserID:    account.UserID,
+		AccountID: account.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(time.Duration(h.config.JWT.RefreshTokenDays) * 24 * time.Hour),
+	}
+	if err := h.userRepo.SaveRefreshToken(ctx, rt); err != nil {
+		return "", "", err
+	}
+
+	ip := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+	session := &models.Session{
+		ID:        familyID,
+		UserID:    account.UserID,
+		AccountID: account.ID,
+		UserAgent: &userAgent,
+		IPAddress: &ip,
+	}
+	if err := h.sessionRepo.Record(ctx, session); err != nil {
+		log.Printf("Failed to record session %s for account %d: %v", familyID, account.ID, err)
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// JWKS publishes the public keys that verify access tokens so other services can
+// validate them without sharing a secret. The set is empty when tokens are signed with HS256.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokens.JWKS())
 }
 
-// Logout handles user logout
+// Logout ends the current session: the access token is added to the revocation
+// list and the session's refresh token family is revoked
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// For JWT, logout is typically handled client-side by removing the token
+	var req LogoutRequest
+	// Body is optional
+	_ = c.ShouldBindJSON(&req)
+
+	ctx := c.Request.Context()
+	userID, _ := middleware.GetUserID(c)
+
+	if jti, expiresAt, ok := middleware.GetTokenID(c); ok {
+		if err := h.revocations.RevokeToken(ctx, jti, userID, expiresAt); err != nil {
+			log.Printf("Failed to revoke access token for user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
+
+	if sessionID, ok := middleware.GetSessionID(c); ok {
+		if err := h.userRepo.RevokeRefreshTokenFamily(ctx, sessionID); err != nil {
+			log.Printf("Failed to revoke session %s: %v", sessionID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		rt, err := h.userRepo.FindRefreshToken(ctx, utils.HashToken(req.RefreshToken))
+		if err == nil && rt != nil && rt.UserID == userID {
+			if err := h.userRepo.RevokeRefreshTokenFamily(ctx, rt.FamilyID); err != nil {
+				log.Printf("Failed to revoke session %s: %v", rt.FamilyID, err)
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
+
+// LogoutAll ends every session of the authenticated user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	if err := h.revokeAllSessions(c.Request.Context(), userID); err != nil {
+		log.Printf("Failed to revoke sessions for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out all sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions logged out successfully"})
+}
+
+// revokeAllSessions revokes every refresh token and outstanding access token of a user
+func (h *AuthHandler) revokeAllSessions(ctx context.Context, userID int) error {
+	if err := h.userRepo.RevokeAllRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+	return h.revocations.RevokeAllForUser(ctx, userID)
+}
+
+// ChangePassword changes the authenticated account's password.
+// Every other session is revoked; the caller receives a fresh token pair for the current session.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, ok := currentAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := h.userRepo.UpdateAccountPassword(ctx, account.ID, string(hashedPassword), account.Name); err != nil {
+		log.Printf("Failed to update password for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	recordAudit(c, h.auditRepo, account.UserID, models.AuditPasswordChanged, "")
+
+	// Revoke the other sessions one by one rather than with a user-wide cutoff, which
+	// would also catch the token issued below if it fell in the same second
+	sessionID, ok := middleware.GetSessionID(c)
+	if !ok {
+		sessionID = uuid.New().String()
+	}
+	families, err := h.userRepo.RevokeRefreshTokensExcept(ctx, account.UserID, sessionID)
+	if err != nil {
+		log.Printf("Failed to revoke other sessions for user %d: %v", account.UserID, err)
+	}
+	// Access tokens issued to those sessions live at most this long
+	expiresAt := time.Now().Add(time.Duration(h.config.JWT.AccessTokenMinutes) * time.Minute)
+	for _, family := range families {
+		if err := h.revocations.RevokeSession(ctx, family, account.UserID, expiresAt); err != nil {
+			log.Printf("Failed to revoke access tokens of session %s: %v", family, err)
+		}
+	}
+
+	accessToken, refreshToken, err := h.issueTokens(c, account, sessionID)
+	if err != nil {
+		log.Printf("Failed to issue tokens for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Password changed successfully",
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    h.config.JWT.AccessTokenMinutes * 60,
+	})
+}
+
+// ChangeEmail starts an email change: a confirmation link is sent to the new
+// address and the change only takes effect once it is redeemed
+func (h *AuthHandler) ChangeEmail(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, ok := currentAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	newEmail := strings.TrimSpace(req.NewEmail)
+	existing, err := h.userRepo.FindByEmail(ctx, newEmail)
+	if err != nil {
+		log.Printf("Failed to look up email %s: %v", newEmail, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	}
+
+	ttl := time.Duration(h.config.Auth.VerificationTokenTTLHours) * time.Hour
+	token, err := h.issueUserToken(ctx, account.UserID, models.TokenPurposeChangeEmail, &newEmail, ttl)
+	if err != nil {
+		log.Printf("Failed to issue email change token for user %d: %v", account.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	if err := h.emailService.SendEmailChangeConfirmation(newEmail, token); err != nil {
+		log.Printf("Failed to send email change confirmation to %s: %v", newEmail, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation email"})
+		return
+	}
+
+	recordAudit(c, h.auditRepo, account.UserID, models.AuditEmailChangeRequested, newEmail)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "A confirmation link has been sent to the new address"})
+}
+
+// ConfirmEmailChange redeems an email change token and switches the user's email address.
+// Accepts the token as a ?token= query parameter (GET) or a JSON body (POST).
+func (h *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	token := c.Query("token")
+	if c.Request.Method == http.MethodPost {
+		var req VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		token = req.Token
+	}
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Confirmation token required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	userToken, err := h.tokenRepo.Consume(ctx, models.TokenPurposeChangeEmail, utils.HashToken(token))
+	if err != nil {
+		log.Printf("Failed to redeem email change token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+	if userToken == nil || userToken.Email == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		return
+	}
+
+	if err := h.userRepo.UpdateEmail(ctx, userToken.UserID, *userToken.Email, "system"); err != nil {
+		if errors.Is(err, database.ErrDuplicateEmail) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+			return
+		}
+		log.Printf("Failed to update email for user %d: %v", userToken.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	if err := h.userRepo.SetEmailVerified(ctx, userToken.UserID); err != nil {
+		log.Printf("Failed to mark user %d as verified: %v", userToken.UserID, err)
+	}
+
+	recordAudit(c, h.auditRepo, userToken.UserID, models.AuditEmailChanged, *userToken.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+}
+
+// currentAccount loads the authenticated account, writing an error response if it cannot
+func currentAccount(c *gin.Context, userRepo *database.UserRepository) (*models.SecAccount, bool) {
+	accountID, ok := middleware.GetAccountID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return nil, false
+	}
+
+	account, err := userRepo.FindAccountByID(c.Request.Context(), accountID)
+	if err != nil {
+		log.Printf("Failed to load account %d: %v", accountID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, false
+	}
+	if account == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return nil, false
+	}
+
+	return account, true
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/services"
+	"github.com/davealexenglish/magnifimind-crm/internal/testutil"
+	"github.com/davealexenglish/magnifimind-crm/pkg/config"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// passwordTest is an account signed in on two sessions, with /me routes behind the real
+// AuthMiddleware and revocation list
+type passwordTest struct {
+	router  *gin.Engine
+	current string // access token of the session changing the password
+	other   string // access token of another session
+}
+
+// newPasswordTest wires ChangePassword the way main.go does
+func newPasswordTest(t *testing.T) *passwordTest {
+	t.Helper()
+	db := testutil.DB(t)
+	account := testutil.CreateUser(t, db, "password")
+
+	cfg := &config.Config{}
+	cfg.JWT = config.JWTConfig{Issuer: "crm-test", AccessTokenMinutes: 15, RefreshTokenDays: 7}
+	keys, err := utils.NewJWTKeySet(cfg.JWT.Issuer, utils.NewHMACKey("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := services.NewTokenIssuer(keys, 15*time.Minute)
+	userRepo := database.NewUserRepository(db)
+	revocations := services.NewTokenRevocationService(database.NewRevocationRepository(db))
+	h := NewAuthHandler(userRepo, database.NewUserTokenRepository(db), database.NewAuditRepository(db), database.NewMFARepository(db),
+		database.NewSessionRepository(db), nil, revocations, nil, tokens, cfg)
+
+	// A session is an access token and a refresh token sharing a family
+	session := func() string {
+		family := uuid.New().String()
+		token, err := tokens.IssueAccessToken(account, family)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refresh, err := utils.GenerateRefreshToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := userRepo.SaveRefreshToken(context.Background(), &models.RefreshToken{
+			UserID: account.UserID, AccountID: account.ID, TokenHash: utils.HashToken(refresh),
+			FamilyID: family, ExpiresAt: time.Now().Add(time.Hour),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	me := router.Group("/me")
+	me.Use(middleware.AuthMiddleware(tokens, revocations, nil, nil, nil))
+	{
+		me.PUT("/password", h.ChangePassword)
+		me.GET("", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+	}
+
+	return &passwordTest{router: router, current: session(), other: session()}
+}
+
+// do sends a request with a bearer token
+func (p *passwordTest) do(method, target, token string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	p.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestChangePasswordKeepsTheCurrentSession(t *testing.T) {
+	p := newPasswordTest(t)
+
+	w := p.do(http.MethodPut, "/me/password", p.current, gin.H{"currentPassword": testutil.Password, "newPassword": "new-password"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("change password status = %d, want 200: %s", w.Code, w.Body)
+	}
+	token, _ := decodeBody(t, w)["token"].(string)
+	if token == "" {
+		t.Fatalf("no token in %s", w.Body)
+	}
+
+	// The new token is used straight away, usually within the second of the change
+	if w := p.do(http.MethodGet, "/me", token, nil); w.Code != http.StatusOK {
+		t.Errorf("new token status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if w := p.do(http.MethodGet, "/me", p.current, nil); w.Code != http.StatusOK {
+		t.Errorf("current session's old token status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if w := p.do(http.MethodGet, "/me", p.other, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("other session's token status = %d, want 401: %s", w.Code, w.Body)
+	}
+}
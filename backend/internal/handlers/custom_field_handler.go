@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// customFieldKeyPattern is what a custom field key must look like, e.g. "linkedin_url"
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// CustomFieldHandler manages the custom fields the active organization defines for its people
+type CustomFieldHandler struct {
+	fieldRepo *database.CustomFieldRepository
+}
+
+// NewCustomFieldHandler creates a new custom field handler
+func NewCustomFieldHandler(fieldRepo *database.CustomFieldRepository) *CustomFieldHandler {
+	return &CustomFieldHandler{fieldRepo: fieldRepo}
+}
+
+// ListCustomFields returns the active organization's custom fields in display order
+func (h *CustomFieldHandler) ListCustomFields(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	fields, err := h.fieldRepo.List(c.Request.Context(), scope.OrgID)
+	if err != nil {
+		log.Printf("Failed to list custom fields of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list custom fields"})
+		return
+	}
+
+	c.JSON(http.StatusOK, fields)
+}
+
+// GetCustomField returns a custom field in the active organization
+func (h *CustomFieldHandler) GetCustomField(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid custom field ID"})
+		return
+	}
+
+	field, err := h.fieldRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil {
+		log.Printf("Failed to load custom field %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if field == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Custom field not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, field)
+}
+
+// CreateCustomField defines a custom field in the active organization
+func (h *CustomFieldHandler) CreateCustomField(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req models.CustomFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !customFieldKeyPattern.MatchString(req.Key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key must start with a lowercase letter and contain only lowercase letters, digits and underscores"})
+		return
+	}
+	options, err := customFieldOptions(req.Type, req.Options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	field := &models.CustomField{
+		Key:        req.Key,
+		Label:      req.Label,
+		Type:       req.Type,
+		Options:    options,
+		Required:   req.Required,
+		SortOrder:  req.SortOrder,
+		CreateUser: &actor,
+		ModifyUser: &actor,
+	}
+
+	err = h.fieldRepo.Create(c.Request.Context(), scope, field)
+	if errors.Is(err, database.ErrDuplicateCustomField) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A custom field with this key already exists"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create custom field %q in org %d: %v", req.Key, scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create custom field"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, field)
+}
+
+// UpdateCustomField changes a custom field's label, options, required flag or position
+func (h *CustomFieldHandler) UpdateCustomField(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid custom field ID"})
+		return
+	}
+
+	var req models.CustomFieldUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	field, err := h.fieldRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil {
+		log.Printf("Failed to load custom field %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if field == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Custom field not found"})
+		return
+	}
+
+	options, err := customFieldOptions(field.Type, req.Options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	field.Label = req.Label
+	field.Options = options
+	field.Required = req.Required
+	field.SortOrder = req.SortOrder
+	field.ModifyUser = &actor
+
+	updated, err := h.fieldRepo.Update(c.Request.Context(), scope, field)
+	if err != nil {
+		log.Printf("Failed to update custom field %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update custom field"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Custom field not found"})
+		return
+	}
+
+	field, err = h.fieldRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil || field == nil {
+		log.Printf("Failed to reload custom field %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, field)
+}
+
+// DeleteCustomField deletes a custom field and everyone's values for it
+func (h *CustomFieldHandler) DeleteCustomField(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid custom field ID"})
+		return
+	}
+
+	deleted, err := h.fieldRepo.Delete(c.Request.Context(), scope, id)
+	if err != nil {
+		log.Printf("Failed to delete custom field %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete custom field"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Custom field not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Custom field deleted"})
+}
+
+// customFieldOptions checks the options of a field type: select fields need at least one
+// and no repeats, other types take none
+func customFieldOptions(fieldType string, options []string) ([]string, error) {
+	if fieldType != models.CustomFieldSelect {
+		if len(options) > 0 {
+			return nil, fmt.Errorf("only select fields take options")
+		}
+		return []string{}, nil
+	}
+
+	if len(options) == 0 {
+		return nil, fmt.Errorf("select fields need at least one option")
+	}
+	seen := make(map[string]bool, len(options))
+	for _, option := range options {
+		if seen[option] {
+			return nil, fmt.Errorf("option %q is listed twice", option)
+		}
+		seen[option] = true
+	}
+	return options, nil
+}
+
+// customFieldValues checks the values in input, keyed by field key, against an
+// organization's custom fields and converts them for storage. A null value clears a
+// field. With creating set, every required field must have a value.
+func customFieldValues(fields []*models.CustomField, input map[string]interface{}, creating bool) ([]models.CustomFieldValue, error) {
+	byKey := make(map[string]*models.CustomField, len(fields))
+	for _, field := range fields {
+		byKey[field.Key] = field
+	}
+	for key := range input {
+		if byKey[key] == nil {
+			return nil, fmt.Errorf("unknown custom field %q", key)
+		}
+	}
+
+	var values []models.CustomFieldValue
+	for _, field := range fields {
+		raw, given := input[field.Key]
+		if !given {
+			if creating && field.Required {
+				return nil, fmt.Errorf("custom field %q is required", field.Key)
+			}
+			continue
+		}
+
+		value, err := customFieldValue(field, raw)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// customFieldValue converts a JSON value for a custom field, checking it suits the field's type
+func customFieldValue(field *models.CustomField, raw interface{}) (models.CustomFieldValue, error) {
+	value := models.CustomFieldValue{FieldID: field.ID}
+	if s, ok := raw.(string); ok && field.Type != models.CustomFieldText && strings.TrimSpace(s) == "" {
+		raw = nil
+	}
+	if raw == nil {
+		if field.Required {
+			return value, fmt.Errorf("custom field %q is required", field.Key)
+		}
+		return value, nil
+	}
+
+	switch field.Type {
+	case models.CustomFieldText:
+		s, ok := raw.(string)
+		if !ok {
+			return value, fmt.Errorf("custom field %q must be text", field.Key)
+		}
+		if utf8.RuneCountInString(s) > models.MaxCustomTextLength {
+			return value, fmt.Errorf("custom field %q must be at most %d characters", field.Key, models.MaxCustomTextLength)
+		}
+		if s == "" && field.Required {
+			return value, fmt.Errorf("custom field %q is required", field.Key)
+		}
+		if s != "" {
+			value.Text = &s
+		}
+	case models.CustomFieldNumber:
+		n, ok := raw.(float64)
+		if !ok {
+			return value, fmt.Errorf("custom field %q must be a number", field.Key)
+		}
+		value.Number = &n
+	case models.CustomFieldDate:
+		s, _ := raw.(string)
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return value, fmt.Errorf("custom field %q must be a date (YYYY-MM-DD)", field.Key)
+		}
+		value.Date = &d
+	case models.CustomFieldSelect:
+		s, _ := raw.(string)
+		valid := false
+		for _, option := range field.Options {
+			if s == option {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return value, fmt.Errorf("custom field %q must be one of: %s", field.Key, strings.Join(field.Options, ", "))
+		}
+		value.Text = &s
+	case models.CustomFieldBoolean:
+		b, ok := raw.(bool)
+		if !ok {
+			return value, fmt.Errorf("custom field %q must be true or false", field.Key)
+		}
+		value.Bool = &b
+	}
+	return value, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/services"
+	"github.com/davealexenglish/magnifimind-crm/pkg/config"
+)
+
+// ImpersonationHandler lets admins act as another account to troubleshoot what it sees
+type ImpersonationHandler struct {
+	userRepo   *database.UserRepository
+	auditRepo  *database.AuditRepository
+	privileges *services.PrivilegeService
+	tokens     *services.TokenIssuer
+	config     *config.Config
+}
+
+// NewImpersonationHandler creates a new impersonation handler
+func NewImpersonationHandler(userRepo *database.UserRepository, auditRepo *database.AuditRepository, privileges *services.PrivilegeService, tokens *services.TokenIssuer, cfg *config.Config) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		userRepo:   userRepo,
+		auditRepo:  auditRepo,
+		privileges: privileges,
+		tokens:     tokens,
+		config:     cfg,
+	}
+}
+
+// Impersonate issues a short-lived access token acting as another account. The token names
+// the admin in its act claim, cannot be refreshed, and every request made with it is audited.
+// Accounts that can manage users or impersonate cannot be impersonated.
+// Requires the users.impersonate privilege.
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	actor, ok := currentAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+	target, ok := loadAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	if target.ID == actor.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot impersonate yourself"})
+		return
+	}
+
+	privileges, err := h.privileges.Privileges(ctx, target.ID)
+	if err != nil {
+		log.Printf("Failed to load privileges for account %d: %v", target.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if privileges[models.PrivUsersManage] || privileges[models.PrivImpersonate] {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Administrators cannot be impersonated"})
+		return
+	}
+
+	ttl := time.Duration(h.config.Auth.ImpersonationMinutes) * time.Minute
+	token, expiresAt, err := h.tokens.IssueImpersonationToken(target, actor, ttl)
+	if err != nil {
+		log.Printf("Failed to issue impersonation token for account %d: %v", target.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	recordAudit(c, h.auditRepo, actor.UserID, models.AuditImpersonationStarted, target.Name+" (account "+strconv.Itoa(target.ID)+")")
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":     token,
+		"expiresIn": int(ttl.Seconds()),
+		"expiresAt": expiresAt,
+		"user": gin.H{
+			"id":           target.ID,
+			"account_name": target.Name,
+		},
+		"impersonator": gin.H{
+			"id":           actor.ID,
+			"account_name": actor.Name,
+		},
+	})
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/services"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// maxDuplicatePairs limits how many pairs a duplicate search returns
+const maxDuplicatePairs = 500
+
+// MergeHandler finds likely duplicate people in the active organization and merges them
+type MergeHandler struct {
+	mergeRepo  *database.MergeRepository
+	fieldRepo  *database.CustomFieldRepository
+	duplicates *services.DuplicateService
+}
+
+// NewMergeHandler creates a new merge handler
+func NewMergeHandler(mergeRepo *database.MergeRepository, fieldRepo *database.CustomFieldRepository, duplicates *services.DuplicateService) *MergeHandler {
+	return &MergeHandler{
+		mergeRepo:  mergeRepo,
+		fieldRepo:  fieldRepo,
+		duplicates: duplicates,
+	}
+}
+
+// FindDuplicates returns pairs of people likely to be the same, best first. min_score
+// (default 40) and limit (default 50) narrow the results.
+func (h *MergeHandler) FindDuplicates(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	minScore, err := strconv.Atoi(c.DefaultQuery("min_score", "40"))
+	if err != nil || minScore < 1 || minScore > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_score must be between 1 and 100"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > maxDuplicatePairs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxDuplicatePairs)})
+		return
+	}
+
+	pairs, err := h.duplicates.Find(c.Request.Context(), scope, minScore, limit)
+	if err != nil {
+		log.Printf("Failed to find duplicate people in org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pairs)
+}
+
+// MergePeople merges one person into another. The merged person's emails, phones,
+// addresses, notes and links move to the survivor and the merged person is
+// deactivated; the returned merge record can undo it.
+func (h *MergeHandler) MergePeople(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req models.MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fields, err := h.fieldRepo.List(c.Request.Context(), scope.OrgID)
+	if err != nil {
+		log.Printf("Failed to list custom fields of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	fieldIDs := make(map[string]int, len(fields))
+	for _, field := range fields {
+		fieldIDs[field.Key] = field.ID
+	}
+
+	personChoices := map[string]string{}
+	customChoices := map[int]string{}
+	for name, choice := range req.Fields {
+		if key, custom := strings.CutPrefix(name, "cf."); custom {
+			id, found := fieldIDs[key]
+			if !found {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown custom field " + strconv.Quote(key)})
+				return
+			}
+			customChoices[id] = choice
+			continue
+		}
+		if !isMergeField(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot choose a value for field " + strconv.Quote(name)})
+			return
+		}
+		personChoices[name] = choice
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	merge, err := h.mergeRepo.Merge(c.Request.Context(), scope, req.SurvivorID, req.MergedID, personChoices, customChoices, actor)
+	if err != nil {
+		log.Printf("Failed to merge person %d into %d: %v", req.MergedID, req.SurvivorID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge people"})
+		return
+	}
+	if merge == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Person not found"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, merge)
+}
+
+// UndoMerge reverses a merge, moving rows back to the merged person and restoring the
+// survivor's replaced values
+func (h *MergeHandler) UndoMerge(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge ID"})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	found, err := h.mergeRepo.Undo(c.Request.Context(), scope, id, actor)
+	if errors.Is(err, database.ErrMergeUndone) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This merge was already undone"})
+		return
+	}
+	if errors.Is(err, database.ErrMergeSuperseded) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A later merge involves these people; undo it first"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to undo merge %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo merge"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merge not found"})
+		return
+	}
+
+	merge, err := h.mergeRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil || merge == nil {
+		log.Printf("Failed to reload merge %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, merge)
+}
+
+// ListPersonMerges returns the merges a person took part in, newest first
+func (h *MergeHandler) ListPersonMerges(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	personID, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid person ID"})
+		return
+	}
+
+	merges, err := h.mergeRepo.ListForPerson(c.Request.Context(), scope, personID)
+	if err != nil {
+		log.Printf("Failed to list merges of person %d: %v", personID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list merges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, merges)
+}
+
+// isMergeField reports whether a merge request can choose a value for a person field
+func isMergeField(name string) bool {
+	for _, field := range models.MergeFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
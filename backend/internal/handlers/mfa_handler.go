@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/config"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+// MFAHandler handles two-factor authentication settings for the current account
+type MFAHandler struct {
+	userRepo  *database.UserRepository
+	mfaRepo   *database.MFARepository
+	auditRepo *database.AuditRepository
+	config    *config.Config
+}
+
+// NewMFAHandler creates a new MFA handler
+func NewMFAHandler(userRepo *database.UserRepository, mfaRepo *database.MFARepository, auditRepo *database.AuditRepository, cfg *config.Config) *MFAHandler {
+	return &MFAHandler{
+		userRepo:  userRepo,
+		mfaRepo:   mfaRepo,
+		auditRepo: auditRepo,
+		config:    cfg,
+	}
+}
+
+// RoleMFARequest sets whether a role requires two-factor authentication
+type RoleMFARequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// verifyTOTP checks a code against an enrollment and records its time step so it cannot be reused
+func verifyTOTP(ctx context.Context, mfaRepo *database.MFARepository, mfa *models.AccountMFA, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return mfaRepo.UseStep(ctx, mfa.AccountID, step)
+}
+
+// issueRecoveryCodes replaces an account's recovery codes and returns the new plaintext codes.
+// Only hashes are stored, so this is the only time the codes can be shown.
+func issueRecoveryCodes(ctx context.Context, mfaRepo *database.MFARepository, accountID int) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+	if err := mfaRepo.ReplaceRecoveryCodes(ctx, accountID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// startEnrollment generates and stores a pending TOTP secret, returning the enrollment details
+func startEnrollment(ctx context.Context, mfaRepo *database.MFARepository, cfg *config.Config, account *models.SecAccount) (gin.H, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := mfaRepo.SavePendingSecret(ctx, account.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"secret":     secret,
+		"otpauthUri": utils.TOTPURI(cfg.Auth.MFAIssuer, account.Name, secret),
+		"digits":     utils.TOTPDigits,
+		"period":     utils.TOTPPeriod,
+	}, nil
+}
+
+// GetStatus returns the current account's two-factor authentication status
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	account, ok := currentAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	mfa, err := h.mfaRepo.FindByAccountID(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to load MFA for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	required, err := h.mfaRepo.AccountRequiresMFA(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to check MFA requirement for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	status := gin.H{
+		"enabled":  mfa != nil && mfa.Enabled,
+		"required": required,
+	}
+	if mfa != nil && mfa.Enabled {
+		remaining, err := h.mfaRepo.CountRecoveryCodes(ctx, account.ID)
+		if err != nil {
+			log.Printf("Failed to count recovery codes for account %d: %v", account.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		status["confirmedAt"] = mfa.ConfirmedAt
+		status["recoveryCodesRemaining"] = remaining
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollTOTP generates a new TOTP secret for the current account.
+// The secret is not active until confirmed with a code from the authenticator app.
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	account, ok := currentAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	mfa, err := h.mfaRepo.FindByAccountID(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to load MFA for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if mfa != nil && mfa.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	enrollment, err := startEnrollment(ctx, h.mfaRepo, h.config, account)
+	if err != nil {
+		log.Printf("Failed to start MFA enrollment for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP enables TOTP for the current account once the user proves they can generate codes.
+// Returns the account's recovery codes.
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, ok := currentAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	mfa, err := h.mfaRepo.FindByAccountID(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to load MFA for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if mfa == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No pending enrollment; start enrollment first"})
+		return
+	}
+	if mfa.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	valid, err := verifyTOTP(ctx, h.mfaRepo, mfa, req.Code)
+	if err != nil {
+		log.Printf("Failed to verify TOTP code for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm enrollment"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	if err := h.mfaRepo.Enable(ctx, account.ID); err != nil {
+		log.Printf("Failed to enable MFA for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm enrollment"})
+		return
+	}
+	codes, err := issueRecoveryCodes(ctx, h.mfaRepo, account.ID)
+	if err != nil {
+		log.Printf("Failed to issue recovery codes for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	recordAudit(c, h.auditRepo, account.UserID, models.AuditMFAEnabled, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Two-factor authentication enabled",
+		"recoveryCodes": codes,
+	})
+}
+
+// DisableTOTP turns off TOTP for the current account.
+// Requires the password and a current code, and is refused if one of the account's roles requires MFA.
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var req models.MFADisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, ok := currentAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	required, err := h.mfaRepo.AccountRequiresMFA(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to check MFA requirement for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+		return
+	}
+
+	mfa, err := h.mfaRepo.FindByAccountID(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to load MFA for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if mfa == nil || !mfa.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	valid, err := verifyTOTP(ctx, h.mfaRepo, mfa, req.Code)
+	if err != nil {
+		log.Printf("Failed to verify TOTP code for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	if err := h.mfaRepo.Disable(ctx, account.ID); err != nil {
+		log.Printf("Failed to disable MFA for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	recordAudit(c, h.auditRepo, account.UserID, models.AuditMFADisabled, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the current account's recovery codes.
+// Requires a current TOTP code so a stolen session alone cannot mint new codes.
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, ok := currentAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	mfa, err := h.mfaRepo.FindByAccountID(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to load MFA for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if mfa == nil || !mfa.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	valid, err := verifyTOTP(ctx, h.mfaRepo, mfa, req.Code)
+	if err != nil {
+		log.Printf("Failed to verify TOTP code for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	codes, err := issueRecoveryCodes(ctx, h.mfaRepo, account.ID)
+	if err != nil {
+		log.Printf("Failed to issue recovery codes for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	recordAudit(c, h.auditRepo, account.UserID, models.AuditMFARecoveryCodesReset, "")
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// SetRoleRequirement sets whether members of a role must use two-factor authentication
+func (h *MFAHandler) SetRoleRequirement(c *gin.Context) {
+	roleID, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req RoleMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	modifyUser, _ := middleware.GetModifyUser(c)
+
+	found, err := h.mfaRepo.SetRoleRequireMFA(c.Request.Context(), roleID, *req.Required, modifyUser)
+	if err != nil {
+		log.Printf("Failed to update MFA requirement for role %d: %v", roleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if userID, ok := middleware.GetUserID(c); ok {
+		recordAudit(c, h.auditRepo, userID, models.AuditRoleMFAUpdated, "role "+strconv.Itoa(roleID)+" require_mfa="+strconv.FormatBool(*req.Required))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": roleID, "requireMfa": *req.Required})
+}
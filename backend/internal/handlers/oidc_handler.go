@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/services"
+	"github.com/davealexenglish/magnifimind-crm/pkg/config"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// maxProvisionNameAttempts is how many numbered variants of an account name are tried when provisioning
+const maxProvisionNameAttempts = 20
+
+// accountNameChars matches characters not allowed in a provisioned account name
+var accountNameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+var (
+	errOIDCNotLinked     = errors.New("no account is linked to this identity")
+	errOIDCDomain        = errors.New("email domain not allowed")
+	errOIDCEmailTaken    = errors.New("email already registered")
+	errOIDCNameExhausted = errors.New("no free account name")
+)
+
+// OIDCHandler handles OpenID Connect single sign-on logins. Identity provider subjects are
+// linked to sec_accounts rows; a successful login gets the same tokens as a password login.
+type OIDCHandler struct {
+	auth      *AuthHandler
+	oidc      *services.OIDCService
+	oidcRepo  *database.OIDCRepository
+	userRepo  *database.UserRepository
+	auditRepo *database.AuditRepository
+	config    *config.Config
+}
+
+// NewOIDCHandler creates a new OIDC handler
+func NewOIDCHandler(auth *AuthHandler, oidc *services.OIDCService, oidcRepo *database.OIDCRepository, userRepo *database.UserRepository, auditRepo *database.AuditRepository, cfg *config.Config) *OIDCHandler {
+	return &OIDCHandler{
+		auth:      auth,
+		oidc:      oidc,
+		oidcRepo:  oidcRepo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+		config:    cfg,
+	}
+}
+
+// Status reports whether single sign-on is available, so the login page can offer it
+func (h *OIDCHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enabled": h.config.OIDC.Enabled})
+}
+
+// Login starts a single sign-on login by redirecting the browser to the identity provider
+func (h *OIDCHandler) Login(c *gin.Context) {
+	if !h.config.OIDC.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := h.oidcRepo.PruneStates(ctx); err != nil {
+		log.Printf("Failed to prune expired OIDC states: %v", err)
+	}
+
+	state, err := utils.GenerateSecureToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	nonce, err := utils.GenerateSecureToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	verifier, err := utils.GenerateSecureToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+
+	authURL, err := h.oidc.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Printf("Failed to build OIDC authorization URL: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	st := &models.OIDCLoginState{
+		StateHash:    utils.HashToken(state),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(time.Duration(h.config.OIDC.StateTTLMinutes) * time.Minute),
+	}
+	if err := h.oidcRepo.CreateState(ctx, st); err != nil {
+		log.Printf("Failed to store OIDC state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback finishes a single sign-on login: it redeems the authorization code, verifies the
+// ID token and maps its subject to an account, provisioning one if allowed. The tokens, or
+// an MFA challenge for accounts that need one, are sent to OIDC_POST_LOGIN_URL in the URL
+// fragment, or returned as JSON if that is not set.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if !h.config.OIDC.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	if idpError := c.Query("error"); idpError != "" {
+		log.Printf("Identity provider refused login: %s %s", idpError, c.Query("error_description"))
+		h.fail(c, http.StatusUnauthorized, "oidc_denied", "Single sign-on was cancelled or refused")
+		return
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		h.fail(c, http.StatusBadRequest, "oidc_invalid_request", "Missing state or code")
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	st, err := h.oidcRepo.ConsumeState(ctx, utils.HashToken(state))
+	if err != nil {
+		log.Printf("Failed to load OIDC state: %v", err)
+		h.fail(c, http.StatusInternalServerError, "oidc_failed", "Single sign-on failed")
+		return
+	}
+	if st == nil {
+		h.fail(c, http.StatusBadRequest, "oidc_invalid_state", "Invalid or expired login state")
+		return
+	}
+
+	claims, err := h.oidc.Exchange(ctx, code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		if errors.Is(err, services.ErrOIDCInvalidToken) {
+			h.fail(c, http.StatusUnauthorized, "oidc_invalid_token", "Identity provider returned an invalid token")
+			return
+		}
+		h.fail(c, http.StatusBadGateway, "oidc_unavailable", "Identity provider unavailable")
+		return
+	}
+
+	account, err := h.resolveAccount(c, claims)
+	if err != nil {
+		switch {
+		case errors.Is(err, errOIDCNotLinked):
+			h.fail(c, http.StatusForbidden, "oidc_not_linked", "No CRM account is linked to this identity")
+		case errors.Is(err, errOIDCDomain):
+			h.fail(c, http.StatusForbidden, "oidc_domain_not_allowed", "Your email domain is not allowed to sign in")
+		case errors.Is(err, errOIDCEmailTaken):
+			h.fail(c, http.StatusConflict, "oidc_email_taken", "Email already registered to another account")
+		default:
+			log.Printf("Failed to map OIDC subject %s to an account: %v", claims.Subject, err)
+			h.fail(c, http.StatusInternalServerError, "oidc_failed", "Single sign-on failed")
+		}
+		return
+	}
+
+	// The identity provider stands in for the password only; accounts that need MFA
+	// finish the login at /auth/login/mfa like a password login
+	challenge, err := h.auth.mfaGate(ctx, account)
+	if err != nil {
+		log.Printf("Failed to check MFA for account %d: %v", account.ID, err)
+		h.fail(c, http.StatusInternalServerError, "oidc_failed", "Single sign-on failed")
+		return
+	}
+	if challenge != nil {
+		recordAudit(c, h.auditRepo, account.UserID, models.AuditOIDCLogin, account.Name+" (MFA pending)")
+		if h.config.OIDC.PostLoginURL == "" {
+			c.JSON(http.StatusOK, challenge)
+			return
+		}
+		fragment := url.Values{}
+		for key, value := range challenge {
+			fragment.Set(key, fmt.Sprint(value))
+		}
+		c.Redirect(http.StatusFound, h.config.OIDC.PostLoginURL+"#"+fragment.Encode())
+		return
+	}
+
+	response, err := h.auth.startSession(c, account)
+	if err != nil {
+		log.Printf("Failed to issue tokens for account %d: %v", account.ID, err)
+		h.fail(c, http.StatusInternalServerError, "oidc_failed", "Failed to generate token")
+		return
+	}
+
+	recordAudit(c, h.auditRepo, account.UserID, models.AuditOIDCLogin, account.Name)
+
+	if h.config.OIDC.PostLoginURL == "" {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	fragment := url.Values{
+		"token":        {response["token"].(string)},
+		"refreshToken": {response["refreshToken"].(string)},
+		"expiresIn":    {strconv.Itoa(h.config.JWT.AccessTokenMinutes * 60)},
+	}
+	c.Redirect(http.StatusFound, h.config.OIDC.PostLoginURL+"#"+fragment.Encode())
+}
+
+// fail writes a failed login, as a redirect to the frontend if one is configured
+func (h *OIDCHandler) fail(c *gin.Context, status int, code, message string) {
+	if h.config.OIDC.PostLoginURL == "" {
+		c.JSON(status, gin.H{"error": message, "code": code})
+		return
+	}
+
+	fragment := url.Values{"error": {code}, "message": {message}}
+	c.Redirect(http.StatusFound, h.config.OIDC.PostLoginURL+"#"+fragment.Encode())
+}
+
+// resolveAccount returns the account linked to the ID token's subject. Unlinked subjects are
+// linked by verified email or given a new account when the configuration allows it.
+func (h *OIDCHandler) resolveAccount(c *gin.Context, claims *models.OIDCClaims) (*models.SecAccount, error) {
+	ctx := c.Request.Context()
+
+	var email *string
+	if claims.EmailVerified && claims.Email != "" {
+		trimmed := strings.TrimSpace(claims.Email)
+		email = &trimmed
+	}
+
+	identity, err := h.oidcRepo.FindIdentity(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		account, err := h.userRepo.FindAccountByID(ctx, identity.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil {
+			return nil, errOIDCNotLinked
+		}
+		if err := h.oidcRepo.TouchIdentity(ctx, identity.ID, email); err != nil {
+			log.Printf("Failed to record login for identity %d: %v", identity.ID, err)
+		}
+		return account, nil
+	}
+
+	if h.config.OIDC.LinkByEmail && email != nil {
+		user, err := h.userRepo.FindByEmail(ctx, *email)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			account, err := h.userRepo.FindFirstAccountForUser(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			if account != nil {
+				if err := h.link(ctx, account, claims, email); err != nil {
+					return nil, err
+				}
+				recordAudit(c, h.auditRepo, user.ID, models.AuditOIDCIdentityLinked, claims.Issuer+" "+claims.Subject)
+				return account, nil
+			}
+		}
+	}
+
+	if !h.config.OIDC.AutoProvision {
+		return nil, errOIDCNotLinked
+	}
+	if !h.domainAllowed(email) {
+		return nil, errOIDCDomain
+	}
+
+	account, err := h.provision(ctx, claims, email)
+	if err != nil {
+		return nil, err
+	}
+	recordAudit(c, h.auditRepo, account.UserID, models.AuditOIDCAccountCreated, account.Name)
+
+	return account, nil
+}
+
+// link links an identity provider subject to an account
+func (h *OIDCHandler) link(ctx context.Context, account *models.SecAccount, claims *models.OIDCClaims, email *string) error {
+	identity := &models.AccountIdentity{
+		AccountID: account.ID,
+		Issuer:    claims.Issuer,
+		Subject:   claims.Subject,
+		Email:     email,
+	}
+	return h.oidcRepo.LinkIdentity(ctx, identity)
+}
+
+// domainAllowed reports whether a verified email address may auto-provision an account
+func (h *OIDCHandler) domainAllowed(email *string) bool {
+	allowed := strings.TrimSpace(h.config.OIDC.AllowedDomains)
+	if allowed == "" {
+		return true
+	}
+	if email == nil {
+		return false
+	}
+
+	at := strings.LastIndex(*email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower((*email)[at+1:])
+	for _, d := range strings.Split(allowed, ",") {
+		if strings.ToLower(strings.TrimSpace(d)) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// provision creates a user and account for a new subject. The account gets a random
+// password nobody knows; its owner signs in through the identity provider.
+func (h *OIDCHandler) provision(ctx context.Context, claims *models.OIDCClaims, email *string) (*models.SecAccount, error) {
+	secret, err := utils.GenerateSecureToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	firstName, lastName := provisionedNames(claims)
+	baseName := provisionedAccountName(claims)
+
+	for attempt := 1; attempt <= maxProvisionNameAttempts; attempt++ {
+		name := baseName
+		if attempt > 1 {
+			suffix := strconv.Itoa(attempt)
+			name = truncate(baseName, 50-len(suffix)-1) + "-" + suffix
+		}
+
+		user := &models.SecUser{
+			FirstName: firstName,
+			LastName:  lastName,
+			Email:     email,
+		}
+		account := &models.SecAccount{
+			Name:     name,
+			Password: string(hashedPassword),
+		}
+
+		err := h.userRepo.Create(ctx, user, account)
+		if errors.Is(err, database.ErrDuplicateAccountName) {
+			continue
+		}
+		if errors.Is(err, database.ErrDuplicateEmail) {
+			return nil, errOIDCEmailTaken
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// The identity provider vouches for the user, so the email gate does not apply
+		if err := h.userRepo.SetEmailVerified(ctx, user.ID); err != nil {
+			log.Printf("Failed to mark user %d as verified: %v", user.ID, err)
+		}
+		account.EmailVerified = true
+
+		if err := h.link(ctx, account, claims, email); err != nil {
+			return nil, err
+		}
+		return account, nil
+	}
+
+	return nil, errOIDCNameExhausted
+}
+
+// provisionedNames picks a first and last name for a new user from the ID token
+func provisionedNames(claims *models.OIDCClaims) (string, string) {
+	first, last := claims.GivenName, claims.FamilyName
+	if first == "" && last == "" {
+		if parts := strings.Fields(claims.Name); len(parts) > 0 {
+			first = parts[0]
+			last = strings.Join(parts[1:], " ")
+		}
+	}
+	if first == "" {
+		first = provisionedAccountName(claims)
+	}
+	return truncate(first, 50), truncate(last, 50)
+}
+
+// provisionedAccountName derives an account name from the preferred username, email or subject
+func provisionedAccountName(claims *models.OIDCClaims) string {
+	candidates := []string{claims.PreferredUsername}
+	if at := strings.Index(claims.Email, "@"); at > 0 {
+		candidates = append(candidates, claims.Email[:at])
+	}
+
+	for _, candidate := range candidates {
+		name := strings.Trim(accountNameChars.ReplaceAllString(strings.ToLower(candidate), "-"), "-.")
+		if len(name) >= 3 {
+			return truncate(name, 50)
+		}
+	}
+
+	return truncate(fmt.Sprintf("sso-%s", utils.HashToken(claims.Issuer + " " + claims.Subject)[:12]), 50)
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/services"
+	"github.com/davealexenglish/magnifimind-crm/internal/testutil"
+	"github.com/davealexenglish/magnifimind-crm/pkg/config"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// oidcTest is a single sign-on setup against a mock identity provider
+type oidcTest struct {
+	db     *database.DB
+	idp    *testutil.MockIdP
+	router *gin.Engine
+}
+
+// newOIDCTest wires an OIDCHandler to a mock provider; configure adjusts the OIDC settings
+func newOIDCTest(t *testing.T, configure func(*config.OIDCConfig)) *oidcTest {
+	t.Helper()
+	db := testutil.DB(t)
+	idp := testutil.NewMockIdP(t, "crm-test")
+
+	cfg := &config.Config{}
+	cfg.JWT = config.JWTConfig{Issuer: "crm-test", AccessTokenMinutes: 15, RefreshTokenDays: 7}
+	cfg.Auth.MFAChallengeTTLMinutes = 5
+	cfg.OIDC = config.OIDCConfig{
+		Enabled:         true,
+		IssuerURL:       idp.Issuer(),
+		ClientID:        idp.ClientID,
+		RedirectURL:     "http://crm.test/api/v1/auth/oidc/callback",
+		Scopes:          "openid email profile",
+		StateTTLMinutes: 10,
+	}
+	if configure != nil {
+		configure(&cfg.OIDC)
+	}
+
+	keys, err := utils.NewJWTKeySet(cfg.JWT.Issuer, utils.NewHMACKey("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	userRepo := database.NewUserRepository(db)
+	auditRepo := database.NewAuditRepository(db)
+	auth := NewAuthHandler(userRepo, database.NewUserTokenRepository(db), auditRepo, database.NewMFARepository(db),
+		database.NewSessionRepository(db), nil, nil, nil, services.NewTokenIssuer(keys, 15*time.Minute), cfg)
+	oidc := services.NewOIDCService(services.OIDCClientConfig{
+		IssuerURL:   cfg.OIDC.IssuerURL,
+		ClientID:    cfg.OIDC.ClientID,
+		RedirectURL: cfg.OIDC.RedirectURL,
+		Scopes:      strings.Fields(cfg.OIDC.Scopes),
+	}, nil)
+	h := NewOIDCHandler(auth, oidc, database.NewOIDCRepository(db), userRepo, auditRepo, cfg)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/oidc/login", h.Login)
+	router.GET("/oidc/callback", h.Callback)
+
+	return &oidcTest{db: db, idp: idp, router: router}
+}
+
+// get sends a GET request to the router
+func (o *oidcTest) get(target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	o.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+// authorize starts a login and has the provider approve it with an ID token carrying claims
+func (o *oidcTest) authorize(t *testing.T, claims jwt.MapClaims) (code, state string) {
+	t.Helper()
+	w := o.get("/oidc/login")
+	if w.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want 302: %s", w.Code, w.Body)
+	}
+	return o.idp.Authorize(t, w.Header().Get("Location"), claims)
+}
+
+// callback returns to the server from the provider
+func (o *oidcTest) callback(state, code string) *httptest.ResponseRecorder {
+	return o.get("/oidc/callback?" + url.Values{"state": {state}, "code": {code}}.Encode())
+}
+
+// login runs a whole login and returns the callback's response
+func (o *oidcTest) login(t *testing.T, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	t.Helper()
+	code, state := o.authorize(t, claims)
+	return o.callback(state, code)
+}
+
+// decodeBody decodes a JSON response body
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON response %q: %v", w.Body, err)
+	}
+	return body
+}
+
+// expectFailure checks a failed callback's status and error code
+func expectFailure(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	if got := decodeBody(t, w)["code"]; got != code {
+		t.Errorf("code = %v, want %s", got, code)
+	}
+}
+
+// loggedInAccount checks a callback issued a session and returns its account ID
+func loggedInAccount(t *testing.T, w *httptest.ResponseRecorder) int {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	body := decodeBody(t, w)
+	if token, _ := body["token"].(string); token == "" {
+		t.Fatalf("no token in %v", body)
+	}
+	user, _ := body["user"].(map[string]interface{})
+	id, _ := user["id"].(float64)
+	return int(id)
+}
+
+func TestOIDCCallbackRejectsUnknownState(t *testing.T) {
+	o := newOIDCTest(t, nil)
+
+	expectFailure(t, o.callback("forged-state", "some-code"), http.StatusBadRequest, "oidc_invalid_state")
+}
+
+func TestOIDCCallbackStateIsSingleUse(t *testing.T) {
+	o := newOIDCTest(t, func(c *config.OIDCConfig) { c.AutoProvision = true })
+
+	name := testutil.Unique("sso")
+	code, state := o.authorize(t, jwt.MapClaims{"sub": name, "email": name + "@example.com", "email_verified": true})
+	loggedInAccount(t, o.callback(state, code))
+
+	expectFailure(t, o.callback(state, code), http.StatusBadRequest, "oidc_invalid_state")
+}
+
+func TestOIDCCallbackRejectsInvalidIDToken(t *testing.T) {
+	o := newOIDCTest(t, func(c *config.OIDCConfig) { c.AutoProvision = true })
+
+	name := testutil.Unique("sso")
+	email := name + "@example.com"
+	w := o.login(t, jwt.MapClaims{"sub": name, "email": email, "email_verified": true, "nonce": "replayed-nonce"})
+	expectFailure(t, w, http.StatusUnauthorized, "oidc_invalid_token")
+
+	user, err := database.NewUserRepository(o.db).FindByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != nil {
+		t.Error("an account was provisioned from an invalid ID token")
+	}
+}
+
+func TestOIDCLinksExistingUserByVerifiedEmail(t *testing.T) {
+	o := newOIDCTest(t, func(c *config.OIDCConfig) { c.LinkByEmail = true })
+	account := testutil.CreateUser(t, o.db, "oidc-link")
+	subject := testutil.Unique("subject")
+
+	w := o.login(t, jwt.MapClaims{"sub": subject, "email": account.Name + "@example.com", "email_verified": true})
+	if got := loggedInAccount(t, w); got != account.ID {
+		t.Fatalf("logged in as account %d, want %d", got, account.ID)
+	}
+
+	// The subject stays linked even when the provider stops sending the address
+	w = o.login(t, jwt.MapClaims{"sub": subject})
+	if got := loggedInAccount(t, w); got != account.ID {
+		t.Errorf("second login as account %d, want %d", got, account.ID)
+	}
+}
+
+func TestOIDCDoesNotLinkUnverifiedEmail(t *testing.T) {
+	o := newOIDCTest(t, func(c *config.OIDCConfig) { c.LinkByEmail = true })
+	account := testutil.CreateUser(t, o.db, "oidc-unverified")
+
+	w := o.login(t, jwt.MapClaims{"sub": testutil.Unique("subject"), "email": account.Name + "@example.com", "email_verified": false})
+	expectFailure(t, w, http.StatusForbidden, "oidc_not_linked")
+}
+
+func TestOIDCAutoProvisionsAccount(t *testing.T) {
+	o := newOIDCTest(t, func(c *config.OIDCConfig) {
+		c.AutoProvision = true
+		c.AllowedDomains = "corp.example, example.com"
+	})
+	name := testutil.Unique("newhire")
+	email := name + "@example.com"
+
+	// A blank name claim falls back to the account name
+	w := o.login(t, jwt.MapClaims{"sub": name, "email": email, "email_verified": true, "preferred_username": name, "name": " "})
+	accountID := loggedInAccount(t, w)
+
+	ctx := context.Background()
+	userRepo := database.NewUserRepository(o.db)
+	account, err := userRepo.FindAccountByID(ctx, accountID)
+	if err != nil || account == nil {
+		t.Fatalf("provisioned account %d not found: %v", accountID, err)
+	}
+	if account.Name != name {
+		t.Errorf("account name = %q, want %q", account.Name, name)
+	}
+	user, err := userRepo.FindByID(ctx, account.UserID)
+	if err != nil || user == nil {
+		t.Fatalf("provisioned user %d not found: %v", account.UserID, err)
+	}
+	if user.Email == nil || *user.Email != email || user.FirstName != name {
+		t.Errorf("provisioned user = %q %v, want %q %s", user.FirstName, user.Email, name, email)
+	}
+	verified, err := userRepo.IsEmailVerified(ctx, user.ID)
+	if err != nil || !verified {
+		t.Errorf("provisioned user's email verified = %v (%v), want true", verified, err)
+	}
+}
+
+func TestOIDCAutoProvisionRefusesOtherDomains(t *testing.T) {
+	o := newOIDCTest(t, func(c *config.OIDCConfig) {
+		c.AutoProvision = true
+		c.AllowedDomains = "corp.example"
+	})
+	name := testutil.Unique("outsider")
+
+	w := o.login(t, jwt.MapClaims{"sub": name, "email": name + "@example.com", "email_verified": true})
+	expectFailure(t, w, http.StatusForbidden, "oidc_domain_not_allowed")
+}
+
+func TestOIDCLoginAsksForMFAWhenRoleRequiresIt(t *testing.T) {
+	o := newOIDCTest(t, func(c *config.OIDCConfig) { c.LinkByEmail = true })
+	account := testutil.CreateUser(t, o.db, "oidc-admin")
+
+	ctx := context.Background()
+	roleRepo := database.NewRoleRepository(o.db)
+	role := &models.SecRole{Name: testutil.Unique("mfa")}
+	if err := roleRepo.CreateRole(ctx, role, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.NewMFARepository(o.db).SetRoleRequireMFA(ctx, role.ID, true, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := roleRepo.AssignRole(ctx, account.ID, role.ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := o.login(t, jwt.MapClaims{"sub": testutil.Unique("subject"), "email": account.Name + "@example.com", "email_verified": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	body := decodeBody(t, w)
+	if body["mfaRequired"] != true || body["mfaEnrollmentRequired"] != true {
+		t.Errorf("response = %v, want an MFA enrollment challenge", body)
+	}
+	if token, _ := body["mfaToken"].(string); token == "" {
+		t.Error("no mfaToken in the challenge")
+	}
+	if _, ok := body["token"]; ok {
+		t.Error("an access token was issued before the second factor")
+	}
+}
+
+func TestOIDCLoginAsksForEnabledTOTP(t *testing.T) {
+	o := newOIDCTest(t, func(c *config.OIDCConfig) {
+		c.LinkByEmail = true
+		c.PostLoginURL = "http://crm.test/sso"
+	})
+	account := testutil.CreateUser(t, o.db, "oidc-totp")
+
+	ctx := context.Background()
+	mfaRepo := database.NewMFARepository(o.db)
+	if err := mfaRepo.SavePendingSecret(ctx, account.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfaRepo.Enable(ctx, account.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	w := o.login(t, jwt.MapClaims{"sub": testutil.Unique("subject"), "email": account.Name + "@example.com", "email_verified": true})
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302: %s", w.Code, w.Body)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment, err := url.ParseQuery(location.Fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fragment.Get("mfaRequired") != "true" || fragment.Get("mfaEnrollmentRequired") != "false" || fragment.Get("mfaToken") == "" {
+		t.Errorf("fragment = %v, want a TOTP challenge", fragment)
+	}
+	if fragment.Has("token") || fragment.Has("refreshToken") {
+		t.Error("tokens were issued before the second factor")
+	}
+}
+
+func TestProvisionedNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    models.OIDCClaims
+		wantFirst string
+		wantLast  string
+	}{
+		{"given and family names", models.OIDCClaims{GivenName: "Ada", FamilyName: "Lovelace", Name: "Countess"}, "Ada", "Lovelace"},
+		{"full name split", models.OIDCClaims{Name: "Ada King Lovelace"}, "Ada", "King Lovelace"},
+		{"blank name", models.OIDCClaims{Name: " \t ", PreferredUsername: "ada"}, "ada", ""},
+		{"no names", models.OIDCClaims{Email: "ada.l@example.com"}, "ada.l", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last := provisionedNames(&tt.claims)
+			if first != tt.wantFirst || last != tt.wantLast {
+				t.Errorf("provisionedNames = %q %q, want %q %q", first, last, tt.wantFirst, tt.wantLast)
+			}
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// OrgHandler manages organizations and their members. Owners and admins manage
+// members; only owners can appoint owners or delete the organization.
+type OrgHandler struct {
+	orgRepo   *database.OrgRepository
+	userRepo  *database.UserRepository
+	auditRepo *database.AuditRepository
+}
+
+// NewOrgHandler creates a new organization handler
+func NewOrgHandler(orgRepo *database.OrgRepository, userRepo *database.UserRepository, auditRepo *database.AuditRepository) *OrgHandler {
+	return &OrgHandler{
+		orgRepo:   orgRepo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+	}
+}
+
+// audit records an organization change made by the authenticated user
+func (h *OrgHandler) audit(c *gin.Context, event, detail string) {
+	if userID, ok := middleware.GetUserID(c); ok {
+		recordAudit(c, h.auditRepo, userID, event, detail)
+	}
+}
+
+// canManageMembers reports whether an organization role may add, change and remove members
+func canManageMembers(role string) bool {
+	return role == models.OrgRoleOwner || role == models.OrgRoleAdmin
+}
+
+// loadMembership parses the :id parameter and loads the organization with the current
+// user's role in it, writing an error response if it cannot. Organizations the user
+// does not belong to are reported as not found.
+func (h *OrgHandler) loadMembership(c *gin.Context) (*models.Org, int, bool) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return nil, 0, false
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return nil, 0, false
+	}
+
+	role, err := h.orgRepo.MemberRole(c.Request.Context(), id, userID)
+	if err != nil {
+		log.Printf("Failed to check membership of organization %d for user %d: %v", id, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, 0, false
+	}
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, 0, false
+	}
+
+	org, err := h.orgRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Failed to load organization %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, 0, false
+	}
+	if org == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, 0, false
+	}
+
+	org.Role = role
+	return org, userID, true
+}
+
+// parseMemberID parses the :userId parameter, writing an error response if it is invalid
+func parseMemberID(c *gin.Context) (int, bool) {
+	memberID, err := utils.ParseInt(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return memberID, true
+}
+
+// keepsAnOwner reports whether an organization still has an owner once the given owner
+// stops being one, writing a conflict response if it would not
+func (h *OrgHandler) keepsAnOwner(c *gin.Context, orgID int) bool {
+	owners, err := h.orgRepo.CountOwners(c.Request.Context(), orgID)
+	if err != nil {
+		log.Printf("Failed to count owners of organization %d: %v", orgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return false
+	}
+	if owners <= 1 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An organization must keep at least one owner"})
+		return false
+	}
+	return true
+}
+
+// ListOrgs returns the organizations the current user belongs to, personal organization first
+func (h *OrgHandler) ListOrgs(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	if _, err := h.orgRepo.EnsurePersonalOrg(c.Request.Context(), userID); err != nil {
+		log.Printf("Failed to load personal organization for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list organizations"})
+		return
+	}
+
+	orgs, err := h.orgRepo.ListForUser(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to list organizations for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// CreateOrg creates a shared organization owned by the current user
+func (h *OrgHandler) CreateOrg(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req models.OrgRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	org := &models.Org{Name: req.Name}
+	if err := h.orgRepo.Create(c.Request.Context(), org, userID, actor); err != nil {
+		log.Printf("Failed to create organization %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+
+	h.audit(c, models.AuditOrgCreated, fmt.Sprintf("%d %s", org.ID, org.Name))
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// GetOrg returns an organization the current user belongs to, with their role
+func (h *OrgHandler) GetOrg(c *gin.Context) {
+	org, _, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// UpdateOrg renames an organization
+func (h *OrgHandler) UpdateOrg(c *gin.Context) {
+	org, _, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !canManageMembers(org.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners and admins can rename the organization"})
+		return
+	}
+
+	var req models.OrgRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	updated, err := h.orgRepo.Rename(c.Request.Context(), org.ID, req.Name, actor)
+	if err != nil {
+		log.Printf("Failed to rename organization %d: %v", org.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	org.Name = req.Name
+	c.JSON(http.StatusOK, org)
+}
+
+// DeleteOrg deletes a shared organization that no longer holds any people
+func (h *OrgHandler) DeleteOrg(c *gin.Context) {
+	org, _, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if org.Role != models.OrgRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can delete the organization"})
+		return
+	}
+	if org.Personal {
+		c.JSON(http.StatusConflict, gin.H{"error": "Personal organizations cannot be deleted"})
+		return
+	}
+
+	people, err := h.orgRepo.CountPeople(c.Request.Context(), org.ID)
+	if err != nil {
+		log.Printf("Failed to count people in organization %d: %v", org.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+	if people > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Delete the organization's people first", "people": people})
+		return
+	}
+
+	deleted, err := h.orgRepo.Delete(c.Request.Context(), org.ID)
+	if err != nil {
+		log.Printf("Failed to delete organization %d: %v", org.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	h.audit(c, models.AuditOrgDeleted, fmt.Sprintf("%d %s", org.ID, org.Name))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted"})
+}
+
+// ListMembers returns the members of an organization the current user belongs to
+func (h *OrgHandler) ListMembers(c *gin.Context) {
+	org, _, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.orgRepo.ListMembers(c.Request.Context(), org.ID)
+	if err != nil {
+		log.Printf("Failed to list members of organization %d: %v", org.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// AddMember adds the user behind an account to an organization
+func (h *OrgHandler) AddMember(c *gin.Context) {
+	org, _, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !canManageMembers(org.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners and admins can add members"})
+		return
+	}
+	if org.Personal {
+		c.JSON(http.StatusConflict, gin.H{"error": "Personal organizations cannot have other members"})
+		return
+	}
+
+	var req models.OrgMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == models.OrgRoleOwner && org.Role != models.OrgRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can add owners"})
+		return
+	}
+
+	account, err := h.userRepo.FindByUsername(c.Request.Context(), req.Account)
+	if err != nil {
+		log.Printf("Failed to load account %s: %v", req.Account, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if account == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	if err := h.orgRepo.AddMember(c.Request.Context(), org.ID, account.UserID, req.Role); err != nil {
+		if errors.Is(err, database.ErrAlreadyMember) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+			return
+		}
+		log.Printf("Failed to add user %d to organization %d: %v", account.UserID, org.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+
+	h.audit(c, models.AuditOrgMemberAdded, fmt.Sprintf("org %d: user %d (%s) as %s", org.ID, account.UserID, account.Name, req.Role))
+
+	c.JSON(http.StatusCreated, gin.H{"orgId": org.ID, "userId": account.UserID, "role": req.Role})
+}
+
+// UpdateMember changes a member's role
+func (h *OrgHandler) UpdateMember(c *gin.Context) {
+	org, _, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !canManageMembers(org.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners and admins can change roles"})
+		return
+	}
+	memberID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+
+	var req models.OrgRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current, err := h.orgRepo.MemberRole(c.Request.Context(), org.ID, memberID)
+	if err != nil {
+		log.Printf("Failed to load role of user %d in organization %d: %v", memberID, org.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if current == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if (current == models.OrgRoleOwner || req.Role == models.OrgRoleOwner) && org.Role != models.OrgRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can appoint or change owners"})
+		return
+	}
+	if current == models.OrgRoleOwner && req.Role != models.OrgRoleOwner && !h.keepsAnOwner(c, org.ID) {
+		return
+	}
+
+	updated, err := h.orgRepo.SetMemberRole(c.Request.Context(), org.ID, memberID, req.Role)
+	if err != nil {
+		log.Printf("Failed to set role of user %d in organization %d: %v", memberID, org.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change role"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	h.audit(c, models.AuditOrgMemberRoleChanged, fmt.Sprintf("org %d: user %d %s -> %s", org.ID, memberID, current, req.Role))
+
+	c.JSON(http.StatusOK, gin.H{"orgId": org.ID, "userId": memberID, "role": req.Role})
+}
+
+// RemoveMember removes a member from an organization. Any member may leave;
+// removing someone else needs owner or admin.
+func (h *OrgHandler) RemoveMember(c *gin.Context) {
+	org, userID, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseMemberID(c)
+	if !ok {
+		return
+	}
+	if memberID != userID && !canManageMembers(org.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners and admins can remove members"})
+		return
+	}
+
+	current, err := h.orgRepo.MemberRole(c.Request.Context(), org.ID, memberID)
+	if err != nil {
+		log.Printf("Failed to load role of user %d in organization %d: %v", memberID, org.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if current == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if current == models.OrgRoleOwner {
+		if memberID != userID && org.Role != models.OrgRoleOwner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can remove owners"})
+			return
+		}
+		if !h.keepsAnOwner(c, org.ID) {
+			return
+		}
+	}
+
+	removed, err := h.orgRepo.RemoveMember(c.Request.Context(), org.ID, memberID)
+	if err != nil {
+		log.Printf("Failed to remove user %d from organization %d: %v", memberID, org.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	h.audit(c, models.AuditOrgMemberRemoved, fmt.Sprintf("org %d: user %d", org.ID, memberID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
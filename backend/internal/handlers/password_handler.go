@@ -121,7 +121,7 @@ func (h *PasswordHandler) CreatePassword(c *gin.Context) {
 		return
 	}
 
-	username, _ := middleware.GetUsername(c)
+	username, _ := middleware.GetModifyUser(c)
 
 	var req CreatePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -159,7 +159,7 @@ func (h *PasswordHandler) UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	username, _ := middleware.GetUsername(c)
+	username, _ := middleware.GetModifyUser(c)
 
 	id, err := utils.ParseInt(c.Param("id"))
 	if err != nil {
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -15,11 +16,37 @@ import (
 // PersonHandler handles person-related requests
 type PersonHandler struct {
 	personRepo *database.PersonRepository
+	fieldRepo  *database.CustomFieldRepository
 }
 
 // NewPersonHandler creates a new PersonHandler
-func NewPersonHandler(personRepo *database.PersonRepository) *PersonHandler {
-	return &PersonHandler{personRepo: personRepo}
+func NewPersonHandler(personRepo *database.PersonRepository, fieldRepo *database.CustomFieldRepository) *PersonHandler {
+	return &PersonHandler{
+		personRepo: personRepo,
+		fieldRepo:  fieldRepo,
+	}
+}
+
+// customFieldValues checks custom field values from a request against the fields of the
+// person's organization. It writes an error response and returns false if it cannot.
+func (h *PersonHandler) customFieldValues(c *gin.Context, orgID *int, input map[string]interface{}, creating bool) ([]models.CustomFieldValue, bool) {
+	fields := []*models.CustomField{}
+	if orgID != nil {
+		var err error
+		fields, err = h.fieldRepo.List(c.Request.Context(), *orgID)
+		if err != nil {
+			log.Printf("Failed to load custom fields of org %d: %v", *orgID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return nil, false
+		}
+	}
+
+	values, err := customFieldValues(fields, input, creating)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return values, true
 }
 
 // ListPersons lists all persons
@@ -34,7 +61,13 @@ func (h *PersonHandler) ListPersons(c *gin.Context) {
 
 	fmt.Printf("[DEBUG] ListPersons called with fname='%s', lname='%s', businessFlag=%v, limit=%d, offset=%d\n", fname, lname, businessFlag, limit, offset)
 
-	persons, total, err := h.personRepo.ListWithFilters(c.Request.Context(), fname, lname, businessFlag, limit, offset)
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	persons, total, err := h.personRepo.ListWithFilters(c.Request.Context(), scope, fname, lname, businessFlag, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch persons"})
 		return
@@ -48,7 +81,7 @@ func (h *PersonHandler) ListPersons(c *gin.Context) {
 	})
 }
 
-// GetPerson gets a person by ID
+// GetPerson gets a person by ID. People the user cannot see are reported as not found.
 func (h *PersonHandler) GetPerson(c *gin.Context) {
 	id, err := utils.ParseInt(c.Param("id"))
 	if err != nil {
@@ -56,7 +89,13 @@ func (h *PersonHandler) GetPerson(c *gin.Context) {
 		return
 	}
 
-	person, err := h.personRepo.FindByID(c.Request.Context(), id)
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	person, err := h.personRepo.FindByID(c.Request.Context(), scope, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 		return
@@ -66,6 +105,13 @@ func (h *PersonHandler) GetPerson(c *gin.Context) {
 		return
 	}
 
+	person.CustomFields, err = h.fieldRepo.Values(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Failed to load custom field values of person %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
 	c.JSON(http.StatusOK, person)
 }
 
@@ -77,17 +123,40 @@ func (h *PersonHandler) CreatePerson(c *gin.Context) {
 		return
 	}
 
-	// Get current user from context
-	username, _ := middleware.GetUsername(c)
+	// Get current user and organization from context
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	username, _ := middleware.GetModifyUser(c)
 	person.CreateUser = username
 	person.ModifyUser = username
 	person.ActiveFlag = "Y"
 
-	if err := h.personRepo.Create(c.Request.Context(), &person); err != nil {
+	values, ok := h.customFieldValues(c, &scope.OrgID, person.CustomFields, true)
+	if !ok {
+		return
+	}
+
+	if err := h.personRepo.Create(c.Request.Context(), scope, &person); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create person"})
 		return
 	}
 
+	if err := h.fieldRepo.SaveValues(c.Request.Context(), person.ID, values, username); err != nil {
+		log.Printf("Failed to save custom field values of person %d: %v", person.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create person"})
+		return
+	}
+	var err error
+	person.CustomFields, err = h.fieldRepo.Values(c.Request.Context(), person.ID)
+	if err != nil {
+		log.Printf("Failed to load custom field values of person %d: %v", person.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, person)
 }
 
@@ -105,17 +174,101 @@ func (h *PersonHandler) UpdatePerson(c *gin.Context) {
 		return
 	}
 
-	// Get current user from context
-	username, _ := middleware.GetUsername(c)
+	// Get current user and organization from context
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	username, _ := middleware.GetModifyUser(c)
 	person.ID = id
 	person.ModifyUser = username
 
-	if err := h.personRepo.Update(c.Request.Context(), &person); err != nil {
+	// Custom fields are those of the person's organization, which for a shared person
+	// need not be the active one. Fields left out of the request keep their values.
+	var values []models.CustomFieldValue
+	if len(person.CustomFields) > 0 {
+		existing, err := h.personRepo.FindByID(c.Request.Context(), scope, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		if existing == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+			return
+		}
+		if values, ok = h.customFieldValues(c, existing.OrgID, person.CustomFields, false); !ok {
+			return
+		}
+	}
+
+	updated, err := h.personRepo.Update(c.Request.Context(), scope, &person)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update person"})
 		return
 	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+		return
+	}
 
-	c.JSON(http.StatusOK, person)
+	if err := h.fieldRepo.SaveValues(c.Request.Context(), id, values, username); err != nil {
+		log.Printf("Failed to save custom field values of person %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update person"})
+		return
+	}
+
+	// Respond with the stored row rather than the request body, whose owner fields are ignored
+	saved, err := h.personRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil || saved == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load updated person"})
+		return
+	}
+	saved.CustomFields, err = h.fieldRepo.Values(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Failed to load custom field values of person %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load updated person"})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// SetPersonPrivacy makes one of the user's own people private or shares it with the organization
+func (h *PersonHandler) SetPersonPrivacy(c *gin.Context) {
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid person ID"})
+		return
+	}
+
+	var req struct {
+		Private *bool `json:"private" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	username, _ := middleware.GetModifyUser(c)
+
+	updated, err := h.personRepo.SetPrivate(c.Request.Context(), scope, id, *req.Private, username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update person"})
+		return
+	}
+	if !updated {
+		// Other members' people cannot be made private, only the owner's own
+		c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "private": *req.Private})
 }
 
 // DeletePerson deletes a person
@@ -126,10 +279,22 @@ func (h *PersonHandler) DeletePerson(c *gin.Context) {
 		return
 	}
 
-	if err := h.personRepo.Delete(c.Request.Context(), id); err != nil {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	username, _ := middleware.GetModifyUser(c)
+
+	deleted, err := h.personRepo.Delete(c.Request.Context(), scope, id, username)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete person"})
 		return
 	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "person deleted successfully"})
 }
@@ -142,15 +307,27 @@ func (h *PersonHandler) HardDeletePerson(c *gin.Context) {
 		return
 	}
 
-	if err := h.personRepo.HardDelete(c.Request.Context(), id); err != nil {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	deleted, err := h.personRepo.HardDelete(c.Request.Context(), scope, id)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to permanently delete person"})
 		return
 	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "person and all related records permanently deleted"})
 }
 
-// HardDeletePersonsBulk permanently deletes multiple persons and all related records
+// HardDeletePersonsBulk permanently deletes multiple persons and all related records.
+// IDs the user cannot see are skipped and listed in the response.
 func (h *PersonHandler) HardDeletePersonsBulk(c *gin.Context) {
 	var req struct {
 		IDs []int `json:"ids" binding:"required"`
@@ -165,14 +342,36 @@ func (h *PersonHandler) HardDeletePersonsBulk(c *gin.Context) {
 		return
 	}
 
-	if err := h.personRepo.HardDeleteBulk(c.Request.Context(), req.IDs); err != nil {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	deleted, err := h.personRepo.HardDeleteBulk(c.Request.Context(), scope, req.IDs)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to permanently delete persons"})
 		return
 	}
 
+	// Everything requested but not deleted was skipped; repeated IDs are reported once
+	seen := make(map[int]bool, len(req.IDs))
+	for _, id := range deleted {
+		seen[id] = true
+	}
+	skipped := []int{}
+	for _, id := range req.IDs {
+		if !seen[id] {
+			skipped = append(skipped, id)
+			seen[id] = true
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("%d person(s) and all related records permanently deleted", len(req.IDs)),
-		"count":   len(req.IDs),
+		"message": fmt.Sprintf("%d person(s) and all related records permanently deleted", len(deleted)),
+		"count":   len(deleted),
+		"deleted": deleted,
+		"skipped": skipped,
 	})
 }
 
@@ -187,7 +386,13 @@ func (h *PersonHandler) SearchPersons(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	persons, total, err := h.personRepo.Search(c.Request.Context(), searchTerm, limit, offset)
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	persons, total, err := h.personRepo.Search(c.Request.Context(), scope, searchTerm, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search persons"})
 		return
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/testutil"
+)
+
+// personTest is a signed-in user with a person of their own, and a person of another user
+// they must not be able to see or change
+type personTest struct {
+	db      *database.DB
+	router  *gin.Engine
+	own     *models.PdatPerson
+	foreign *models.PdatPerson
+}
+
+// newPersonTest wires a PersonHandler the way main.go does, signed in as a new user
+func newPersonTest(t *testing.T) *personTest {
+	t.Helper()
+	db := testutil.DB(t)
+
+	account := testutil.CreateUser(t, db, "owner")
+	other := testutil.CreateUser(t, db, "other")
+
+	h := NewPersonHandler(database.NewPersonRepository(db), database.NewCustomFieldRepository(db))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	persons := router.Group("/persons")
+	persons.Use(testutil.Authenticate(account), middleware.OrgContext(database.NewOrgRepository(db)), middleware.RowSecurity(db))
+	{
+		persons.GET("", h.ListPersons)
+		persons.GET("/:id", h.GetPerson)
+		persons.PUT("/:id", h.UpdatePerson)
+		persons.DELETE("/:id", h.DeletePerson)
+		persons.GET("/search", h.SearchPersons)
+		persons.DELETE("/:id/hard", h.HardDeletePerson)
+		persons.POST("/hard-delete-bulk", h.HardDeletePersonsBulk)
+	}
+
+	return &personTest{
+		db:      db,
+		router:  router,
+		own:     createPerson(t, db, account, "Own"),
+		foreign: createPerson(t, db, other, "Foreign"),
+	}
+}
+
+// createPerson adds a person to a user's personal organization, with a unique last name
+func createPerson(t *testing.T, db *database.DB, account *models.SecAccount, firstName string) *models.PdatPerson {
+	t.Helper()
+	lastName := testutil.Unique("Person")
+	person := &models.PdatPerson{FirstName: &firstName, LastName: &lastName, BusinessFlag: "N", PrivateFlag: "N",
+		ActiveFlag: "Y", CreateUser: account.Name, ModifyUser: account.Name}
+	scope := models.TenantScope{UserID: account.UserID, OrgID: testutil.PersonalOrg(t, db, account.UserID)}
+	if err := database.NewPersonRepository(db).Create(context.Background(), scope, person); err != nil {
+		t.Fatalf("Failed to create person: %v", err)
+	}
+	return person
+}
+
+// do sends a request, with body encoded as JSON unless it is nil
+func (p *personTest) do(t *testing.T, method, target string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	p.router.ServeHTTP(w, req)
+	return w
+}
+
+// personRow is the stored state of a person, read past the handlers
+type personRow struct {
+	exists     bool
+	firstName  string
+	activeFlag string
+	modifyUser string
+}
+
+// row reads a person straight from the database
+func (p *personTest) row(t *testing.T, id int) personRow {
+	t.Helper()
+	row := personRow{}
+	err := p.db.QueryRowContext(context.Background(),
+		`SELECT fname, active_flag, modify_user FROM pdat_person WHERE pdat_person_id = $1`, id).
+		Scan(&row.firstName, &row.activeFlag, &row.modifyUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return row
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	row.exists = true
+	return row
+}
+
+// listedIDs returns the IDs of the people in a list or search response
+func listedIDs(t *testing.T, w *httptest.ResponseRecorder) map[int]bool {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Persons []models.PdatPerson `json:"persons"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[int]bool, len(body.Persons))
+	for _, person := range body.Persons {
+		ids[person.ID] = true
+	}
+	return ids
+}
+
+func TestPersonHandlerForeignPeopleAreNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{name: "get", method: http.MethodGet, path: "/persons/%d"},
+		{name: "update", method: http.MethodPut, path: "/persons/%d", body: gin.H{"firstName": "Changed", "activeFlag": "Y"}},
+		{name: "delete", method: http.MethodDelete, path: "/persons/%d"},
+		{name: "hard delete", method: http.MethodDelete, path: "/persons/%d/hard"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPersonTest(t)
+			before := p.row(t, p.foreign.ID)
+
+			w := p.do(t, tt.method, fmt.Sprintf(tt.path, p.foreign.ID), tt.body)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404: %s", w.Code, w.Body)
+			}
+			if after := p.row(t, p.foreign.ID); after != before {
+				t.Errorf("foreign person changed from %+v to %+v", before, after)
+			}
+		})
+	}
+}
+
+func TestPersonHandlerOwnPeople(t *testing.T) {
+	p := newPersonTest(t)
+
+	if w := p.do(t, http.MethodGet, fmt.Sprintf("/persons/%d", p.own.ID), nil); w.Code != http.StatusOK {
+		t.Fatalf("get status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	w := p.do(t, http.MethodPut, fmt.Sprintf("/persons/%d", p.own.ID), gin.H{"firstName": "Changed", "lastName": *p.own.LastName, "activeFlag": "Y"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if got := p.row(t, p.own.ID).firstName; got != "Changed" {
+		t.Errorf("first name = %q after update, want Changed", got)
+	}
+
+	if w := p.do(t, http.MethodDelete, fmt.Sprintf("/persons/%d", p.own.ID), nil); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if got := p.row(t, p.own.ID).activeFlag; got != "N" {
+		t.Errorf("active flag = %q after delete, want N", got)
+	}
+
+	if w := p.do(t, http.MethodDelete, fmt.Sprintf("/persons/%d/hard", p.own.ID), nil); w.Code != http.StatusOK {
+		t.Fatalf("hard delete status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if p.row(t, p.own.ID).exists {
+		t.Error("person still exists after hard delete")
+	}
+}
+
+func TestPersonHandlerListAndSearchLeaveOutForeignPeople(t *testing.T) {
+	p := newPersonTest(t)
+
+	own := []string{"/persons?lname=" + *p.own.LastName, "/persons/search?q=" + *p.own.LastName}
+	for _, target := range own {
+		if ids := listedIDs(t, p.do(t, http.MethodGet, target, nil)); !ids[p.own.ID] {
+			t.Errorf("%s left out the user's own person", target)
+		}
+	}
+
+	foreign := []string{"/persons?lname=" + *p.foreign.LastName, "/persons/search?q=" + *p.foreign.LastName}
+	for _, target := range foreign {
+		if ids := listedIDs(t, p.do(t, http.MethodGet, target, nil)); len(ids) != 0 {
+			t.Errorf("%s found %v, want nothing", target, ids)
+		}
+	}
+}
+
+func TestPersonHandlerHardDeleteBulkSkipsForeignPeople(t *testing.T) {
+	p := newPersonTest(t)
+	before := p.row(t, p.foreign.ID)
+
+	w := p.do(t, http.MethodPost, "/persons/hard-delete-bulk", gin.H{"ids": []int{p.foreign.ID, p.own.ID, p.foreign.ID}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Count   int   `json:"count"`
+		Deleted []int `json:"deleted"`
+		Skipped []int `json:"skipped"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Count != 1 || len(body.Deleted) != 1 || body.Deleted[0] != p.own.ID {
+		t.Errorf("deleted = %v (count %d), want [%d]", body.Deleted, body.Count, p.own.ID)
+	}
+	if len(body.Skipped) != 1 || body.Skipped[0] != p.foreign.ID {
+		t.Errorf("skipped = %v, want [%d]", body.Skipped, p.foreign.ID)
+	}
+	if p.row(t, p.own.ID).exists {
+		t.Error("own person still exists")
+	}
+	if after := p.row(t, p.foreign.ID); after != before {
+		t.Errorf("foreign person changed from %+v to %+v", before, after)
+	}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// RelationshipHandler relates people in the active organization to each other
+type RelationshipHandler struct {
+	relRepo    *database.RelationshipRepository
+	personRepo *database.PersonRepository
+}
+
+// NewRelationshipHandler creates a new relationship handler
+func NewRelationshipHandler(relRepo *database.RelationshipRepository, personRepo *database.PersonRepository) *RelationshipHandler {
+	return &RelationshipHandler{
+		relRepo:    relRepo,
+		personRepo: personRepo,
+	}
+}
+
+// GetRelationship returns a relationship between two people visible to the user
+func (h *RelationshipHandler) GetRelationship(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid relationship ID"})
+		return
+	}
+
+	rel, err := h.relRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil {
+		log.Printf("Failed to load relationship %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if rel == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Relationship not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rel)
+}
+
+// CreateRelationship relates two people. Both must be visible in the active organization.
+func (h *RelationshipHandler) CreateRelationship(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req models.RelationshipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	rel := &models.PersonRelationship{
+		PersonID:        req.PersonID,
+		RelatedPersonID: req.RelatedPersonID,
+		Type:            req.Type,
+		Bidirectional:   req.Bidirectional,
+		CreateUser:      &actor,
+		ModifyUser:      &actor,
+	}
+
+	created, err := h.relRepo.Create(c.Request.Context(), scope, rel)
+	if errors.Is(err, database.ErrDuplicateRelationship) {
+		c.JSON(http.StatusConflict, gin.H{"error": "These people already have a relationship of this type"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to relate person %d to person %d: %v", req.PersonID, req.RelatedPersonID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create relationship"})
+		return
+	}
+	if !created {
+		c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rel)
+}
+
+// UpdateRelationship changes a relationship's type or direction
+func (h *RelationshipHandler) UpdateRelationship(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid relationship ID"})
+		return
+	}
+
+	var req models.RelationshipUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	updated, err := h.relRepo.Update(c.Request.Context(), scope, id, req.Type, req.Bidirectional, actor)
+	if errors.Is(err, database.ErrDuplicateRelationship) {
+		c.JSON(http.StatusConflict, gin.H{"error": "These people already have a relationship of this type"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update relationship %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update relationship"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Relationship not found"})
+		return
+	}
+
+	rel, err := h.relRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil || rel == nil {
+		log.Printf("Failed to reload relationship %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rel)
+}
+
+// DeleteRelationship removes a relationship
+func (h *RelationshipHandler) DeleteRelationship(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid relationship ID"})
+		return
+	}
+
+	deleted, err := h.relRepo.Delete(c.Request.Context(), scope, id)
+	if err != nil {
+		log.Printf("Failed to delete relationship %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete relationship"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Relationship not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Relationship deleted"})
+}
+
+// GetNetwork returns everyone within ?hops= relationships of a person (default 2)
+func (h *RelationshipHandler) GetNetwork(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	personID, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid person ID"})
+		return
+	}
+
+	hops, err := strconv.Atoi(c.DefaultQuery("hops", "2"))
+	if err != nil || hops < 1 || hops > models.MaxRelationshipHops {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hops must be between 1 and " + strconv.Itoa(models.MaxRelationshipHops)})
+		return
+	}
+
+	person, err := h.personRepo.FindByID(c.Request.Context(), scope, personID)
+	if err != nil {
+		log.Printf("Failed to load person %d: %v", personID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if person == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+		return
+	}
+
+	people, err := h.relRepo.Network(c.Request.Context(), scope, personID, hops)
+	if err != nil {
+		log.Printf("Failed to traverse relationships of person %d: %v", personID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load relationship network"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"personId": personID, "hops": hops, "people": people})
+}
@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/services"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// RoleHandler handles roles, privileges and their assignments
+type RoleHandler struct {
+	roleRepo   *database.RoleRepository
+	userRepo   *database.UserRepository
+	auditRepo  *database.AuditRepository
+	privileges *services.PrivilegeService
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(roleRepo *database.RoleRepository, userRepo *database.UserRepository, auditRepo *database.AuditRepository, privileges *services.PrivilegeService) *RoleHandler {
+	return &RoleHandler{
+		roleRepo:   roleRepo,
+		userRepo:   userRepo,
+		auditRepo:  auditRepo,
+		privileges: privileges,
+	}
+}
+
+// audit records an RBAC change made by the authenticated user
+func (h *RoleHandler) audit(c *gin.Context, event, detail string) {
+	if userID, ok := middleware.GetUserID(c); ok {
+		recordAudit(c, h.auditRepo, userID, event, detail)
+	}
+}
+
+// loadRole parses the :id parameter and loads the role, writing an error response if it cannot
+func (h *RoleHandler) loadRole(c *gin.Context) (*models.SecRole, bool) {
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return nil, false
+	}
+
+	role, err := h.roleRepo.FindRoleByID(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Failed to load role %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, false
+	}
+	if role == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return nil, false
+	}
+
+	return role, true
+}
+
+// loadPrivilege parses the named parameter and loads the privilege, writing an error response if it cannot
+func (h *RoleHandler) loadPrivilege(c *gin.Context, param string) (*models.SecPrivilege, bool) {
+	id, err := utils.ParseInt(c.Param(param))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid privilege ID"})
+		return nil, false
+	}
+
+	priv, err := h.roleRepo.FindPrivilegeByID(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Failed to load privilege %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, false
+	}
+	if priv == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Privilege not found"})
+		return nil, false
+	}
+
+	return priv, true
+}
+
+// isBuiltInPrivilege reports whether a privilege is one the API checks
+func isBuiltInPrivilege(name string) bool {
+	for _, p := range models.AllPrivileges {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateRole creates a new role
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	role := &models.SecRole{Name: req.Name, Description: req.Description}
+	if err := h.roleRepo.CreateRole(c.Request.Context(), role, actor); err != nil {
+		if errors.Is(err, database.ErrDuplicateRoleName) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Role name already exists"})
+			return
+		}
+		log.Printf("Failed to create role %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+
+	h.audit(c, models.AuditRoleCreated, role.Name)
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole renames a role or changes its description
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Default roles are looked up by name
+	if role.Name != req.Name && models.DefaultRolePrivileges[role.Name] != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Default roles cannot be renamed"})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	role.Name = req.Name
+	role.Description = req.Description
+	if _, err := h.roleRepo.UpdateRole(c.Request.Context(), role, actor); err != nil {
+		if errors.Is(err, database.ErrDuplicateRoleName) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Role name already exists"})
+			return
+		}
+		log.Printf("Failed to update role %d: %v", role.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a role and removes it from every account
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+
+	if models.DefaultRolePrivileges[role.Name] != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Default roles cannot be deleted"})
+		return
+	}
+
+	if _, err := h.roleRepo.DeleteRole(c.Request.Context(), role.ID); err != nil {
+		log.Printf("Failed to delete role %d: %v", role.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	h.privileges.InvalidateAll()
+
+	h.audit(c, models.AuditRoleDeleted, role.Name)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// ListRolePrivileges returns the privileges granted to a role
+func (h *RoleHandler) ListRolePrivileges(c *gin.Context) {
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+
+	privileges, err := h.roleRepo.ListRolePrivileges(c.Request.Context(), role.ID)
+	if err != nil {
+		log.Printf("Failed to list privileges for role %d: %v", role.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"role":       role,
+		"privileges": privileges,
+	})
+}
+
+// GrantPrivilege grants a privilege to a role
+func (h *RoleHandler) GrantPrivilege(c *gin.Context) {
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+	priv, ok := h.loadPrivilege(c, "privilegeId")
+	if !ok {
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	if err := h.roleRepo.GrantPrivilege(c.Request.Context(), role.ID, priv.ID, actor); err != nil {
+		log.Printf("Failed to grant privilege %d to role %d: %v", priv.ID, role.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant privilege"})
+		return
+	}
+	h.privileges.InvalidateAll()
+
+	h.audit(c, models.AuditPrivilegeGranted, fmt.Sprintf("%s -> %s", priv.Name, role.Name))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Privilege granted"})
+}
+
+// RevokePrivilege removes a privilege from a role
+func (h *RoleHandler) RevokePrivilege(c *gin.Context) {
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+	priv, ok := h.loadPrivilege(c, "privilegeId")
+	if !ok {
+		return
+	}
+
+	if role.Name == models.RoleAdmin && isBuiltInPrivilege(priv.Name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The admin role always holds the built-in privileges"})
+		return
+	}
+
+	removed, err := h.roleRepo.RevokePrivilege(c.Request.Context(), role.ID, priv.ID)
+	if err != nil {
+		log.Printf("Failed to revoke privilege %d from role %d: %v", priv.ID, role.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke privilege"})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role does not have that privilege"})
+		return
+	}
+	h.privileges.InvalidateAll()
+
+	h.audit(c, models.AuditPrivilegeRevoked, fmt.Sprintf("%s -> %s", priv.Name, role.Name))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Privilege revoked"})
+}
+
+// ListPrivileges returns all privileges
+func (h *RoleHandler) ListPrivileges(c *gin.Context) {
+	privileges, err := h.roleRepo.ListPrivileges(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to list privileges: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"privileges": privileges})
+}
+
+// CreatePrivilege creates a new privilege
+func (h *RoleHandler) CreatePrivilege(c *gin.Context) {
+	var req models.PrivilegeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	priv, err := h.roleRepo.CreatePrivilege(c.Request.Context(), req.Name, actor)
+	if err != nil {
+		if errors.Is(err, database.ErrDuplicatePrivilegeName) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Privilege name already exists"})
+			return
+		}
+		log.Printf("Failed to create privilege %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create privilege"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, priv)
+}
+
+// DeletePrivilege deletes a privilege and removes it from every role
+func (h *RoleHandler) DeletePrivilege(c *gin.Context) {
+	priv, ok := h.loadPrivilege(c, "id")
+	if !ok {
+		return
+	}
+
+	if isBuiltInPrivilege(priv.Name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Built-in privileges cannot be deleted"})
+		return
+	}
+
+	if _, err := h.roleRepo.DeletePrivilege(c.Request.Context(), priv.ID); err != nil {
+		log.Printf("Failed to delete privilege %d: %v", priv.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete privilege"})
+		return
+	}
+	h.privileges.InvalidateAll()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Privilege deleted successfully"})
+}
+
+// loadAccount parses the :id parameter and loads the account, writing an error response if it cannot
+func loadAccount(c *gin.Context, userRepo *database.UserRepository) (*models.SecAccount, bool) {
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return nil, false
+	}
+
+	account, err := userRepo.FindAccountByID(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Failed to load account %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, false
+	}
+	if account == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return nil, false
+	}
+
+	return account, true
+}
+
+// ListAccountRoles returns the roles assigned to an account
+func (h *RoleHandler) ListAccountRoles(c *gin.Context) {
+	account, ok := loadAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	roles, err := h.roleRepo.ListAccountRoles(c.Request.Context(), account.ID)
+	if err != nil {
+		log.Printf("Failed to list roles for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// AssignRole gives an account a role
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	account, ok := loadAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+	roleID, err := utils.ParseInt(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	role, err := h.roleRepo.FindRoleByID(ctx, roleID)
+	if err != nil {
+		log.Printf("Failed to load role %d: %v", roleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if role == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	if err := h.roleRepo.AssignRole(ctx, account.ID, role.ID, actor); err != nil {
+		log.Printf("Failed to assign role %d to account %d: %v", role.ID, account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+		return
+	}
+	h.privileges.Invalidate(account.ID)
+
+	h.audit(c, models.AuditRoleAssigned, fmt.Sprintf("%s -> %s", role.Name, account.Name))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned"})
+}
+
+// RemoveRole takes a role away from an account. The last admin cannot be removed.
+func (h *RoleHandler) RemoveRole(c *gin.Context) {
+	account, ok := loadAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+	roleID, err := utils.ParseInt(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	role, err := h.roleRepo.FindRoleByID(ctx, roleID)
+	if err != nil {
+		log.Printf("Failed to load role %d: %v", roleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if role == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if role.Name == models.RoleAdmin {
+		members, err := h.roleRepo.CountRoleMembers(ctx, role.ID)
+		if err != nil {
+			log.Printf("Failed to count members of role %d: %v", role.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		if members <= 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove the last admin"})
+			return
+		}
+	}
+
+	removed, err := h.roleRepo.RemoveRole(ctx, account.ID, role.ID)
+	if err != nil {
+		log.Printf("Failed to remove role %d from account %d: %v", role.ID, account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role"})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account does not have that role"})
+		return
+	}
+	h.privileges.Invalidate(account.ID)
+
+	h.audit(c, models.AuditRoleRemoved, fmt.Sprintf("%s -> %s", role.Name, account.Name))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role removed"})
+}
+
+// MyPrivileges returns the roles and privileges of the authenticated account
+func (h *RoleHandler) MyPrivileges(c *gin.Context) {
+	accountID, ok := middleware.GetAccountID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	roles, err := h.roleRepo.ListAccountRoles(c.Request.Context(), accountID)
+	if err != nil {
+		log.Printf("Failed to list roles for account %d: %v", accountID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	names := make([]string, 0)
+	for name := range middleware.GetPrivileges(c) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles":      roles,
+		"privileges": names,
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/services"
+	"github.com/davealexenglish/magnifimind-crm/pkg/config"
+)
+
+// SessionHandler lists and ends login sessions, for the current user or (with users.manage) anyone
+type SessionHandler struct {
+	sessionRepo *database.SessionRepository
+	userRepo    *database.UserRepository
+	auditRepo   *database.AuditRepository
+	revocations *services.TokenRevocationService
+	config      *config.Config
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessionRepo *database.SessionRepository, userRepo *database.UserRepository, auditRepo *database.AuditRepository, revocations *services.TokenRevocationService, cfg *config.Config) *SessionHandler {
+	return &SessionHandler{
+		sessionRepo: sessionRepo,
+		userRepo:    userRepo,
+		auditRepo:   auditRepo,
+		revocations: revocations,
+		config:      cfg,
+	}
+}
+
+// ListMySessions returns the current user's active sessions, marking the one making the request
+func (h *SessionHandler) ListMySessions(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	h.listSessions(c, userID)
+}
+
+// RevokeMySession ends one of the current user's sessions. Ending the current session logs it out.
+func (h *SessionHandler) RevokeMySession(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	h.revokeSession(c, userID, c.Param("id"))
+}
+
+// ListUserSessions returns a user's active sessions. Requires the users.manage privilege.
+func (h *SessionHandler) ListUserSessions(c *gin.Context) {
+	userID, ok := h.loadUserID(c)
+	if !ok {
+		return
+	}
+
+	h.listSessions(c, userID)
+}
+
+// RevokeUserSession ends one of a user's sessions. Requires the users.manage privilege.
+func (h *SessionHandler) RevokeUserSession(c *gin.Context) {
+	userID, ok := h.loadUserID(c)
+	if !ok {
+		return
+	}
+
+	h.revokeSession(c, userID, c.Param("sessionId"))
+}
+
+// loadUserID returns the user ID in the path after checking the user exists
+func (h *SessionHandler) loadUserID(c *gin.Context) (int, bool) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return 0, false
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return 0, false
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// listSessions writes a user's active sessions
+func (h *SessionHandler) listSessions(c *gin.Context, userID int) {
+	sessions, err := h.sessionRepo.ListActive(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to list sessions for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if current, ok := middleware.GetSessionID(c); ok {
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == current
+		}
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// revokeSession ends a session: its refresh tokens are revoked so it cannot be refreshed,
+// and its outstanding access tokens are put on the revocation list
+func (h *SessionHandler) revokeSession(c *gin.Context, userID int, sessionID string) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	revoked, err := h.sessionRepo.Revoke(ctx, userID, sessionID)
+	if err != nil {
+		log.Printf("Failed to revoke session %s: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	// Access tokens issued to the session live at most this long
+	expiresAt := time.Now().Add(time.Duration(h.config.JWT.AccessTokenMinutes) * time.Minute)
+	if err := h.revocations.RevokeSession(ctx, sessionID, userID, expiresAt); err != nil {
+		log.Printf("Failed to revoke access tokens of session %s: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+		return
+	}
+
+	if currentUserID, ok := middleware.GetUserID(c); ok {
+		recordAudit(c, h.auditRepo, currentUserID, models.AuditSessionRevoked, "user "+strconv.Itoa(userID)+" session "+sessionID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session ended"})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// ShareHandler shares individual people with other users. Only a person's owner
+// can see and change who it is shared with.
+type ShareHandler struct {
+	shareRepo *database.ShareRepository
+	userRepo  *database.UserRepository
+	auditRepo *database.AuditRepository
+}
+
+// NewShareHandler creates a new share handler
+func NewShareHandler(shareRepo *database.ShareRepository, userRepo *database.UserRepository, auditRepo *database.AuditRepository) *ShareHandler {
+	return &ShareHandler{
+		shareRepo: shareRepo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+	}
+}
+
+// loadOwnPerson parses the :id parameter and checks the current user owns the person,
+// writing an error response if not. People the user does not own are reported as not found.
+func (h *ShareHandler) loadOwnPerson(c *gin.Context) (personID, userID int, ok bool) {
+	userID, ok = middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return 0, 0, false
+	}
+
+	personID, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid person ID"})
+		return 0, 0, false
+	}
+
+	owns, err := h.shareRepo.OwnsPerson(c.Request.Context(), personID, userID)
+	if err != nil {
+		log.Printf("Failed to check owner of person %d: %v", personID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return 0, 0, false
+	}
+	if !owns {
+		c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+		return 0, 0, false
+	}
+
+	return personID, userID, true
+}
+
+// ListShares returns the users one of the current user's people is shared with
+func (h *ShareHandler) ListShares(c *gin.Context) {
+	personID, _, ok := h.loadOwnPerson(c)
+	if !ok {
+		return
+	}
+
+	shares, err := h.shareRepo.ListForPerson(c.Request.Context(), personID)
+	if err != nil {
+		log.Printf("Failed to list shares of person %d: %v", personID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list shares"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+// SharePerson shares one of the current user's people with the user behind an account,
+// or changes the permission it is shared with
+func (h *ShareHandler) SharePerson(c *gin.Context) {
+	personID, userID, ok := h.loadOwnPerson(c)
+	if !ok {
+		return
+	}
+
+	var req models.ShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.userRepo.FindByUsername(c.Request.Context(), req.Account)
+	if err != nil {
+		log.Printf("Failed to load account %s: %v", req.Account, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if account == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+	if account.UserID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot share a person with yourself"})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	if err := h.shareRepo.Grant(c.Request.Context(), personID, account.UserID, req.Permission, actor); err != nil {
+		log.Printf("Failed to share person %d with user %d: %v", personID, account.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share person"})
+		return
+	}
+
+	recordAudit(c, h.auditRepo, userID, models.AuditPersonShared,
+		fmt.Sprintf("person %d with user %d (%s): %s", personID, account.UserID, account.Name, req.Permission))
+
+	c.JSON(http.StatusOK, gin.H{"personId": personID, "userId": account.UserID, "permission": req.Permission})
+}
+
+// RevokeShare stops sharing one of the current user's people with a user
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	personID, userID, ok := h.loadOwnPerson(c)
+	if !ok {
+		return
+	}
+
+	granteeID, err := utils.ParseInt(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	revoked, err := h.shareRepo.Revoke(c.Request.Context(), personID, granteeID)
+	if err != nil {
+		log.Printf("Failed to revoke share of person %d with user %d: %v", personID, granteeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	recordAudit(c, h.auditRepo, userID, models.AuditPersonShareRevoked, fmt.Sprintf("person %d with user %d", personID, granteeID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share revoked"})
+}
+
+// ListSharedWithMe returns the people other users have shared with the current user
+func (h *ShareHandler) ListSharedWithMe(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	people, err := h.shareRepo.ListSharedWith(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to list people shared with user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list shared people"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"people": people})
+}
@@ -1,44 +1,57 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/davealexenglish/magnifimind-crm/internal/database"
 	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
 	"github.com/gin-gonic/gin"
 )
 
 // TableHandler handles generic table operations
 type TableHandler struct {
-	db *database.DB
+	db        *database.DB
+	fieldRepo *database.CustomFieldRepository
 }
 
 // NewTableHandler creates a new TableHandler
 func NewTableHandler(db *database.DB) *TableHandler {
-	return &TableHandler{db: db}
+	return &TableHandler{
+		db:        db,
+		fieldRepo: database.NewCustomFieldRepository(db),
+	}
 }
 
 // TableConfig defines configuration for a table
 type TableConfig struct {
-	TableName   string
-	IDColumn    string
-	Columns     []string
-	OrderBy     string
-	CreateUser  bool   // Whether to track create/modify user
-	MultiTenant bool   // Whether to filter by sec_users_id
-	UseView     bool   // Whether to use a view instead of base table
-	ViewName    string // Name of the view if UseView is true
+	TableName     string
+	IDColumn      string
+	Columns       []string
+	OrderBy       string
+	CreateUser    bool   // Whether to track create/modify user
+	MultiTenant   bool   // Whether to limit rows to people visible in the active organization
+	CompanyScoped bool   // Whether to limit rows to companies in the active organization
+	UseView       bool   // Whether to use a view instead of base table
+	ViewName      string // Name of the view if UseView is true
 }
 
 var tableConfigs = map[string]TableConfig{
 	"people": {
 		TableName:   "v_active_people",
 		IDColumn:    "pdat_person_id",
-		Columns:     []string{"pdat_person_id", "fname", "lname", "full_name", "birthday", "business_flag", "sec_users_id", "create_date", "create_user", "modify_date", "modify_user", "active_flag"},
+		Columns:     []string{"pdat_person_id", "fname", "lname", "full_name", "birthday", "business_flag", "sec_users_id", "create_date", "create_user", "modify_date", "modify_user", "active_flag", "sec_orgs_id", "private_flag"},
 		OrderBy:     "lname, fname",
 		CreateUser:  true,
 		MultiTenant: true,
@@ -95,6 +108,46 @@ var tableConfigs = map[string]TableConfig{
 		UseView:     true,
 		ViewName:    "v_person_links",
 	},
+	"companies": {
+		TableName:     "v_active_companies",
+		IDColumn:      "pdat_company_id",
+		Columns:       []string{"pdat_company_id", "name", "domain", "industry", "sec_orgs_id", "create_date", "create_user", "modify_date", "modify_user", "active_flag"},
+		OrderBy:       "name",
+		CreateUser:    true,
+		CompanyScoped: true,
+		UseView:       true,
+		ViewName:      "v_active_companies",
+	},
+	"company-addresses": {
+		TableName:     "v_company_addresses",
+		IDColumn:      "pdat_company_address_id",
+		Columns:       []string{"pdat_company_address_id", "addr1", "addr2", "city", "cmn_states_id", "state", "state_name", "zip", "zip_plus_4", "country", "pdat_company_id", "company_name", "sec_orgs_id", "create_date", "create_user", "modify_date", "modify_user", "active_flag"},
+		OrderBy:       "company_name",
+		CreateUser:    true,
+		CompanyScoped: true,
+		UseView:       true,
+		ViewName:      "v_company_addresses",
+	},
+	"company-phones": {
+		TableName:     "v_company_phones",
+		IDColumn:      "pdat_company_phone_id",
+		Columns:       []string{"pdat_company_phone_id", "phone_num", "phone_ext", "country_code", "pdat_phone_type_id", "phone_type_name", "pdat_company_id", "company_name", "sec_orgs_id", "create_date", "create_user", "modify_date", "modify_user", "active_flag"},
+		OrderBy:       "company_name",
+		CreateUser:    true,
+		CompanyScoped: true,
+		UseView:       true,
+		ViewName:      "v_company_phones",
+	},
+	"employments": {
+		TableName:   "v_person_employment",
+		IDColumn:    "pdat_employment_id",
+		Columns:     []string{"pdat_employment_id", "pdat_person_id", "pdat_company_id", "company_name", "title", "department", "start_date", "end_date", "person_fname", "person_lname", "person_full_name", "sec_users_id", "create_date", "create_user", "modify_date", "modify_user", "active_flag", "sec_orgs_id", "private_flag"},
+		OrderBy:     "person_lname, person_fname",
+		CreateUser:  true,
+		MultiTenant: true,
+		UseView:     true,
+		ViewName:    "v_person_employment",
+	},
 	"accounts": {
 		TableName:  "sec_accounts",
 		IDColumn:   "sec_accounts_id",
@@ -141,15 +194,222 @@ var writeableColumns = map[string][]string{
 	"addresses": {"addr1", "addr2", "city", "cmn_states_id", "zip", "zip_plus_4", "country", "pdat_person_id"},
 	"notes":     {"note_text", "pdat_person_id"},
 	"links":     {"link_text", "link_url", "note", "pdat_person_id", "sec_users_id"},
+	// sec_orgs_id is set from the active organization on create and never changes
+	"companies":         {"name", "domain", "industry"},
+	"company-addresses": {"addr1", "addr2", "city", "cmn_states_id", "zip", "zip_plus_4", "country", "pdat_company_id"},
+	"company-phones":    {"phone_num", "phone_ext", "country_code", "pdat_phone_type_id", "pdat_company_id"},
+	"employments":       {"pdat_person_id", "pdat_company_id", "title", "department", "start_date", "end_date"},
 }
 
 // baseTableNames maps table keys to their base table names (for inserts/updates)
 var baseTableNames = map[string]string{
-	"emails":    "pdat_pers_emails",
-	"phones":    "pdat_pers_phone",
-	"addresses": "pdat_address",
-	"notes":     "pdat_pers_notes",
-	"links":     "pdat_links",
+	"emails":            "pdat_pers_emails",
+	"phones":            "pdat_pers_phone",
+	"addresses":         "pdat_address",
+	"notes":             "pdat_pers_notes",
+	"links":             "pdat_links",
+	"companies":         "pdat_company",
+	"company-addresses": "pdat_company_address",
+	"company-phones":    "pdat_company_phone",
+	"employments":       "pdat_employment",
+}
+
+// personAccessible reports whether a person is visible in the tenant scope or shared with
+// its user; with edit set, read-only shares do not count
+func (h *TableHandler) personAccessible(ctx context.Context, personID interface{}, scope models.TenantScope, edit bool) (bool, error) {
+	var accessible bool
+	query := "SELECT EXISTS (SELECT 1 FROM pdat_person WHERE pdat_person_id = $1 AND " +
+		database.AccessibleCondition("pdat_person.", 2, 3, edit) + ")"
+	err := h.db.QueryRowContext(ctx, query, personID, scope.OrgID, scope.UserID).Scan(&accessible)
+	return accessible, err
+}
+
+// companyVisible reports whether a company belongs to the tenant scope's organization
+func (h *TableHandler) companyVisible(ctx context.Context, companyID interface{}, scope models.TenantScope) (bool, error) {
+	var visible bool
+	query := "SELECT EXISTS (SELECT 1 FROM pdat_company WHERE pdat_company_id = $1 AND " +
+		database.CompanyCondition("pdat_company.", 2) + ")"
+	err := h.db.QueryRowContext(ctx, query, companyID, scope.OrgID).Scan(&visible)
+	return visible, err
+}
+
+// personSortColumns are the people columns ?sort= accepts besides custom fields
+var personSortColumns = map[string]bool{
+	"fname":       true,
+	"lname":       true,
+	"birthday":    true,
+	"create_date": true,
+	"modify_date": true,
+}
+
+// personFilters returns the conditions the people list's query parameters put on people,
+// numbering their placeholders from argNum, and the ORDER BY ?sort= asks for, if any.
+// prefix must name the queried table or view (e.g. "v_active_people."); fields are the
+// organization's custom fields.
+//
+// Each ?tag= parameter is a condition a person must meet; the comma-separated tag names in
+// it are alternatives and a leading "-" matches people without that tag, so
+// ?tag=vip,partner&tag=-former matches people tagged vip or partner who are not tagged
+// former. Tag names are matched case-insensitively in the organization.
+//
+// ?cf.<key>=value matches a custom field: text fields by substring, ignoring case, and
+// other types exactly. ?cf.<key>.min= and ?cf.<key>.max= bound number and date fields.
+// ?sort= takes a person column or cf.<key>, with a leading "-" to sort descending.
+func personFilters(c *gin.Context, prefix string, orgID, argNum int, fields []*models.CustomField) ([]string, []interface{}, string, error) {
+	var conditions []string
+	var args []interface{}
+
+	// Combined name search (searches both fname and lname)
+	name := c.Query("name")
+	if name != "" {
+		conditions = append(conditions, fmt.Sprintf("(fname ILIKE $%d OR lname ILIKE $%d)", argNum, argNum+1))
+		args = append(args, "%"+name+"%", "%"+name+"%")
+		argNum += 2
+	}
+
+	// Individual field searches (backward compatibility)
+	fname := c.Query("fname")
+	if fname != "" {
+		conditions = append(conditions, fmt.Sprintf("fname ILIKE $%d", argNum))
+		args = append(args, "%"+fname+"%")
+		argNum++
+	}
+
+	lname := c.Query("lname")
+	if lname != "" {
+		conditions = append(conditions, fmt.Sprintf("lname ILIKE $%d", argNum))
+		args = append(args, "%"+lname+"%")
+		argNum++
+	}
+
+	businessFlag := c.Query("business_flag")
+	if businessFlag == "true" {
+		conditions = append(conditions, "business_flag = 'Y'")
+	}
+
+	if tagFilters := c.QueryArray("tag"); len(tagFilters) > 0 {
+		orgArg := argNum
+		args = append(args, orgID)
+		argNum++
+		for _, filter := range tagFilters {
+			var alternatives []string
+			for _, tagName := range strings.Split(filter, ",") {
+				tagName = strings.TrimSpace(tagName)
+				negate := strings.HasPrefix(tagName, "-")
+				if negate {
+					tagName = strings.TrimSpace(tagName[1:])
+				}
+				if tagName == "" {
+					return nil, nil, "", fmt.Errorf("invalid tag filter %q", filter)
+				}
+
+				condition := database.TagCondition(prefix, orgArg, argNum)
+				if negate {
+					condition = "NOT " + condition
+				}
+				alternatives = append(alternatives, condition)
+				args = append(args, tagName)
+				argNum++
+			}
+			conditions = append(conditions, "("+strings.Join(alternatives, " OR ")+")")
+		}
+	}
+
+	byKey := make(map[string]*models.CustomField, len(fields))
+	for _, field := range fields {
+		byKey[field.Key] = field
+	}
+
+	// Custom field filters, in a stable order so placeholders are numbered predictably
+	var params []string
+	for param := range c.Request.URL.Query() {
+		if strings.HasPrefix(param, "cf.") {
+			params = append(params, param)
+		}
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		key, bound, _ := strings.Cut(strings.TrimPrefix(param, "cf."), ".")
+		field := byKey[key]
+		if field == nil {
+			return nil, nil, "", fmt.Errorf("unknown custom field %q", key)
+		}
+
+		op := "="
+		switch bound {
+		case "":
+			if field.Type == models.CustomFieldText {
+				op = "ILIKE"
+				raw = "%" + raw + "%"
+			}
+		case "min", "max":
+			if field.Type != models.CustomFieldNumber && field.Type != models.CustomFieldDate {
+				return nil, nil, "", fmt.Errorf("%s only applies to number and date fields", param)
+			}
+			op = map[string]string{"min": ">=", "max": "<="}[bound]
+		default:
+			return nil, nil, "", fmt.Errorf("invalid custom field filter %q", param)
+		}
+
+		value, err := customFilterValue(field, raw)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		conditions = append(conditions, database.CustomValueCondition(prefix, field, op, argNum))
+		args = append(args, value)
+		argNum++
+	}
+
+	orderBy := ""
+	if sortBy := c.Query("sort"); sortBy != "" {
+		direction := "ASC"
+		if strings.HasPrefix(sortBy, "-") {
+			direction = "DESC"
+			sortBy = sortBy[1:]
+		}
+		if key, ok := strings.CutPrefix(sortBy, "cf."); ok {
+			field := byKey[key]
+			if field == nil {
+				return nil, nil, "", fmt.Errorf("unknown custom field %q", key)
+			}
+			orderBy = fmt.Sprintf("%s %s NULLS LAST, lname, fname", database.CustomValueExpr(prefix, field), direction)
+		} else if personSortColumns[sortBy] {
+			orderBy = fmt.Sprintf("%s %s NULLS LAST, lname, fname", sortBy, direction)
+		} else {
+			return nil, nil, "", fmt.Errorf("cannot sort by %q", sortBy)
+		}
+	}
+
+	return conditions, args, orderBy, nil
+}
+
+// customFilterValue converts a custom field filter value from the query string for comparison
+func customFilterValue(field *models.CustomField, raw string) (interface{}, error) {
+	switch field.Type {
+	case models.CustomFieldNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("custom field %q must be filtered by a number", field.Key)
+		}
+		return n, nil
+	case models.CustomFieldDate:
+		if _, err := time.Parse("2006-01-02", raw); err != nil {
+			return nil, fmt.Errorf("custom field %q must be filtered by a date (YYYY-MM-DD)", field.Key)
+		}
+		return raw, nil
+	case models.CustomFieldBoolean:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("custom field %q must be filtered by true or false", field.Key)
+		}
+		return b, nil
+	default:
+		return raw, nil
+	}
 }
 
 // ListRecords returns all records from a table
@@ -176,45 +436,54 @@ func (h *TableHandler) ListRecords(tableKey string) gin.HandlerFunc {
 		argNum := 1
 
 		if config.MultiTenant {
-			// Get user ID from JWT context
-			userID, ok := middleware.GetUserID(c)
+			// Get user and organization from context
+			scope, ok := middleware.GetTenantScope(c)
 			if !ok {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 				return
 			}
-			conditions = append(conditions, fmt.Sprintf("sec_users_id = $%d", argNum))
-			args = append(args, userID)
+			conditions = append(conditions, database.VisibleCondition("", argNum, argNum+1))
+			args = append(args, scope.OrgID, scope.UserID)
+			argNum += 2
+		} else if config.CompanyScoped {
+			scope, ok := middleware.GetTenantScope(c)
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+				return
+			}
+			conditions = append(conditions, database.CompanyCondition("", argNum))
+			args = append(args, scope.OrgID)
 			argNum++
 		}
 
-		// Handle query parameters for filtering (for people table)
-		if tableKey == "people" {
-			// Combined name search (searches both fname and lname)
-			name := c.Query("name")
-			if name != "" {
-				conditions = append(conditions, fmt.Sprintf("(fname ILIKE $%d OR lname ILIKE $%d)", argNum, argNum+1))
-				args = append(args, "%"+name+"%", "%"+name+"%")
-				argNum += 2
-			}
-
-			// Individual field searches (backward compatibility)
-			fname := c.Query("fname")
-			if fname != "" {
-				conditions = append(conditions, fmt.Sprintf("fname ILIKE $%d", argNum))
-				args = append(args, "%"+fname+"%")
+		// Name search for companies
+		if tableKey == "companies" {
+			if name := c.Query("name"); name != "" {
+				conditions = append(conditions, fmt.Sprintf("name ILIKE $%d", argNum))
+				args = append(args, "%"+name+"%")
 				argNum++
 			}
+		}
 
-			lname := c.Query("lname")
-			if lname != "" {
-				conditions = append(conditions, fmt.Sprintf("lname ILIKE $%d", argNum))
-				args = append(args, "%"+lname+"%")
-				argNum++
+		// Handle query parameters for filtering (for people table)
+		orderBy := config.OrderBy
+		if tableKey == "people" {
+			scope, _ := middleware.GetTenantScope(c)
+			fields, err := h.fieldRepo.List(c.Request.Context(), scope.OrgID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
 			}
-
-			businessFlag := c.Query("business_flag")
-			if businessFlag == "true" {
-				conditions = append(conditions, "business_flag = 'Y'")
+			filters, filterArgs, sortBy, err := personFilters(c, tableName+".", scope.OrgID, argNum, fields)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			conditions = append(conditions, filters...)
+			args = append(args, filterArgs...)
+			argNum += len(filterArgs)
+			if sortBy != "" {
+				orderBy = sortBy
 			}
 		}
 
@@ -226,6 +495,9 @@ func (h *TableHandler) ListRecords(tableKey string) gin.HandlerFunc {
 		} else {
 			selectClause = "*"
 		}
+		if tableKey == "people" {
+			selectClause += ", " + database.CustomValuesJSON(tableName+".") + " AS custom_fields"
+		}
 
 		var query string
 		if len(conditions) > 0 {
@@ -233,9 +505,9 @@ func (h *TableHandler) ListRecords(tableKey string) gin.HandlerFunc {
 			for i := 1; i < len(conditions); i++ {
 				whereClause += " AND " + conditions[i]
 			}
-			query = fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s", selectClause, tableName, whereClause, config.OrderBy)
+			query = fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s", selectClause, tableName, whereClause, orderBy)
 		} else {
-			query = fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", selectClause, tableName, config.OrderBy)
+			query = fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", selectClause, tableName, orderBy)
 		}
 
 		fmt.Printf("[DEBUG] TableHandler ListRecords query: %s\n", query)
@@ -244,7 +516,7 @@ func (h *TableHandler) ListRecords(tableKey string) gin.HandlerFunc {
 		// Execute query
 		var rows *sql.Rows
 		var err error
-		rows, err = h.db.Query(query, args...)
+		rows, err = h.db.QueryContext(c.Request.Context(), query, args...)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -278,6 +550,10 @@ func (h *TableHandler) ListRecords(tableKey string) gin.HandlerFunc {
 			for i, col := range columns {
 				record[col] = values[i]
 			}
+			// Custom field values arrive as JSON text
+			if raw, ok := record["custom_fields"].([]byte); ok {
+				record["custom_fields"] = json.RawMessage(raw)
+			}
 			records = append(records, record)
 		}
 
@@ -305,18 +581,30 @@ func (h *TableHandler) GetRecord(tableKey string) gin.HandlerFunc {
 		var rows *sql.Rows
 
 		if config.MultiTenant {
-			// Get user ID from JWT context
-			userID, ok := middleware.GetUserID(c)
+			// Get user and organization from context
+			scope, ok := middleware.GetTenantScope(c)
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+				return
+			}
+
+			// People shared with the user are readable too
+			query = fmt.Sprintf("SELECT * FROM %s WHERE %s = $1 AND %s", config.TableName, config.IDColumn,
+				database.AccessibleCondition(config.TableName+".", 2, 3, false))
+			rows, err = h.db.QueryContext(c.Request.Context(), query, id, scope.OrgID, scope.UserID)
+		} else if config.CompanyScoped {
+			scope, ok := middleware.GetTenantScope(c)
 			if !ok {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 				return
 			}
 
-			query = fmt.Sprintf("SELECT * FROM %s WHERE %s = $1 AND sec_users_id = $2", config.TableName, config.IDColumn)
-			rows, err = h.db.Query(query, id, userID)
+			query = fmt.Sprintf("SELECT * FROM %s WHERE %s = $1 AND %s", config.TableName, config.IDColumn,
+				database.CompanyCondition(config.TableName+".", 2))
+			rows, err = h.db.QueryContext(c.Request.Context(), query, id, scope.OrgID)
 		} else {
 			query = fmt.Sprintf("SELECT * FROM %s WHERE %s = $1", config.TableName, config.IDColumn)
-			rows, err = h.db.Query(query, id)
+			rows, err = h.db.QueryContext(c.Request.Context(), query, id)
 		}
 
 		if err != nil {
@@ -391,33 +679,50 @@ func (h *TableHandler) DeleteRecord(tableKey string) gin.HandlerFunc {
 				tableName = "pdat_pers_notes"
 			case "v_person_links":
 				tableName = "pdat_links"
+			case "v_active_companies":
+				tableName = "pdat_company"
+			case "v_company_addresses":
+				tableName = "pdat_company_address"
+			case "v_company_phones":
+				tableName = "pdat_company_phone"
+			case "v_person_employment":
+				tableName = "pdat_employment"
 			}
 		}
 
 		var result sql.Result
 		if config.MultiTenant {
-			// Get user ID from JWT context
-			userID, ok := middleware.GetUserID(c)
+			// Get user and organization from context
+			scope, ok := middleware.GetTenantScope(c)
 			if !ok {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 				return
 			}
 
-			// Multi-tenant delete - ensure user owns the record via JOIN
-			if config.UseView {
-				// For related tables, join to pdat_person to verify ownership
-				query = fmt.Sprintf(`DELETE FROM %s
-					WHERE %s = $1
-					AND pdat_person_id IN (SELECT pdat_person_id FROM pdat_person WHERE sec_users_id = $2)`,
-					tableName, config.IDColumn)
-			} else {
-				// For direct tables, check sec_users_id directly
-				query = fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND sec_users_id = $2", tableName, config.IDColumn)
+			// Multi-tenant delete - ensure the record's person is visible, or shared with
+			// edit permission, via a subquery. Shares never allow deleting the person itself.
+			condition := database.AccessibleCondition("pdat_person.", 2, 3, true)
+			if tableName == "pdat_person" {
+				condition = database.VisibleCondition("pdat_person.", 2, 3)
 			}
-			result, err = h.db.Exec(query, id, userID)
+			query = fmt.Sprintf(`DELETE FROM %s
+				WHERE %s = $1
+				AND pdat_person_id IN (SELECT pdat_person_id FROM pdat_person WHERE %s)`,
+				tableName, config.IDColumn, condition)
+			result, err = h.db.ExecContext(c.Request.Context(), query, id, scope.OrgID, scope.UserID)
+		} else if config.CompanyScoped {
+			scope, ok := middleware.GetTenantScope(c)
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+				return
+			}
+
+			// Deleting a company also deletes its addresses, phones and employment records (via CASCADE)
+			query = fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND %s", tableName, config.IDColumn, database.CompanyCondition("", 2))
+			result, err = h.db.ExecContext(c.Request.Context(), query, id, scope.OrgID)
 		} else {
 			query = fmt.Sprintf("DELETE FROM %s WHERE %s = $1", tableName, config.IDColumn)
-			result, err = h.db.Exec(query, id)
+			result, err = h.db.ExecContext(c.Request.Context(), query, id)
 		}
 
 		if err != nil {
@@ -435,13 +740,54 @@ func (h *TableHandler) DeleteRecord(tableKey string) gin.HandlerFunc {
 	}
 }
 
-// GetPersonFull returns a person with all related data (emails, phones, addresses, links, notes)
+// fetchRecords runs a query and returns its rows as maps keyed by column name
+func (h *TableHandler) fetchRecords(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		record := make(map[string]interface{})
+		for i, col := range columns {
+			// Convert []byte to string (PostgreSQL char(1) comes as []byte)
+			if b, ok := values[i].([]byte); ok {
+				record[col] = string(b)
+			} else {
+				record[col] = values[i]
+			}
+		}
+		records = append(records, record)
+	}
+	if records == nil {
+		records = []map[string]interface{}{}
+	}
+	return records, nil
+}
+
+// GetPersonFull returns a person with all related data (emails, phones, addresses, links, notes, employers,
+// relationships, tags)
 func (h *TableHandler) GetPersonFull(c *gin.Context) {
 	id := c.Param("id")
 	showInactive := c.Query("show_inactive") == "true"
 
-	// Get user ID from JWT context for multi-tenant filtering
-	userID, ok := middleware.GetUserID(c)
+	// Get user and organization from context for multi-tenant filtering
+	scope, ok := middleware.GetTenantScope(c)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 		return
@@ -451,27 +797,30 @@ func (h *TableHandler) GetPersonFull(c *gin.Context) {
 	var personQuery string
 	if showInactive {
 		personQuery = `SELECT pdat_person_id, fname, lname, fname || ' ' || lname AS full_name,
-			birthday, business_flag, sec_users_id, create_date, create_user, modify_date, modify_user, active_flag
-			FROM pdat_person WHERE pdat_person_id = $1 AND sec_users_id = $2`
+			birthday, business_flag, sec_users_id, create_date, create_user, modify_date, modify_user, active_flag,
+			sec_orgs_id, private_flag
+			FROM pdat_person WHERE pdat_person_id = $1 AND ` + database.AccessibleCondition("pdat_person.", 2, 3, false)
 	} else {
 		personQuery = `SELECT pdat_person_id, fname, lname, full_name,
-			birthday, business_flag, sec_users_id, create_date, create_user, modify_date, modify_user, active_flag
-			FROM v_active_people WHERE pdat_person_id = $1 AND sec_users_id = $2`
+			birthday, business_flag, sec_users_id, create_date, create_user, modify_date, modify_user, active_flag,
+			sec_orgs_id, private_flag
+			FROM v_active_people WHERE pdat_person_id = $1 AND ` + database.AccessibleCondition("v_active_people.", 2, 3, false)
 	}
 
-	personRow := h.db.QueryRow(personQuery, id, userID)
+	personRow := h.db.QueryRowContext(c.Request.Context(), personQuery, id, scope.OrgID, scope.UserID)
 	person := make(map[string]interface{})
 
 	var pdatPersonID int
 	var fname, lname, fullName sql.NullString
 	var birthday sql.NullTime
-	var businessFlag, activeFlag string
-	var secUsersID int
+	var businessFlag, activeFlag, privateFlag string
+	var secUsersID, secOrgsID int
 	var createDate, modifyDate sql.NullTime
 	var createUser, modifyUser sql.NullString
 
 	err := personRow.Scan(&pdatPersonID, &fname, &lname, &fullName, &birthday, &businessFlag,
-		&secUsersID, &createDate, &createUser, &modifyDate, &modifyUser, &activeFlag)
+		&secUsersID, &createDate, &createUser, &modifyDate, &modifyUser, &activeFlag,
+		&secOrgsID, &privateFlag)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
@@ -490,46 +839,16 @@ func (h *TableHandler) GetPersonFull(c *gin.Context) {
 	}
 	person["business_flag"] = businessFlag
 	person["active_flag"] = activeFlag
+	person["sec_users_id"] = secUsersID
+	person["sec_orgs_id"] = secOrgsID
+	person["private_flag"] = privateFlag
 
-	// Helper function to fetch related records
-	fetchRelated := func(query string, args ...interface{}) ([]map[string]interface{}, error) {
-		rows, err := h.db.Query(query, args...)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
-
-		columns, err := rows.Columns()
-		if err != nil {
-			return nil, err
-		}
-
-		var records []map[string]interface{}
-		for rows.Next() {
-			values := make([]interface{}, len(columns))
-			valuePtrs := make([]interface{}, len(columns))
-			for i := range values {
-				valuePtrs[i] = &values[i]
-			}
-			if err := rows.Scan(valuePtrs...); err != nil {
-				return nil, err
-			}
-			record := make(map[string]interface{})
-			for i, col := range columns {
-				// Convert []byte to string (PostgreSQL char(1) comes as []byte)
-				if b, ok := values[i].([]byte); ok {
-					record[col] = string(b)
-				} else {
-					record[col] = values[i]
-				}
-			}
-			records = append(records, record)
-		}
-		if records == nil {
-			records = []map[string]interface{}{}
-		}
-		return records, nil
+	customFields, err := h.fieldRepo.Values(c.Request.Context(), pdatPersonID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch custom fields: " + err.Error()})
+		return
 	}
+	person["custom_fields"] = customFields
 
 	// Fetch emails
 	var emailQuery string
@@ -544,7 +863,7 @@ func (h *TableHandler) GetPersonFull(c *gin.Context) {
 			email_type_name, create_date, create_user, modify_date, modify_user, active_flag
 			FROM v_person_emails WHERE pdat_person_id = $1 ORDER BY email_addr`
 	}
-	emails, err := fetchRelated(emailQuery, id)
+	emails, err := h.fetchRecords(c.Request.Context(), emailQuery, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch emails: " + err.Error()})
 		return
@@ -565,7 +884,7 @@ func (h *TableHandler) GetPersonFull(c *gin.Context) {
 			create_date, create_user, modify_date, modify_user, active_flag
 			FROM v_person_phones WHERE pdat_person_id = $1 ORDER BY phone_num`
 	}
-	phones, err := fetchRelated(phoneQuery, id)
+	phones, err := h.fetchRecords(c.Request.Context(), phoneQuery, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch phones: " + err.Error()})
 		return
@@ -586,7 +905,7 @@ func (h *TableHandler) GetPersonFull(c *gin.Context) {
 			create_date, create_user, modify_date, modify_user, active_flag
 			FROM v_person_addresses WHERE pdat_person_id = $1 ORDER BY addr1`
 	}
-	addresses, err := fetchRelated(addressQuery, id)
+	addresses, err := h.fetchRecords(c.Request.Context(), addressQuery, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch addresses: " + err.Error()})
 		return
@@ -603,7 +922,7 @@ func (h *TableHandler) GetPersonFull(c *gin.Context) {
 			create_date, create_user, modify_date, modify_user, active_flag
 			FROM v_person_links WHERE pdat_person_id = $1 ORDER BY link_text`
 	}
-	links, err := fetchRelated(linkQuery, id)
+	links, err := h.fetchRecords(c.Request.Context(), linkQuery, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch links: " + err.Error()})
 		return
@@ -620,19 +939,283 @@ func (h *TableHandler) GetPersonFull(c *gin.Context) {
 			create_date, create_user, modify_date, modify_user, active_flag
 			FROM v_person_notes WHERE pdat_person_id = $1 ORDER BY create_date DESC`
 	}
-	notes, err := fetchRelated(noteQuery, id)
+	notes, err := h.fetchRecords(c.Request.Context(), noteQuery, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch notes: " + err.Error()})
 		return
 	}
 
+	// Fetch employers, current jobs first
+	var employerQuery string
+	if showInactive {
+		employerQuery = `SELECT e.pdat_employment_id, e.pdat_company_id, c.name AS company_name, e.title, e.department,
+			e.start_date, e.end_date, e.pdat_person_id, e.create_date, e.create_user, e.modify_date, e.modify_user, e.active_flag
+			FROM pdat_employment e
+			JOIN pdat_company c ON e.pdat_company_id = c.pdat_company_id
+			WHERE e.pdat_person_id = $1 ORDER BY e.end_date DESC NULLS FIRST, e.start_date DESC`
+	} else {
+		employerQuery = `SELECT pdat_employment_id, pdat_company_id, company_name, title, department,
+			start_date, end_date, pdat_person_id, create_date, create_user, modify_date, modify_user, active_flag
+			FROM v_person_employment WHERE pdat_person_id = $1 ORDER BY end_date DESC NULLS FIRST, start_date DESC`
+	}
+	employers, err := h.fetchRecords(c.Request.Context(), employerQuery, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch employers: " + err.Error()})
+		return
+	}
+
+	// Fetch relationships from either end, leaving out people the user cannot see
+	otherActive := " AND o.active_flag = 'Y'"
+	if showInactive {
+		otherActive = ""
+	}
+	relationshipQuery := `SELECT r.pdat_relationship_id, r.relationship_type, r.bidirectional,
+		CASE WHEN r.pdat_person_id = $1 THEN '` + models.RelationshipOutgoing + `' ELSE '` + models.RelationshipIncoming + `' END AS direction,
+		o.pdat_person_id AS other_person_id, o.fname AS other_fname, o.lname AS other_lname,
+		r.create_date, r.create_user, r.modify_date, r.modify_user
+		FROM pdat_person_relationship r
+		JOIN pdat_person o ON o.pdat_person_id = CASE WHEN r.pdat_person_id = $1 THEN r.related_person_id ELSE r.pdat_person_id END
+		WHERE (r.pdat_person_id = $1 OR r.related_person_id = $1) AND ` + database.VisibleCondition("o.", 2, 3) + otherActive + `
+		ORDER BY r.relationship_type, o.lname, o.fname`
+	relationships, err := h.fetchRecords(c.Request.Context(), relationshipQuery, id, scope.OrgID, scope.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch relationships: " + err.Error()})
+		return
+	}
+
+	// Fetch tags from the person's organization
+	tagQuery := `SELECT t.pdat_tag_id, t.name, t.color, pt.create_date, pt.create_user
+		FROM pdat_person_tag pt
+		JOIN pdat_tag t ON t.pdat_tag_id = pt.pdat_tag_id
+		WHERE pt.pdat_person_id = $1 AND t.sec_orgs_id = $2
+		ORDER BY LOWER(t.name)`
+	tags, err := h.fetchRecords(c.Request.Context(), tagQuery, id, secOrgsID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tags: " + err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"person":    person,
-		"emails":    emails,
-		"phones":    phones,
+		"person":        person,
+		"emails":        emails,
+		"phones":        phones,
+		"addresses":     addresses,
+		"links":         links,
+		"notes":         notes,
+		"employers":     employers,
+		"relationships": relationships,
+		"tags":          tags,
+	})
+}
+
+// ExportPeopleCSV streams the people list as CSV with each person's tags and a column per
+// custom field. It takes the same filters and sorting as the people list, including ?tag=
+// and ?show_inactive=.
+func (h *TableHandler) ExportPeopleCSV(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	tableName := "v_active_people"
+	if c.Query("show_inactive") == "true" {
+		tableName = "pdat_person"
+	}
+
+	conditions := []string{database.VisibleCondition("", 1, 2)}
+	args := []interface{}{scope.OrgID, scope.UserID}
+	fields, err := h.fieldRepo.List(c.Request.Context(), scope.OrgID)
+	if err != nil {
+		log.Printf("Failed to load custom fields of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+		return
+	}
+	filters, filterArgs, orderBy, err := personFilters(c, tableName+".", scope.OrgID, len(args)+1, fields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	conditions = append(conditions, filters...)
+	args = append(args, filterArgs...)
+	if orderBy == "" {
+		orderBy = "lname, fname"
+	}
+
+	query := `SELECT pdat_person_id, fname, lname, birthday, business_flag, private_flag, active_flag,
+		ARRAY_TO_STRING(ARRAY(
+			SELECT t.name FROM pdat_person_tag pt JOIN pdat_tag t ON t.pdat_tag_id = pt.pdat_tag_id
+			WHERE pt.pdat_person_id = ` + tableName + `.pdat_person_id AND t.sec_orgs_id = $1
+			ORDER BY LOWER(t.name)), '; ') AS tags,
+		` + database.CustomValuesJSON(tableName+".") + ` AS custom_fields
+		FROM ` + tableName + ` WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY ` + orderBy
+
+	rows, err := h.db.QueryContext(c.Request.Context(), query, args...)
+	if err != nil {
+		log.Printf("Failed to export people of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+		return
+	}
+	defer rows.Close()
+
+	// The export is built in memory so a failure part way through is reported as an error
+	// instead of a download that silently ends early
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"id", "first_name", "last_name", "birthday", "business", "private", "active", "tags"}
+	for _, field := range fields {
+		header = append(header, field.Key)
+	}
+	if err := w.Write(header); err != nil {
+		log.Printf("Failed to write export of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+		return
+	}
+	for rows.Next() {
+		var id int
+		var fname, lname sql.NullString
+		var birthday sql.NullTime
+		var businessFlag, privateFlag, activeFlag, tags string
+		var customJSON []byte
+		if err := rows.Scan(&id, &fname, &lname, &birthday, &businessFlag, &privateFlag, &activeFlag, &tags, &customJSON); err != nil {
+			log.Printf("Failed to read person during export of org %d: %v", scope.OrgID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+			return
+		}
+		var birthdayText string
+		if birthday.Valid {
+			birthdayText = birthday.Time.Format("2006-01-02")
+		}
+		record := []string{strconv.Itoa(id), csvCell(fname.String), csvCell(lname.String), birthdayText,
+			businessFlag, privateFlag, activeFlag, csvCell(tags)}
+
+		custom := map[string]interface{}{}
+		if err := json.Unmarshal(customJSON, &custom); err != nil {
+			log.Printf("Failed to read custom fields of person %d during export: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+			return
+		}
+		for _, field := range fields {
+			switch v := custom[field.Key].(type) {
+			case nil:
+				record = append(record, "")
+			case float64:
+				record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				record = append(record, csvCell(fmt.Sprint(v)))
+			}
+		}
+		if err := w.Write(record); err != nil {
+			log.Printf("Failed to write export of org %d: %v", scope.OrgID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to export people of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+		return
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("Failed to write export of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+		return
+	}
+
+	filename := fmt.Sprintf("people_%s.csv", time.Now().Format("20060102_150405"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// csvCell keeps a spreadsheet from running a cell as a formula: text starting with =, +,
+// -, @, a tab or a carriage return is prefixed with a single quote, which spreadsheets
+// show as plain text
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// GetCompanyFull returns a company in the active organization with its addresses, phones
+// and the people working there. People private to other members are left out.
+func (h *TableHandler) GetCompanyFull(c *gin.Context) {
+	id := c.Param("id")
+
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	company := make(map[string]interface{})
+	var companyID, orgID int
+	var name, activeFlag string
+	var domain, industry, createUser, modifyUser sql.NullString
+	var createDate, modifyDate sql.NullTime
+
+	companyQuery := `SELECT pdat_company_id, name, domain, industry, sec_orgs_id,
+		create_date, create_user, modify_date, modify_user, active_flag
+		FROM v_active_companies WHERE pdat_company_id = $1 AND ` + database.CompanyCondition("v_active_companies.", 2)
+	err := h.db.QueryRowContext(c.Request.Context(), companyQuery, id, scope.OrgID).Scan(&companyID, &name, &domain, &industry,
+		&orgID, &createDate, &createUser, &modifyDate, &modifyUser, &activeFlag)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "company not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	company["pdat_company_id"] = companyID
+	company["name"] = name
+	company["domain"] = domain.String
+	company["industry"] = industry.String
+	company["sec_orgs_id"] = orgID
+	if createDate.Valid {
+		company["create_date"] = createDate.Time
+	}
+	company["create_user"] = createUser.String
+	if modifyDate.Valid {
+		company["modify_date"] = modifyDate.Time
+	}
+	company["modify_user"] = modifyUser.String
+	company["active_flag"] = activeFlag
+
+	addresses, err := h.fetchRecords(c.Request.Context(), `SELECT pdat_company_address_id, addr1, addr2, city, cmn_states_id,
+		state, state_name, zip, zip_plus_4, country, pdat_company_id,
+		create_date, create_user, modify_date, modify_user, active_flag
+		FROM v_company_addresses WHERE pdat_company_id = $1 ORDER BY addr1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch addresses: " + err.Error()})
+		return
+	}
+
+	phones, err := h.fetchRecords(c.Request.Context(), `SELECT pdat_company_phone_id, phone_num, phone_ext, country_code,
+		pdat_phone_type_id, phone_type_name, pdat_company_id,
+		create_date, create_user, modify_date, modify_user, active_flag
+		FROM v_company_phones WHERE pdat_company_id = $1 ORDER BY phone_num`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch phones: " + err.Error()})
+		return
+	}
+
+	people, err := h.fetchRecords(c.Request.Context(), `SELECT pdat_employment_id, pdat_person_id, person_fname, person_lname,
+		person_full_name, title, department, start_date, end_date
+		FROM v_person_employment WHERE pdat_company_id = $1 AND `+database.VisibleCondition("", 2, 3)+`
+		ORDER BY end_date DESC NULLS FIRST, person_lname, person_fname`, id, scope.OrgID, scope.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch people: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"company":   company,
 		"addresses": addresses,
-		"links":     links,
-		"notes":     notes,
+		"phones":    phones,
+		"people":    people,
 	})
 }
 
@@ -657,13 +1240,14 @@ func (h *TableHandler) CreateRecord(tableKey string) gin.HandlerFunc {
 			return
 		}
 
-		// Get user info from JWT context
-		userID, ok := middleware.GetUserID(c)
+		// Get user and organization from context
+		scope, ok := middleware.GetTenantScope(c)
 		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 			return
 		}
-		username, _ := middleware.GetUsername(c)
+		userID := scope.UserID
+		username, _ := middleware.GetModifyUser(c)
 
 		// Parse request body
 		var data map[string]interface{}
@@ -672,16 +1256,28 @@ func (h *TableHandler) CreateRecord(tableKey string) gin.HandlerFunc {
 			return
 		}
 
-		// Verify person ownership for related tables
+		// Verify the person is visible in the active organization, or shared with edit permission, for related tables
 		if personID, exists := data["pdat_person_id"]; exists {
-			var ownerID int
-			err := h.db.QueryRow("SELECT sec_users_id FROM pdat_person WHERE pdat_person_id = $1", personID).Scan(&ownerID)
+			visible, err := h.personAccessible(c.Request.Context(), personID, scope, true)
 			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !visible {
 				c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
 				return
 			}
-			if ownerID != userID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		}
+
+		// Verify the company is in the active organization for company details and employment
+		if companyID, exists := data["pdat_company_id"]; exists {
+			visible, err := h.companyVisible(c.Request.Context(), companyID, scope)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !visible {
+				c.JSON(http.StatusNotFound, gin.H{"error": "company not found"})
 				return
 			}
 		}
@@ -695,6 +1291,12 @@ func (h *TableHandler) CreateRecord(tableKey string) gin.HandlerFunc {
 			data["sec_users_id"] = userID
 		}
 
+		// Companies are created in the active organization
+		if tableKey == "companies" {
+			allColumns = append(allColumns, "sec_orgs_id")
+			data["sec_orgs_id"] = scope.OrgID
+		}
+
 		var placeholders []string
 		var values []interface{}
 		for i, col := range allColumns {
@@ -720,7 +1322,7 @@ func (h *TableHandler) CreateRecord(tableKey string) gin.HandlerFunc {
 		)
 
 		var newID int
-		err := h.db.QueryRow(query, values...).Scan(&newID)
+		err := h.db.QueryRowContext(c.Request.Context(), query, values...).Scan(&newID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -753,13 +1355,13 @@ func (h *TableHandler) UpdateRecord(tableKey string) gin.HandlerFunc {
 
 		id := c.Param("id")
 
-		// Get user info from JWT context
-		userID, ok := middleware.GetUserID(c)
+		// Get user and organization from context
+		scope, ok := middleware.GetTenantScope(c)
 		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 			return
 		}
-		username, _ := middleware.GetUsername(c)
+		username, _ := middleware.GetModifyUser(c)
 
 		// Parse request body
 		var data map[string]interface{}
@@ -768,28 +1370,66 @@ func (h *TableHandler) UpdateRecord(tableKey string) gin.HandlerFunc {
 			return
 		}
 
-		// Verify ownership by checking the person this record belongs to
-		var ownerQuery string
-		if tableKey == "links" {
-			ownerQuery = fmt.Sprintf("SELECT sec_users_id FROM %s WHERE %s = $1", baseTable, config.IDColumn)
+		// Verify the person this record belongs to is visible in the active organization, or
+		// shared with edit permission. Links need not belong to a person; those are checked
+		// against their own owner. Company records are checked against the active organization.
+		var visibleQuery string
+		visibleArgs := []interface{}{id, scope.OrgID, scope.UserID}
+		if config.CompanyScoped {
+			visibleQuery = fmt.Sprintf(`
+				SELECT EXISTS (SELECT 1 FROM %s t WHERE t.%s = $1 AND %s)`,
+				baseTable, config.IDColumn, database.CompanyCondition("t.", 2))
+			visibleArgs = visibleArgs[:2]
+		} else if tableKey == "links" {
+			visibleQuery = fmt.Sprintf(`
+				SELECT EXISTS (SELECT 1 FROM %s t
+				LEFT JOIN pdat_person p ON t.pdat_person_id = p.pdat_person_id
+				WHERE t.%s = $1 AND CASE WHEN p.pdat_person_id IS NULL THEN t.sec_users_id = $3 ELSE %s END)`,
+				baseTable, config.IDColumn, database.AccessibleCondition("p.", 2, 3, true))
 		} else {
-			ownerQuery = fmt.Sprintf(`
-				SELECT p.sec_users_id FROM %s t
+			visibleQuery = fmt.Sprintf(`
+				SELECT EXISTS (SELECT 1 FROM %s t
 				JOIN pdat_person p ON t.pdat_person_id = p.pdat_person_id
-				WHERE t.%s = $1`, baseTable, config.IDColumn)
+				WHERE t.%s = $1 AND %s)`, baseTable, config.IDColumn, database.AccessibleCondition("p.", 2, 3, true))
 		}
 
-		var ownerID int
-		err := h.db.QueryRow(ownerQuery, id).Scan(&ownerID)
+		var visible bool
+		err := h.db.QueryRowContext(c.Request.Context(), visibleQuery, visibleArgs...).Scan(&visible)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		if ownerID != userID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		if !visible {
+			c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
 			return
 		}
 
+		// A record can only be moved to another person the user can edit
+		if personID, exists := data["pdat_person_id"]; exists {
+			visible, err := h.personAccessible(c.Request.Context(), personID, scope, true)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !visible {
+				c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+				return
+			}
+		}
+
+		// ...and only to a company in the active organization
+		if companyID, exists := data["pdat_company_id"]; exists {
+			visible, err := h.companyVisible(c.Request.Context(), companyID, scope)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !visible {
+				c.JSON(http.StatusNotFound, gin.H{"error": "company not found"})
+				return
+			}
+		}
+
 		// Build UPDATE query - only update columns that are provided
 		now := time.Now()
 		var setClauses []string
@@ -824,7 +1464,7 @@ func (h *TableHandler) UpdateRecord(tableKey string) gin.HandlerFunc {
 			argNum,
 		)
 
-		result, err := h.db.Exec(query, values...)
+		result, err := h.db.ExecContext(c.Request.Context(), query, values...)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -0,0 +1,28 @@
+package handlers
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: ""},
+		{value: "Ada", want: "Ada"},
+		{value: "=HYPERLINK(\"http://evil.example\")", want: "'=HYPERLINK(\"http://evil.example\")"},
+		{value: "+1 555 0100", want: "'+1 555 0100"},
+		{value: "-2+3", want: "'-2+3"},
+		{value: "@SUM(A1:A2)", want: "'@SUM(A1:A2)"},
+		{value: "\t=1+2", want: "'\t=1+2"},
+		{value: "\r=1+2", want: "'\r=1+2"},
+		{value: "a=b", want: "a=b"},
+		{value: "a\tb", want: "a\tb"},
+		{value: "'quoted", want: "'quoted"},
+	}
+
+	for _, tt := range tests {
+		if got := csvCell(tt.value); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
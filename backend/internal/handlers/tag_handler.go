@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// TagHandler manages the active organization's tags and who carries them
+type TagHandler struct {
+	tagRepo *database.TagRepository
+}
+
+// NewTagHandler creates a new tag handler
+func NewTagHandler(tagRepo *database.TagRepository) *TagHandler {
+	return &TagHandler{tagRepo: tagRepo}
+}
+
+// ListTags returns the active organization's tags
+func (h *TagHandler) ListTags(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	tags, err := h.tagRepo.List(c.Request.Context(), scope)
+	if err != nil {
+		log.Printf("Failed to list tags of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// GetTag returns a tag in the active organization
+func (h *TagHandler) GetTag(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	tag, err := h.tagRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil {
+		log.Printf("Failed to load tag %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if tag == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// CreateTag adds a tag to the active organization
+func (h *TagHandler) CreateTag(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Color == "" {
+		req.Color = models.DefaultTagColor
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	tag := &models.Tag{
+		Name:       req.Name,
+		Color:      req.Color,
+		CreateUser: &actor,
+		ModifyUser: &actor,
+	}
+
+	err := h.tagRepo.Create(c.Request.Context(), scope, tag)
+	if errors.Is(err, database.ErrDuplicateTag) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create tag %q in org %d: %v", req.Name, scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag renames or recolours a tag
+func (h *TagHandler) UpdateTag(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Color == "" {
+		req.Color = models.DefaultTagColor
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	updated, err := h.tagRepo.Update(c.Request.Context(), scope, id, req.Name, req.Color, actor)
+	if errors.Is(err, database.ErrDuplicateTag) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update tag %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	tag, err := h.tagRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil || tag == nil {
+		log.Printf("Failed to reload tag %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag deletes a tag and removes it from everyone carrying it
+func (h *TagHandler) DeleteTag(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	deleted, err := h.tagRepo.Delete(c.Request.Context(), scope, id)
+	if err != nil {
+		log.Printf("Failed to delete tag %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted"})
+}
+
+// AssignTags gives several tags to several people at once
+func (h *TagHandler) AssignTags(c *gin.Context) {
+	h.changeAssignments(c, true)
+}
+
+// UnassignTags removes several tags from several people at once
+func (h *TagHandler) UnassignTags(c *gin.Context) {
+	h.changeAssignments(c, false)
+}
+
+// changeAssignments tags or untags the people in the request, reporting the people and
+// tags that were skipped because the user cannot see them in the active organization
+func (h *TagHandler) changeAssignments(c *gin.Context, assign bool) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req models.TagAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result *models.TagAssignmentResult
+	var err error
+	if assign {
+		actor, _ := middleware.GetModifyUser(c)
+		result, err = h.tagRepo.Assign(c.Request.Context(), scope, req.PersonIDs, req.TagIDs, actor)
+	} else {
+		result, err = h.tagRepo.Unassign(c.Request.Context(), scope, req.PersonIDs, req.TagIDs)
+	}
+	if err != nil {
+		log.Printf("Failed to change tags %v on people %v: %v", req.TagIDs, req.PersonIDs, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change tags"})
+		return
+	}
+
+	result.SkippedPersonIDs = missingIDs(req.PersonIDs, result.PersonIDs)
+	result.SkippedTagIDs = missingIDs(req.TagIDs, result.TagIDs)
+	c.JSON(http.StatusOK, result)
+}
+
+// missingIDs returns the IDs in requested that are not in found, each once
+func missingIDs(requested, found []int) []int {
+	seen := make(map[int]bool, len(requested))
+	for _, id := range found {
+		seen[id] = true
+	}
+	missing := []int{}
+	for _, id := range requested {
+		if !seen[id] {
+			missing = append(missing, id)
+			seen[id] = true
+		}
+	}
+	return missing
+}
@@ -2,24 +2,64 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 // UserHandler handles user-related endpoints
 type UserHandler struct {
-	userRepo *database.UserRepository
+	userRepo   *database.UserRepository
+	roleRepo   *database.RoleRepository
+	auditRepo  *database.AuditRepository
+	privileges *services.PrivilegeService
+	throttle   *services.LoginThrottleService
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(userRepo *database.UserRepository) *UserHandler {
+func NewUserHandler(userRepo *database.UserRepository, roleRepo *database.RoleRepository, auditRepo *database.AuditRepository, privileges *services.PrivilegeService, throttle *services.LoginThrottleService) *UserHandler {
 	return &UserHandler{
-		userRepo: userRepo,
+		userRepo:   userRepo,
+		roleRepo:   roleRepo,
+		auditRepo:  auditRepo,
+		privileges: privileges,
+		throttle:   throttle,
 	}
 }
 
+// UpdateUserRequest represents a request to update a user's name
+type UpdateUserRequest struct {
+	FirstName string `json:"firstName" binding:"required,max=50"`
+	LastName  string `json:"lastName" binding:"required,max=50"`
+}
+
+// parseUserID parses the :id parameter, writing an error response if it is invalid
+func parseUserID(c *gin.Context) (int, bool) {
+	var userID int
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// canAccessUser reports whether the authenticated user may view or edit the given user:
+// themselves, or anyone if they can manage users
+func canAccessUser(c *gin.Context, userID int) bool {
+	currentUserID, ok := middleware.GetUserID(c)
+	if ok && currentUserID == userID {
+		return true
+	}
+	return middleware.HasPrivilege(c, models.PrivUsersManage)
+}
+
 // ListUsers returns all users
 func (h *UserHandler) ListUsers(c *gin.Context) {
 	limit := 50
@@ -37,14 +77,14 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	})
 }
 
-// GetUser returns a single user by ID
+// GetUser returns a single user by ID. Users can get themselves; admins can get anyone.
 func (h *UserHandler) GetUser(c *gin.Context) {
-	id := c.Param("id")
-
-	// Parse ID
-	var userID int
-	if _, err := fmt.Sscanf(id, "%d", &userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	if !canAccessUser(c, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 		return
 	}
 
@@ -75,14 +115,199 @@ func (h *UserHandler) SearchUsers(c *gin.Context) {
 	})
 }
 
-// UpdateUser updates a user
+// UpdateUser updates a user's name. Users can edit themselves; admins can edit anyone.
 func (h *UserHandler) UpdateUser(c *gin.Context) {
-	// TODO: Implement update logic
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	if !canAccessUser(c, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	user.FirstName = req.FirstName
+	user.LastName = req.LastName
+	if err := h.userRepo.Update(ctx, user, actor); err != nil {
+		log.Printf("Failed to update user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
 }
 
-// DeleteUser deletes a user
+// DeleteUser deletes a user, their accounts and their data. Requires the users.manage privilege.
+// By default the user's personal organization, links and calendar are deleted; pass
+// ?reassignTo=<userId> to give them to another user instead. People the user created in
+// shared organizations are handed to another member. Vault entries are always deleted.
 func (h *UserHandler) DeleteUser(c *gin.Context) {
-	// TODO: Implement delete logic
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	currentUserID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	if currentUserID == userID {
+		c.JSON(http.StatusConflict, gin.H{"error": "You cannot delete yourself"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var reassignTo *int
+	if value := c.Query("reassignTo"); value != "" {
+		targetID, err := strconv.Atoi(value)
+		if err != nil || targetID == userID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reassignTo user ID"})
+			return
+		}
+		target, err := h.userRepo.FindByID(ctx, targetID)
+		if err != nil {
+			log.Printf("Failed to load user %d: %v", targetID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		if target == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reassignTo user not found"})
+			return
+		}
+		reassignTo = &targetID
+	}
+
+	// Never delete the last admin
+	isAdmin, err := h.roleRepo.UserHasRole(ctx, userID, models.RoleAdmin)
+	if err != nil {
+		log.Printf("Failed to check roles of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if isAdmin {
+		otherAdmins, err := h.roleRepo.CountRoleMembersExcludingUser(ctx, models.RoleAdmin, userID)
+		if err != nil {
+			log.Printf("Failed to count admins: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		if otherAdmins == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete the last admin"})
+			return
+		}
+	}
+
+	accountIDs, err := h.userRepo.ListAccountIDs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to list accounts of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	// Email/phone types still used by other users' contacts go to the admin doing the delete
+	if err := h.userRepo.Delete(ctx, userID, reassignTo, currentUserID); err != nil {
+		log.Printf("Failed to delete user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+
+	// Outstanding access tokens of the deleted accounts lose every privilege
+	for _, accountID := range accountIDs {
+		h.privileges.Invalidate(accountID)
+	}
+
+	detail := fmt.Sprintf("%s %s (user %d)", user.FirstName, user.LastName, userID)
+	if reassignTo != nil {
+		detail += fmt.Sprintf(", data reassigned to user %d", *reassignTo)
+	}
+	recordAudit(c, h.auditRepo, currentUserID, models.AuditUserDeleted, detail)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// GetAccountLockout returns an account's recent failed logins and any lockout in force
+func (h *UserHandler) GetAccountLockout(c *gin.Context) {
+	account, ok := loadAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	status, err := h.throttle.Status(c.Request.Context(), account.ID)
+	if err != nil {
+		log.Printf("Failed to load login failures for account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	response := gin.H{
+		"accountId": account.ID,
+		"failures":  0,
+		"locked":    false,
+	}
+	if status != nil {
+		response["failures"] = status.Failures
+		response["lastFailureAt"] = status.LastFailureAt
+		if status.BlockedUntil != nil && status.BlockedUntil.After(time.Now()) {
+			response["locked"] = status.Locked
+			response["blockedUntil"] = status.BlockedUntil
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UnlockAccount clears an account's lockout and failed login count. Requires the users.manage privilege.
+func (h *UserHandler) UnlockAccount(c *gin.Context) {
+	account, ok := loadAccount(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	cleared, err := h.throttle.Unlock(c.Request.Context(), account.ID)
+	if err != nil {
+		log.Printf("Failed to unlock account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+
+	if cleared {
+		if currentUserID, ok := middleware.GetUserID(c); ok {
+			recordAudit(c, h.auditRepo, currentUserID, models.AuditAccountUnlocked, account.Name)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
 }
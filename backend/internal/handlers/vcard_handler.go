@@ -0,0 +1,669 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/middleware"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+	"github.com/davealexenglish/magnifimind-crm/pkg/vcard"
+)
+
+// errDryRun rolls back a dry-run import once every card has been tried
+var errDryRun = errors.New("dry run")
+
+// ignoredVCardTypes are TYPE values that say nothing about which email or phone type to use
+var ignoredVCardTypes = map[string]bool{
+	"pref": true, "internet": true, "x400": true, "voice": true, "msg": true, "text": true,
+}
+
+// VCardHandler exports people as vCards and imports vCards as people
+type VCardHandler struct {
+	db          *database.DB
+	personRepo  *database.PersonRepository
+	contactRepo *database.ContactRepository
+	fieldRepo   *database.CustomFieldRepository
+}
+
+// NewVCardHandler creates a new vCard handler
+func NewVCardHandler(db *database.DB, personRepo *database.PersonRepository, contactRepo *database.ContactRepository, fieldRepo *database.CustomFieldRepository) *VCardHandler {
+	return &VCardHandler{
+		db:          db,
+		personRepo:  personRepo,
+		contactRepo: contactRepo,
+		fieldRepo:   fieldRepo,
+	}
+}
+
+// ExportPersonVCard downloads a person visible to the user as a vCard. ?version= picks
+// 3.0 (the default) or 4.0.
+func (h *VCardHandler) ExportPersonVCard(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := utils.ParseInt(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid person ID"})
+		return
+	}
+	version, ok := vcardVersion(c)
+	if !ok {
+		return
+	}
+
+	person, err := h.personRepo.FindByID(c.Request.Context(), scope, id)
+	if err != nil {
+		log.Printf("Failed to load person %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if person == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+		return
+	}
+
+	contacts, err := h.contactRepo.Load(c.Request.Context(), scope.OrgID, []int{id})
+	if err != nil || len(contacts) == 0 {
+		log.Printf("Failed to load contact details of person %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export person"})
+		return
+	}
+
+	writeVCards(c, fmt.Sprintf("person_%d.vcf", id), version, contacts)
+}
+
+// ExportPeopleVCF downloads the people list as one vCard file. It takes the same
+// filters as ListRecords("people") and ?version= like ExportPersonVCard.
+func (h *VCardHandler) ExportPeopleVCF(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	version, ok := vcardVersion(c)
+	if !ok {
+		return
+	}
+
+	tableName := "v_active_people"
+	if c.Query("show_inactive") == "true" {
+		tableName = "pdat_person"
+	}
+
+	conditions := []string{database.VisibleCondition("", 1, 2)}
+	args := []interface{}{scope.OrgID, scope.UserID}
+	fields, err := h.fieldRepo.List(c.Request.Context(), scope.OrgID)
+	if err != nil {
+		log.Printf("Failed to load custom fields of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+		return
+	}
+	filters, filterArgs, orderBy, err := personFilters(c, tableName+".", scope.OrgID, len(args)+1, fields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	conditions = append(conditions, filters...)
+	args = append(args, filterArgs...)
+	if orderBy == "" {
+		orderBy = "lname, fname"
+	}
+
+	query := `SELECT pdat_person_id FROM ` + tableName + ` WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY ` + orderBy
+	rows, err := h.db.QueryContext(c.Request.Context(), query, args...)
+	if err != nil {
+		log.Printf("Failed to export people of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+		return
+	}
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			log.Printf("Failed to export people of org %d: %v", scope.OrgID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to export people of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+		return
+	}
+
+	contacts, err := h.contactRepo.Load(c.Request.Context(), scope.OrgID, ids)
+	if err != nil {
+		log.Printf("Failed to load contact details for export of org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export people"})
+		return
+	}
+
+	writeVCards(c, fmt.Sprintf("people_%s.vcf", time.Now().Format("20060102_150405")), version, contacts)
+}
+
+// ImportVCards creates a person in the active organization for each card in a vCard
+// file, sent as the request body or as the "file" field of a multipart form. Each card
+// succeeds or fails on its own and the response reports errors per card. With
+// ?dry_run=true every card is checked and saved, then everything is rolled back.
+func (h *VCardHandler) ImportVCards(c *gin.Context) {
+	scope, ok := middleware.GetTenantScope(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, models.MaxVCardImportBytes)
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No vCard file provided: " + err.Error()})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	cards, err := vcard.Parse(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("vCard files are limited to %d MB", models.MaxVCardImportBytes>>20)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read vCard file: " + err.Error()})
+		return
+	}
+	if len(cards) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No vCards found"})
+		return
+	}
+	if len(cards) > models.MaxVCardImportCards {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d vCards can be imported at once", models.MaxVCardImportCards)})
+		return
+	}
+
+	states, err := h.contactRepo.StateIDs(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to load states: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	actor, _ := middleware.GetModifyUser(c)
+	resp := &models.VCardImportResponse{DryRun: dryRun, Total: len(cards), Results: []*models.VCardImportResult{}}
+	err = h.db.InTx(c.Request.Context(), func(ctx context.Context) error {
+		for i, card := range cards {
+			result := &models.VCardImportResult{Card: i + 1, Line: card.Line, Status: models.VCardFailed}
+			resp.Results = append(resp.Results, result)
+
+			if card.Err != nil {
+				result.Errors = []string{card.Err.Error()}
+				resp.Failed++
+				continue
+			}
+			contact, problems, warnings := cardContact(card, states)
+			result.Name = contactName(contact)
+			result.Warnings = warnings
+			if len(problems) > 0 {
+				result.Errors = problems
+				resp.Failed++
+				continue
+			}
+
+			personID, err := h.contactRepo.Create(ctx, scope, contact, actor)
+			if err != nil {
+				log.Printf("Failed to import vCard %d into org %d: %v", i+1, scope.OrgID, err)
+				result.Errors = []string{"card could not be saved"}
+				resp.Failed++
+				continue
+			}
+			resp.Created++
+			if dryRun {
+				result.Status = models.VCardValid
+			} else {
+				result.Status = models.VCardCreated
+				result.PersonID = &personID
+			}
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		log.Printf("Failed to import vCards into org %d: %v", scope.OrgID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import vCards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// vcardVersion reads ?version=, answering 400 and returning false if it is not supported
+func vcardVersion(c *gin.Context) (string, bool) {
+	version := c.DefaultQuery("version", vcard.Version3)
+	if version != vcard.Version3 && version != vcard.Version4 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be 3.0 or 4.0"})
+		return "", false
+	}
+	return version, true
+}
+
+// writeVCards sends contacts as a vCard file download
+func writeVCards(c *gin.Context, filename, version string, contacts []*models.Contact) {
+	cards := make([]*vcard.Card, len(contacts))
+	for i, contact := range contacts {
+		cards[i] = contactCard(contact, version)
+	}
+
+	c.Header("Content-Type", "text/vcard; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+	if err := vcard.Encode(c.Writer, cards...); err != nil {
+		log.Printf("Failed to write vCards: %v", err)
+	}
+}
+
+// contactCard converts a contact to a vCard of a version
+func contactCard(contact *models.Contact, version string) *vcard.Card {
+	card := &vcard.Card{}
+	card.Add("VERSION", version)
+	card.Add("PRODID", "-//Magnifimind CRM//EN")
+	if contact.BusinessFlag == "Y" {
+		if version == vcard.Version4 {
+			card.Add("KIND", "org")
+		} else {
+			card.Add("X-ABSHOWAS", "COMPANY")
+		}
+	}
+
+	var fname, lname string
+	if contact.FirstName != nil {
+		fname = *contact.FirstName
+	}
+	if contact.LastName != nil {
+		lname = *contact.LastName
+	}
+	card.Add("N", vcard.Structured(lname, fname, "", "", ""))
+	card.Add("FN", vcard.EscapeText(contactName(contact)))
+
+	if contact.Birthday != nil {
+		layout := "2006-01-02"
+		if version == vcard.Version4 {
+			layout = "20060102"
+		}
+		card.Add("BDAY", contact.Birthday.Format(layout))
+	}
+
+	for _, email := range contact.Emails {
+		card.Add("EMAIL", vcard.EscapeText(email.Address), vcardTypeParam(email.Type)...)
+	}
+	for _, phone := range contact.Phones {
+		number := phone.Number
+		if phone.CountryCode != "" {
+			number = "+" + strings.TrimPrefix(phone.CountryCode, "+") + " " + number
+		}
+		if phone.Ext != "" {
+			number += " x" + phone.Ext
+		}
+		card.Add("TEL", vcard.EscapeText(number), vcardTypeParam(phone.Type)...)
+	}
+	for _, addr := range contact.Addresses {
+		zip := addr.Zip
+		if addr.ZipPlus4 != "" {
+			zip += "-" + addr.ZipPlus4
+		}
+		card.Add("ADR", vcard.Structured("", addr.Addr2, addr.Addr1, addr.City, addr.State, zip, addr.Country))
+	}
+	for _, note := range contact.Notes {
+		card.Add("NOTE", vcard.EscapeText(note))
+	}
+	for _, link := range contact.Links {
+		// URL is a URI value, which vCard does not escape
+		card.Add("URL", strings.Join(strings.Fields(link.URL), ""))
+	}
+	if len(contact.Tags) > 0 {
+		tags := make([]string, len(contact.Tags))
+		for i, tag := range contact.Tags {
+			tags[i] = vcard.EscapeText(tag)
+		}
+		card.Add("CATEGORIES", strings.Join(tags, ","))
+	}
+
+	layout := "2006-01-02T15:04:05Z"
+	if version == vcard.Version4 {
+		layout = "20060102T150405Z"
+	}
+	card.Add("REV", contact.ModifyDate.UTC().Format(layout))
+	return card
+}
+
+// vcardTypeParam turns an email or phone type name into a TYPE parameter, e.g. "Work"
+// into TYPE=work. Names with nothing usable give no parameter.
+func vcardTypeParam(name string) []vcard.Param {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-') {
+			sb.WriteRune(r)
+		}
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+	return []vcard.Param{{Name: "TYPE", Values: []string{sb.String()}}}
+}
+
+// contactName is how a contact is named in a card's FN and in import results
+func contactName(contact *models.Contact) string {
+	var parts []string
+	for _, s := range []*string{contact.FirstName, contact.LastName} {
+		if s != nil && strings.TrimSpace(*s) != "" {
+			parts = append(parts, strings.TrimSpace(*s))
+		}
+	}
+	if len(parts) == 0 && len(contact.Emails) > 0 {
+		return contact.Emails[0].Address
+	}
+	if len(parts) == 0 {
+		return "Unnamed"
+	}
+	return strings.Join(parts, " ")
+}
+
+// cardContact converts an imported vCard to a contact. problems are reasons the card
+// cannot be imported; warnings are values left out of an otherwise good card. states
+// maps lowercase state names and abbreviations to cmn_states IDs.
+func cardContact(card *vcard.Card, states map[string]int) (*models.Contact, []string, []string) {
+	contact := &models.Contact{BusinessFlag: "N"}
+	var problems, warnings []string
+
+	if p := card.Get("KIND"); p != nil && strings.EqualFold(strings.TrimSpace(p.Text()), "org") {
+		contact.BusinessFlag = "Y"
+	}
+	if p := card.Get("X-ABSHOWAS"); p != nil && strings.EqualFold(strings.TrimSpace(p.Text()), "COMPANY") {
+		contact.BusinessFlag = "Y"
+	}
+
+	var fname, lname string
+	if p := card.Get("N"); p != nil {
+		parts := p.Components()
+		lname = strings.TrimSpace(parts[0])
+		if len(parts) > 1 {
+			fname = strings.TrimSpace(parts[1])
+		}
+	}
+	if fname == "" && lname == "" {
+		if p := card.Get("FN"); p != nil {
+			full := strings.TrimSpace(p.Text())
+			if i := strings.LastIndex(full, " "); i > 0 && contact.BusinessFlag != "Y" {
+				fname, lname = strings.TrimSpace(full[:i]), full[i+1:]
+			} else if contact.BusinessFlag == "Y" {
+				lname = full
+			} else {
+				fname = full
+			}
+		}
+	}
+	if fname == "" && lname == "" {
+		problems = append(problems, "card has no name (N or FN)")
+	}
+	if utf8.RuneCountInString(fname) > 120 {
+		problems = append(problems, "first name is longer than 120 characters")
+	}
+	if utf8.RuneCountInString(lname) > 120 {
+		problems = append(problems, "last name is longer than 120 characters")
+	}
+	if fname != "" {
+		contact.FirstName = &fname
+	}
+	if lname != "" {
+		contact.LastName = &lname
+	}
+
+	if p := card.Get("BDAY"); p != nil {
+		value := strings.TrimSpace(p.Text())
+		if birthday, ok := parseVCardDate(value); ok {
+			contact.Birthday = &birthday
+		} else if strings.HasPrefix(value, "--") {
+			warnings = append(warnings, "birthday "+value+" has no year and was left out")
+		} else {
+			problems = append(problems, "birthday "+value+" is not a date")
+		}
+	}
+
+	for _, p := range card.All("EMAIL") {
+		address := strings.TrimSpace(strings.TrimPrefix(p.Text(), "mailto:"))
+		switch {
+		case address == "":
+			continue
+		case !strings.Contains(address, "@"):
+			problems = append(problems, "email "+address+" is not an email address")
+		case len(address) > 50:
+			problems = append(problems, "email "+address+" is longer than 50 characters")
+		default:
+			contact.Emails = append(contact.Emails, models.ContactEmail{Address: address, Type: vcardTypeName(p.Types())})
+		}
+	}
+
+	for _, p := range card.All("TEL") {
+		phone, err := parseVCardPhone(p.Text())
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if phone != nil {
+			phone.Type = vcardTypeName(p.Types())
+			contact.Phones = append(contact.Phones, *phone)
+		}
+	}
+
+	for _, p := range card.All("ADR") {
+		addr, addrProblems, addrWarnings := parseVCardAddress(p.Components(), states)
+		problems = append(problems, addrProblems...)
+		warnings = append(warnings, addrWarnings...)
+		if addr != nil && len(addrProblems) == 0 {
+			contact.Addresses = append(contact.Addresses, *addr)
+		}
+	}
+
+	for _, p := range card.All("NOTE") {
+		note := strings.TrimSpace(p.Text())
+		if note == "" {
+			continue
+		}
+		if len(note) > 8190 {
+			problems = append(problems, "a note is longer than 8190 characters")
+			continue
+		}
+		contact.Notes = append(contact.Notes, note)
+	}
+
+	for _, p := range card.All("URL") {
+		url := strings.TrimSpace(p.Text())
+		if url == "" {
+			continue
+		}
+		if len(url) > 8190 {
+			problems = append(problems, "a URL is longer than 8190 characters")
+			continue
+		}
+		contact.Links = append(contact.Links, models.ContactLink{URL: url})
+	}
+
+	return contact, problems, warnings
+}
+
+// vcardTypeName picks the email or phone type name for a property's TYPE values, e.g.
+// "Work" for TYPE=work,pref. Properties without a usable type get "Other".
+func vcardTypeName(types []string) string {
+	for _, t := range types {
+		if ignoredVCardTypes[t] || strings.HasPrefix(t, "x-") {
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(t)
+		name := string(unicode.ToUpper(r)) + t[size:]
+		if len(name) > 50 {
+			continue
+		}
+		return name
+	}
+	return "Other"
+}
+
+// parseVCardDate parses a vCard 3.0 or 4.0 date, or the date part of a date-time
+func parseVCardDate(value string) (time.Time, bool) {
+	if i := strings.IndexByte(value, 'T'); i > 0 {
+		value = value[:i]
+	}
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseVCardPhone splits a TEL value such as "+1 (555) 123-4567 x12" or
+// "tel:+1-555-123-4567;ext=12" into a number of up to 10 digits, a country code and an
+// extension. Empty values give nil.
+func parseVCardPhone(value string) (*models.ContactPhone, error) {
+	value = strings.TrimSpace(value)
+	original := value
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "tel:"), "TEL:")
+
+	var ext string
+	lower := strings.ToLower(value)
+	for _, marker := range []string{";ext=", " ext.", " ext", "ext.", "ext", " x", "x"} {
+		if i := strings.LastIndex(lower, marker); i > 0 {
+			ext = digitsOf(value[i+len(marker):])
+			value = value[:i]
+			break
+		}
+	}
+	if i := strings.IndexByte(value, ';'); i >= 0 {
+		value = value[:i]
+	}
+
+	digits := digitsOf(value)
+	if digits == "" {
+		if original == "" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("phone %s has no digits", original)
+	}
+
+	phone := &models.ContactPhone{Number: digits, Ext: ext}
+	if len(digits) > 10 {
+		phone.CountryCode, phone.Number = digits[:len(digits)-10], digits[len(digits)-10:]
+	}
+	if len(phone.CountryCode) > 5 {
+		return nil, fmt.Errorf("phone %s is too long", original)
+	}
+	if len(phone.Ext) > 5 {
+		return nil, fmt.Errorf("phone %s has an extension longer than 5 digits", original)
+	}
+	return phone, nil
+}
+
+// digitsOf returns the ASCII digits in s
+func digitsOf(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// parseVCardAddress converts the components of an ADR value (post office box, extended
+// address, street, locality, region, postal code, country) to an address. Empty
+// addresses give nil. Regions that are not a known state and postal codes that are
+// not US ZIP codes are left out with a warning.
+func parseVCardAddress(parts []string, states map[string]int) (*models.ContactAddress, []string, []string) {
+	for len(parts) < 7 {
+		parts = append(parts, "")
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	poBox, extended, street, city, region, postal, country := parts[0], parts[1], parts[2], parts[3], parts[4], parts[5], parts[6]
+	if strings.Join(parts, "") == "" {
+		return nil, nil, nil
+	}
+
+	var problems, warnings []string
+	addr := &models.ContactAddress{City: city, Country: country}
+
+	lines := strings.Split(street, "\n")
+	addr.Addr1 = strings.TrimSpace(lines[0])
+	var rest []string
+	for _, line := range append(lines[1:], extended) {
+		if line = strings.TrimSpace(line); line != "" {
+			rest = append(rest, line)
+		}
+	}
+	if poBox != "" {
+		rest = append(rest, "PO Box "+poBox)
+	}
+	addr.Addr2 = strings.Join(rest, ", ")
+	if addr.Addr1 == "" {
+		addr.Addr1, addr.Addr2 = addr.Addr2, ""
+	}
+
+	limits := []struct {
+		label string
+		value string
+		max   int
+	}{
+		{"street", addr.Addr1, 80}, {"second line", addr.Addr2, 80}, {"city", city, 50}, {"country", country, 50},
+	}
+	for _, limit := range limits {
+		if utf8.RuneCountInString(limit.value) > limit.max {
+			problems = append(problems, fmt.Sprintf("address %s is longer than %d characters", limit.label, limit.max))
+		}
+	}
+
+	if region != "" {
+		if id, found := states[strings.ToLower(region)]; found {
+			addr.StateID = &id
+			addr.State = region
+		} else {
+			warnings = append(warnings, "region "+region+" is not a known state and was left out")
+		}
+	}
+
+	if postal != "" {
+		digits := digitsOf(postal)
+		switch {
+		case len(digits) == 5 && len(postal) == 5:
+			addr.Zip = digits
+		case len(digits) == 9 && strings.TrimLeft(postal, "0123456789- ") == "":
+			addr.Zip, addr.ZipPlus4 = digits[:5], digits[5:]
+		default:
+			warnings = append(warnings, "postal code "+postal+" is not a US ZIP code and was left out")
+		}
+	}
+
+	return addr, problems, warnings
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+func TestParseVCardPhone(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *models.ContactPhone
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "  ", want: nil},
+		{value: "555-0100", want: &models.ContactPhone{Number: "5550100"}},
+		{value: "(555) 123-4567", want: &models.ContactPhone{Number: "5551234567"}},
+		{value: "+1 (555) 123-4567", want: &models.ContactPhone{Number: "5551234567", CountryCode: "1"}},
+		{value: "+44 20 7946 0958", want: &models.ContactPhone{Number: "2079460958", CountryCode: "44"}},
+		{value: "+91 98765 43210", want: &models.ContactPhone{Number: "9876543210", CountryCode: "91"}},
+		{value: "+1 (555) 123-4567 x12", want: &models.ContactPhone{Number: "5551234567", CountryCode: "1", Ext: "12"}},
+		{value: "555 123 4567 ext. 89", want: &models.ContactPhone{Number: "5551234567", Ext: "89"}},
+		{value: "555-123-4567EXT3", want: &models.ContactPhone{Number: "5551234567", Ext: "3"}},
+		{value: "tel:+1-555-123-4567;ext=12", want: &models.ContactPhone{Number: "5551234567", CountryCode: "1", Ext: "12"}},
+		{value: "TEL:+1-555-123-4567", want: &models.ContactPhone{Number: "5551234567", CountryCode: "1"}},
+		{value: "tel:+1-555-123-4567;phone-context=example.com", want: &models.ContactPhone{Number: "5551234567", CountryCode: "1"}},
+		{value: "call me", wantErr: true},
+		{value: "+1234567 555 123 4567", wantErr: true},
+		{value: "555 123 4567 x123456", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseVCardPhone(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseVCardPhone(%q) = %+v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseVCardPhone(%q): %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVCardPhone(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVCardAddress(t *testing.T) {
+	states := map[string]int{"il": 14, "illinois": 14}
+	il := 14
+
+	tests := []struct {
+		name         string
+		parts        []string
+		want         *models.ContactAddress
+		wantProblems int
+		wantWarnings int
+	}{
+		{
+			name:  "empty",
+			parts: []string{"", " ", "", "", "", "", ""},
+			want:  nil,
+		},
+		{
+			name:  "full US address with ZIP+4",
+			parts: []string{"", "Apt 4", "12 Main St", "Springfield", "IL", "62701-1234", "USA"},
+			want: &models.ContactAddress{Addr1: "12 Main St", Addr2: "Apt 4", City: "Springfield", State: "IL", StateID: &il,
+				Zip: "62701", ZipPlus4: "1234", Country: "USA"},
+		},
+		{
+			name:  "ZIP+4 without a hyphen",
+			parts: []string{"", "", "12 Main St", "Springfield", "illinois", "627011234"},
+			want:  &models.ContactAddress{Addr1: "12 Main St", City: "Springfield", State: "illinois", StateID: &il, Zip: "62701", ZipPlus4: "1234"},
+		},
+		{
+			name:  "ZIP+4 with a space",
+			parts: []string{"", "", "12 Main St", "", "", "62701 1234"},
+			want:  &models.ContactAddress{Addr1: "12 Main St", Zip: "62701", ZipPlus4: "1234"},
+		},
+		{
+			name:  "short components",
+			parts: []string{"", "", "12 Main St", "Springfield"},
+			want:  &models.ContactAddress{Addr1: "12 Main St", City: "Springfield"},
+		},
+		{
+			name:  "street lines and post office box",
+			parts: []string{"55", "Building B", "12 Main St\nFloor 2", "Springfield", "", "62701", ""},
+			want:  &models.ContactAddress{Addr1: "12 Main St", Addr2: "Floor 2, Building B, PO Box 55", City: "Springfield", Zip: "62701"},
+		},
+		{
+			name:  "post office box only",
+			parts: []string{"55", "", "", "Springfield", "", "62701", ""},
+			want:  &models.ContactAddress{Addr1: "PO Box 55", City: "Springfield", Zip: "62701"},
+		},
+		{
+			name:         "foreign postal code and region",
+			parts:        []string{"", "", "10 Downing St", "London", "Greater London", "SW1A 2AA", "United Kingdom"},
+			want:         &models.ContactAddress{Addr1: "10 Downing St", City: "London", Country: "United Kingdom"},
+			wantWarnings: 2,
+		},
+		{
+			name:         "postal code with letters and nine digits",
+			parts:        []string{"", "", "12 Main St", "", "", "62701-1234A"},
+			want:         &models.ContactAddress{Addr1: "12 Main St"},
+			wantWarnings: 1,
+		},
+		{
+			name:         "partial ZIP+4",
+			parts:        []string{"", "", "12 Main St", "", "", "62701-12"},
+			want:         &models.ContactAddress{Addr1: "12 Main St"},
+			wantWarnings: 1,
+		},
+		{
+			name:         "too long",
+			parts:        []string{"", "", strings.Repeat("x", 81), strings.Repeat("y", 51)},
+			want:         &models.ContactAddress{Addr1: strings.Repeat("x", 81), City: strings.Repeat("y", 51)},
+			wantProblems: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems, warnings := parseVCardAddress(tt.parts, states)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("address = %+v, want %+v", got, tt.want)
+			}
+			if len(problems) != tt.wantProblems {
+				t.Errorf("problems = %q, want %d", problems, tt.wantProblems)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %q, want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
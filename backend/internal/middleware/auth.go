@@ -1,15 +1,31 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
 )
 
-// AuthMiddleware validates JWT tokens
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// RevocationChecker reports whether an access token has been revoked
+type RevocationChecker interface {
+	IsRevoked(jti, sessionID string, userID int, issuedAt time.Time) bool
+}
+
+// TokenParser verifies JWT access tokens
+type TokenParser interface {
+	ParseAccessToken(token string) (*utils.Claims, error)
+}
+
+// AuthMiddleware validates JWT tokens or personal access tokens, rejects tokens on the
+// revocation list and loads the account's privileges for RequirePrivilege.
+// Requests made with an impersonation token are recorded in the audit log.
+func AuthMiddleware(tokens TokenParser, revocations RevocationChecker, privileges PrivilegeLoader, accessTokens AccessTokenAuthenticator, audit AuditRecorder) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -28,31 +44,120 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 
 		tokenString := parts[1]
 
-		// Parse and validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
+		if strings.HasPrefix(tokenString, models.AccessTokenPrefix) {
+			if !authenticateAccessToken(c, tokenString, accessTokens) {
+				c.Abort()
+				return
 			}
-			return []byte(jwtSecret), nil
-		})
+			if !loadPrivileges(c, privileges) {
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// Parse and validate token
+		claims, err := tokens.ParseAccessToken(tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
 
-		if err != nil || !token.Valid {
+		// Every access token carries a jti so it can be revoked
+		if claims.ID == "" || claims.IssuedAt == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
 		// Extract claims
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("user_id", claims["user_id"])
-			c.Set("account_name", claims["account_name"])
+		c.Set("user_id", claims.UserID)
+		c.Set("account_id", claims.AccountID)
+		c.Set("account_name", claims.AccountName)
+		c.Set("session_id", claims.SessionID)
+		c.Set("token_id", claims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+		if claims.Act != nil {
+			c.Set("impersonator", claims.Act)
+		}
+
+		if revocations != nil {
+			revoked := revocations.IsRevoked(claims.ID, claims.SessionID, claims.UserID, claims.IssuedAt.Time)
+			// Logging the impersonator out everywhere also ends their impersonation tokens
+			if claims.Act != nil {
+				revoked = revoked || revocations.IsRevoked("", "", claims.Act.UserID, claims.IssuedAt.Time)
+			}
+			if revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				c.Abort()
+				return
+			}
+		}
+
+		if !loadPrivileges(c, privileges) {
+			c.Abort()
+			return
 		}
 
 		c.Next()
+
+		if claims.Act != nil {
+			recordImpersonatedRequest(c, audit, claims.Act)
+		}
 	}
 }
 
+// authenticateAccessToken sets the request's identity and scopes from a personal access token,
+// writing an error response if the token is not valid
+func authenticateAccessToken(c *gin.Context, tokenString string, accessTokens AccessTokenAuthenticator) bool {
+	if accessTokens == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return false
+	}
+
+	token, err := accessTokens.Authenticate(c.Request.Context(), tokenString)
+	if err != nil {
+		log.Printf("Failed to authenticate access token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+		return false
+	}
+	if token == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return false
+	}
+
+	scopes := make(map[string]bool, len(token.Scopes))
+	for _, scope := range token.Scopes {
+		scopes[scope] = true
+	}
+
+	c.Set("user_id", token.UserID)
+	c.Set("account_id", token.AccountID)
+	c.Set("account_name", token.AccountName)
+	c.Set("access_token_id", token.ID)
+	c.Set("scopes", scopes)
+	return true
+}
+
+// loadPrivileges stores the account's privileges in the context, writing an error response on failure
+func loadPrivileges(c *gin.Context, privileges PrivilegeLoader) bool {
+	if privileges == nil {
+		return true
+	}
+
+	accountID, _ := GetAccountID(c)
+	set, err := privileges.Privileges(c.Request.Context(), accountID)
+	if err != nil {
+		log.Printf("Failed to load privileges for account %d: %v", accountID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load privileges"})
+		return false
+	}
+	c.Set("privileges", set)
+	return true
+}
+
 // GetUserID retrieves the user ID from the context
 func GetUserID(c *gin.Context) (int, bool) {
 	userID, exists := c.Get("user_id")
@@ -83,3 +188,44 @@ func GetUsername(c *gin.Context) (string, bool) {
 	}
 	return "", false
 }
+
+// GetAccountID retrieves the account ID from the context
+func GetAccountID(c *gin.Context) (int, bool) {
+	accountID, exists := c.Get("account_id")
+	if !exists {
+		return 0, false
+	}
+
+	switch v := accountID.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// GetSessionID retrieves the session (refresh token family) ID from the context
+func GetSessionID(c *gin.Context) (string, bool) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return "", false
+	}
+
+	str, ok := sessionID.(string)
+	return str, ok && str != ""
+}
+
+// GetTokenID retrieves the access token's jti and expiry from the context
+func GetTokenID(c *gin.Context) (string, time.Time, bool) {
+	jti, exists := c.Get("token_id")
+	if !exists {
+		return "", time.Time{}, false
+	}
+	expiresAt, _ := c.Get("token_expires_at")
+
+	str, ok := jti.(string)
+	exp, _ := expiresAt.(time.Time)
+	return str, exp, ok
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+func TestGetModifyUser(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor string // impersonating account, if any
+		want  string
+	}{
+		{name: "ada", want: "ada"},
+		{name: strings.Repeat("a", 40), want: strings.Repeat("a", 30)},
+		{name: strings.Repeat("é", 40), want: strings.Repeat("é", 30)},
+		{name: "ada", actor: "admin", want: "admin as ada"},
+		{name: "jane.doe@example.com", actor: "administrator@example.com", want: "administrator as jane.doe@exam"},
+		{name: "ada", actor: "administrator.of.everything@example.com", want: "administrator.of.everyt as ada"},
+		{name: "customer.service@example.com", actor: "ops", want: "ops as customer.service@exampl"},
+		{name: "ünïcødé.üsér.wîth.lóng.nàme", actor: "ädmïnïstrâtör.wîth.lóng.nàme", want: "ädmïnïstrâtör as ünïcødé.üsér."},
+		{name: "日本語のアカウント名前です日本語のアカウント", actor: "管理者管理者管理者管理者管理者管理者", want: "管理者管理者管理者管理者管 as 日本語のアカウント名前です"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Set("account_name", tt.name)
+			if tt.actor != "" {
+				c.Set("impersonator", &utils.Actor{AccountName: tt.actor})
+			}
+
+			got, ok := GetModifyUser(c)
+			if !ok {
+				t.Fatal("GetModifyUser found no account name")
+			}
+			if got != tt.want {
+				t.Errorf("GetModifyUser() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) || utf8.RuneCountInString(got) > modifyUserLen {
+				t.Errorf("GetModifyUser() = %q does not fit a varchar(%d) column", got, modifyUserLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// OrgHeader selects the organization a request works in. Without it the
+// user's personal organization is used.
+const OrgHeader = "X-Organization-ID"
+
+// OrgResolver looks up organization membership
+type OrgResolver interface {
+	MemberRole(ctx context.Context, orgID, userID int) (string, error)
+	EnsurePersonalOrg(ctx context.Context, userID int) (int, error)
+}
+
+// OrgContext resolves the active organization from the X-Organization-ID header and
+// checks the user belongs to it. Viewers may only read. It must run after AuthMiddleware.
+func OrgContext(orgs OrgResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			c.Abort()
+			return
+		}
+
+		var orgID int
+		var role string
+		if header := c.GetHeader(OrgHeader); header != "" {
+			id, err := strconv.Atoi(header)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + OrgHeader + " header"})
+				c.Abort()
+				return
+			}
+			role, err = orgs.MemberRole(c.Request.Context(), id, userID)
+			if err != nil {
+				log.Printf("Failed to check membership of organization %d for user %d: %v", id, userID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+				c.Abort()
+				return
+			}
+			if role == "" {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+				c.Abort()
+				return
+			}
+			orgID = id
+		} else {
+			id, err := orgs.EnsurePersonalOrg(c.Request.Context(), userID)
+			if err != nil {
+				log.Printf("Failed to load personal organization for user %d: %v", userID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+				c.Abort()
+				return
+			}
+			orgID, role = id, models.OrgRoleOwner
+		}
+
+		if role == models.OrgRoleViewer && c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Viewers cannot change contacts"})
+			c.Abort()
+			return
+		}
+
+		c.Set("org_id", orgID)
+		c.Set("org_role", role)
+		c.Next()
+	}
+}
+
+// GetOrgID retrieves the active organization ID from the context
+func GetOrgID(c *gin.Context) (int, bool) {
+	orgID, exists := c.Get("org_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := orgID.(int)
+	return id, ok
+}
+
+// GetTenantScope returns the authenticated user and active organization.
+// Returns false unless both AuthMiddleware and OrgContext have run.
+func GetTenantScope(c *gin.Context) (models.TenantScope, bool) {
+	userID, ok := GetUserID(c)
+	if !ok {
+		return models.TenantScope{}, false
+	}
+	orgID, ok := GetOrgID(c)
+	if !ok {
+		return models.TenantScope{}, false
+	}
+	return models.TenantScope{UserID: userID, OrgID: orgID}, true
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivilegeLoader returns the privileges an account holds through its roles
+type PrivilegeLoader interface {
+	Privileges(ctx context.Context, accountID int) (map[string]bool, error)
+}
+
+// RequirePrivilege allows the request only if the authenticated account holds at least
+// one of the given privileges. It must run after AuthMiddleware.
+func RequirePrivilege(privileges ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, privilege := range privileges {
+			if HasPrivilege(c, privilege) {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient privileges"})
+		c.Abort()
+	}
+}
+
+// HasPrivilege reports whether the authenticated account holds a privilege
+func HasPrivilege(c *gin.Context, privilege string) bool {
+	privileges, exists := c.Get("privileges")
+	if !exists {
+		return false
+	}
+	set, ok := privileges.(map[string]bool)
+	return ok && set[privilege]
+}
+
+// GetPrivileges retrieves the authenticated account's privileges from the context
+func GetPrivileges(c *gin.Context) map[string]bool {
+	privileges, _ := c.Get("privileges")
+	set, _ := privileges.(map[string]bool)
+	return set
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTxBeginner starts the transaction a request's queries run in
+type RequestTxBeginner interface {
+	BeginRequest(ctx context.Context, userID int) (context.Context, *sql.Tx, error)
+}
+
+// RowSecurity runs the rest of the request in a transaction that applies the database's
+// row-level security policies for the authenticated user, so queries that forget to filter
+// by user still cannot reach other users' rows. The transaction commits when the handler
+// responds with a success status and rolls back otherwise. The response is held back until
+// then, so a write whose commit fails is answered with a 500 rather than the handler's
+// success. It must run after AuthMiddleware.
+func RowSecurity(db RequestTxBeginner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			c.Abort()
+			return
+		}
+
+		ctx, tx, err := db.BeginRequest(c.Request.Context(), userID)
+		if err != nil {
+			log.Printf("Failed to begin request transaction for user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			c.Abort()
+			return
+		}
+		// Rolls back if a handler panics; a no-op after Commit
+		defer func() { _ = tx.Rollback() }()
+
+		header := c.Writer.Header().Clone()
+		buffered := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = buffered
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		c.Writer = buffered.ResponseWriter
+
+		if buffered.Status() < http.StatusBadRequest {
+			if err := tx.Commit(); err != nil {
+				log.Printf("Failed to commit request transaction for user %d: %v", userID, err)
+				// Drop the headers the handler set for its response, such as a download's
+				for name := range c.Writer.Header() {
+					c.Writer.Header().Del(name)
+				}
+				for name, values := range header {
+					c.Writer.Header()[name] = values
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+				return
+			}
+		}
+		buffered.flush()
+	}
+}
+
+// bufferedWriter holds a handler's response until the request transaction is done
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+// WriteHeader records the status to send
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow marks the headers as written without sending them
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+// Write buffers part of the body
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+// WriteString buffers part of the body
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+// Status returns the status to send, 200 unless the handler set one
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the number of body bytes buffered, or -1 if nothing was written
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+// Written reports whether the handler has written a response
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// Flush is a no-op; the response is sent once the transaction is done
+func (w *bufferedWriter) Flush() {}
+
+// flush sends the buffered response
+func (w *bufferedWriter) flush() {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if !w.written {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+	if w.body.Len() > 0 {
+		if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+			log.Printf("Failed to write response: %v", err)
+		}
+	}
+}
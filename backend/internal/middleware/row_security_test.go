@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failCommit makes the fake driver's transactions fail to commit
+var failCommit atomic.Bool
+
+// fakeDriver hands out connections whose transactions only record how they ended
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	if failCommit.Load() {
+		return errors.New("could not serialize access")
+	}
+	return nil
+}
+func (fakeTx) Rollback() error { return nil }
+
+func init() {
+	sql.Register("rowsecurity-fake", fakeDriver{})
+}
+
+// fakeBeginner begins plain transactions on the fake driver
+type fakeBeginner struct {
+	db *sql.DB
+}
+
+func (b fakeBeginner) BeginRequest(ctx context.Context, userID int) (context.Context, *sql.Tx, error) {
+	tx, err := b.db.BeginTx(ctx, nil)
+	return ctx, tx, err
+}
+
+func TestRowSecurityHoldsResponseUntilCommit(t *testing.T) {
+	db, err := sql.Open("rowsecurity-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Writer.Header().Set("X-Before", "kept")
+		c.Next()
+	}, RowSecurity(fakeBeginner{db: db}))
+	router.POST("/created", func(c *gin.Context) {
+		c.Header("Content-Disposition", `attachment; filename="people.csv"`)
+		c.JSON(http.StatusCreated, gin.H{"id": 7})
+	})
+	router.POST("/refused", func(c *gin.Context) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid"})
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		failCommit bool
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "commit succeeds", path: "/created", wantStatus: http.StatusCreated, wantBody: `{"id":7}`},
+		{name: "commit fails", path: "/created", failCommit: true, wantStatus: http.StatusInternalServerError, wantBody: `{"error":"database error"}`},
+		{name: "client error is not committed", path: "/refused", failCommit: true, wantStatus: http.StatusBadRequest, wantBody: `{"error":"invalid"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failCommit.Store(tt.failCommit)
+			defer failCommit.Store(false)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", w.Body, tt.wantBody)
+			}
+			if got := w.Header().Get("X-Before"); got != "kept" {
+				t.Errorf("X-Before = %q, want the header set before the transaction", got)
+			}
+			if got := w.Header().Get("Content-Disposition"); tt.failCommit && tt.wantStatus == http.StatusInternalServerError && got != "" {
+				t.Errorf("failed response kept the handler's Content-Disposition %q", got)
+			}
+		})
+	}
+}
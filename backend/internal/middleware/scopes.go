@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// AccessTokenAuthenticator looks up the personal access token behind a bearer token
+type AccessTokenAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (*models.AccessToken, error)
+}
+
+// RequireScope limits personal access tokens to the route groups they were granted.
+// GET and HEAD requests need "<resource>:read" or "<resource>:write"; anything else needs
+// "<resource>:write". Requests authenticated with a login session are not affected.
+// It must run after AuthMiddleware.
+func RequireScope(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, ok := GetScopes(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		allowed := scopes[resource+":write"]
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			allowed = allowed || scopes[resource+":read"]
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access token does not have the required scope"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession rejects requests authenticated with a personal access token, for endpoints
+// that manage the account's own credentials. It must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := GetScopes(c); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with an access token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetScopes retrieves the scopes of the personal access token used for the request.
+// Returns false if the request was authenticated with a login session.
+func GetScopes(c *gin.Context) (map[string]bool, bool) {
+	scopes, exists := c.Get("scopes")
+	if !exists {
+		return nil, false
+	}
+	set, ok := scopes.(map[string]bool)
+	return set, ok
+}
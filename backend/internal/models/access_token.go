@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// AccessTokenPrefix marks a bearer token as a personal access token rather than a JWT
+const AccessTokenPrefix = "mmc_pat_"
+
+// Scope resources. A personal access token holds "<resource>:read" and/or
+// "<resource>:write" for each route group it may call; write implies read.
+const (
+	ScopePeople = "people"
+	ScopeVault  = "vault"
+	ScopeUsers  = "users"
+	ScopeRoles  = "roles"
+	ScopeAdmin  = "admin"
+)
+
+// AllScopes lists every scope a personal access token can be granted
+var AllScopes = []string{
+	ScopePeople + ":read", ScopePeople + ":write",
+	ScopeVault + ":read", ScopeVault + ":write",
+	ScopeUsers + ":read", ScopeUsers + ":write",
+	ScopeRoles + ":read", ScopeRoles + ":write",
+	ScopeAdmin + ":read", ScopeAdmin + ":write",
+}
+
+// AccessToken represents a personal access token (sec_access_tokens).
+// Only the hash of the token is stored; the token itself is shown once when created.
+type AccessToken struct {
+	ID          int        `json:"id" db:"sec_access_tokens_id"`
+	AccountID   int        `json:"accountId" db:"sec_accounts_id"`
+	UserID      int        `json:"userId" db:"sec_users_id"`
+	AccountName string     `json:"-" db:"-"`
+	Name        string     `json:"name" db:"name"`
+	Prefix      string     `json:"prefix" db:"token_prefix"`
+	TokenHash   string     `json:"-" db:"token_hash"`
+	Scopes      []string   `json:"scopes" db:"scopes"`
+	ExpiresAt   *time.Time `json:"expiresAt" db:"expires_at"`
+	LastUsedAt  *time.Time `json:"lastUsedAt" db:"last_used_at"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+	CreateDate  time.Time  `json:"createDate" db:"create_date"`
+}
+
+// CreateAccessTokenRequest represents a request to create a personal access token
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays *int     `json:"expiresInDays" binding:"omitempty,min=1,max=3650"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// Audit event names
+const (
+	AuditPasswordChanged       = "password_changed"
+	AuditEmailChangeRequested  = "email_change_requested"
+	AuditEmailChanged          = "email_changed"
+	AuditMFAEnabled            = "mfa_enabled"
+	AuditMFADisabled           = "mfa_disabled"
+	AuditMFARecoveryCodeUsed   = "mfa_recovery_code_used"
+	AuditMFARecoveryCodesReset = "mfa_recovery_codes_regenerated"
+	AuditRoleMFAUpdated        = "role_mfa_updated"
+	AuditRoleCreated           = "role_created"
+	AuditRoleDeleted           = "role_deleted"
+	AuditRoleAssigned          = "role_assigned"
+	AuditRoleRemoved           = "role_removed"
+	AuditPrivilegeGranted      = "privilege_granted"
+	AuditPrivilegeRevoked      = "privilege_revoked"
+	AuditUserDeleted           = "user_deleted"
+	AuditAccessTokenCreated    = "access_token_created"
+	AuditAccessTokenRevoked    = "access_token_revoked"
+	AuditAccountLocked         = "account_locked"
+	AuditAccountUnlocked       = "account_unlocked"
+	AuditOIDCLogin             = "oidc_login"
+	AuditOIDCIdentityLinked    = "oidc_identity_linked"
+	AuditOIDCAccountCreated    = "oidc_account_created"
+	AuditSessionRevoked        = "session_revoked"
+	AuditImpersonationStarted  = "impersonation_started"
+	AuditImpersonatedRequest   = "impersonated_request"
+	AuditOrgCreated            = "org_created"
+	AuditOrgDeleted            = "org_deleted"
+	AuditOrgMemberAdded        = "org_member_added"
+	AuditOrgMemberRoleChanged  = "org_member_role_changed"
+	AuditOrgMemberRemoved      = "org_member_removed"
+	AuditPersonShared          = "person_shared"
+	AuditPersonShareRevoked    = "person_share_revoked"
+)
+
+// AuditEvent represents a security-relevant action recorded in sec_audit_log
+type AuditEvent struct {
+	ID         int       `json:"id" db:"sec_audit_log_id"`
+	UserID     *int      `json:"userId" db:"sec_users_id"`
+	AccountID  *int      `json:"accountId" db:"sec_accounts_id"`
+	Event      string    `json:"event" db:"event"`
+	Detail     *string   `json:"detail" db:"detail"`
+	IPAddress  *string   `json:"ipAddress" db:"ip_address"`
+	UserAgent  *string   `json:"userAgent" db:"user_agent"`
+	CreateDate time.Time `json:"createDate" db:"create_date"`
+}
@@ -8,10 +8,10 @@ type LoginRequest struct {
 
 // RegisterRequest represents a registration request
 type RegisterRequest struct {
-	FirstName string `json:"firstName" binding:"required"`
-	LastName  string `json:"lastName" binding:"required"`
-	Email     string `json:"email" binding:"required,email"`
-	Username  string `json:"username" binding:"required,min=3"`
+	FirstName string `json:"firstName" binding:"required,max=50"`
+	LastName  string `json:"lastName" binding:"required,max=50"`
+	Email     string `json:"email" binding:"required,email,max=254"`
+	Username  string `json:"username" binding:"required,min=3,max=50"`
 	Password  string `json:"password" binding:"required,min=6"`
 }
 
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+)
+
+// Limits on vCard imports
+const (
+	MaxVCardImportBytes = 5 << 20
+	MaxVCardImportCards = 1000
+)
+
+// Contact is a person with the contact details exchanged as a vCard. Type and state
+// names are carried by name; importing resolves them to IDs.
+type Contact struct {
+	PersonID     int
+	FirstName    *string
+	LastName     *string
+	Birthday     *time.Time
+	BusinessFlag string
+	ModifyDate   time.Time
+	Emails       []ContactEmail
+	Phones       []ContactPhone
+	Addresses    []ContactAddress
+	Notes        []string
+	Links        []ContactLink
+	Tags         []string
+}
+
+// ContactEmail is an email address with the name of its pdat_email_types type
+type ContactEmail struct {
+	Address string
+	Type    string
+}
+
+// ContactPhone is a phone number with the name of its pdat_phone_type type
+type ContactPhone struct {
+	Number      string
+	Ext         string
+	CountryCode string
+	Type        string
+}
+
+// ContactAddress is a postal address. State is the cmn_states abbreviation; StateID is
+// set when importing.
+type ContactAddress struct {
+	Addr1    string
+	Addr2    string
+	City     string
+	State    string
+	StateID  *int
+	Zip      string
+	ZipPlus4 string
+	Country  string
+}
+
+// ContactLink is a web link about a person
+type ContactLink struct {
+	URL  string
+	Text string
+}
+
+// Outcomes of importing a vCard
+const (
+	VCardCreated = "created"
+	VCardValid   = "valid" // The card would be created; reported by dry runs
+	VCardFailed  = "failed"
+)
+
+// VCardImportResult reports what happened to one card of an import
+type VCardImportResult struct {
+	Card     int      `json:"card"` // Position of the card in the upload, from 1
+	Line     int      `json:"line"`
+	Name     string   `json:"name,omitempty"`
+	Status   string   `json:"status"`
+	PersonID *int     `json:"personId,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// VCardImportResponse summarizes an import
+type VCardImportResponse struct {
+	DryRun  bool                 `json:"dryRun"`
+	Total   int                  `json:"total"`
+	Created int                  `json:"created"` // Cards created, or that would be on a dry run
+	Failed  int                  `json:"failed"`
+	Results []*VCardImportResult `json:"results"`
+}
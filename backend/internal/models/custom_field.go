@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// Types of custom field
+const (
+	CustomFieldText    = "text"
+	CustomFieldNumber  = "number"
+	CustomFieldDate    = "date"
+	CustomFieldSelect  = "select"
+	CustomFieldBoolean = "boolean"
+)
+
+// MaxCustomTextLength limits the length of a text custom field value
+const MaxCustomTextLength = 1000
+
+// CustomField is an extra field an organization defines for its people, such as a
+// LinkedIn URL or an account number
+type CustomField struct {
+	ID         int       `json:"id" db:"pdat_custom_field_id"`
+	OrgID      int       `json:"orgId" db:"sec_orgs_id"`
+	Key        string    `json:"key" db:"field_key"`
+	Label      string    `json:"label" db:"label"`
+	Type       string    `json:"type" db:"field_type"`
+	Options    []string  `json:"options" db:"options"`
+	Required   bool      `json:"required" db:"required"`
+	SortOrder  int       `json:"sortOrder" db:"sort_order"`
+	CreateDate time.Time `json:"createDate" db:"create_date"`
+	CreateUser *string   `json:"createUser" db:"create_user"`
+	ModifyDate time.Time `json:"modifyDate" db:"modify_date"`
+	ModifyUser *string   `json:"modifyUser" db:"modify_user"`
+}
+
+// CustomFieldValue is a person's value for a custom field, set in the member matching the
+// field's type. A value with every member nil clears the field.
+type CustomFieldValue struct {
+	FieldID int
+	Text    *string
+	Number  *float64
+	Date    *time.Time
+	Bool    *bool
+}
+
+// CustomFieldRequest represents a request to define a custom field
+type CustomFieldRequest struct {
+	Key       string   `json:"key" binding:"required,max=50"`
+	Label     string   `json:"label" binding:"required,max=100"`
+	Type      string   `json:"type" binding:"required,oneof=text number date select boolean"`
+	Options   []string `json:"options" binding:"dive,required,max=100"`
+	Required  bool     `json:"required"`
+	SortOrder int      `json:"sortOrder"`
+}
+
+// CustomFieldUpdateRequest represents a request to change a custom field. Its key and
+// type cannot change once people have values for it.
+type CustomFieldUpdateRequest struct {
+	Label     string   `json:"label" binding:"required,max=100"`
+	Options   []string `json:"options" binding:"dive,required,max=100"`
+	Required  bool     `json:"required"`
+	SortOrder int      `json:"sortOrder"`
+}
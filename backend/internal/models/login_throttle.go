@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Scopes for LoginThrottle. Failed logins are counted per account and per client IP.
+const (
+	ThrottleScopeAccount = "account"
+	ThrottleScopeIP      = "ip"
+)
+
+// LoginThrottle tracks recent failed logins for one account or client IP (sec_login_throttle).
+// While BlockedUntil is in the future, logins are refused; Locked marks an account
+// lockout (as opposed to a short backoff), which an admin can clear early.
+type LoginThrottle struct {
+	Scope         string     `json:"scope" db:"scope"`
+	Key           string     `json:"key" db:"throttle_key"`
+	Failures      int        `json:"failures" db:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt" db:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blockedUntil" db:"blocked_until"`
+	Locked        bool       `json:"locked" db:"locked"`
+}
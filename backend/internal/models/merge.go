@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+)
+
+// Which person's value a merge keeps for a field
+const (
+	MergeKeepSurvivor = "survivor"
+	MergeKeepMerged   = "merged"
+)
+
+// MergeFields are the pdat_person columns a merge request can choose values for
+var MergeFields = []string{"fname", "lname", "birthday", "business_flag"}
+
+// Reasons two people are reported as likely duplicates
+const (
+	MatchName     = "name"
+	MatchNickname = "nickname"
+	MatchEmail    = "email"
+	MatchPhone    = "phone"
+	MatchAddress  = "address"
+)
+
+// DuplicateAddress is the part of an address duplicate detection compares
+type DuplicateAddress struct {
+	Addr1 string `json:"addr1"`
+	City  string `json:"city"`
+	Zip   string `json:"zip"`
+}
+
+// DuplicatePerson is a person with the contact details duplicate detection compares
+type DuplicatePerson struct {
+	PersonID  int                `json:"personId"`
+	FirstName *string            `json:"firstName"`
+	LastName  *string            `json:"lastName"`
+	Emails    []string           `json:"emails"`
+	Phones    []string           `json:"phones"`
+	Addresses []DuplicateAddress `json:"addresses"`
+}
+
+// DuplicatePair is two people who are likely the same, scored from 0 to 100
+type DuplicatePair struct {
+	Score   int              `json:"score"`
+	Reasons []string         `json:"reasons"`
+	Person  *DuplicatePerson `json:"person"`
+	Other   *DuplicatePerson `json:"other"`
+}
+
+// MergeRequest represents a request to merge one person into another. Fields maps a
+// field in MergeFields, or a custom field as "cf.<key>", to the person whose value to
+// keep. Fields left out keep the survivor's value unless it is empty.
+type MergeRequest struct {
+	SurvivorID int               `json:"survivorId" binding:"required"`
+	MergedID   int               `json:"mergedId" binding:"required,nefield=SurvivorID"`
+	Fields     map[string]string `json:"fields" binding:"dive,oneof=survivor merged"`
+}
+
+// PersonMerge records a merge of one person into another so it can be undone. Moved
+// lists, by table, the rows moved from the merged person to the survivor.
+type PersonMerge struct {
+	ID         int              `json:"id" db:"pdat_person_merge_id"`
+	SurvivorID int              `json:"survivorId" db:"survivor_id"`
+	MergedID   int              `json:"mergedId" db:"merged_id"`
+	Moved      map[string][]int `json:"moved" db:"moved"`
+	CreateDate time.Time        `json:"createDate" db:"create_date"`
+	CreateUser *string          `json:"createUser" db:"create_user"`
+	UndoneDate *time.Time       `json:"undoneDate" db:"undone_date"`
+	UndoneUser *string          `json:"undoneUser" db:"undone_user"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// AccountMFA represents an account's TOTP enrollment. Secret is pending until Enabled.
+type AccountMFA struct {
+	AccountID    int        `json:"accountId" db:"sec_accounts_id"`
+	Secret       string     `json:"-" db:"totp_secret"`
+	Enabled      bool       `json:"enabled" db:"enabled"`
+	ConfirmedAt  *time.Time `json:"confirmedAt" db:"confirmed_at"`
+	LastUsedStep *int64     `json:"-" db:"last_used_step"`
+	CreateDate   time.Time  `json:"createDate" db:"create_date"`
+}
+
+// MFAChallenge represents the short-lived token returned by a password login
+// that still needs a second factor. Only the hash of the token is stored.
+type MFAChallenge struct {
+	ID         int        `json:"id" db:"sec_mfa_challenges_id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	AccountID  int        `json:"accountId" db:"sec_accounts_id"`
+	ExpiresAt  time.Time  `json:"expiresAt" db:"expires_at"`
+	Attempts   int        `json:"attempts" db:"attempts"`
+	UsedAt     *time.Time `json:"usedAt" db:"used_at"`
+	CreateDate time.Time  `json:"createDate" db:"create_date"`
+}
+
+// MFALoginRequest completes a login with a second factor
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfaToken" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// MFAEnrollChallengeRequest starts TOTP enrollment during login when a role requires MFA
+type MFAEnrollChallengeRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+}
+
+// MFACodeRequest carries a TOTP code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFADisableRequest disables TOTP for the current account
+type MFADisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
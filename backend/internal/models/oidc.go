@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// OIDCLoginState holds what the callback of an OpenID Connect login needs to finish it.
+// It is keyed by the hash of the state parameter sent to the identity provider.
+type OIDCLoginState struct {
+	ID           int       `json:"id" db:"sec_oidc_states_id"`
+	StateHash    string    `json:"-" db:"state_hash"`
+	CodeVerifier string    `json:"-" db:"code_verifier"`
+	Nonce        string    `json:"-" db:"nonce"`
+	ExpiresAt    time.Time `json:"expiresAt" db:"expires_at"`
+	CreateDate   time.Time `json:"createDate" db:"create_date"`
+}
+
+// AccountIdentity links an identity provider subject to an account (sec_account_identities)
+type AccountIdentity struct {
+	ID          int        `json:"id" db:"sec_account_identities_id"`
+	AccountID   int        `json:"accountId" db:"sec_accounts_id"`
+	Issuer      string     `json:"issuer" db:"issuer"`
+	Subject     string     `json:"subject" db:"subject"`
+	Email       *string    `json:"email" db:"email"`
+	LastLoginAt *time.Time `json:"lastLoginAt" db:"last_login_at"`
+	CreateDate  time.Time  `json:"createDate" db:"create_date"`
+}
+
+// OIDCClaims are the ID token claims used to find or provision an account
+type OIDCClaims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	GivenName         string
+	FamilyName        string
+	Name              string
+}
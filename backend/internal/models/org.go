@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// Organization member roles, from most to least privileged
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+	OrgRoleViewer = "viewer"
+)
+
+// Org is an organization (workspace) whose members share a contact book
+type Org struct {
+	ID         int       `json:"id" db:"sec_orgs_id"`
+	Name       string    `json:"name" db:"name"`
+	Personal   bool      `json:"personal" db:"-"`
+	Role       string    `json:"role,omitempty" db:"role"` // The requesting user's role, when listed for a user
+	CreateDate time.Time `json:"createDate" db:"create_date"`
+	CreateUser *string   `json:"createUser" db:"create_user"`
+	ModifyDate time.Time `json:"modifyDate" db:"modify_date"`
+	ModifyUser *string   `json:"modifyUser" db:"modify_user"`
+}
+
+// OrgMember is a user's membership of an organization
+type OrgMember struct {
+	OrgID      int       `json:"orgId" db:"sec_orgs_id"`
+	UserID     int       `json:"userId" db:"sec_users_id"`
+	FirstName  *string   `json:"firstName" db:"fname"`
+	LastName   *string   `json:"lastName" db:"lname"`
+	Email      *string   `json:"email" db:"email"`
+	Role       string    `json:"role" db:"role"`
+	CreateDate time.Time `json:"createDate" db:"create_date"`
+}
+
+// TenantScope identifies whose contacts a request may see: people in the active
+// organization that are shared, or private to the user
+type TenantScope struct {
+	UserID int
+	OrgID  int
+}
+
+// OrgRequest represents a request to create or rename an organization
+type OrgRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// OrgMemberRequest represents a request to add a member by account name
+type OrgMemberRequest struct {
+	Account string `json:"account" binding:"required"`
+	Role    string `json:"role" binding:"required,oneof=owner admin member viewer"`
+}
+
+// OrgRoleRequest represents a request to change a member's role
+type OrgRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member viewer"`
+}
@@ -12,11 +12,16 @@ type PdatPerson struct {
 	Birthday     *time.Time `json:"birthday" db:"birthday"`
 	BusinessFlag string     `json:"businessFlag" db:"business_flag"`
 	UserID       int        `json:"userId" db:"sec_users_id"`
+	OrgID        *int       `json:"orgId" db:"sec_orgs_id"`
+	PrivateFlag  string     `json:"privateFlag" db:"private_flag"` // 'Y' hides the person from other organization members
 	CreateDate   time.Time  `json:"createDate" db:"create_date"`
 	CreateUser   string     `json:"createUser" db:"create_user"`
 	ModifyDate   time.Time  `json:"modifyDate" db:"modify_date"`
 	ModifyUser   string     `json:"modifyUser" db:"modify_user"`
 	ActiveFlag   string     `json:"activeFlag" db:"active_flag"`
+
+	// CustomFields holds the person's custom field values by field key
+	CustomFields map[string]interface{} `json:"customFields,omitempty" db:"-"`
 }
 
 // PdatAddress represents a person's address
@@ -0,0 +1,51 @@
+package models
+
+// Privileges checked by the API. They are stored by name in sec_privileges
+// alongside the privileges that came with the original data.
+const (
+	PrivContactsRead  = "contacts.read"
+	PrivContactsWrite = "contacts.write"
+	PrivVaultAccess   = "vault.access"
+	PrivUsersManage   = "users.manage"
+	PrivImpersonate   = "users.impersonate"
+	PrivRolesManage   = "roles.manage"
+	PrivAdminBackup   = "admin.backup"
+	PrivAdminRestore  = "admin.restore"
+)
+
+// Default roles seeded at startup
+const (
+	RoleAdmin    = "admin"
+	RoleUser     = "user"
+	RoleReadOnly = "read-only"
+)
+
+// AllPrivileges lists every privilege checked by the API. The admin role always holds all of them.
+var AllPrivileges = []string{
+	PrivContactsRead,
+	PrivContactsWrite,
+	PrivVaultAccess,
+	PrivUsersManage,
+	PrivImpersonate,
+	PrivRolesManage,
+	PrivAdminBackup,
+	PrivAdminRestore,
+}
+
+// DefaultRolePrivileges holds the privileges granted to each default role when it is first created
+var DefaultRolePrivileges = map[string][]string{
+	RoleAdmin:    AllPrivileges,
+	RoleUser:     {PrivContactsRead, PrivContactsWrite, PrivVaultAccess},
+	RoleReadOnly: {PrivContactsRead},
+}
+
+// RoleRequest represents a request to create or update a role
+type RoleRequest struct {
+	Name        string  `json:"name" binding:"required,max=50"`
+	Description *string `json:"descr" binding:"omitempty,max=255"`
+}
+
+// PrivilegeRequest represents a request to create a privilege
+type PrivilegeRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
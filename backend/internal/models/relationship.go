@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// Relationship types between people. A relationship reads from the first person to the
+// related person, e.g. "A assistant-of B" or "A referred-by B".
+const (
+	RelationshipSpouse      = "spouse"
+	RelationshipPartner     = "partner"
+	RelationshipParent      = "parent"
+	RelationshipChild       = "child"
+	RelationshipSibling     = "sibling"
+	RelationshipFriend      = "friend"
+	RelationshipColleague   = "colleague"
+	RelationshipManagerOf   = "manager-of"
+	RelationshipAssistantOf = "assistant-of"
+	RelationshipReferredBy  = "referred-by"
+	RelationshipOther       = "other"
+)
+
+// Directions of a relationship as seen from one of its people
+const (
+	RelationshipOutgoing = "outgoing"
+	RelationshipIncoming = "incoming"
+)
+
+// MaxRelationshipHops limits how far a relationship network is traversed
+const MaxRelationshipHops = 4
+
+// PersonRelationship is a typed link from one person to another in the same organization.
+// Bidirectional relationships read the same from both ends.
+type PersonRelationship struct {
+	ID              int       `json:"id" db:"pdat_relationship_id"`
+	PersonID        int       `json:"personId" db:"pdat_person_id"`
+	RelatedPersonID int       `json:"relatedPersonId" db:"related_person_id"`
+	Type            string    `json:"type" db:"relationship_type"`
+	Bidirectional   bool      `json:"bidirectional" db:"bidirectional"`
+	CreateDate      time.Time `json:"createDate" db:"create_date"`
+	CreateUser      *string   `json:"createUser" db:"create_user"`
+	ModifyDate      time.Time `json:"modifyDate" db:"modify_date"`
+	ModifyUser      *string   `json:"modifyUser" db:"modify_user"`
+}
+
+// NetworkPerson is a person reached from another through relationships
+type NetworkPerson struct {
+	PersonID  int     `json:"personId" db:"pdat_person_id"`
+	FirstName *string `json:"firstName" db:"fname"`
+	LastName  *string `json:"lastName" db:"lname"`
+	Hops      int     `json:"hops" db:"hops"`
+}
+
+// RelationshipRequest represents a request to relate two people
+type RelationshipRequest struct {
+	PersonID        int    `json:"personId" binding:"required"`
+	RelatedPersonID int    `json:"relatedPersonId" binding:"required,nefield=PersonID"`
+	Type            string `json:"type" binding:"required,oneof=spouse partner parent child sibling friend colleague manager-of assistant-of referred-by other"`
+	Bidirectional   bool   `json:"bidirectional"`
+}
+
+// RelationshipUpdateRequest represents a request to change a relationship's type or direction
+type RelationshipUpdateRequest struct {
+	Type          string `json:"type" binding:"required,oneof=spouse partner parent child sibling friend colleague manager-of assistant-of referred-by other"`
+	Bidirectional bool   `json:"bidirectional"`
+}
@@ -6,37 +6,40 @@ import (
 
 // SecUser represents a user in the system
 type SecUser struct {
-	ID         int        `json:"id" db:"sec_users_id"`
-	FirstName  string     `json:"firstName" db:"fname"`
-	LastName   string     `json:"lastName" db:"lname"`
-	CreateDate time.Time  `json:"createDate" db:"create_date"`
-	CreateUser string     `json:"createUser" db:"create_user"`
-	ModifyDate time.Time  `json:"modifyDate" db:"modify_date"`
-	ModifyUser string     `json:"modifyUser" db:"modify_user"`
-	Email      *string    `json:"email,omitempty" db:"-"` // Not in original schema, added for auth
-	Password   *string    `json:"-" db:"-"`               // Not in original schema, added for auth
-}
-
-// SecAccount represents an account with authentication credentials
-type SecAccount struct {
-	ID         int       `json:"id" db:"sec_accounts_id"`
-	Name       string    `json:"name" db:"name"`
-	Password   string    `json:"-" db:"password"`
-	UserID     int       `json:"userId" db:"sec_users_id"`
+	ID         int       `json:"id" db:"sec_users_id"`
+	FirstName  string    `json:"firstName" db:"fname"`
+	LastName   string    `json:"lastName" db:"lname"`
 	CreateDate time.Time `json:"createDate" db:"create_date"`
 	CreateUser string    `json:"createUser" db:"create_user"`
 	ModifyDate time.Time `json:"modifyDate" db:"modify_date"`
 	ModifyUser string    `json:"modifyUser" db:"modify_user"`
+	Email      *string   `json:"email,omitempty" db:"email"`
+	Password   *string   `json:"-" db:"-"` // Not in original schema, added for auth
+}
+
+// SecAccount represents an account with authentication credentials
+type SecAccount struct {
+	ID            int       `json:"id" db:"sec_accounts_id"`
+	Name          string    `json:"name" db:"name"`
+	Password      string    `json:"-" db:"password"`
+	UserID        int       `json:"userId" db:"sec_users_id"`
+	EmailVerified bool      `json:"emailVerified" db:"email_verified"`
+	CreateDate    time.Time `json:"createDate" db:"create_date"`
+	CreateUser    string    `json:"createUser" db:"create_user"`
+	ModifyDate    time.Time `json:"modifyDate" db:"modify_date"`
+	ModifyUser    string    `json:"modifyUser" db:"modify_user"`
 }
 
 // SecRole represents a security role
 type SecRole struct {
-	ID         int       `json:"id" db:"sec_roles_id"`
-	Name       string    `json:"name" db:"name"`
-	CreateDate time.Time `json:"createDate" db:"create_date"`
-	CreateUser string    `json:"createUser" db:"create_user"`
-	ModifyDate time.Time `json:"modifyDate" db:"modify_date"`
-	ModifyUser string    `json:"modifyUser" db:"modify_user"`
+	ID          int       `json:"id" db:"sec_roles_id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"descr" db:"descr"`
+	RequireMFA  bool      `json:"requireMfa" db:"require_mfa"`
+	CreateDate  time.Time `json:"createDate" db:"create_date"`
+	CreateUser  string    `json:"createUser" db:"create_user"`
+	ModifyDate  time.Time `json:"modifyDate" db:"modify_date"`
+	ModifyUser  string    `json:"modifyUser" db:"modify_user"`
 }
 
 // SecPrivilege represents a security privilege
@@ -69,12 +72,36 @@ type SecRolePrivilege struct {
 	ModifyUser  string    `json:"modifyUser" db:"modify_user"`
 }
 
-// RefreshToken represents a refresh token for authentication
+// RefreshToken represents a refresh token for authentication.
+// Tokens rotate on every use; all tokens issued from one login share a FamilyID.
 type RefreshToken struct {
-	ID        int       `json:"id" db:"id"`
-	UserID    int       `json:"userId" db:"user_id"`
-	Token     string    `json:"token" db:"token"`
-	ExpiresAt time.Time `json:"expiresAt" db:"expires_at"`
-	Revoked   bool      `json:"revoked" db:"revoked"`
-	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"userId" db:"user_id"`
+	AccountID int        `json:"accountId" db:"sec_accounts_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	FamilyID  string     `json:"familyId" db:"family_id"`
+	ExpiresAt time.Time  `json:"expiresAt" db:"expires_at"`
+	Revoked   bool       `json:"revoked" db:"revoked"`
+	RotatedAt *time.Time `json:"rotatedAt" db:"rotated_at"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// Purposes for UserToken
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+	TokenPurposeChangeEmail   = "change_email"
+)
+
+// UserToken represents a single-use, expiring token emailed to a user.
+// Only the SHA-256 hash of the token is stored.
+type UserToken struct {
+	ID         int        `json:"id" db:"sec_user_tokens_id"`
+	UserID     int        `json:"userId" db:"sec_users_id"`
+	Purpose    string     `json:"purpose" db:"purpose"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Email      *string    `json:"email" db:"email"`
+	ExpiresAt  time.Time  `json:"expiresAt" db:"expires_at"`
+	UsedAt     *time.Time `json:"usedAt" db:"used_at"`
+	CreateDate time.Time  `json:"createDate" db:"create_date"`
 }
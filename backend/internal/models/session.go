@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a login session: one refresh token family, with the device it was
+// started from and when it was last refreshed
+type Session struct {
+	ID          string    `json:"id" db:"family_id"`
+	UserID      int       `json:"userId" db:"sec_users_id"`
+	AccountID   int       `json:"accountId" db:"sec_accounts_id"`
+	AccountName string    `json:"accountName" db:"name"`
+	UserAgent   *string   `json:"userAgent" db:"user_agent"`
+	IPAddress   *string   `json:"ipAddress" db:"ip_address"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	LastSeenAt  time.Time `json:"lastSeenAt" db:"last_seen_at"`
+	ExpiresAt   time.Time `json:"expiresAt" db:"expires_at"`
+	Current     bool      `json:"current" db:"-"`
+}
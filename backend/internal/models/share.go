@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// Permissions a person can be shared with
+const (
+	SharePermissionRead = "read"
+	SharePermissionEdit = "edit"
+)
+
+// PersonShare is a person shared by its owner with another user
+type PersonShare struct {
+	PersonID   int       `json:"personId" db:"pdat_person_id"`
+	UserID     int       `json:"userId" db:"sec_users_id"`
+	FirstName  *string   `json:"firstName" db:"fname"`
+	LastName   *string   `json:"lastName" db:"lname"`
+	Permission string    `json:"permission" db:"permission"`
+	CreateDate time.Time `json:"createDate" db:"create_date"`
+	CreateUser *string   `json:"createUser" db:"create_user"`
+}
+
+// SharedPerson is a person another user has shared with the current user
+type SharedPerson struct {
+	PersonID       int        `json:"personId" db:"pdat_person_id"`
+	FirstName      *string    `json:"firstName" db:"fname"`
+	LastName       *string    `json:"lastName" db:"lname"`
+	Birthday       *time.Time `json:"birthday" db:"birthday"`
+	BusinessFlag   string     `json:"businessFlag" db:"business_flag"`
+	OwnerID        int        `json:"ownerId" db:"sec_users_id"`
+	OwnerFirstName *string    `json:"ownerFirstName" db:"owner_fname"`
+	OwnerLastName  *string    `json:"ownerLastName" db:"owner_lname"`
+	Permission     string     `json:"permission" db:"permission"`
+	SharedDate     time.Time  `json:"sharedDate" db:"create_date"`
+}
+
+// ShareRequest represents a request to share a person with the user behind an account
+type ShareRequest struct {
+	Account    string `json:"account" binding:"required"`
+	Permission string `json:"permission" binding:"required,oneof=read edit"`
+}
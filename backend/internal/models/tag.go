@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultTagColor is the colour given to tags created without one
+const DefaultTagColor = "#808080"
+
+// Tag is a coloured label people in an organization can be given
+type Tag struct {
+	ID          int       `json:"id" db:"pdat_tag_id"`
+	Name        string    `json:"name" db:"name"`
+	Color       string    `json:"color" db:"color"`
+	OrgID       int       `json:"orgId" db:"sec_orgs_id"`
+	PersonCount int       `json:"personCount" db:"person_count"`
+	CreateDate  time.Time `json:"createDate" db:"create_date"`
+	CreateUser  *string   `json:"createUser" db:"create_user"`
+	ModifyDate  time.Time `json:"modifyDate" db:"modify_date"`
+	ModifyUser  *string   `json:"modifyUser" db:"modify_user"`
+}
+
+// TagRequest represents a request to create or change a tag
+type TagRequest struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Color string `json:"color" binding:"omitempty,hexcolor,len=7"`
+}
+
+// TagAssignmentRequest represents a request to tag or untag several people at once
+type TagAssignmentRequest struct {
+	PersonIDs []int `json:"personIds" binding:"required,min=1"`
+	TagIDs    []int `json:"tagIds" binding:"required,min=1"`
+}
+
+// TagAssignmentResult reports what a bulk tag or untag request changed. People and tags
+// the user cannot see in the active organization are skipped.
+type TagAssignmentResult struct {
+	Changed          int   `json:"changed"`
+	PersonIDs        []int `json:"personIds"`
+	TagIDs           []int `json:"tagIds"`
+	SkippedPersonIDs []int `json:"skippedPersonIds"`
+	SkippedTagIDs    []int `json:"skippedTagIds"`
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// AccessTokenService authenticates personal access tokens for AuthMiddleware
+type AccessTokenService struct {
+	repo *database.AccessTokenRepository
+}
+
+// NewAccessTokenService creates a new access token service
+func NewAccessTokenService(repo *database.AccessTokenRepository) *AccessTokenService {
+	return &AccessTokenService{repo: repo}
+}
+
+// Authenticate returns the active personal access token matching a bearer token,
+// or nil if it is unknown, expired or revoked, and records that it was used
+func (s *AccessTokenService) Authenticate(ctx context.Context, token string) (*models.AccessToken, error) {
+	t, err := s.repo.FindActiveByHash(ctx, utils.HashToken(token))
+	if err != nil || t == nil {
+		return nil, err
+	}
+
+	if err := s.repo.TouchLastUsed(ctx, t.ID); err != nil {
+		log.Printf("Failed to record use of access token %d: %v", t.ID, err)
+	}
+
+	return t, nil
+}
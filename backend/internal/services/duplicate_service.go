@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// Points each kind of match adds to a duplicate score, which is capped at 100
+const (
+	nameScore     = 40
+	nicknameScore = 30
+	emailScore    = 40
+	phoneScore    = 30
+	addressScore  = 20
+)
+
+// maxBlockSize skips match keys shared by more people than this, such as an office
+// switchboard number, since they say little about any one pair
+const maxBlockSize = 50
+
+// nicknames maps common short first names to the name they are short for
+var nicknames = map[string]string{
+	"abby": "abigail", "al": "albert", "alex": "alexander", "andy": "andrew", "drew": "andrew",
+	"ben": "benjamin", "beth": "elizabeth", "betty": "elizabeth", "liz": "elizabeth", "lizzie": "elizabeth",
+	"bill": "william", "billy": "william", "will": "william", "willy": "william", "liam": "william",
+	"bob": "robert", "bobby": "robert", "rob": "robert", "robbie": "robert", "bert": "robert",
+	"cathy": "catherine", "kathy": "catherine", "kate": "catherine", "katie": "catherine", "katherine": "catherine",
+	"charlie": "charles", "chuck": "charles", "chris": "christopher", "dan": "daniel", "danny": "daniel",
+	"dave": "david", "davy": "david", "dick": "richard", "rich": "richard", "rick": "richard", "ricky": "richard",
+	"ed": "edward", "eddie": "edward", "ted": "edward", "greg": "gregory", "jack": "john", "johnny": "john", "jon": "john",
+	"jen": "jennifer", "jenny": "jennifer", "jim": "james", "jimmy": "james", "jamie": "james",
+	"joe": "joseph", "joey": "joseph", "larry": "lawrence", "maggie": "margaret", "meg": "margaret", "peggy": "margaret",
+	"matt": "matthew", "mike": "michael", "mikey": "michael", "nick": "nicholas", "pat": "patrick",
+	"sam": "samuel", "steve": "steven", "stephen": "steven", "sue": "susan", "susie": "susan",
+	"tom": "thomas", "tommy": "thomas", "tony": "anthony",
+}
+
+// addressWords shortens the words of a street address to their postal abbreviations
+var addressWords = map[string]string{
+	"street": "st", "avenue": "ave", "road": "rd", "drive": "dr", "boulevard": "blvd", "lane": "ln",
+	"court": "ct", "place": "pl", "terrace": "ter", "highway": "hwy", "parkway": "pkwy", "circle": "cir",
+	"apartment": "apt", "suite": "ste", "north": "n", "south": "s", "east": "e", "west": "w",
+}
+
+// DuplicateService finds people in an organization who are likely the same person
+type DuplicateService struct {
+	mergeRepo *database.MergeRepository
+}
+
+// NewDuplicateService creates a new DuplicateService
+func NewDuplicateService(mergeRepo *database.MergeRepository) *DuplicateService {
+	return &DuplicateService{mergeRepo: mergeRepo}
+}
+
+// Find scores pairs of active people visible in the tenant scope on normalized name,
+// email, phone and address, and returns up to limit pairs scoring at least minScore,
+// best first. Only people sharing at least one normalized value are compared.
+func (s *DuplicateService) Find(ctx context.Context, scope models.TenantScope, minScore, limit int) ([]*models.DuplicatePair, error) {
+	people, err := s.mergeRepo.DuplicateCandidates(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]duplicateKeys, len(people))
+	blocks := map[string][]int{}
+	for i, p := range people {
+		keys[i] = normalizeDuplicate(p)
+		for _, key := range keys[i].blockKeys() {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	type pair struct{ a, b int }
+	seen := map[pair]bool{}
+	pairs := []*models.DuplicatePair{}
+	for _, members := range blocks {
+		if len(members) < 2 || len(members) > maxBlockSize {
+			continue
+		}
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				pr := pair{members[x], members[y]}
+				if seen[pr] {
+					continue
+				}
+				seen[pr] = true
+
+				score, reasons := scoreDuplicate(keys[pr.a], keys[pr.b])
+				if score >= minScore {
+					pairs = append(pairs, &models.DuplicatePair{
+						Score:   score,
+						Reasons: reasons,
+						Person:  people[pr.a],
+						Other:   people[pr.b],
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score > pairs[j].Score
+		}
+		if pairs[i].Person.PersonID != pairs[j].Person.PersonID {
+			return pairs[i].Person.PersonID < pairs[j].Person.PersonID
+		}
+		return pairs[i].Other.PersonID < pairs[j].Other.PersonID
+	})
+	if len(pairs) > limit {
+		pairs = pairs[:limit]
+	}
+	return pairs, nil
+}
+
+// duplicateKeys holds a person's normalized values
+type duplicateKeys struct {
+	first     string // First name as given
+	canonical string // First name with nicknames expanded
+	last      string
+	emails    map[string]bool
+	phones    map[string]bool
+	addresses map[string]bool
+}
+
+// blockKeys returns the values a person must share with another to be compared with them
+func (k duplicateKeys) blockKeys() []string {
+	var keys []string
+	if k.canonical != "" && k.last != "" {
+		keys = append(keys, "n:"+k.canonical+" "+k.last)
+	}
+	for email := range k.emails {
+		keys = append(keys, "e:"+email)
+	}
+	for phone := range k.phones {
+		keys = append(keys, "p:"+phone)
+	}
+	for address := range k.addresses {
+		keys = append(keys, "a:"+address)
+	}
+	return keys
+}
+
+// normalizeDuplicate normalizes a person's name and contact details for comparison
+func normalizeDuplicate(p *models.DuplicatePerson) duplicateKeys {
+	k := duplicateKeys{
+		emails:    map[string]bool{},
+		phones:    map[string]bool{},
+		addresses: map[string]bool{},
+	}
+	if p.FirstName != nil {
+		k.first = normalizeName(*p.FirstName)
+		k.canonical = k.first
+		if full, ok := nicknames[k.first]; ok {
+			k.canonical = full
+		}
+	}
+	if p.LastName != nil {
+		k.last = normalizeName(*p.LastName)
+	}
+	for _, email := range p.Emails {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			k.emails[email] = true
+		}
+	}
+	for _, phone := range p.Phones {
+		if phone = normalizePhone(phone); phone != "" {
+			k.phones[phone] = true
+		}
+	}
+	for _, addr := range p.Addresses {
+		if street := normalizeStreet(addr.Addr1); street != "" {
+			place := strings.TrimSpace(addr.Zip)
+			if place == "" {
+				place = normalizeName(addr.City)
+			}
+			k.addresses[street+"|"+place] = true
+		}
+	}
+	return k
+}
+
+// scoreDuplicate scores how likely two people are the same and says why
+func scoreDuplicate(a, b duplicateKeys) (int, []string) {
+	score := 0
+	reasons := []string{}
+	if a.last != "" && a.last == b.last && a.canonical != "" {
+		if a.first == b.first {
+			score += nameScore
+			reasons = append(reasons, models.MatchName)
+		} else if a.canonical == b.canonical {
+			score += nicknameScore
+			reasons = append(reasons, models.MatchNickname)
+		}
+	}
+	if shares(a.emails, b.emails) {
+		score += emailScore
+		reasons = append(reasons, models.MatchEmail)
+	}
+	if shares(a.phones, b.phones) {
+		score += phoneScore
+		reasons = append(reasons, models.MatchPhone)
+	}
+	if shares(a.addresses, b.addresses) {
+		score += addressScore
+		reasons = append(reasons, models.MatchAddress)
+	}
+	if score > 100 {
+		score = 100
+	}
+	return score, reasons
+}
+
+// shares reports whether two sets have a value in common
+func shares(a, b map[string]bool) bool {
+	for v := range a {
+		if b[v] {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeName lowercases a name and drops everything but letters, so "O'Brien" and
+// "obrien" match
+func normalizeName(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// normalizePhone keeps a phone number's digits, dropping a leading US country code.
+// Numbers too short to identify anyone normalize to "".
+func normalizePhone(phone string) string {
+	var sb strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	digits := sb.String()
+	if len(digits) == 11 && digits[0] == '1' {
+		digits = digits[1:]
+	}
+	if len(digits) < 7 {
+		return ""
+	}
+	return digits
+}
+
+// normalizeStreet lowercases a street address, drops punctuation and abbreviates common
+// words, so "12 Main Street." and "12 main st" match
+func normalizeStreet(street string) string {
+	words := strings.FieldsFunc(strings.ToLower(street), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		if short, ok := addressWords[w]; ok {
+			words[i] = short
+		}
+	}
+	return strings.Join(words, " ")
+}
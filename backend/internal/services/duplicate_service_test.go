@@ -0,0 +1,202 @@
+package services
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// candidate builds a person for duplicate detection
+func candidate(first, last string, emails, phones []string, addresses ...models.DuplicateAddress) *models.DuplicatePerson {
+	p := &models.DuplicatePerson{Emails: emails, Phones: phones, Addresses: addresses}
+	if first != "" {
+		p.FirstName = &first
+	}
+	if last != "" {
+		p.LastName = &last
+	}
+	return p
+}
+
+func TestNormalizeDuplicate(t *testing.T) {
+	tests := []struct {
+		name   string
+		person *models.DuplicatePerson
+		want   duplicateKeys
+	}{
+		{
+			name:   "names drop case and punctuation",
+			person: candidate(" Mary-Ann ", "O'Brien", nil, nil),
+			want:   duplicateKeys{first: "maryann", canonical: "maryann", last: "obrien"},
+		},
+		{
+			name:   "nicknames expand",
+			person: candidate("Bill", "Smith", nil, nil),
+			want:   duplicateKeys{first: "bill", canonical: "william", last: "smith"},
+		},
+		{
+			name:   "no name",
+			person: candidate("", "", nil, nil),
+			want:   duplicateKeys{},
+		},
+		{
+			name:   "emails ignore case and blanks",
+			person: candidate("", "", []string{" Ada@Example.COM ", "", "ada@example.com"}, nil),
+			want:   duplicateKeys{emails: map[string]bool{"ada@example.com": true}},
+		},
+		{
+			name:   "phones keep digits and drop a US country code",
+			person: candidate("", "", nil, []string{"+1 (555) 010-0123", "555.010.0123", "44 20 7946 0000", "x123"}),
+			want:   duplicateKeys{phones: map[string]bool{"5550100123": true, "442079460000": true}},
+		},
+		{
+			name: "addresses abbreviate and fall back to city without a zip",
+			person: candidate("", "", nil, nil,
+				models.DuplicateAddress{Addr1: "12 Main Street.", Zip: " 02134 "},
+				models.DuplicateAddress{Addr1: "1 North Avenue, Suite 5", City: "St. Louis"},
+				models.DuplicateAddress{Addr1: " ", City: "Nowhere"}),
+			want: duplicateKeys{addresses: map[string]bool{"12 main st|02134": true, "1 n ave ste 5|stlouis": true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, set := range []*map[string]bool{&tt.want.emails, &tt.want.phones, &tt.want.addresses} {
+				if *set == nil {
+					*set = map[string]bool{}
+				}
+			}
+			if got := normalizeDuplicate(tt.person); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeDuplicate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreDuplicate(t *testing.T) {
+	home := models.DuplicateAddress{Addr1: "12 Main Street", Zip: "02134"}
+
+	tests := []struct {
+		name        string
+		a, b        *models.DuplicatePerson
+		wantScore   int
+		wantReasons []string
+	}{
+		{
+			name:        "same name",
+			a:           candidate("Ada", "Lovelace", nil, nil),
+			b:           candidate("ada", "LOVELACE", nil, nil),
+			wantScore:   nameScore,
+			wantReasons: []string{models.MatchName},
+		},
+		{
+			name:        "nickname",
+			a:           candidate("Bill", "Gates", nil, nil),
+			b:           candidate("William", "Gates", nil, nil),
+			wantScore:   nicknameScore,
+			wantReasons: []string{models.MatchNickname},
+		},
+		{
+			name:        "two nicknames of the same name",
+			a:           candidate("Bill", "Gates", nil, nil),
+			b:           candidate("Will", "Gates", nil, nil),
+			wantScore:   nicknameScore,
+			wantReasons: []string{models.MatchNickname},
+		},
+		{
+			name:        "different first name",
+			a:           candidate("Ada", "Lovelace", nil, nil),
+			b:           candidate("Byron", "Lovelace", nil, nil),
+			wantScore:   0,
+			wantReasons: []string{},
+		},
+		{
+			name:        "last name alone",
+			a:           candidate("", "Lovelace", nil, nil),
+			b:           candidate("", "Lovelace", nil, nil),
+			wantScore:   0,
+			wantReasons: []string{},
+		},
+		{
+			name:        "email",
+			a:           candidate("Ada", "Lovelace", []string{"ada@example.com"}, nil),
+			b:           candidate("Augusta", "King", []string{"ADA@example.com", "other@example.com"}, nil),
+			wantScore:   emailScore,
+			wantReasons: []string{models.MatchEmail},
+		},
+		{
+			name:        "phone",
+			a:           candidate("", "", nil, []string{"(555) 010-0123"}),
+			b:           candidate("", "", nil, []string{"1-555-010-0123"}),
+			wantScore:   phoneScore,
+			wantReasons: []string{models.MatchPhone},
+		},
+		{
+			name:        "address",
+			a:           candidate("", "", nil, nil, home),
+			b:           candidate("", "", nil, nil, models.DuplicateAddress{Addr1: "12 main st", Zip: "02134"}),
+			wantScore:   addressScore,
+			wantReasons: []string{models.MatchAddress},
+		},
+		{
+			name:        "same street elsewhere",
+			a:           candidate("", "", nil, nil, home),
+			b:           candidate("", "", nil, nil, models.DuplicateAddress{Addr1: "12 Main Street", Zip: "90210"}),
+			wantScore:   0,
+			wantReasons: []string{},
+		},
+		{
+			name:        "everything is capped at 100",
+			a:           candidate("Ada", "Lovelace", []string{"ada@example.com"}, []string{"555 010 0123"}, home),
+			b:           candidate("Ada", "Lovelace", []string{"ada@example.com"}, []string{"5550100123"}, home),
+			wantScore:   100,
+			wantReasons: []string{models.MatchName, models.MatchEmail, models.MatchPhone, models.MatchAddress},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := normalizeDuplicate(tt.a), normalizeDuplicate(tt.b)
+			for _, order := range [][2]duplicateKeys{{a, b}, {b, a}} {
+				score, reasons := scoreDuplicate(order[0], order[1])
+				if score != tt.wantScore || !reflect.DeepEqual(reasons, tt.wantReasons) {
+					t.Errorf("scoreDuplicate() = %d %v, want %d %v", score, reasons, tt.wantScore, tt.wantReasons)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockKeysGroupNicknames(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      *models.DuplicatePerson
+		wantBlock bool
+	}{
+		{name: "nickname and full name", a: candidate("Bob", "Smith", nil, nil), b: candidate("Robert", "Smith", nil, nil), wantBlock: true},
+		{name: "two nicknames", a: candidate("Bob", "Smith", nil, nil), b: candidate("Rob", "smith", nil, nil), wantBlock: true},
+		{name: "different people", a: candidate("Bob", "Smith", nil, nil), b: candidate("Richard", "Smith", nil, nil), wantBlock: false},
+		{name: "first name only", a: candidate("Bob", "", nil, nil), b: candidate("Robert", "", nil, nil), wantBlock: false},
+		{name: "shared phone", a: candidate("Bob", "", nil, []string{"555 010 0123"}), b: candidate("", "Jones", nil, []string{"+1 555 010 0123"}), wantBlock: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := normalizeDuplicate(tt.a).blockKeys()
+			b := normalizeDuplicate(tt.b).blockKeys()
+			sort.Strings(a)
+			sort.Strings(b)
+			shared := false
+			for _, key := range a {
+				if i := sort.SearchStrings(b, key); i < len(b) && b[i] == key {
+					shared = true
+				}
+			}
+			if shared != tt.wantBlock {
+				t.Errorf("%v and %v share a block = %v, want %v", a, b, shared, tt.wantBlock)
+			}
+		})
+	}
+}
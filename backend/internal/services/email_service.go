@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"log"
+	"time"
 )
 
 // EmailService handles email sending
@@ -50,6 +51,30 @@ func (s *EmailService) SendPasswordResetEmail(to, resetToken string) error {
 	return nil
 }
 
+// SendEmailChangeConfirmation sends a link confirming a new email address
+func (s *EmailService) SendEmailChangeConfirmation(to, confirmationToken string) error {
+	if s.devMode {
+		log.Printf("[DEV MODE] Would send email change confirmation to %s with token: %s", to, confirmationToken)
+		return nil
+	}
+
+	// TODO: Implement AWS SES integration
+	log.Printf("Sending email change confirmation to %s", to)
+	return nil
+}
+
+// SendAccountLockedEmail tells a user their account was locked after repeated failed logins
+func (s *EmailService) SendAccountLockedEmail(to string, lockedUntil time.Time) error {
+	if s.devMode {
+		log.Printf("[DEV MODE] Would send account locked email to %s (locked until %s)", to, lockedUntil.Format(time.RFC3339))
+		return nil
+	}
+
+	// TODO: Implement AWS SES integration
+	log.Printf("Sending account locked email to %s", to)
+	return nil
+}
+
 // SendWelcomeEmail sends a welcome email to new users
 func (s *EmailService) SendWelcomeEmail(to, name string) error {
 	if s.devMode {
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// maxBackoffShift keeps the backoff doubling from overflowing before it is capped
+const maxBackoffShift = 30
+
+// LoginThrottlePolicy holds the limits applied to failed logins
+type LoginThrottlePolicy struct {
+	Window           time.Duration // Failures older than this are forgotten
+	FreeAttempts     int           // Failures per account before backoff starts
+	IPFreeAttempts   int           // Failures per client IP before backoff starts
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	LockoutThreshold int // Failures per account that lock it; 0 disables lockout
+	LockoutDuration  time.Duration
+}
+
+// LoginBlock describes why logins are currently refused
+type LoginBlock struct {
+	Until  time.Time
+	Locked bool // The account is locked out, rather than waiting out a backoff delay
+}
+
+// RetryAfter returns how long until logins are accepted again
+func (b *LoginBlock) RetryAfter() time.Duration {
+	return time.Until(b.Until)
+}
+
+// LoginThrottleService tracks failed logins per account and per client IP.
+// After a few free attempts each failure doubles the wait before the next try,
+// and enough failures on one account lock it for LockoutDuration.
+type LoginThrottleService struct {
+	repo   *database.LoginThrottleRepository
+	policy LoginThrottlePolicy
+}
+
+// NewLoginThrottleService creates a new login throttle service
+func NewLoginThrottleService(repo *database.LoginThrottleRepository, policy LoginThrottlePolicy) *LoginThrottleService {
+	return &LoginThrottleService{repo: repo, policy: policy}
+}
+
+// accountKey is the throttle key for an account
+func accountKey(accountID int) string {
+	return strconv.Itoa(accountID)
+}
+
+// Blocked returns the block in force for a client IP or account, or nil if logins are allowed.
+// Pass accountID 0 when the account name is unknown to check the IP only.
+func (s *LoginThrottleService) Blocked(ctx context.Context, accountID int, ip string) (*LoginBlock, error) {
+	if accountID != 0 {
+		block, err := s.activeBlock(ctx, models.ThrottleScopeAccount, accountKey(accountID))
+		if err != nil || block != nil {
+			return block, err
+		}
+	}
+	return s.activeBlock(ctx, models.ThrottleScopeIP, ip)
+}
+
+// activeBlock returns the unexpired block on one account or IP, if any
+func (s *LoginThrottleService) activeBlock(ctx context.Context, scope, key string) (*LoginBlock, error) {
+	t, err := s.repo.Find(ctx, scope, key)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	if t.BlockedUntil == nil || !t.BlockedUntil.After(time.Now()) {
+		return nil, nil
+	}
+	return &LoginBlock{Until: *t.BlockedUntil, Locked: t.Locked}, nil
+}
+
+// RecordFailure counts a failed login against the client IP and, if known, the account,
+// and applies backoff or lockout. Returns the lockout if this failure locked the account.
+func (s *LoginThrottleService) RecordFailure(ctx context.Context, accountID int, ip string) (*LoginBlock, error) {
+	if err := s.repo.Prune(ctx, s.policy.Window); err != nil {
+		log.Printf("Failed to prune login throttle records: %v", err)
+	}
+
+	ipFailures, err := s.repo.RecordFailure(ctx, models.ThrottleScopeIP, ip, s.policy.Window)
+	if err != nil {
+		return nil, err
+	}
+	if delay := s.backoff(ipFailures, s.policy.IPFreeAttempts); delay > 0 {
+		if err := s.repo.Block(ctx, models.ThrottleScopeIP, ip, time.Now().Add(delay), false); err != nil {
+			return nil, err
+		}
+	}
+
+	if accountID == 0 {
+		return nil, nil
+	}
+
+	key := accountKey(accountID)
+	failures, err := s.repo.RecordFailure(ctx, models.ThrottleScopeAccount, key, s.policy.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.policy.LockoutThreshold > 0 && failures >= s.policy.LockoutThreshold {
+		lock := &LoginBlock{Until: time.Now().Add(s.policy.LockoutDuration), Locked: true}
+		if err := s.repo.Block(ctx, models.ThrottleScopeAccount, key, lock.Until, true); err != nil {
+			return nil, err
+		}
+		return lock, nil
+	}
+
+	if delay := s.backoff(failures, s.policy.FreeAttempts); delay > 0 {
+		if err := s.repo.Block(ctx, models.ThrottleScopeAccount, key, time.Now().Add(delay), false); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// backoff returns the wait imposed after the given number of failures
+func (s *LoginThrottleService) backoff(failures, freeAttempts int) time.Duration {
+	if failures <= freeAttempts || s.policy.BackoffBase <= 0 {
+		return 0
+	}
+	shift := failures - freeAttempts - 1
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+	delay := s.policy.BackoffBase << shift
+	if delay > s.policy.BackoffMax {
+		delay = s.policy.BackoffMax
+	}
+	return delay
+}
+
+// RecordSuccess clears an account's failed logins after it signs in
+func (s *LoginThrottleService) RecordSuccess(ctx context.Context, accountID int) error {
+	_, err := s.repo.Reset(ctx, models.ThrottleScopeAccount, accountKey(accountID))
+	return err
+}
+
+// Status returns an account's failed login record, or nil if it has none
+func (s *LoginThrottleService) Status(ctx context.Context, accountID int) (*models.LoginThrottle, error) {
+	return s.repo.Find(ctx, models.ThrottleScopeAccount, accountKey(accountID))
+}
+
+// Unlock clears an account's lockout and failed logins.
+// Returns false if the account had no failed logins recorded.
+func (s *LoginThrottleService) Unlock(ctx context.Context, accountID int) (bool, error) {
+	return s.repo.Reset(ctx, models.ThrottleScopeAccount, accountKey(accountID))
+}
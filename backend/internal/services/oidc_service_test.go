@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/testutil"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+const testClientID = "crm-test"
+
+// newTestOIDCService returns a service configured for a mock provider
+func newTestOIDCService(t *testing.T) (*OIDCService, *testutil.MockIdP) {
+	t.Helper()
+	idp := testutil.NewMockIdP(t, testClientID)
+	svc := NewOIDCService(OIDCClientConfig{
+		IssuerURL:   idp.Issuer(),
+		ClientID:    testClientID,
+		RedirectURL: "http://crm.test/api/v1/auth/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	}, nil)
+	return svc, idp
+}
+
+// authorize builds the authorization URL and returns the provider's code for it
+func authorize(t *testing.T, svc *OIDCService, idp *testutil.MockIdP, nonce, verifier string, claims jwt.MapClaims) string {
+	t.Helper()
+	authURL, err := svc.AuthCodeURL(context.Background(), "state-1", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, state := idp.Authorize(t, authURL, claims)
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+	return code
+}
+
+func TestOIDCAuthCodeURLCarriesPKCEStateAndNonce(t *testing.T) {
+	svc, _ := newTestOIDCService(t)
+
+	authURL, err := svc.AuthCodeURL(context.Background(), "the-state", "the-nonce", "the-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+
+	want := map[string]string{
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        utils.PKCEChallenge("the-verifier"),
+		"code_challenge_method": "S256",
+		"client_id":             testClientID,
+		"response_type":         "code",
+	}
+	for name, value := range want {
+		if got := q.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if q.Get("code_verifier") != "" {
+		t.Error("the code verifier must not be sent to the authorization endpoint")
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	svc, idp := newTestOIDCService(t)
+
+	code := authorize(t, svc, idp, "nonce-1", "verifier-1", jwt.MapClaims{
+		"sub":            "subject-1",
+		"email":          "ada@example.com",
+		"email_verified": "true",
+		"given_name":     "Ada",
+		"family_name":    "Lovelace",
+	})
+	claims, err := svc.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	if claims.Issuer != idp.Issuer() || claims.Subject != "subject-1" {
+		t.Errorf("identity = %s %s, want %s subject-1", claims.Issuer, claims.Subject, idp.Issuer())
+	}
+	if claims.Email != "ada@example.com" || !claims.EmailVerified {
+		t.Errorf("email = %q verified=%v, want ada@example.com verified", claims.Email, claims.EmailVerified)
+	}
+	if claims.GivenName != "Ada" || claims.FamilyName != "Lovelace" {
+		t.Errorf("name = %q %q, want Ada Lovelace", claims.GivenName, claims.FamilyName)
+	}
+
+	// Codes are single use
+	if _, err := svc.Exchange(context.Background(), code, "verifier-1", "nonce-1"); err == nil {
+		t.Error("redeeming a code twice succeeded")
+	}
+}
+
+func TestOIDCExchangeRejectsWrongPKCEVerifier(t *testing.T) {
+	svc, idp := newTestOIDCService(t)
+
+	code := authorize(t, svc, idp, "nonce-1", "verifier-1", jwt.MapClaims{"sub": "subject-1"})
+	_, err := svc.Exchange(context.Background(), code, "another-verifier", "nonce-1")
+	if err == nil {
+		t.Fatal("Exchange succeeded with the wrong code verifier")
+	}
+	if errors.Is(err, ErrOIDCInvalidToken) {
+		t.Errorf("a refused code is a provider error, not an invalid token: %v", err)
+	}
+}
+
+func TestOIDCExchangeRejectsInvalidIDTokens(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string // nonce the login was started with; defaults to the one sent
+	}{
+		{name: "wrong audience", claims: jwt.MapClaims{"aud": "another-client"}},
+		{name: "wrong issuer", claims: jwt.MapClaims{"iss": "https://idp.evil.example"}},
+		{name: "expired", claims: jwt.MapClaims{"iat": past.Add(-time.Hour).Unix(), "exp": past.Unix()}},
+		{name: "no expiry", claims: jwt.MapClaims{"exp": nil}},
+		{name: "wrong nonce", claims: jwt.MapClaims{"nonce": "replayed-nonce"}},
+		{name: "no nonce", claims: jwt.MapClaims{"nonce": nil}},
+		{name: "nonce of another login", nonce: "nonce-of-another-login"},
+		{name: "no subject", claims: jwt.MapClaims{"sub": nil}},
+		{name: "several audiences without azp", claims: jwt.MapClaims{"aud": []string{testClientID, "another-client"}}},
+		{name: "azp naming another client", claims: jwt.MapClaims{"aud": []string{testClientID, "another-client"}, "azp": "another-client"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, idp := newTestOIDCService(t)
+
+			claims := jwt.MapClaims{"sub": "subject-1"}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+			code := authorize(t, svc, idp, "nonce-1", "verifier-1", claims)
+
+			nonce := "nonce-1"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			_, err := svc.Exchange(context.Background(), code, "verifier-1", nonce)
+			if !errors.Is(err, ErrOIDCInvalidToken) {
+				t.Errorf("Exchange error = %v, want ErrOIDCInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeAcceptsAuthorizedParty(t *testing.T) {
+	svc, idp := newTestOIDCService(t)
+
+	code := authorize(t, svc, idp, "nonce-1", "verifier-1", jwt.MapClaims{
+		"sub": "subject-1",
+		"aud": []string{testClientID, "another-client"},
+		"azp": testClientID,
+	})
+	if _, err := svc.Exchange(context.Background(), code, "verifier-1", "nonce-1"); err != nil {
+		t.Errorf("Exchange: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+)
+
+// PrivilegeService caches each account's effective privileges so AuthMiddleware
+// does not query the role tables on every request. Entries expire after ttl so
+// role changes made on other server instances are picked up.
+type PrivilegeService struct {
+	repo *database.RoleRepository
+	ttl  time.Duration
+
+	mu      sync.RWMutex
+	entries map[int]privilegeEntry // sec_accounts_id -> privileges
+}
+
+type privilegeEntry struct {
+	privileges map[string]bool
+	loadedAt   time.Time
+}
+
+// NewPrivilegeService creates a new privilege service
+func NewPrivilegeService(repo *database.RoleRepository, ttl time.Duration) *PrivilegeService {
+	return &PrivilegeService{
+		repo:    repo,
+		ttl:     ttl,
+		entries: make(map[int]privilegeEntry),
+	}
+}
+
+// Privileges returns the set of privilege names an account holds through its roles
+func (s *PrivilegeService) Privileges(ctx context.Context, accountID int) (map[string]bool, error) {
+	s.mu.RLock()
+	entry, ok := s.entries[accountID]
+	s.mu.RUnlock()
+	if ok && time.Since(entry.loadedAt) < s.ttl {
+		return entry.privileges, nil
+	}
+
+	names, err := s.repo.ListAccountPrivileges(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	privileges := make(map[string]bool, len(names))
+	for _, name := range names {
+		privileges[name] = true
+	}
+
+	s.mu.Lock()
+	s.entries[accountID] = privilegeEntry{privileges: privileges, loadedAt: time.Now()}
+	s.mu.Unlock()
+
+	return privileges, nil
+}
+
+// Invalidate drops the cached privileges of one account
+func (s *PrivilegeService) Invalidate(accountID int) {
+	s.mu.Lock()
+	delete(s.entries, accountID)
+	s.mu.Unlock()
+}
+
+// InvalidateAll drops every cached entry, e.g. after a role's privileges change
+func (s *PrivilegeService) InvalidateAll() {
+	s.mu.Lock()
+	s.entries = make(map[int]privilegeEntry)
+	s.mu.Unlock()
+}
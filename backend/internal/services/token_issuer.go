@@ -0,0 +1,74 @@
+package services
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// TokenIssuer issues and verifies the JWT access tokens used by every login path
+type TokenIssuer struct {
+	keys *utils.JWTKeySet
+	ttl  time.Duration
+}
+
+// NewTokenIssuer creates a new token issuer
+func NewTokenIssuer(keys *utils.JWTKeySet, ttl time.Duration) *TokenIssuer {
+	return &TokenIssuer{keys: keys, ttl: ttl}
+}
+
+// IssueAccessToken signs a short-lived access token for an account's session
+func (s *TokenIssuer) IssueAccessToken(account *models.SecAccount, sessionID string) (string, error) {
+	now := time.Now()
+	claims := &utils.Claims{
+		UserID:      account.UserID,
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		SessionID:   sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		},
+	}
+	return s.keys.Sign(claims)
+}
+
+// IssueImpersonationToken signs an access token that acts as the target account on behalf
+// of the actor. It belongs to no session, so it cannot be refreshed and lapses after ttl.
+func (s *TokenIssuer) IssueImpersonationToken(target, actor *models.SecAccount, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := &utils.Claims{
+		UserID:      target.UserID,
+		AccountID:   target.ID,
+		AccountName: target.Name,
+		Act: &utils.Actor{
+			UserID:      actor.UserID,
+			AccountID:   actor.ID,
+			AccountName: actor.Name,
+		},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := s.keys.Sign(claims)
+	return token, expiresAt, err
+}
+
+// ParseAccessToken verifies an access token and returns its claims
+func (s *TokenIssuer) ParseAccessToken(token string) (*utils.Claims, error) {
+	return s.keys.Parse(token)
+}
+
+// JWKS returns the public signing keys as a JSON Web Key Set
+func (s *TokenIssuer) JWKS() map[string]interface{} {
+	return s.keys.JWKS()
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+)
+
+// TokenRevocationService keeps an in-memory copy of the access-token revocation
+// list so AuthMiddleware can check every request without a database round trip.
+// Postgres is the source of truth; the cache is reloaded periodically so
+// revocations made by other server instances are picked up.
+type TokenRevocationService struct {
+	repo *database.RevocationRepository
+
+	mu              sync.RWMutex
+	revokedJTIs     map[string]time.Time // jti -> token expiry
+	revokedSessions map[string]time.Time // session (refresh token family) -> expiry of its last access token
+	userCutoffs     map[int]time.Time    // sec_users_id -> tokens issued at or before this are invalid
+}
+
+// NewTokenRevocationService creates a new token revocation service
+func NewTokenRevocationService(repo *database.RevocationRepository) *TokenRevocationService {
+	return &TokenRevocationService{
+		repo:            repo,
+		revokedJTIs:     make(map[string]time.Time),
+		revokedSessions: make(map[string]time.Time),
+		userCutoffs:     make(map[int]time.Time),
+	}
+}
+
+// Load replaces the cache with the current contents of the database
+func (s *TokenRevocationService) Load(ctx context.Context) error {
+	revoked, err := s.repo.ListRevokedAccessTokens(ctx)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.repo.ListRevokedSessions(ctx)
+	if err != nil {
+		return err
+	}
+	cutoffs, err := s.repo.ListTokenCutoffs(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revokedJTIs = revoked
+	s.revokedSessions = sessions
+	s.userCutoffs = cutoffs
+	s.mu.Unlock()
+
+	return nil
+}
+
+// StartSync reloads the cache and prunes expired entries every interval until ctx is cancelled
+func (s *TokenRevocationService) StartSync(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.repo.PruneRevokedAccessTokens(ctx); err != nil {
+					log.Printf("Failed to prune revoked access tokens: %v", err)
+				}
+				if err := s.repo.PruneRevokedSessions(ctx); err != nil {
+					log.Printf("Failed to prune revoked sessions: %v", err)
+				}
+				if err := s.Load(ctx); err != nil {
+					log.Printf("Failed to reload token revocation list: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// RevokeToken revokes a single access token until it expires
+func (s *TokenRevocationService) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	if err := s.repo.RevokeAccessToken(ctx, jti, userID, expiresAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revokedJTIs[jti] = expiresAt
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RevokeSession revokes every access token issued to a session. expiresAt is
+// when the last of them expires.
+func (s *TokenRevocationService) RevokeSession(ctx context.Context, sessionID string, userID int, expiresAt time.Time) error {
+	if err := s.repo.RevokeSession(ctx, sessionID, userID, expiresAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revokedSessions[sessionID] = expiresAt
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RevokeAllForUser revokes every access token issued to a user up to now. Token issue
+// times only have whole seconds, so tokens issued later in the same second are revoked
+// too; to keep one session, revoke the others with RevokeSession instead.
+func (s *TokenRevocationService) RevokeAllForUser(ctx context.Context, userID int) error {
+	cutoff := time.Now().Truncate(time.Microsecond)
+	if err := s.repo.SetTokensValidAfter(ctx, userID, cutoff); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.userCutoffs[userID] = cutoff
+	s.mu.Unlock()
+
+	return nil
+}
+
+// IsRevoked reports whether an access token has been revoked
+func (s *TokenRevocationService) IsRevoked(jti, sessionID string, userID int, issuedAt time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.revokedJTIs[jti]; ok {
+		return true
+	}
+	if _, ok := s.revokedSessions[sessionID]; sessionID != "" && ok {
+		return true
+	}
+	if cutoff, ok := s.userCutoffs[userID]; ok && !issuedAt.After(cutoff) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+// Package testutil holds helpers shared by the tests of several packages: a PostgreSQL
+// database for repository and handler tests, test users and a mock OpenID Connect provider.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/davealexenglish/magnifimind-crm/internal/database"
+	"github.com/davealexenglish/magnifimind-crm/internal/models"
+)
+
+// DatabaseURLEnv names the database the tests use. Tests that need one are skipped when
+// it is not set. The database may be empty; it is given the schema on first use.
+const DatabaseURLEnv = "TEST_DATABASE_URL"
+
+// Password is the password of every account made by CreateUser
+const Password = "test-password"
+
+// setupLockKey serializes schema setup between test binaries sharing a database
+const setupLockKey = 7311001
+
+var (
+	setupOnce sync.Once
+	sharedDB  *database.DB
+	setupErr  error
+)
+
+// DB returns a connection to the test database, with the schema applied and the default
+// roles seeded, or skips the test if TEST_DATABASE_URL is not set
+func DB(t testing.TB) *database.DB {
+	t.Helper()
+
+	dsn := os.Getenv(DatabaseURLEnv)
+	if dsn == "" {
+		t.Skip(DatabaseURLEnv + " is not set")
+	}
+
+	setupOnce.Do(func() {
+		sharedDB, setupErr = open(dsn)
+	})
+	if setupErr != nil {
+		t.Fatalf("Failed to set up test database: %v", setupErr)
+	}
+	return sharedDB
+}
+
+// open connects to the database and brings its schema up to date
+func open(dsn string) (*database.DB, error) {
+	db, err := database.NewDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+
+	// Packages are tested in parallel; only one may create the schema
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, setupLockKey); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, setupLockKey)
+
+	var initialized bool
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass('public.sec_users') IS NOT NULL`).Scan(&initialized); err != nil {
+		return nil, err
+	}
+	if !initialized {
+		script, err := os.ReadFile(filepath.Join(repoRoot(), "db", "init_magnifimind_crm.sql"))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := db.ExecContext(ctx, string(script)); err != nil {
+			return nil, fmt.Errorf("failed to load the base schema: %w", err)
+		}
+	}
+
+	if err := db.EnsureSchema(ctx); err != nil {
+		return nil, err
+	}
+	if err := database.NewRoleRepository(db).SeedDefaults(ctx); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// repoRoot returns the repository's top directory
+func repoRoot() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..")
+}
+
+// Unique returns prefix with a random suffix, for names that must not clash between
+// test runs sharing a database
+func Unique(prefix string) string {
+	return prefix + "-" + strings.ReplaceAll(uuid.New().String(), "-", "")[:10]
+}
+
+// CreateUser adds a user with one account (named after name, made unique), an email
+// address at example.com, the default role and a personal organization
+func CreateUser(t testing.TB, db *database.DB, name string) *models.SecAccount {
+	t.Helper()
+	ctx := context.Background()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accountName := Unique(name)
+	email := accountName + "@example.com"
+	user := &models.SecUser{FirstName: name, LastName: "Test", Email: &email}
+	account := &models.SecAccount{Name: accountName, Password: string(hash)}
+	if err := database.NewUserRepository(db).Create(ctx, user, account); err != nil {
+		t.Fatalf("Failed to create user %s: %v", accountName, err)
+	}
+	if _, err := database.NewOrgRepository(db).EnsurePersonalOrg(ctx, user.ID); err != nil {
+		t.Fatalf("Failed to create personal organization of %s: %v", accountName, err)
+	}
+	return account
+}
+
+// PersonalOrg returns the ID of a user's personal organization
+func PersonalOrg(t testing.TB, db *database.DB, userID int) int {
+	t.Helper()
+	orgID, err := database.NewOrgRepository(db).EnsurePersonalOrg(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return orgID
+}
+
+// Authenticate stands in for AuthMiddleware, signing every request in as the account
+func Authenticate(account *models.SecAccount) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", account.UserID)
+		c.Set("account_id", account.ID)
+		c.Set("account_name", account.Name)
+		c.Next()
+	}
+}
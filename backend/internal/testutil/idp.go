@@ -0,0 +1,203 @@
+package testutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/davealexenglish/magnifimind-crm/pkg/utils"
+)
+
+// mockIdPKeyID is the kid of the mock provider's signing key
+const mockIdPKeyID = "mock-idp"
+
+// MockIdP is a local OpenID Connect provider. It serves a discovery document, a JWKS and
+// a token endpoint that redeems the codes handed out by Authorize once, after checking
+// the PKCE verifier against the challenge.
+type MockIdP struct {
+	Server   *httptest.Server
+	ClientID string
+
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	grants map[string]mockGrant // code -> grant
+}
+
+// mockGrant is an authorization code waiting to be redeemed
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+// NewMockIdP starts a provider for a client; it is stopped when the test ends
+func NewMockIdP(t testing.TB, clientID string) *MockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &MockIdP{ClientID: clientID, key: key, grants: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.serveDiscovery)
+	mux.HandleFunc("/jwks", p.serveJWKS)
+	mux.HandleFunc("/token", p.serveToken)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Server.Close)
+
+	return p
+}
+
+// Issuer returns the provider's issuer URL
+func (p *MockIdP) Issuer() string {
+	return p.Server.URL
+}
+
+// Authorize stands in for the browser and the provider's login page. It checks the
+// authorization URL a client built and returns the code and state the provider would
+// redirect back with. The ID token gets valid iss, aud, iat, exp and nonce claims unless
+// claims overrides them; a claim set to nil is left out.
+func (p *MockIdP) Authorize(t testing.TB, authURL string, claims jwt.MapClaims) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Invalid authorization URL %q: %v", authURL, err)
+	}
+	if !strings.HasPrefix(authURL, p.Issuer()+"/authorize?") {
+		t.Fatalf("Authorization URL %q does not use the provider's endpoint", authURL)
+	}
+	q := u.Query()
+	for name, want := range map[string]string{"response_type": "code", "client_id": p.ClientID, "code_challenge_method": "S256"} {
+		if got := q.Get(name); got != want {
+			t.Fatalf("Authorization URL has %s=%q, want %q", name, got, want)
+		}
+	}
+	for _, name := range []string{"redirect_uri", "state", "nonce", "code_challenge"} {
+		if q.Get(name) == "" {
+			t.Fatalf("Authorization URL has no %s", name)
+		}
+	}
+	if !strings.Contains(" "+q.Get("scope")+" ", " openid ") {
+		t.Fatalf("Authorization URL scope %q does not include openid", q.Get("scope"))
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   p.Issuer(),
+		"aud":   p.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(idClaims, name)
+			continue
+		}
+		idClaims[name] = value
+	}
+
+	code, err = utils.GenerateSecureToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.grants[code] = mockGrant{challenge: q.Get("code_challenge"), claims: idClaims}
+	p.mu.Unlock()
+
+	return code, q.Get("state")
+}
+
+// serveDiscovery serves the discovery document
+func (p *MockIdP) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.Issuer(),
+		"authorization_endpoint": p.Issuer() + "/authorize",
+		"token_endpoint":         p.Issuer() + "/token",
+		"jwks_uri":               p.Issuer() + "/jwks",
+	})
+}
+
+// serveJWKS serves the public half of the signing key
+func (p *MockIdP) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": mockIdPKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// serveToken redeems an authorization code for a signed ID token
+func (p *MockIdP) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID := r.PostForm.Get("client_id")
+	if user, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(user)
+	}
+	if clientID != p.ClientID {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown or used code"})
+		return
+	}
+	if utils.PKCEChallenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	token.Header["kid"] = mockIdPKeyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +14,8 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Auth     AuthConfig
+	OIDC     OIDCConfig
 	AWS      AWSConfig
 }
 
@@ -32,10 +36,61 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+// DefaultJWTSecret is the placeholder JWT secret used when JWT_SECRET is not set.
+// The server refuses to start with it in release mode.
+const DefaultJWTSecret = "your-secret-key-change-this-in-production"
+
 // JWTConfig holds JWT-related configuration
 type JWTConfig struct {
-	Secret          string
-	ExpirationHours int
+	Secret               string // HS256 only
+	Algorithm            string // HS256, RS256 or EdDSA
+	Issuer               string
+	SigningKeyFile       string   // PEM private key that signs new tokens (RS256/EdDSA)
+	VerificationKeyFiles []string // PEM keys of retired signing keys whose tokens are still accepted
+	AccessTokenMinutes   int
+	RefreshTokenDays     int
+}
+
+// AuthConfig holds account verification and login policy configuration
+type AuthConfig struct {
+	RequireEmailVerification  bool
+	VerificationGraceHours    int // Unverified accounts may log in for this long after registration
+	VerificationTokenTTLHours int
+	VerificationResendSeconds int // Minimum time between verification emails to one user
+
+	PasswordResetTokenTTLMinutes int
+	PasswordResetResendSeconds   int // Minimum time between password reset emails to one user
+
+	MFAIssuer               string // Shown as the account issuer in authenticator apps
+	MFAChallengeTTLMinutes  int    // How long a password login waits for its second factor
+	MFAChallengeMaxAttempts int    // Wrong codes allowed before the challenge is discarded
+
+	PrivilegeCacheSeconds int    // How long an account's privileges are cached before reloading
+	BootstrapAdminAccount string // Account given the admin role at startup, if set
+	ImpersonationMinutes  int    // Lifetime of an admin's "view as user" token
+
+	LoginFailureWindowMinutes int // Failed logins older than this are forgotten
+	LoginFreeAttempts         int // Failed logins per account before backoff starts
+	LoginIPFreeAttempts       int // Failed logins per client IP before backoff starts
+	LoginBackoffBaseSeconds   int // First backoff delay; doubles with every further failure
+	LoginBackoffMaxSeconds    int
+	LoginLockoutThreshold     int // Failed logins per account that lock it (0 disables lockout)
+	LoginLockoutMinutes       int
+}
+
+// OIDCConfig holds OpenID Connect single sign-on configuration
+type OIDCConfig struct {
+	Enabled         bool
+	IssuerURL       string // Discovery document is read from <IssuerURL>/.well-known/openid-configuration
+	ClientID        string
+	ClientSecret    string // Leave empty for a public client (PKCE only)
+	RedirectURL     string // This server's /api/v1/auth/oidc/callback URL as registered with the IdP
+	Scopes          string // Space-separated; must include openid
+	AutoProvision   bool   // Create an account on first login for subjects not yet linked
+	LinkByEmail     bool   // Link a new subject to the existing user with the same verified email
+	AllowedDomains  string // Comma-separated email domains allowed to auto-provision (empty allows any)
+	PostLoginURL    string // Frontend URL that receives the tokens in its fragment; JSON response if empty
+	StateTTLMinutes int    // How long a login may take at the IdP
 }
 
 // AWSConfig holds AWS-related configuration
@@ -65,8 +120,51 @@ func Load() *Config {
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		},
 		JWT: JWTConfig{
-			Secret:          getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
-			ExpirationHours: getEnvInt("JWT_EXPIRATION_HOURS", 24),
+			Secret:               getEnv("JWT_SECRET", DefaultJWTSecret),
+			Algorithm:            getEnv("JWT_ALGORITHM", "HS256"),
+			Issuer:               getEnv("JWT_ISSUER", "magnifimind-crm"),
+			SigningKeyFile:       getEnv("JWT_SIGNING_KEY_FILE", ""),
+			VerificationKeyFiles: getEnvList("JWT_VERIFICATION_KEY_FILES"),
+			AccessTokenMinutes:   getEnvInt("JWT_ACCESS_TOKEN_MINUTES", 15),
+			RefreshTokenDays:     getEnvInt("JWT_REFRESH_TOKEN_DAYS", 30),
+		},
+		Auth: AuthConfig{
+			RequireEmailVerification:  getEnvBool("REQUIRE_EMAIL_VERIFICATION", true),
+			VerificationGraceHours:    getEnvInt("EMAIL_VERIFICATION_GRACE_HOURS", 0),
+			VerificationTokenTTLHours: getEnvInt("EMAIL_VERIFICATION_TOKEN_TTL_HOURS", 24),
+			VerificationResendSeconds: getEnvInt("EMAIL_VERIFICATION_RESEND_SECONDS", 60),
+
+			PasswordResetTokenTTLMinutes: getEnvInt("PASSWORD_RESET_TOKEN_TTL_MINUTES", 60),
+			PasswordResetResendSeconds:   getEnvInt("PASSWORD_RESET_RESEND_SECONDS", 60),
+
+			MFAIssuer:               getEnv("MFA_ISSUER", "Magnifimind CRM"),
+			MFAChallengeTTLMinutes:  getEnvInt("MFA_CHALLENGE_TTL_MINUTES", 5),
+			MFAChallengeMaxAttempts: getEnvInt("MFA_CHALLENGE_MAX_ATTEMPTS", 5),
+
+			PrivilegeCacheSeconds: getEnvInt("PRIVILEGE_CACHE_SECONDS", 60),
+			BootstrapAdminAccount: getEnv("BOOTSTRAP_ADMIN_ACCOUNT", ""),
+			ImpersonationMinutes:  getEnvInt("IMPERSONATION_TOKEN_MINUTES", 30),
+
+			LoginFailureWindowMinutes: getEnvInt("LOGIN_FAILURE_WINDOW_MINUTES", 15),
+			LoginFreeAttempts:         getEnvInt("LOGIN_FREE_ATTEMPTS", 3),
+			LoginIPFreeAttempts:       getEnvInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+			LoginBackoffBaseSeconds:   getEnvInt("LOGIN_BACKOFF_BASE_SECONDS", 1),
+			LoginBackoffMaxSeconds:    getEnvInt("LOGIN_BACKOFF_MAX_SECONDS", 300),
+			LoginLockoutThreshold:     getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+			LoginLockoutMinutes:       getEnvInt("LOGIN_LOCKOUT_MINUTES", 30),
+		},
+		OIDC: OIDCConfig{
+			Enabled:         getEnvBool("OIDC_ENABLED", false),
+			IssuerURL:       getEnv("OIDC_ISSUER_URL", ""),
+			ClientID:        getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret:    getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:     getEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/v1/auth/oidc/callback"),
+			Scopes:          getEnv("OIDC_SCOPES", "openid email profile"),
+			AutoProvision:   getEnvBool("OIDC_AUTO_PROVISION", false),
+			LinkByEmail:     getEnvBool("OIDC_LINK_BY_EMAIL", false),
+			AllowedDomains:  getEnv("OIDC_ALLOWED_DOMAINS", ""),
+			PostLoginURL:    getEnv("OIDC_POST_LOGIN_URL", ""),
+			StateTTLMinutes: getEnvInt("OIDC_STATE_TTL_MINUTES", 10),
 		},
 		AWS: AWSConfig{
 			Region:          getEnv("AWS_REGION", "us-east-1"),
@@ -89,6 +187,24 @@ func (c *Config) GetDSN() string {
 	)
 }
 
+// Validate rejects configurations that are unsafe to run. Release mode
+// (GIN_MODE=release) requires a real JWT secret or signing key.
+func (c *Config) Validate() error {
+	switch c.JWT.Algorithm {
+	case "HS256":
+		if c.Server.GinMode == "release" && (c.JWT.Secret == "" || c.JWT.Secret == DefaultJWTSecret) {
+			return fmt.Errorf("JWT_SECRET must be set to a real secret in release mode")
+		}
+	case "RS256", "EdDSA":
+		if c.Server.GinMode == "release" && c.JWT.SigningKeyFile == "" {
+			return fmt.Errorf("JWT_SIGNING_KEY_FILE is required for %s in release mode", c.JWT.Algorithm)
+		}
+	default:
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q (use HS256, RS256 or EdDSA)", c.JWT.Algorithm)
+	}
+	return nil
+}
+
 // HasAWSCredentials returns true if AWS credentials are configured
 func (c *Config) HasAWSCredentials() bool {
 	return c.AWS.AccessKeyID != "" && c.AWS.SecretAccessKey != ""
@@ -112,3 +228,24 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvList gets a comma-separated environment variable as a list, skipping empty entries
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvBool gets an environment variable as bool or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -1,11 +1,20 @@
 package utils
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
-	"time"
+	"fmt"
+	"math/big"
+	"os"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 )
 
 var (
@@ -13,55 +22,252 @@ var (
 	ErrExpiredToken = errors.New("token expired")
 )
 
-// Claims represents JWT claims
+// JWT signing algorithms supported for access tokens
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA signing keys
+const rsaKeyBits = 2048
+
+// Claims are the claims carried by every access token
 type Claims struct {
-	UserID   int    `json:"userId"`
-	Email    string `json:"email"`
-	Username string `json:"username"`
+	UserID      int    `json:"user_id"`
+	AccountID   int    `json:"account_id"`
+	AccountName string `json:"account_name"`
+	SessionID   string `json:"sid,omitempty"`
+	Act         *Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a new JWT token for a user
-func GenerateJWT(userID int, email, username, secret string, expirationHours int) (string, error) {
-	claims := &Claims{
-		UserID:   userID,
-		Email:    email,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expirationHours))),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}
+// Actor identifies who is really behind an impersonation token, in the style of the
+// RFC 8693 "act" claim
+type Actor struct {
+	UserID      int    `json:"user_id"`
+	AccountID   int    `json:"account_id"`
+	AccountName string `json:"account_name"`
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+// SigningKey is a JWT key identified by its kid. Keys loaded from a public key
+// file can only verify tokens.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	sign   interface{} // []byte, *rsa.PrivateKey or ed25519.PrivateKey; nil for verify-only keys
+	verify interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
 }
 
-// ValidateJWT validates a JWT token and returns the claims
-func ValidateJWT(tokenString, secret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
+// NewHMACKey returns an HS256 key for a shared secret. HMAC keys are never published in the JWKS.
+func NewHMACKey(secret string) *SigningKey {
+	return &SigningKey{ID: "hs256", Method: jwt.SigningMethodHS256, sign: []byte(secret), verify: []byte(secret)}
+}
+
+// GenerateSigningKey creates a new RS256 or EdDSA key pair
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	switch alg {
+	case AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
 		}
-		return []byte(secret), nil
-	})
+		return newAsymmetricKey(key, &key.PublicKey)
+	case AlgEdDSA:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return newAsymmetricKey(priv, pub)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+}
 
+// LoadSigningKey reads an RSA or Ed25519 key from a PEM file. A private key (PKCS#8 or
+// PKCS#1) can sign and verify; a public key (PKIX) can only verify.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		if time.Now().After(claims.ExpiresAt.Time) {
-			return nil, ErrExpiredToken
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return newAsymmetricKey(k, &k.PublicKey)
+		case ed25519.PrivateKey:
+			return newAsymmetricKey(k, k.Public())
+		default:
+			return nil, fmt.Errorf("%s: unsupported private key type %T", path, key)
+		}
+	case "RSA PRIVATE KEY":
+		k, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return newAsymmetricKey(k, &k.PublicKey)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
 		}
-		return claims, nil
+		return newAsymmetricKey(nil, key)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+}
+
+// newAsymmetricKey wraps a key pair, naming it by its RFC 7638 JWK thumbprint
+func newAsymmetricKey(private, public interface{}) (*SigningKey, error) {
+	k := &SigningKey{verify: public}
+	if private != nil {
+		k.sign = private
+	}
+
+	switch public.(type) {
+	case *rsa.PublicKey:
+		k.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", public)
 	}
 
-	return nil, ErrInvalidToken
+	thumbprint, err := json.Marshal(k.thumbprintMembers())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(thumbprint)
+	k.ID = base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return k, nil
+}
+
+// CanSign reports whether the key holds a private or secret part
+func (k *SigningKey) CanSign() bool {
+	return k.sign != nil
+}
+
+// thumbprintMembers returns the required JWK members, which encoding/json emits in the
+// lexicographic order RFC 7638 requires since they are map keys
+func (k *SigningKey) thumbprintMembers() map[string]string {
+	switch pub := k.verify.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	default:
+		return nil
+	}
+}
+
+// JWK returns the public key as a JSON Web Key, or nil for HMAC keys
+func (k *SigningKey) JWK() map[string]string {
+	jwk := k.thumbprintMembers()
+	if jwk == nil {
+		return nil
+	}
+	jwk["kid"] = k.ID
+	jwk["alg"] = k.Method.Alg()
+	jwk["use"] = "sig"
+	return jwk
+}
+
+// JWTKeySet signs access tokens with one active key and verifies tokens signed by any
+// key in the set, so a key can be rotated out while tokens it signed are still valid
+type JWTKeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+	issuer string
+}
+
+// NewJWTKeySet creates a key set. The active key signs new tokens; retired keys only verify.
+func NewJWTKeySet(issuer string, active *SigningKey, retired ...*SigningKey) (*JWTKeySet, error) {
+	if active == nil || !active.CanSign() {
+		return nil, errors.New("the active JWT key must be a private key")
+	}
+
+	keys := map[string]*SigningKey{active.ID: active}
+	for _, k := range retired {
+		keys[k.ID] = k
+	}
+	return &JWTKeySet{active: active, keys: keys, issuer: issuer}, nil
+}
+
+// Sign signs claims with the active key, setting the issuer and the kid header
+func (s *JWTKeySet) Sign(claims *Claims) (string, error) {
+	claims.Issuer = s.issuer
+	token := jwt.NewWithClaims(s.active.Method, claims)
+	token.Header["kid"] = s.active.ID
+	return token.SignedString(s.active.sign)
+}
+
+// Parse verifies a token's signature, issuer and expiry and returns its claims
+func (s *JWTKeySet) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		// The algorithm must be the one the key was made for
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return key.verify, nil
+	},
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrExpiredToken
+	}
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// JWKS returns the public keys of the set as a JSON Web Key Set. HMAC keys are omitted.
+func (s *JWTKeySet) JWKS() map[string]interface{} {
+	keys := make([]map[string]string, 0, len(s.keys))
+	if jwk := s.active.JWK(); jwk != nil {
+		keys = append(keys, jwk)
+	}
+	for id, k := range s.keys {
+		if id == s.active.ID {
+			continue
+		}
+		if jwk := k.JWK(); jwk != nil {
+			keys = append(keys, jwk)
+		}
+	}
+	return map[string]interface{}{"keys": keys}
 }
 
 // GenerateRefreshToken generates a random refresh token
 func GenerateRefreshToken() (string, error) {
-	return uuid.New().String(), nil
+	return GenerateSecureToken()
 }
@@ -0,0 +1,367 @@
+// Package vcard reads and writes vCard 3.0 (RFC 2426) and 4.0 (RFC 6350) contact cards.
+// It handles line folding, parameters and value escaping; what the properties mean is
+// left to the caller.
+package vcard
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Supported vCard versions
+const (
+	Version3 = "3.0"
+	Version4 = "4.0"
+)
+
+// maxLineOctets is where Encode folds long lines, as both RFCs recommend
+const maxLineOctets = 75
+
+// Param is a property parameter such as TYPE=home,pref
+type Param struct {
+	Name   string
+	Values []string
+}
+
+// Property is one content line of a card. Value is kept escaped as it appears in the
+// card; use Text or Components to read it and EscapeText or Structured to build it.
+type Property struct {
+	Group  string
+	Name   string
+	Params []Param
+	Value  string
+}
+
+// Card is a parsed or to-be-written vCard. BEGIN and END are not kept as properties.
+// Line is where a parsed card starts; Err is set when it could not be parsed.
+type Card struct {
+	Properties []*Property
+	Line       int
+	Err        error
+}
+
+// Add appends a property with an already escaped value
+func (c *Card) Add(name, value string, params ...Param) {
+	c.Properties = append(c.Properties, &Property{Name: name, Value: value, Params: params})
+}
+
+// Get returns the first property with a name, or nil
+func (c *Card) Get(name string) *Property {
+	for _, p := range c.Properties {
+		if strings.EqualFold(p.Name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// All returns every property with a name
+func (c *Card) All(name string) []*Property {
+	var props []*Property
+	for _, p := range c.Properties {
+		if strings.EqualFold(p.Name, name) {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Version returns the card's VERSION, or "" if it has none
+func (c *Card) Version() string {
+	if p := c.Get("VERSION"); p != nil {
+		return strings.TrimSpace(p.Value)
+	}
+	return ""
+}
+
+// Param returns the values of a parameter, or nil
+func (p *Property) Param(name string) []string {
+	var values []string
+	for _, param := range p.Params {
+		if strings.EqualFold(param.Name, name) {
+			values = append(values, param.Values...)
+		}
+	}
+	return values
+}
+
+// Types returns the property's TYPE values in lower case
+func (p *Property) Types() []string {
+	var types []string
+	for _, v := range p.Param("TYPE") {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+				types = append(types, t)
+			}
+		}
+	}
+	return types
+}
+
+// Text returns the property's value unescaped
+func (p *Property) Text() string {
+	return unescape(p.Value)
+}
+
+// Components splits a structured value such as N or ADR on its unescaped semicolons
+// and unescapes each component
+func (p *Property) Components() []string {
+	var parts []string
+	var sb strings.Builder
+	escaped := false
+	for _, r := range p.Value {
+		switch {
+		case escaped:
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			parts = append(parts, unescape(sb.String()))
+			sb.Reset()
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return append(parts, unescape(sb.String()))
+}
+
+// EscapeText escapes a text value for a property
+func EscapeText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`, ",", `\,`, ";", `\;`)
+	return r.Replace(s)
+}
+
+// Structured escapes the components of a structured value such as N or ADR and joins them
+func Structured(components ...string) string {
+	escaped := make([]string, len(components))
+	for i, c := range components {
+		escaped[i] = EscapeText(c)
+	}
+	return strings.Join(escaped, ";")
+}
+
+// unescape reverses EscapeText
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	escaped := false
+	for _, r := range s {
+		if escaped {
+			switch r {
+			case 'n', 'N':
+				sb.WriteRune('\n')
+			default:
+				sb.WriteRune(r)
+			}
+			escaped = false
+			continue
+		}
+		if r == '\\' {
+			escaped = true
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// Parse reads every card in r. A card that cannot be parsed is still returned, with Err
+// set, so the others can be used; the error returned is only for failing to read r.
+// Lines outside BEGIN:VCARD and END:VCARD are ignored.
+func Parse(r io.Reader) ([]*Card, error) {
+	lines, starts, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []*Card
+	var card *Card
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			if card != nil && card.Err == nil {
+				card.Err = fmt.Errorf("line %d: %w", starts[i], err)
+			}
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(prop.Name, "BEGIN") && strings.EqualFold(strings.TrimSpace(prop.Value), "VCARD"):
+			if card != nil && card.Err == nil {
+				card.Err = fmt.Errorf("line %d: card is missing END:VCARD", card.Line)
+			}
+			card = &Card{Line: starts[i]}
+			cards = append(cards, card)
+		case card == nil:
+			continue
+		case strings.EqualFold(prop.Name, "END") && strings.EqualFold(strings.TrimSpace(prop.Value), "VCARD"):
+			card = nil
+		default:
+			card.Properties = append(card.Properties, prop)
+		}
+	}
+	if card != nil && card.Err == nil {
+		card.Err = fmt.Errorf("line %d: card is missing END:VCARD", card.Line)
+	}
+
+	for _, c := range cards {
+		if c.Err != nil {
+			continue
+		}
+		if v := c.Version(); v == "" {
+			c.Err = fmt.Errorf("line %d: card has no VERSION", c.Line)
+		} else if v != Version3 && v != Version4 {
+			c.Err = fmt.Errorf("line %d: unsupported vCard version %q", c.Line, v)
+		}
+	}
+
+	return cards, nil
+}
+
+// unfold reads r as lines, joining folded continuation lines, and returns each logical
+// line with the number of the physical line it starts on
+func unfold(r io.Reader) ([]string, []int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	var starts []int
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+		starts = append(starts, n)
+	}
+	return lines, starts, scanner.Err()
+}
+
+// parseLine parses a content line: [group.]name[;param...]:value
+func parseLine(line string) (*Property, error) {
+	colon := -1
+	quoted := false
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return nil, errors.New("content line has no ':'")
+	}
+
+	head, value := line[:colon], line[colon+1:]
+	parts := splitQuoted(head, ';')
+	prop := &Property{Name: strings.TrimSpace(parts[0]), Value: value}
+	if dot := strings.LastIndex(prop.Name, "."); dot >= 0 {
+		prop.Group, prop.Name = prop.Name[:dot], prop.Name[dot+1:]
+	}
+	if prop.Name == "" {
+		return nil, errors.New("content line has no property name")
+	}
+	prop.Name = strings.ToUpper(prop.Name)
+
+	for _, part := range parts[1:] {
+		name, values, found := strings.Cut(part, "=")
+		if !found {
+			// vCard 2.1 style, e.g. TEL;WORK;VOICE:...
+			prop.Params = append(prop.Params, Param{Name: "TYPE", Values: []string{part}})
+			continue
+		}
+		param := Param{Name: strings.ToUpper(strings.TrimSpace(name))}
+		for _, v := range splitQuoted(values, ',') {
+			param.Values = append(param.Values, strings.Trim(v, `"`))
+		}
+		prop.Params = append(prop.Params, param)
+	}
+	return prop, nil
+}
+
+// splitQuoted splits s on sep outside double quotes
+func splitQuoted(s string, sep rune) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, r := range s {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == sep && !quoted {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// Encode writes cards to w with CRLF line endings, folding lines longer than 75 octets
+func Encode(w io.Writer, cards ...*Card) error {
+	bw := bufio.NewWriter(w)
+	for _, card := range cards {
+		writeFolded(bw, "BEGIN:VCARD")
+		for _, p := range card.Properties {
+			var sb strings.Builder
+			if p.Group != "" {
+				sb.WriteString(p.Group)
+				sb.WriteByte('.')
+			}
+			sb.WriteString(p.Name)
+			for _, param := range p.Params {
+				sb.WriteByte(';')
+				sb.WriteString(param.Name)
+				sb.WriteByte('=')
+				for i, v := range param.Values {
+					if i > 0 {
+						sb.WriteByte(',')
+					}
+					if strings.ContainsAny(v, ":;,") {
+						v = `"` + strings.ReplaceAll(v, `"`, "") + `"`
+					}
+					sb.WriteString(v)
+				}
+			}
+			sb.WriteByte(':')
+			sb.WriteString(p.Value)
+			writeFolded(bw, sb.String())
+		}
+		writeFolded(bw, "END:VCARD")
+	}
+	return bw.Flush()
+}
+
+// writeFolded writes a content line, folding it so no line exceeds maxLineOctets
+// without splitting a UTF-8 sequence
+func writeFolded(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
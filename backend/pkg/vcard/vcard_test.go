@@ -0,0 +1,178 @@
+package vcard
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnfold(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantLines  []string
+		wantStarts []int
+	}{
+		{
+			name:       "CRLF line endings",
+			input:      "BEGIN:VCARD\r\nFN:Ada\r\nEND:VCARD\r\n",
+			wantLines:  []string{"BEGIN:VCARD", "FN:Ada", "END:VCARD"},
+			wantStarts: []int{1, 2, 3},
+		},
+		{
+			name:       "space and tab continuations",
+			input:      "NOTE:one\r\n  two\r\n\tthree\r\nFN:Ada\r\n",
+			wantLines:  []string{"NOTE:one twothree", "FN:Ada"},
+			wantStarts: []int{1, 4},
+		},
+		{
+			name:       "fold inside a multi-byte character",
+			input:      "FN:Ren\xc3\r\n \xa9e\n",
+			wantLines:  []string{"FN:Renée"},
+			wantStarts: []int{1},
+		},
+		{
+			name:       "byte order mark",
+			input:      "\ufeffBEGIN:VCARD\n",
+			wantLines:  []string{"BEGIN:VCARD"},
+			wantStarts: []int{1},
+		},
+		{
+			name:       "continuation with nothing to continue",
+			input:      " stray\nFN:Ada\n",
+			wantLines:  []string{" stray", "FN:Ada"},
+			wantStarts: []int{1, 2},
+		},
+		{
+			name:       "blank lines are kept",
+			input:      "FN:Ada\n\nFN:Bob",
+			wantLines:  []string{"FN:Ada", "", "FN:Bob"},
+			wantStarts: []int{1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, starts, err := unfold(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("lines = %q, want %q", lines, tt.wantLines)
+			}
+			if !reflect.DeepEqual(starts, tt.wantStarts) {
+				t.Errorf("starts = %v, want %v", starts, tt.wantStarts)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *Property
+		wantErr bool
+	}{
+		{
+			name: "plain",
+			line: "fn:Ada Lovelace",
+			want: &Property{Name: "FN", Value: "Ada Lovelace"},
+		},
+		{
+			name: "group",
+			line: "item1.EMAIL;TYPE=INTERNET:ada@example.com",
+			want: &Property{Group: "item1", Name: "EMAIL", Params: []Param{{Name: "TYPE", Values: []string{"INTERNET"}}}, Value: "ada@example.com"},
+		},
+		{
+			name: "parameter lists",
+			line: "TEL;type=work,voice;PREF=1:+1 555 0100",
+			want: &Property{Name: "TEL", Params: []Param{
+				{Name: "TYPE", Values: []string{"work", "voice"}},
+				{Name: "PREF", Values: []string{"1"}},
+			}, Value: "+1 555 0100"},
+		},
+		{
+			name: "quoted parameter with separators",
+			line: `ADR;LABEL="12 Main St;Springfield, IL: USA";TYPE=home:;;12 Main St;Springfield;IL;62701;USA`,
+			want: &Property{Name: "ADR", Params: []Param{
+				{Name: "LABEL", Values: []string{"12 Main St;Springfield, IL: USA"}},
+				{Name: "TYPE", Values: []string{"home"}},
+			}, Value: ";;12 Main St;Springfield;IL;62701;USA"},
+		},
+		{
+			name: "quoted parameter values in a list",
+			line: `TEL;TYPE="work,voice",cell:555 0100`,
+			want: &Property{Name: "TEL", Params: []Param{{Name: "TYPE", Values: []string{"work,voice", "cell"}}}, Value: "555 0100"},
+		},
+		{
+			name: "vCard 2.1 style parameters",
+			line: "TEL;WORK;VOICE:555 0100",
+			want: &Property{Name: "TEL", Params: []Param{
+				{Name: "TYPE", Values: []string{"WORK"}},
+				{Name: "TYPE", Values: []string{"VOICE"}},
+			}, Value: "555 0100"},
+		},
+		{
+			name: "value with colons",
+			line: "URL:https://example.com:8443/ada",
+			want: &Property{Name: "URL", Value: "https://example.com:8443/ada"},
+		},
+		{
+			name: "empty value",
+			line: "NOTE:",
+			want: &Property{Name: "NOTE", Value: ""},
+		},
+		{name: "no colon", line: "FN Ada", wantErr: true},
+		{name: "no name", line: ":Ada", wantErr: true},
+		{name: "group without name", line: "item1.:Ada", wantErr: true},
+		{name: "colon only inside quotes", line: `X-A;P="a:b"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLine(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseLine(%q) = %+v, want an error", tt.line, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseLine(%q): %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeFoldsLongLinesThatParseBack(t *testing.T) {
+	note := strings.Repeat("Renée writes long notes. ", 10)
+	card := &Card{}
+	card.Add("VERSION", Version4)
+	card.Add("NOTE", EscapeText(note))
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, card); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+	}
+
+	cards, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 1 || cards[0].Err != nil {
+		t.Fatalf("Parse() = %+v", cards)
+	}
+	if got := cards[0].Get("NOTE").Text(); got != note {
+		t.Errorf("NOTE = %q, want %q", got, note)
+	}
+}
@@ -1,5 +1,7 @@
 -- Multi-tenant Views for Manifimind CRM
--- These views join person data to related tables and include sec_users_id for tenant filtering
+-- These views join person data to related tables and include the person's sec_orgs_id and
+-- private_flag for tenant filtering. The backend recreates them at startup; this script
+-- assumes the organization columns have already been added to pdat_person.
 
 -- View: Person Emails with Person Name and Email Type
 CREATE OR REPLACE VIEW v_person_emails AS
@@ -17,7 +19,9 @@ SELECT
     pe.create_user,
     pe.modify_date,
     pe.modify_user,
-    pe.active_flag
+    pe.active_flag,
+    p.sec_orgs_id,
+    p.private_flag
 FROM pdat_pers_emails pe
 JOIN pdat_person p ON pe.pdat_person_id = p.pdat_person_id
 JOIN pdat_email_types et ON pe.pdat_email_types_id = et.pdat_email_types_id
@@ -31,6 +35,8 @@ SELECT
     pa.addr2,
     pa.city,
     pa.cmn_states_id,
+    s.abbrev AS state,
+    s.name AS state_name,
     pa.zip,
     pa.zip_plus_4,
     pa.country,
@@ -43,9 +49,12 @@ SELECT
     pa.create_user,
     pa.modify_date,
     pa.modify_user,
-    pa.active_flag
+    pa.active_flag,
+    p.sec_orgs_id,
+    p.private_flag
 FROM pdat_address pa
 JOIN pdat_person p ON pa.pdat_person_id = p.pdat_person_id
+LEFT JOIN cmn_states s ON pa.cmn_states_id = s.cmn_states_id
 WHERE pa.active_flag = 'Y' AND p.active_flag = 'Y';
 
 -- View: Person Phones with Person Name and Phone Type
@@ -66,7 +75,9 @@ SELECT
     pp.create_user,
     pp.modify_date,
     pp.modify_user,
-    pp.active_flag
+    pp.active_flag,
+    p.sec_orgs_id,
+    p.private_flag
 FROM pdat_pers_phone pp
 JOIN pdat_person p ON pp.pdat_person_id = p.pdat_person_id
 JOIN pdat_phone_type pt ON pp.pdat_phone_type_id = pt.pdat_phone_type_id
@@ -86,7 +97,9 @@ SELECT
     pn.create_user,
     pn.modify_date,
     pn.modify_user,
-    pn.active_flag
+    pn.active_flag,
+    p.sec_orgs_id,
+    p.private_flag
 FROM pdat_pers_notes pn
 JOIN pdat_person p ON pn.pdat_person_id = p.pdat_person_id
 WHERE pn.active_flag = 'Y' AND p.active_flag = 'Y';
@@ -107,7 +120,9 @@ SELECT
     pl.create_user,
     pl.modify_date,
     pl.modify_user,
-    pl.active_flag
+    pl.active_flag,
+    p.sec_orgs_id,
+    p.private_flag
 FROM pdat_links pl
 JOIN pdat_person p ON pl.pdat_person_id = p.pdat_person_id
 WHERE pl.active_flag = 'Y' AND p.active_flag = 'Y';
@@ -126,7 +141,9 @@ SELECT
     create_user,
     modify_date,
     modify_user,
-    active_flag
+    active_flag,
+    sec_orgs_id,
+    private_flag
 FROM pdat_person
 WHERE active_flag = 'Y';
 
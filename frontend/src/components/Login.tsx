@@ -1,29 +1,87 @@
-import { useState, type FormEvent } from 'react'
+import { useEffect, useState, type FormEvent } from 'react'
 import { useNavigate } from 'react-router-dom'
 import axios, { type AxiosError } from 'axios'
 import type { LoginProps } from '../types'
 
+interface LoginResponse {
+  token: string
+  refreshToken: string
+  recoveryCodes?: string[]
+  mfaRequired?: boolean
+  mfaEnrollmentRequired?: boolean
+  mfaToken?: string
+}
+
 function Login({ onLogin }: LoginProps) {
   const [username, setUsername] = useState<string>('')
   const [password, setPassword] = useState<string>('')
   const [showPassword, setShowPassword] = useState<boolean>(false)
   const [error, setError] = useState<string>('')
+  const [mfaToken, setMfaToken] = useState<string>('')
+  const [mfaCode, setMfaCode] = useState<string>('')
+  const [useRecoveryCode, setUseRecoveryCode] = useState<boolean>(false)
+  const [enrollment, setEnrollment] = useState<{ secret: string; otpauthUri: string } | null>(null)
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([])
+  const [ssoEnabled, setSsoEnabled] = useState<boolean>(false)
   const navigate = useNavigate()
 
+  useEffect(() => {
+    // Single sign-on returns here with the tokens (or an error) in the URL fragment
+    const params = new URLSearchParams(window.location.hash.slice(1))
+    window.history.replaceState(null, '', window.location.pathname + window.location.search)
+    const token = params.get('token')
+    const refreshToken = params.get('refreshToken')
+    if (token && refreshToken) {
+      localStorage.setItem('token', token)
+      localStorage.setItem('refreshToken', refreshToken)
+      onLogin()
+      navigate('/dashboard')
+      return
+    }
+    if (params.get('error')) {
+      setError(params.get('message') || 'Single sign-on failed')
+    }
+
+    axios.get<{ enabled: boolean }>('/api/v1/auth/oidc')
+      .then((response) => setSsoEnabled(response.data.enabled))
+      .catch(() => setSsoEnabled(false))
+  }, [onLogin, navigate])
+
+  const finishLogin = (data: LoginResponse) => {
+    localStorage.setItem('token', data.token)
+    localStorage.setItem('refreshToken', data.refreshToken)
+    if (data.recoveryCodes && data.recoveryCodes.length > 0) {
+      // Show the new recovery codes once before continuing
+      setRecoveryCodes(data.recoveryCodes)
+      return
+    }
+    onLogin()
+    navigate('/dashboard')
+  }
+
   const handleSubmit = async (e: FormEvent<HTMLFormElement>) => {
     e.preventDefault()
     setError('')
 
     try {
-      const response = await axios.post<{ token: string }>('/api/v1/auth/login', {
+      const response = await axios.post<LoginResponse>('/api/v1/auth/login', {
         username,
         password
       })
 
+      if (response.data.mfaRequired && response.data.mfaToken) {
+        setMfaToken(response.data.mfaToken)
+        if (response.data.mfaEnrollmentRequired) {
+          const enroll = await axios.post<{ secret: string; otpauthUri: string }>('/api/v1/auth/login/mfa/enroll', {
+            mfaToken: response.data.mfaToken
+          })
+          setEnrollment(enroll.data)
+        }
+        return
+      }
+
       if (response.data.token) {
-        localStorage.setItem('token', response.data.token)
-        onLogin()
-        navigate('/dashboard')
+        finishLogin(response.data)
       }
     } catch (err) {
       const error = err as AxiosError<{ error: string }>
@@ -32,6 +90,88 @@ function Login({ onLogin }: LoginProps) {
     }
   }
 
+  const handleMfaSubmit = async (e: FormEvent<HTMLFormElement>) => {
+    e.preventDefault()
+    setError('')
+
+    try {
+      const response = await axios.post<LoginResponse>('/api/v1/auth/login/mfa', useRecoveryCode
+        ? { mfaToken, recoveryCode: mfaCode }
+        : { mfaToken, code: mfaCode })
+      finishLogin(response.data)
+    } catch (err) {
+      const error = err as AxiosError<{ error: string }>
+      setError(error.response?.data?.error || 'Verification failed')
+      setMfaCode('')
+      if (error.response?.status === 401 && error.response?.data?.error === 'Invalid or expired MFA token') {
+        // Challenge expired or too many attempts; start over
+        setMfaToken('')
+        setEnrollment(null)
+      }
+    }
+  }
+
+  const containerStyle = { maxWidth: '450px', margin: '100px auto', padding: '2rem', backgroundColor: '#f5f5f5', borderRadius: '8px', boxShadow: '0 2px 4px rgba(0,0,0,0.1)' }
+  const buttonStyle = { padding: '0.75rem 2rem', fontSize: '1rem', backgroundColor: '#646cff', color: 'white', border: 'none', borderRadius: '4px', cursor: 'pointer' }
+
+  if (recoveryCodes.length > 0) {
+    return (
+      <div style={containerStyle}>
+        <h2 style={{ color: '#213547', marginBottom: '1rem', textAlign: 'center' }}>Save your recovery codes</h2>
+        <p style={{ color: '#213547' }}>Each code can be used once to sign in if you lose your authenticator. They will not be shown again.</p>
+        <pre style={{ backgroundColor: 'white', padding: '1rem', borderRadius: '4px', color: '#213547' }}>{recoveryCodes.join('\n')}</pre>
+        <div style={{ marginTop: '1.5rem', textAlign: 'center' }}>
+          <button type="button" style={buttonStyle} onClick={() => { onLogin(); navigate('/dashboard') }}>
+            Continue
+          </button>
+        </div>
+      </div>
+    )
+  }
+
+  if (mfaToken) {
+    return (
+      <div style={containerStyle}>
+        <h2 style={{ color: '#213547', marginBottom: '1.5rem', textAlign: 'center' }}>Two-factor authentication</h2>
+        {enrollment && (
+          <div style={{ color: '#213547', marginBottom: '1rem' }}>
+            <p>Your role requires two-factor authentication. Add this key to your authenticator app, then enter the code it shows.</p>
+            <pre style={{ backgroundColor: 'white', padding: '0.5rem', borderRadius: '4px', wordBreak: 'break-all', whiteSpace: 'pre-wrap' }}>{enrollment.secret}</pre>
+            <a href={enrollment.otpauthUri}>Open in authenticator app</a>
+          </div>
+        )}
+        <form onSubmit={handleMfaSubmit}>
+          <input
+            type="text"
+            inputMode={useRecoveryCode ? 'text' : 'numeric'}
+            autoComplete="one-time-code"
+            placeholder={useRecoveryCode ? 'Recovery code' : '6-digit code'}
+            value={mfaCode}
+            onChange={(e) => setMfaCode(e.target.value)}
+            required
+            autoFocus
+            style={{ width: '100%', padding: '0.5rem', fontSize: '1rem', border: '1px solid #ccc', borderRadius: '4px', boxSizing: 'border-box' }}
+          />
+          {!enrollment && (
+            <button
+              type="button"
+              onClick={() => { setUseRecoveryCode(!useRecoveryCode); setMfaCode('') }}
+              style={{ marginTop: '0.5rem', background: 'none', border: 'none', color: '#646cff', cursor: 'pointer', padding: 0 }}
+            >
+              {useRecoveryCode ? 'Use authenticator code' : 'Use a recovery code'}
+            </button>
+          )}
+          {error && <div style={{ color: '#d32f2f', marginTop: '1rem', padding: '0.5rem', backgroundColor: '#ffebee', borderRadius: '4px' }}>{error}</div>}
+          <div style={{ marginTop: '1.5rem', textAlign: 'center' }}>
+            <button type="submit" style={buttonStyle}>
+              Verify
+            </button>
+          </div>
+        </form>
+      </div>
+    )
+  }
+
   return (
     <div style={{ maxWidth: '450px', margin: '100px auto', padding: '2rem', backgroundColor: '#f5f5f5', borderRadius: '8px', boxShadow: '0 2px 4px rgba(0,0,0,0.1)' }}>
       <h2 style={{ color: '#213547', marginBottom: '1.5rem', textAlign: 'center' }}>Manifimind CRM - Login</h2>
@@ -92,6 +232,13 @@ function Login({ onLogin }: LoginProps) {
           </button>
         </div>
       </form>
+      {ssoEnabled && (
+        <div style={{ marginTop: '1rem', textAlign: 'center' }}>
+          <a href="/api/v1/auth/oidc/login" style={{ color: '#646cff' }}>
+            Sign in with single sign-on
+          </a>
+        </div>
+      )}
     </div>
   )
 }
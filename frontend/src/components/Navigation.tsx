@@ -1,5 +1,6 @@
 import { useState, useEffect, useRef } from 'react'
 import { Link, useNavigate, useLocation } from 'react-router-dom'
+import api from '../utils/api'
 
 function Navigation() {
   const navigate = useNavigate()
@@ -7,8 +8,16 @@ function Navigation() {
   const [openDropdown, setOpenDropdown] = useState<string | null>(null)
   const navRef = useRef<HTMLElement>(null)
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    try {
+      // Revoke the session server-side so the tokens cannot be reused
+      await api.post('/auth/logout', { refreshToken: localStorage.getItem('refreshToken') })
+    } catch (err) {
+      console.error('Logout error:', err)
+    }
     localStorage.removeItem('token')
+    localStorage.removeItem('refreshToken')
+    localStorage.removeItem('organizationId')
     navigate('/login')
     window.location.reload()
   }
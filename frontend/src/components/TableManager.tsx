@@ -206,9 +206,13 @@ function TableManager({ title, apiEndpoint, columns, idField, hardDeleteEndpoint
 
     try {
       const ids = Array.from(selectedRows).map(id => typeof id === 'string' ? parseInt(id, 10) : id)
-      await api.post(`/${hardDeleteEndpoint}`, { ids })
+      const response = await api.post(`/${hardDeleteEndpoint}`, { ids })
       setSelectedRows(new Set())
       setHardDeleteModal({ isOpen: false })
+      const skipped: number[] = response.data?.skipped || []
+      if (skipped.length > 0) {
+        setError(`${skipped.length} record(s) were not deleted because you do not have access to them`)
+      }
       fetchRecords()
     } catch (err) {
       const error = err as AxiosError<{ error: string }>
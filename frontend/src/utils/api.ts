@@ -12,6 +12,11 @@ api.interceptors.request.use(
     if (token) {
       config.headers.Authorization = `Bearer ${token}`
     }
+    // Work in the selected organization; without it the API uses the personal one
+    const organizationId = localStorage.getItem('organizationId')
+    if (organizationId) {
+      config.headers['X-Organization-ID'] = organizationId
+    }
     return config
   },
   (error) => {
@@ -19,17 +24,52 @@ api.interceptors.request.use(
   }
 )
 
-// Response interceptor - handle 401 errors
+// Clear tokens and redirect to login
+function redirectToLogin() {
+  localStorage.removeItem('token')
+  localStorage.removeItem('refreshToken')
+  localStorage.removeItem('organizationId')
+  // Only redirect if not already on login page
+  if (!window.location.pathname.includes('/login')) {
+    window.location.href = '/login'
+  }
+}
+
+// Exchange the stored refresh token for a new access token.
+// Concurrent callers share one request, since each refresh token can only be used once.
+let refreshPromise: Promise<boolean> | null = null
+export function refreshAccessToken(): Promise<boolean> {
+  const refreshToken = localStorage.getItem('refreshToken')
+  if (!refreshToken) {
+    return Promise.resolve(false)
+  }
+  if (!refreshPromise) {
+    refreshPromise = axios
+      .post<{ token: string; refreshToken: string }>('/api/v1/auth/refresh', { refreshToken })
+      .then((response) => {
+        localStorage.setItem('token', response.data.token)
+        localStorage.setItem('refreshToken', response.data.refreshToken)
+        return true
+      })
+      .catch(() => false)
+      .finally(() => {
+        refreshPromise = null
+      })
+  }
+  return refreshPromise
+}
+
+// Response interceptor - refresh once on 401, otherwise send the user to login
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
-      // Clear token and redirect to login
-      localStorage.removeItem('token')
-      // Only redirect if not already on login page
-      if (!window.location.pathname.includes('/login')) {
-        window.location.href = '/login'
+  async (error) => {
+    const original = error.config
+    if (error.response?.status === 401 && original && !original._retried) {
+      original._retried = true
+      if (await refreshAccessToken()) {
+        return api(original)
       }
+      redirectToLogin()
     }
     return Promise.reject(error)
   }
@@ -44,16 +84,21 @@ export async function apiFetch(url: string, options: RequestInit = {}): Promise<
     headers.set('Authorization', `Bearer ${token}`)
   }
 
-  const response = await fetch(url, {
+  let response = await fetch(url, {
     ...options,
     headers
   })
 
+  if (response.status === 401 && await refreshAccessToken()) {
+    headers.set('Authorization', `Bearer ${localStorage.getItem('token')}`)
+    response = await fetch(url, {
+      ...options,
+      headers
+    })
+  }
+
   if (response.status === 401) {
-    localStorage.removeItem('token')
-    if (!window.location.pathname.includes('/login')) {
-      window.location.href = '/login'
-    }
+    redirectToLogin()
     throw new Error('Unauthorized')
   }
 